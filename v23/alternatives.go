@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/textproc"
 	"strings"
 )
@@ -78,7 +79,12 @@ func (ck *AlternativesChecker) checkAlternativeAbs(alternative string, line *Lin
 	fix.Explain(
 		"It usually starts with @PREFIX@/... to refer to a path inside the installation prefix.")
 	if lex.TestByteSet(textproc.Alnum) {
-		fix.ReplaceAfter(space, alternative, "@PREFIX@/"+alternative)
+		f, _ := line.Tree()
+		if found := f.Find(f.Lines()[0], space+alternative); found != nil {
+			ed := ast.NewFileEditor(f)
+			ed.InsertAfter(f.Slice(found, 0, len(space)), ast.NewLiteral("@PREFIX@/"))
+			fix.Edit(ed)
+		}
 	}
 	fix.Apply()
 }
@@ -81,8 +81,8 @@ func (s *Suite) Test_AlternativesChecker_Check__PLIST(c *check.C) {
 	G.Check(".")
 
 	t.CheckOutputLines(
-		"AUTOFIX: ALTERNATIVES:3: Replacing \"bin/gnu-echo\" with \"@PREFIX@/bin/gnu-echo\".",
-		"AUTOFIX: ALTERNATIVES:4: Replacing \"bin/vim\" with \"@PREFIX@/bin/vim\".")
+		"AUTOFIX: ALTERNATIVES:3: Inserting \"@PREFIX@/\".",
+		"AUTOFIX: ALTERNATIVES:4: Inserting \"@PREFIX@/\".")
 }
 
 func (s *Suite) Test_AlternativesChecker_checkLine(c *check.C) {
@@ -0,0 +1,328 @@
+package pkglint
+
+import (
+	"errors"
+	"strings"
+)
+
+// fileDiff is the part of a unified diff that applies to a single file.
+type fileDiff struct {
+	oldName string // Empty for newly created files.
+	newName string // Empty for removed files.
+	hunks   []*diffHunk
+}
+
+// diffHunk is a single "@@ -1,3 +1,4 @@" section of a unified diff.
+type diffHunk struct {
+	oldStart int
+	oldLines []string
+	newLines []string
+
+	oldNoNewline bool // Whether oldLines has no newline at the end of the file.
+	newNoNewline bool // Whether newLines has no newline at the end of the file.
+}
+
+// parseUnifiedDiff splits a unified diff, as produced by "diff -u" or
+// "git diff", into the changes for the individual files.
+//
+// Any text outside the file diffs is ignored, such as an email text
+// or the "diff --git" and "index" lines.
+func parseUnifiedDiff(text string) ([]*fileDiff, error) {
+	lines := strings.SplitAfter(text, "\n")
+	var diffs []*fileDiff
+
+	for i := 0; i+1 < len(lines); i++ {
+		if !hasPrefix(lines[i], "--- ") || !hasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+
+		fd := fileDiff{diffFileName(lines[i][4:]), diffFileName(lines[i+1][4:]), nil}
+		i += 2
+		for i < len(lines) && hasPrefix(lines[i], "@@ ") {
+			hunk, n, err := parseDiffHunk(lines[i:])
+			if err != nil {
+				return nil, err
+			}
+			fd.hunks = append(fd.hunks, hunk)
+			i += n
+		}
+		i--
+
+		if fd.oldName == "" && fd.newName == "" {
+			return nil, errors.New("file diff without file name")
+		}
+		diffs = append(diffs, &fd)
+	}
+
+	if len(diffs) == 0 {
+		return nil, errors.New("no file diffs found")
+	}
+	return diffs, nil
+}
+
+// diffFileName extracts the file name from a "---" or "+++" line.
+// It returns an empty string for /dev/null.
+func diffFileName(text string) string {
+	name := strings.TrimRight(text, "\r\n")
+	if tab := strings.IndexByte(name, '\t'); tab >= 0 {
+		name = name[:tab]
+	}
+	return condStr(name == "/dev/null", "", name)
+}
+
+// parseDiffHunk parses a single hunk and returns the number of lines
+// that it consumed.
+func parseDiffHunk(lines []string) (*diffHunk, int, error) {
+	header := strings.TrimRight(lines[0], "\r\n")
+	m := match(header, `^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+	if m == nil {
+		return nil, 0, errors.New("malformed hunk header: " + header)
+	}
+
+	hunk := diffHunk{oldStart: toInt(m[1], 0)}
+	oldCount := toInt(m[2], 1)
+	newCount := toInt(m[4], 1)
+	if m[2] != "" && oldCount == 0 {
+		// For pure insertions, the line number refers to the line
+		// after which the new lines are inserted.
+		hunk.oldStart++
+	}
+
+	i := 1
+	var lastSide byte
+	for i < len(lines) && lines[i] != "" &&
+		(len(hunk.oldLines) < oldCount || len(hunk.newLines) < newCount || hasPrefix(lines[i], "\\")) {
+
+		line := lines[i]
+		text := strings.TrimRight(line, "\r\n")
+		i++
+
+		switch {
+		case text == "":
+			// Some mail programs remove the trailing space from
+			// empty context lines.
+			hunk.oldLines = append(hunk.oldLines, "")
+			hunk.newLines = append(hunk.newLines, "")
+			lastSide = ' '
+		case text[0] == ' ':
+			hunk.oldLines = append(hunk.oldLines, text[1:])
+			hunk.newLines = append(hunk.newLines, text[1:])
+			lastSide = ' '
+		case text[0] == '-':
+			hunk.oldLines = append(hunk.oldLines, text[1:])
+			lastSide = '-'
+		case text[0] == '+':
+			hunk.newLines = append(hunk.newLines, text[1:])
+			lastSide = '+'
+		case text[0] == '\\':
+			// "\ No newline at end of file"
+			hunk.oldNoNewline = hunk.oldNoNewline || lastSide != '+'
+			hunk.newNoNewline = hunk.newNoNewline || lastSide != '-'
+		default:
+			return nil, 0, errors.New("malformed hunk line: " + text)
+		}
+	}
+
+	if len(hunk.oldLines) != oldCount || len(hunk.newLines) != newCount {
+		return nil, 0, errors.New("truncated hunk: " + header)
+	}
+	return &hunk, i, nil
+}
+
+// apply applies the hunks to the old content of the file and returns
+// the new content.
+//
+// Like patch(1), it tolerates hunks whose context has moved to other
+// lines, but not changed context lines.
+func (fd *fileDiff) apply(old string) (string, error) {
+	lines := strings.SplitAfter(old, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	newline := old == "" || hasSuffix(old, "\n")
+
+	var result []string
+	pos := 0    // The first line from lines that has not been copied.
+	offset := 0 // How far the hunks have moved compared to their header.
+	for _, hunk := range fd.hunks {
+		start := fd.findHunk(lines, hunk, pos, hunk.oldStart-1+offset)
+		if start < 0 {
+			return "", errors.New(sprintf("hunk at line %d does not apply", hunk.oldStart))
+		}
+		offset = start - (hunk.oldStart - 1)
+
+		result = append(result, lines[pos:start]...)
+		result = append(result, hunk.newLines...)
+		pos = start + len(hunk.oldLines)
+
+		if pos == len(lines) {
+			if hunk.newNoNewline {
+				newline = false
+			} else if hunk.oldNoNewline {
+				newline = true
+			}
+		}
+	}
+	result = append(result, lines[pos:]...)
+
+	if len(result) == 0 {
+		return "", nil
+	}
+	return strings.Join(result, "\n") + condStr(newline, "\n", ""), nil
+}
+
+// findHunk returns the index of the line where the old lines of the hunk
+// appear in the file, preferring the lines near the expected index.
+// It returns -1 if the old lines don't appear anywhere after min.
+func (*fileDiff) findHunk(lines []string, hunk *diffHunk, min, expected int) int {
+	matchesAt := func(start int) bool {
+		if start < min || start+len(hunk.oldLines) > len(lines) {
+			return false
+		}
+		for i, oldLine := range hunk.oldLines {
+			if lines[start+i] != oldLine {
+				return false
+			}
+		}
+		return true
+	}
+
+	for distance := 0; expected-distance >= min || expected+distance <= len(lines); distance++ {
+		if matchesAt(expected - distance) {
+			return expected - distance
+		}
+		if matchesAt(expected + distance) {
+			return expected + distance
+		}
+	}
+	return -1
+}
+
+// loadDiff applies the unified diff from the given file in memory,
+// on top of the pkgsrc tree from the current file system.
+//
+// It returns the patched file system and the package directories
+// that are affected by the diff.
+func loadDiff(diffFile CurrPath, pkgsrcdir CurrPath) (*OverlayFileSystem, []CurrPath, error) {
+	text, err := diffFile.ReadString()
+	if err != nil {
+		return nil, nil, err
+	}
+	diffs, err := parseUnifiedDiff(text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	patched := NewOverlayFileSystem(NewMemFileSystem(), G.FileSystem)
+	var pkgdirs []CurrPath
+	seen := make(map[CurrPath]bool)
+
+	for _, fd := range diffs {
+		name := fd.newName
+		if name == "" {
+			name = fd.oldName
+		}
+		rel := diffRelPath(name, pkgsrcdir)
+		filename := pkgsrcdir.JoinNoClean(rel).String()
+
+		old := ""
+		if fd.oldName != "" {
+			data, err := patched.ReadFile(filename)
+			if err != nil {
+				return nil, nil, err
+			}
+			old = string(data)
+		}
+
+		content, err := fd.apply(old)
+		if err != nil {
+			return nil, nil, errors.New(rel.String() + ": " + err.Error())
+		}
+
+		if fd.newName == "" {
+			patched.Remove(filename)
+		} else if err := patched.upper.WriteFile(filename, []byte(content), 0644); err != nil {
+			return nil, nil, err
+		}
+
+		parts := rel.Parts()
+		if len(parts) >= 3 && parts[0] != "mk" {
+			pkgdir := pkgsrcdir.JoinNoClean(NewRelPathString(parts[0] + "/" + parts[1]))
+			if !seen[pkgdir] {
+				seen[pkgdir] = true
+				pkgdirs = append(pkgdirs, pkgdir)
+			}
+		}
+	}
+
+	// Packages that are removed completely cannot be checked.
+	// Their directories may still exist, for example because of
+	// the CVS directory, therefore the Makefile decides.
+	var existing []CurrPath
+	for _, pkgdir := range pkgdirs {
+		if _, err := patched.Stat(pkgdir.JoinNoClean("Makefile").String()); err == nil {
+			existing = append(existing, pkgdir)
+		}
+	}
+	return patched, existing, nil
+}
+
+// diffRelPath determines the path of a file from a unified diff,
+// relative to the pkgsrc root directory.
+//
+// Diffs from "git diff" have the prefixes "a/" and "b/", diffs from
+// "diff -ru pkgsrc.orig pkgsrc" have the top directory as prefix.
+// To handle all these cases, leading components are stripped until
+// the first component exists in the pkgsrc root directory.
+func diffRelPath(name string, pkgsrcdir CurrPath) RelPath {
+	cleaned := NewPath(strings.TrimLeft(name, "/")).Clean().String()
+	parts := strings.Split(cleaned, "/")
+	for strip := 0; strip < len(parts); strip++ {
+		if pkgsrcdir.JoinNoClean(NewRelPathString(parts[strip])).Exists() {
+			return NewRelPathString(strings.Join(parts[strip:], "/"))
+		}
+	}
+	return NewRelPathString(cleaned)
+}
+
+// DiagBaseline remembers the diagnostics from checking the unpatched
+// tree, so that checking the patched tree only reports the diagnostics
+// that are new.
+//
+// The diagnostics are compared by file and message, ignoring the line
+// numbers, since the diff may move the lines around.
+type DiagBaseline struct {
+	recording bool
+	counts    map[string]int
+}
+
+func NewDiagBaseline() *DiagBaseline {
+	return &DiagBaseline{true, make(map[string]int)}
+}
+
+// IsNew records the diagnostic while checking the unpatched tree, or
+// returns whether the diagnostic is new while checking the patched tree.
+func (b *DiagBaseline) IsNew(filename CurrPath, msg string) bool {
+	key := b.key(filename, msg)
+	if b.recording {
+		b.counts[key]++
+		return false
+	}
+	if b.counts[key] > 0 {
+		b.counts[key]--
+		return false
+	}
+	return true
+}
+
+// Finish ends recording the diagnostics of the unpatched tree.
+func (b *DiagBaseline) Finish() { b.recording = false }
+
+func (*DiagBaseline) key(filename CurrPath, msg string) string {
+	// Line numbers in the messages may change as well.
+	normalized := replaceAll(msg, `\b((?:[Ll]ines?|:) ?)\d+(?:[-–]\d+)?`, "${1}#")
+	return filename.Clean().String() + "\x00" + normalized
+}
@@ -0,0 +1,361 @@
+package pkglint
+
+import (
+	"gopkg.in/check.v1"
+	"strings"
+)
+
+func (s *Suite) Test_parseUnifiedDiff(c *check.C) {
+	t := s.Init(c)
+
+	diffs, err := parseUnifiedDiff(strings.Join([]string{
+		"diff --git a/category/package/Makefile b/category/package/Makefile",
+		"index 1234567..89abcde 100644",
+		"--- a/category/package/Makefile",
+		"+++ b/category/package/Makefile",
+		"@@ -1 +1 @@",
+		"--- old",
+		"+++ new",
+		"--- /dev/null\t2025-01-01 00:00:00",
+		"+++ category/package/DESCR\t2025-01-01 00:00:00",
+		"@@ -0,0 +1 @@",
+		"+Description",
+		""}, "\n"))
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(diffs), 2)
+	t.CheckEquals(diffs[0].oldName, "a/category/package/Makefile")
+	t.CheckEquals(diffs[0].newName, "b/category/package/Makefile")
+	t.CheckDeepEquals(diffs[0].hunks[0].oldLines, []string{"-- old"})
+	t.CheckDeepEquals(diffs[0].hunks[0].newLines, []string{"++ new"})
+	t.CheckEquals(diffs[1].oldName, "")
+	t.CheckEquals(diffs[1].newName, "category/package/DESCR")
+	t.CheckDeepEquals(diffs[1].hunks[0].newLines, []string{"Description"})
+}
+
+func (s *Suite) Test_parseUnifiedDiff__errors(c *check.C) {
+	t := s.Init(c)
+
+	test := func(expected string, lines ...string) {
+		_, err := parseUnifiedDiff(strings.Join(lines, "\n") + "\n")
+		t.CheckEquals(err.Error(), expected)
+	}
+
+	test("no file diffs found",
+		"Just some text.")
+	test("file diff without file name",
+		"--- /dev/null",
+		"+++ /dev/null")
+	test("malformed hunk header: @@ -a +b @@",
+		"--- file",
+		"+++ file",
+		"@@ -a +b @@")
+}
+
+func (s *Suite) Test_diffFileName(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(diffFileName("category/package/Makefile\n"), "category/package/Makefile")
+	t.CheckEquals(diffFileName("Makefile\t2025-01-01 00:00:00.000 +0100\n"), "Makefile")
+	t.CheckEquals(diffFileName("Makefile\r\n"), "Makefile")
+	t.CheckEquals(diffFileName("/dev/null\n"), "")
+}
+
+func (s *Suite) Test_parseDiffHunk(c *check.C) {
+	t := s.Init(c)
+
+	hunk, n, err := parseDiffHunk([]string{
+		"@@ -3,3 +3,3 @@ section\n",
+		" context\n",
+		"-old\n",
+		"+new\n",
+		"\n",
+		"--- next file\n"})
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(n, 5)
+	t.CheckEquals(hunk.oldStart, 3)
+	t.CheckDeepEquals(hunk.oldLines, []string{"context", "old", ""})
+	t.CheckDeepEquals(hunk.newLines, []string{"context", "new", ""})
+}
+
+func (s *Suite) Test_parseDiffHunk__no_newline_at_end_of_file(c *check.C) {
+	t := s.Init(c)
+
+	hunk, n, err := parseDiffHunk([]string{
+		"@@ -1 +1 @@\n",
+		"-old\n",
+		"\\ No newline at end of file\n",
+		"+new\n"})
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(n, 4)
+	t.CheckEquals(hunk.oldNoNewline, true)
+	t.CheckEquals(hunk.newNoNewline, false)
+}
+
+func (s *Suite) Test_parseDiffHunk__insertion(c *check.C) {
+	t := s.Init(c)
+
+	hunk, _, err := parseDiffHunk([]string{
+		"@@ -5,0 +6 @@\n",
+		"+inserted\n"})
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(hunk.oldStart, 6)
+	t.CheckEquals(len(hunk.oldLines), 0)
+}
+
+func (s *Suite) Test_parseDiffHunk__errors(c *check.C) {
+	t := s.Init(c)
+
+	_, _, err := parseDiffHunk([]string{
+		"@@ -1,2 +1,2 @@\n",
+		" context\n",
+		"?\n"})
+
+	t.CheckEquals(err.Error(), "malformed hunk line: ?")
+
+	_, _, err = parseDiffHunk([]string{
+		"@@ -1,2 +1,2 @@\n",
+		" context\n",
+		""})
+
+	t.CheckEquals(err.Error(), "truncated hunk: @@ -1,2 +1,2 @@")
+}
+
+func (s *Suite) Test_fileDiff_apply(c *check.C) {
+	t := s.Init(c)
+
+	test := func(old string, diff []string, expected string) {
+		diffs, err := parseUnifiedDiff(strings.Join(diff, "\n") + "\n")
+		t.AssertNil(err)
+		actual, err := diffs[0].apply(old)
+		t.CheckEquals(err, nil)
+		t.CheckEquals(actual, expected)
+	}
+
+	// Simple replacement.
+	test("1\n2\n3\n",
+		[]string{"--- f", "+++ f", "@@ -2 +2 @@", "-2", "+two"},
+		"1\ntwo\n3\n")
+
+	// The file has changed since the diff was created,
+	// therefore the hunk is found 2 lines later.
+	test("a\nb\n1\n2\n3\n",
+		[]string{"--- f", "+++ f", "@@ -1,2 +1,2 @@", " 1", "-2", "+two"},
+		"a\nb\n1\ntwo\n3\n")
+
+	// Newly created file.
+	test("",
+		[]string{"--- /dev/null", "+++ f", "@@ -0,0 +1,2 @@", "+1", "+2"},
+		"1\n2\n")
+
+	// Removed file.
+	test("1\n2\n",
+		[]string{"--- f", "+++ /dev/null", "@@ -1,2 +0,0 @@", "-1", "-2"},
+		"")
+
+	// Pure insertion after line 1.
+	test("1\n2\n",
+		[]string{"--- f", "+++ f", "@@ -1,0 +2 @@", "+inserted"},
+		"1\ninserted\n2\n")
+
+	// Adding the missing newline at the end of the file.
+	test("1\n2",
+		[]string{"--- f", "+++ f", "@@ -2 +2 @@", "-2", "\\ No newline at end of file", "+2"},
+		"1\n2\n")
+
+	// Removing the newline at the end of the file.
+	test("1\n2\n",
+		[]string{"--- f", "+++ f", "@@ -2 +2 @@", "-2", "+2", "\\ No newline at end of file"},
+		"1\n2")
+}
+
+func (s *Suite) Test_fileDiff_apply__mismatch(c *check.C) {
+	t := s.Init(c)
+
+	diffs, err := parseUnifiedDiff("--- f\n+++ f\n@@ -2 +2 @@\n-2\n+two\n")
+	t.AssertNil(err)
+
+	_, err = diffs[0].apply("1\nchanged\n3\n")
+
+	t.CheckEquals(err.Error(), "hunk at line 2 does not apply")
+}
+
+func (s *Suite) Test_fileDiff_findHunk(c *check.C) {
+	t := s.Init(c)
+
+	lines := []string{"x", "a", "x", "x", "a"}
+	hunk := diffHunk{oldLines: []string{"a"}}
+	var fd fileDiff
+
+	t.CheckEquals(fd.findHunk(lines, &hunk, 0, 0), 1)
+	t.CheckEquals(fd.findHunk(lines, &hunk, 0, 3), 4)
+	t.CheckEquals(fd.findHunk(lines, &hunk, 0, 2), 1)
+	t.CheckEquals(fd.findHunk(lines, &hunk, 2, 2), 4)
+	t.CheckEquals(fd.findHunk(lines, &diffHunk{oldLines: []string{"b"}}, 0, 2), -1)
+}
+
+func (s *Suite) Test_loadDiff(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.SetUpPackage("category/removed")
+	t.SetUpPackage("category/gone")
+	t.FinishSetUp()
+	diff := []string{
+		"--- pkgsrc.orig/category/package/DESCR",
+		"+++ pkgsrc/category/package/DESCR",
+		"@@ -1 +1 @@",
+		"-Package description",
+		"+Improved description",
+		"--- /dev/null",
+		"+++ pkgsrc/category/package/patches/patch-a",
+		"@@ -0,0 +1 @@",
+		"+patch",
+		"--- pkgsrc.orig/category/removed/DESCR",
+		"+++ /dev/null",
+		"@@ -1 +0,0 @@",
+		"-Package description",
+		"--- pkgsrc.orig/category/gone/Makefile",
+		"+++ /dev/null",
+		"@@ -1 +0,0 @@",
+		"-" + MkCvsID,
+		"--- pkgsrc.orig/mk/bsd.pkg.mk",
+		"+++ pkgsrc/mk/bsd.pkg.mk",
+		"@@ -1 +1,2 @@",
+		" # $NetBSD$",
+		"+# infrastructure",
+		"--- pkgsrc.orig/category/new/Makefile",
+		"+++ pkgsrc/category/new/Makefile",
+		"@@ -1 +1 @@",
+		"-old",
+		"+new"}
+	t.CreateFileLines("proposed.diff", diff...)
+
+	_, _, err := loadDiff(t.File("proposed.diff"), t.File("."))
+
+	t.CheckEquals(err.Error(), "open "+t.File("category/new/Makefile").String()+": no such file or directory")
+
+	t.CreateFileLines("proposed.diff", diff[:len(diff)-5]...)
+
+	patched, pkgdirs, err := loadDiff(t.File("proposed.diff"), t.File("."))
+
+	t.CheckEquals(err, nil)
+	// The package without Makefile cannot be checked anymore,
+	// even though its other files still exist.
+	t.CheckDeepEquals(pkgdirs, []CurrPath{t.File("category/package"), t.File("category/removed")})
+	descr, _ := patched.ReadFile(t.File("category/package/DESCR").String())
+	t.CheckEquals(string(descr), "Improved description\n")
+	patch, _ := patched.ReadFile(t.File("category/package/patches/patch-a").String())
+	t.CheckEquals(string(patch), "patch\n")
+	_, err = patched.Stat(t.File("category/removed/DESCR").String())
+	t.CheckEquals(err != nil, true)
+
+	// The files on disk are not modified.
+	t.CheckFileLines("category/package/DESCR",
+		"Package description")
+}
+
+func (s *Suite) Test_loadDiff__not_applicable(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+	t.CreateFileLines("proposed.diff",
+		"--- category/package/DESCR",
+		"+++ category/package/DESCR",
+		"@@ -1 +1 @@",
+		"-Other description",
+		"+Improved description")
+
+	_, _, err := loadDiff(t.File("proposed.diff"), t.File("."))
+
+	t.CheckEquals(err.Error(), "category/package/DESCR: hunk at line 1 does not apply")
+
+	_, _, err = loadDiff(t.File("nonexistent.diff"), t.File("."))
+
+	t.CheckEquals(err != nil, true)
+
+	t.CreateFileLines("empty.diff")
+
+	_, _, err = loadDiff(t.File("empty.diff"), t.File("."))
+
+	t.CheckEquals(err.Error(), "no file diffs found")
+}
+
+func (s *Suite) Test_diffRelPath(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("category/package/Makefile")
+	t.CreateFileLines("doc/CHANGES-2025")
+
+	test := func(name string, expected RelPath) {
+		t.CheckEquals(diffRelPath(name, t.File(".")), expected)
+	}
+
+	test("category/package/Makefile", "category/package/Makefile")
+	test("a/category/package/Makefile", "category/package/Makefile")
+	test("b/category/new/Makefile", "category/new/Makefile")
+	test("pkgsrc/doc/CHANGES-2025", "doc/CHANGES-2025")
+	test("/usr/pkgsrc/./category/package/Makefile", "category/package/Makefile")
+	test("unknown/file", "unknown/file")
+}
+
+func (s *Suite) Test_NewDiagBaseline(c *check.C) {
+	t := s.Init(c)
+
+	b := NewDiagBaseline()
+
+	t.CheckEquals(b.recording, true)
+	t.CheckEquals(len(b.counts), 0)
+}
+
+func (s *Suite) Test_DiagBaseline_IsNew(c *check.C) {
+	t := s.Init(c)
+
+	b := NewDiagBaseline()
+
+	t.CheckEquals(b.IsNew("Makefile", "Message."), false)
+	t.CheckEquals(b.IsNew("Makefile", "Message."), false)
+	b.Finish()
+
+	// Each diagnostic from the unpatched tree hides exactly one
+	// diagnostic from the patched tree.
+	t.CheckEquals(b.IsNew("Makefile", "Message."), false)
+	t.CheckEquals(b.IsNew("Makefile", "Message."), false)
+	t.CheckEquals(b.IsNew("Makefile", "Message."), true)
+	t.CheckEquals(b.IsNew("other.mk", "Message."), true)
+}
+
+func (s *Suite) Test_DiagBaseline_Finish(c *check.C) {
+	t := s.Init(c)
+
+	b := NewDiagBaseline()
+	b.Finish()
+
+	t.CheckEquals(b.recording, false)
+	t.CheckEquals(b.IsNew("Makefile", "Message."), true)
+}
+
+func (s *Suite) Test_DiagBaseline_key(c *check.C) {
+	t := s.Init(c)
+
+	b := NewDiagBaseline()
+
+	test := func(filename CurrPath, msg string, expected string) {
+		t.CheckEquals(b.key(filename, msg), expected)
+	}
+
+	test("dir/../Makefile", "Message.",
+		"Makefile\x00Message.")
+	test("Makefile", "Variable X is overwritten in line 12.",
+		"Makefile\x00Variable X is overwritten in line #.")
+	test("Makefile", "Same as in lines 12-15 of Makefile.common.",
+		"Makefile\x00Same as in lines # of Makefile.common.")
+	test("Makefile", "Already defined in Makefile.common:7.",
+		"Makefile\x00Already defined in Makefile.common:#.")
+	test("Makefile", "Version 1.2 is outdated.",
+		"Makefile\x00Version 1.2 is outdated.")
+}
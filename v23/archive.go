@@ -0,0 +1,174 @@
+package pkglint
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// isArchiveArg returns whether the command line argument refers to a
+// tar archive or to a tree in a git repository, instead of a plain file
+// or directory.
+func isArchiveArg(arg string) bool {
+	if hasPrefix(arg, "git:") {
+		return true
+	}
+	for _, ext := range [...]string{".tar", ".tar.gz", ".tgz", ".tar.xz", ".txz"} {
+		if hasSuffix(arg, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadArchive reads the files from a tar archive or a git tree into
+// memory, as if they were extracted into the pkgsrc root directory.
+//
+// An archive contains a subtree of pkgsrc, such as category/package/*.
+// A git tree is given as git:REV:path, where REV is any revision that
+// is understood by git and path is relative to the top of the
+// repository, which is expected to be the pkgsrc root directory.
+//
+// It returns the directory that contains all files from the archive,
+// which is the directory to be checked.
+func loadArchive(arg string, pkgsrcdir CurrPath, mem *MemFileSystem) (CurrPath, error) {
+	r, err := openArchive(arg, pkgsrcdir)
+	if err != nil {
+		return "", err
+	}
+
+	var common RelPath
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		cleaned := NewPath(path.Clean(header.Name))
+		if cleaned.IsAbs() || cleaned.HasPrefixPath("..") {
+			return "", errors.New("the archive contains the unsafe path " + header.Name)
+		}
+		name := NewRelPath(cleaned)
+
+		// Other file types, such as symlinks, are not supported
+		// in pkgsrc packages and are therefore skipped.
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return "", err
+		}
+		mode := header.FileInfo().Mode().Perm()
+		err = mem.WriteFile(pkgsrcdir.JoinNoClean(name).String(), data, mode)
+		if err != nil {
+			return "", err
+		}
+
+		common = commonDir(common, name.Dir())
+	}
+
+	if common.IsEmpty() {
+		return "", errors.New("the archive contains no files")
+	}
+	return pkgsrcdir.JoinClean(common), nil
+}
+
+// openArchive returns the uncompressed tar stream for the archive.
+func openArchive(arg string, pkgsrcdir CurrPath) (io.Reader, error) {
+	if hasPrefix(arg, "git:") {
+		rev, dir, _ := strings.Cut(arg[4:], ":")
+		dir = strings.Trim(dir, "/")
+		args := []string{"-C", pkgsrcdir.String(), "archive", "--format=tar"}
+		if dir != "" {
+			args = append(args, "--prefix="+dir+"/", rev+":"+dir)
+		} else {
+			args = append(args, rev)
+		}
+		return runFilter(nil, "git", args...)
+	}
+
+	f, err := G.FileSystem.Open(arg)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	switch {
+	case hasSuffix(arg, ".gz"), hasSuffix(arg, ".tgz"):
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		return runFilter(zr, "")
+	case hasSuffix(arg, ".xz"), hasSuffix(arg, ".txz"):
+		return runFilter(f, "xz", "-dc")
+	}
+	return runFilter(f, "")
+}
+
+// runFilter reads the complete output of the command, which gets the
+// input on its standard input. If the command is empty, the input is
+// returned unchanged.
+//
+// The output is read completely before returning, to make sure that
+// the archive file is no longer needed afterwards.
+func runFilter(input io.Reader, command string, args ...string) (io.Reader, error) {
+	if command == "" {
+		data, err := io.ReadAll(input)
+		return bytes.NewReader(data), err
+	}
+
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = input
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		msg := strings.TrimSpace(string(exitErr.Stderr))
+		return nil, &fs.PathError{Op: command, Path: strings.Join(args, " "), Err: errors.New(msg)}
+	}
+	return bytes.NewReader(out), err
+}
+
+// commonDir returns the longest directory that contains both
+// directories. An empty directory means that no directory has been
+// seen so far.
+func commonDir(a, b RelPath) RelPath {
+	if a.IsEmpty() {
+		return b
+	}
+	for a != "." && !b.HasPrefixPath(a.AsPath()) {
+		a = a.Dir()
+	}
+	return a
+}
+
+// replaceArchivePackages hides the packages from the lower file system
+// that are contained in the archives, since an archive contains the
+// complete package. Otherwise, the patches and other files that are
+// no longer part of the package would still be visible.
+func replaceArchivePackages(overlay *OverlayFileSystem, pkgsrcdir CurrPath) {
+	categories, _ := overlay.upper.ReadDir(pkgsrcdir.String())
+	for _, category := range categories {
+		if !category.IsDir() || category.Name() == "mk" {
+			continue
+		}
+		categoryDir := pkgsrcdir.JoinNoClean(NewRelPathString(category.Name()))
+		packages, _ := overlay.upper.ReadDir(categoryDir.String())
+		for _, pkg := range packages {
+			if pkg.IsDir() {
+				overlay.Replace(categoryDir.JoinNoClean(NewRelPathString(pkg.Name())).String())
+			}
+		}
+	}
+}
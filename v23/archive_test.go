@@ -0,0 +1,288 @@
+package pkglint
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"gopkg.in/check.v1"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// tarArchive returns a tar archive containing the given files,
+// which are given as pairs of name and content.
+func tarArchive(t *Tester, nameContent ...string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i+1 < len(nameContent); i += 2 {
+		name, content := nameContent[i], nameContent[i+1]
+		mode := int64(0644)
+		typeflag := byte(tar.TypeReg)
+		if hasSuffix(name, "/") {
+			mode, typeflag = 0755, tar.TypeDir
+		}
+		header := tar.Header{Name: name, Mode: mode, Size: int64(len(content)), Typeflag: typeflag}
+		t.AssertNil(tw.WriteHeader(&header))
+		_, err := tw.Write([]byte(content))
+		t.AssertNil(err)
+	}
+	t.AssertNil(tw.Close())
+	return buf.Bytes()
+}
+
+// packPackage moves the files of the package from the file system
+// into a tar archive.
+func packPackage(t *Tester, pkgpath RelPath) []byte {
+	var nameContent []string
+	for _, filename := range t.File(pkgpath).ReadPaths() {
+		content, err := filename.ReadString()
+		t.AssertNil(err)
+		nameContent = append(nameContent, pkgpath.JoinNoClean(filename.Base()).String(), content)
+	}
+	t.AssertNil(os.RemoveAll(t.File(pkgpath).String()))
+	return tarArchive(t, nameContent...)
+}
+
+func (s *Suite) Test_isArchiveArg(c *check.C) {
+	t := s.Init(c)
+
+	test := func(arg string, expected bool) {
+		t.CheckEquals(isArchiveArg(arg), expected)
+	}
+
+	test("category/package", false)
+	test("package.tar", true)
+	test("package.tar.gz", true)
+	test("package.tgz", true)
+	test("package.tar.xz", true)
+	test("package.txz", true)
+	test("package.tar.bz2", false)
+	test("git:HEAD:category/package", true)
+	test("git:HEAD", true)
+	test("category/git:HEAD", false)
+}
+
+func (s *Suite) Test_loadArchive(c *check.C) {
+	t := s.Init(c)
+
+	t.AssertNil(t.File("package.tar").WriteString(string(tarArchive(t,
+		"./category/",
+		"",
+		"./category/package/Makefile",
+		"# Makefile\n",
+		"./category/package/patches/patch-configure",
+		"# patch\n"))))
+	mem := NewMemFileSystem()
+
+	dir, err := loadArchive(t.File("package.tar").String(), "/pkgsrc", mem)
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(dir, CurrPath("/pkgsrc/category/package"))
+	data, err := mem.ReadFile("/pkgsrc/category/package/patches/patch-configure")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(string(data), "# patch\n")
+}
+
+func (s *Suite) Test_loadArchive__empty(c *check.C) {
+	t := s.Init(c)
+
+	t.AssertNil(t.File("empty.tar").WriteString(string(tarArchive(t,
+		"category/",
+		""))))
+
+	_, err := loadArchive(t.File("empty.tar").String(), "/pkgsrc", NewMemFileSystem())
+
+	t.CheckEquals(err.Error(), "the archive contains no files")
+}
+
+func (s *Suite) Test_loadArchive__unsafe_path(c *check.C) {
+	t := s.Init(c)
+
+	t.AssertNil(t.File("unsafe.tar").WriteString(string(tarArchive(t,
+		"category/../../etc/passwd",
+		"root"))))
+
+	_, err := loadArchive(t.File("unsafe.tar").String(), "/pkgsrc", NewMemFileSystem())
+
+	t.CheckEquals(err.Error(), "the archive contains the unsafe path category/../../etc/passwd")
+}
+
+func (s *Suite) Test_loadArchive__not_a_tar_file(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("package.tar",
+		"plain text")
+
+	_, err := loadArchive(t.File("package.tar").String(), "/pkgsrc", NewMemFileSystem())
+
+	t.CheckEquals(err.Error(), "unexpected EOF")
+}
+
+func (s *Suite) Test_loadArchive__git(c *check.C) {
+	t := s.Init(c)
+
+	if _, err := exec.LookPath("git"); err != nil {
+		c.Skip("git is not available")
+	}
+	t.CreateFileLines("category/package/Makefile",
+		"# committed")
+	git := func(args ...string) {
+		_, err := runFilter(nil, "git", append([]string{"-C", t.File(".").String()}, args...)...)
+		t.AssertNil(err)
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("-c", "user.name=Name", "-c", "user.email=name@example.org", "commit", "-q", "-m", "msg")
+	t.CreateFileLines("category/package/Makefile",
+		"# modified")
+
+	mem := NewMemFileSystem()
+	dir, err := loadArchive("git:HEAD:category/package", t.File("."), mem)
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(dir, t.File("category/package"))
+	data, err := mem.ReadFile(t.File("category/package/Makefile").String())
+	t.CheckEquals(err, nil)
+	t.CheckEquals(string(data), "# committed\n")
+
+}
+
+func (s *Suite) Test_openArchive(c *check.C) {
+	t := s.Init(c)
+
+	archive := tarArchive(t, "category/package/Makefile", "content\n")
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write(archive)
+	t.AssertNil(err)
+	t.AssertNil(zw.Close())
+	t.AssertNil(t.File("package.tar.gz").WriteString(gz.String()))
+
+	r, err := openArchive(t.File("package.tar.gz").String(), "/pkgsrc")
+	t.AssertNil(err)
+	data, err := io.ReadAll(r)
+
+	t.CheckEquals(err, nil)
+	t.CheckDeepEquals(data, archive)
+}
+
+func (s *Suite) Test_openArchive__xz(c *check.C) {
+	t := s.Init(c)
+
+	if _, err := exec.LookPath("xz"); err != nil {
+		c.Skip("xz is not available")
+	}
+	archive := tarArchive(t, "category/package/Makefile", "content\n")
+	xz, err := runFilter(bytes.NewReader(archive), "xz", "-c")
+	t.AssertNil(err)
+	compressed, _ := io.ReadAll(xz)
+	t.AssertNil(t.File("package.tar.xz").WriteString(string(compressed)))
+
+	r, err := openArchive(t.File("package.tar.xz").String(), "/pkgsrc")
+	t.AssertNil(err)
+	data, err := io.ReadAll(r)
+
+	t.CheckEquals(err, nil)
+	t.CheckDeepEquals(data, archive)
+}
+
+func (s *Suite) Test_openArchive__nonexistent(c *check.C) {
+	t := s.Init(c)
+
+	_, err := openArchive(t.File("nonexistent.tar").String(), "/pkgsrc")
+
+	t.CheckEquals(os.IsNotExist(err), true)
+}
+
+func (s *Suite) Test_openArchive__broken_gzip(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("package.tgz",
+		"plain text")
+
+	_, err := openArchive(t.File("package.tgz").String(), "/pkgsrc")
+
+	t.CheckEquals(err.Error(), "gzip: invalid header")
+}
+
+func (s *Suite) Test_openArchive__git_error(c *check.C) {
+	t := s.Init(c)
+
+	if _, err := exec.LookPath("git"); err != nil {
+		c.Skip("git is not available")
+	}
+	_, err := runFilter(nil, "git", "-C", t.File(".").String(), "init", "-q")
+	t.AssertNil(err)
+
+	_, err = openArchive("git:nonexistent:category/package", t.File("."))
+
+	t.CheckEquals(err.Error(), "git -C "+t.File(".").String()+" archive --format=tar "+
+		"--prefix=category/package/ nonexistent:category/package: "+
+		"fatal: not a valid object name: nonexistent:category/package")
+}
+
+func (s *Suite) Test_runFilter(c *check.C) {
+	t := s.Init(c)
+
+	r, err := runFilter(bytes.NewReader([]byte("text")), "")
+	t.AssertNil(err)
+	data, _ := io.ReadAll(r)
+
+	t.CheckEquals(string(data), "text")
+
+	_, err = runFilter(nil, "nonexistent-command")
+
+	t.CheckEquals(err.Error(), "exec: \"nonexistent-command\": executable file not found in $PATH")
+}
+
+func (s *Suite) Test_commonDir(c *check.C) {
+	t := s.Init(c)
+
+	test := func(a, b RelPath, expected RelPath) {
+		t.CheckEquals(commonDir(a, b), expected)
+	}
+
+	test("", "category/package", "category/package")
+	test("category/package", "category/package/patches", "category/package")
+	test("category/package/patches", "category/package", "category/package")
+	test("category/package", "category/other", "category")
+	test("category/package", "other/package", ".")
+	test(".", "category", ".")
+}
+
+func (s *Suite) Test_replaceArchivePackages(c *check.C) {
+	t := s.Init(c)
+
+	upper := NewMemFileSystem()
+	lower := NewMemFileSystem()
+	for _, name := range []string{
+		"/pkgsrc/category/package/Makefile",
+		"/pkgsrc/category/Makefile",
+		"/pkgsrc/mk/pkgformat/pkg.mk"} {
+		t.AssertNil(upper.WriteFile(name, nil, 0644))
+	}
+	for _, name := range []string{
+		"/pkgsrc/category/package/patches/patch-aa",
+		"/pkgsrc/category/other/Makefile",
+		"/pkgsrc/mk/pkgformat/other.mk"} {
+		t.AssertNil(lower.WriteFile(name, nil, 0644))
+	}
+	overlay := NewOverlayFileSystem(upper, lower)
+
+	replaceArchivePackages(overlay, "/pkgsrc")
+
+	test := func(name string, exists bool) {
+		_, err := overlay.Stat(name)
+		t.CheckEquals(err == nil, exists)
+	}
+
+	// The package from the archive replaces the package from the tree.
+	test("/pkgsrc/category/package/Makefile", true)
+	test("/pkgsrc/category/package/patches", false)
+
+	// Other packages and the infrastructure are merged.
+	test("/pkgsrc/category/other/Makefile", true)
+	test("/pkgsrc/mk/pkgformat/pkg.mk", true)
+	test("/pkgsrc/mk/pkgformat/other.mk", true)
+}
@@ -2,7 +2,10 @@
 // maintaining the exact relationship between the text stored in the file and
 // the application-visible value.
 //
-// It is work in progress.
+// For makefiles, every byte of the file is represented by exactly one leaf
+// node, which makes it possible to reproduce the file byte by byte, see
+// File.Text. To modify the file, collect the changes in a FileEditor, which
+// operates on the nodes instead of on the raw text.
 package ast
 
 import (
@@ -30,10 +33,62 @@ func NewFile(text string) *File {
 	return &File{text}
 }
 
+// Text returns the text of the node, exactly as it appears in the file.
+//
+// Nodes that have been created in-memory are only supported if they are
+// literals, see NewLiteral.
 func (f *File) Text(n Node) string {
+	if n.Start() == NoPos {
+		return n.(*Literal).Text
+	}
 	return f.text[n.Start()-1 : n.End()-1]
 }
 
+// Line returns the 0-based index of the physical line in which the given
+// position is located.
+func (f *File) Line(pos Pos) int {
+	return strings.Count(f.text[:pos-1], "\n")
+}
+
+// Lines returns the physical lines of the file, including their
+// trailing newlines.
+func (f *File) Lines() []*Literal {
+	var lines []*Literal
+	start := Pos(1)
+	for _, text := range strings.SplitAfter(f.text, "\n") {
+		if text != "" {
+			lines = append(lines, &Literal{start, text})
+			start = start.PlusLen(text)
+		}
+	}
+	return lines
+}
+
+// Find returns the first occurrence of the text in the given node,
+// or nil if the node doesn't contain the text.
+//
+// This allows editing parts of nodes that are not parsed any further,
+// such as the path of an '.include' directive.
+func (f *File) Find(n Node, text string) *Literal {
+	index := strings.Index(f.Text(n), text)
+	if index == -1 || n.Start() == NoPos {
+		return nil
+	}
+	return &Literal{n.Start().Plus(index), text}
+}
+
+// Slice returns the part of the node between the given byte offsets.
+//
+// This allows editing parts of nodes whose position is already known,
+// such as the whitespace before the continuation backslash.
+func (f *File) Slice(n Node, start, end int) *Literal {
+	text := f.Text(n)
+	if n.Start() == NoPos || start < 0 || start > end || end > len(text) {
+		return nil
+	}
+	return &Literal{n.Start().Plus(start), text[start:end]}
+}
+
 // A Node in an abstract syntax tree represents a structural element of the
 // file. Every single byte from the file must be represented in some node,
 // even whitespace, linebreaks and comments.
@@ -48,6 +103,10 @@ type Literal struct {
 	Text  string
 }
 
+// NewLiteral creates a literal that is not yet part of the file,
+// to be inserted by a FileEditor.
+func NewLiteral(text string) *Literal { return &Literal{NoPos, text} }
+
 func (l *Literal) Start() Pos { return l.start }
 func (l *Literal) End() Pos   { return l.start.PlusLen(l.Text) }
 
@@ -68,7 +127,7 @@ type EscapableText struct {
 }
 
 func (t *EscapableText) Start() Pos { return t.start }
-func (t *EscapableText) End() Pos   { return t.start.PlusLen(t.LogicalText) }
+func (t *EscapableText) End() Pos   { return t.end }
 
 // Space represents whitespace. In case of backslash-newline sequences, the
 // represented text does not equal the text that is stored in the file.
@@ -87,10 +146,14 @@ type MkCond interface {
 	Node
 }
 
+// MkCondBinary is a condition of the form 'left && right' or
+// 'left || right'.
 type MkCondBinary struct {
 	Left   MkCond
+	S1     Space
 	OpText *Literal
 	Op     MkCondBoolOp
+	S2     Space
 	Right  MkCond
 }
 
@@ -117,13 +180,25 @@ type MkCondNot struct {
 func (n *MkCondNot) Start() Pos { return n.Exclam.Start() }
 func (n *MkCondNot) End() Pos   { return n.X.End() }
 
+// MkCondComparison is either a comparison such as '${VAR} == value' or a
+// single operand such as 'defined(VAR)' or '${VAR:Mpattern}'.
+// In the latter case, OpText and Right are nil.
 type MkCondComparison struct {
-	// TODO: Actually model a comparison in detail.
-	Text *EscapableText
+	Left   *EscapableText
+	S1     Space
+	OpText *Literal
+	Op     MkCondCompareOp
+	S2     Space
+	Right  *EscapableText
 }
 
-func (c *MkCondComparison) Start() Pos { return c.Text.Start() }
-func (c *MkCondComparison) End() Pos   { return c.Text.End() }
+func (c *MkCondComparison) Start() Pos { return c.Left.Start() }
+func (c *MkCondComparison) End() Pos {
+	if c.Right != nil {
+		return c.Right.End()
+	}
+	return c.Left.End()
+}
 
 // MkExpr represents an expression such as '$V', '${VAR:Mpattern}' or
 // '$(PARENTHESIZED)'.
@@ -144,14 +219,10 @@ type MkExprModifier interface {
 	ModifierText()
 }
 
-type MkVarname EscapableText // TODO
-
-// Editor allows manipulating the AST in-memory.
-type Editor interface {
-	Remove(Node)
-	Replace(Node, Node)
-	InsertBefore(Node, Node)
-	InsertAfter(Node, Node)
+// MkVarname is the name of a variable, which may contain expressions, as in
+// 'PKG_OPTIONS.${pkgbase}'.
+type MkVarname struct {
+	EscapableText
 }
 
 type MkCondCompareOp uint8
@@ -177,164 +248,688 @@ const (
 
 func (op MkCondBoolOp) String() string { return [...]string{"&&", "||", "!"}[op] }
 
-type MkParser struct {
-	lex     *textproc.Lexer
-	textLen int
-}
-
-func NewMkParser(f *File) *MkParser {
-	lex := textproc.NewLexer(f.text)
-	return &MkParser{lex, len(f.text)}
+// MkLine is a logical line of a makefile, including its continuation lines
+// and the trailing newline.
+type MkLine interface {
+	Node
 }
 
-func (p *MkParser) ParseLine() MkLine {
-	dot := p.ParseLiteral(".")
-	s1 := p.ParseSpace()
-	directive := p.ParseDirective()
-	s2 := p.ParseSpace()
-	cond := p.ParseMkCond()
-	s3 := p.ParseSpace()
-	comment := p.ParseComment()
-	endOfLine := p.ParseEndOfLine()
-
-	return &MkCondLine{
-		dot,
-		s1,
-		directive,
-		s2,
-		cond,
-		s3,
-		comment,
-		endOfLine,
-	}
-}
-
-var directiveBytes = textproc.NewByteSet("a-z-")
-
-func (p *MkParser) ParseDirective() *Literal {
-	pos := p.Pos()
-	dir := p.lex.NextBytesSet(directiveBytes)
-	if dir == "" {
-		return nil
-	}
-	return &Literal{pos, dir}
+// MkCommentLine is an empty line or a line consisting only of a comment.
+// For empty lines, Comment is nil.
+type MkCommentLine struct {
+	S0        Space
+	Comment   *EscapableText
+	EndOfLine Space
 }
 
-func (p *MkParser) ParseMkCond() MkCond {
-	pos := p.Pos()
-	text := p.lex.NextBytesSet(textproc.Space.Inverse())
-	// TODO: Properly parse conditions.
-	return &MkCondComparison{
-		Text: &EscapableText{
-			start:       pos,
-			end:         p.Pos(),
-			LogicalText: text,
-		},
-	}
-}
+func (l *MkCommentLine) Start() Pos { return l.S0.Start() }
+func (l *MkCommentLine) End() Pos   { return l.EndOfLine.End() }
 
-func (p *MkParser) ParseComment() *EscapableText {
-	return nil
+// MkCondLine is a directive that has a condition, such as '.if', '.elif',
+// '.ifdef' or '.ifndef'.
+type MkCondLine struct {
+	Dot       *Literal
+	S0        Space
+	Directive *Literal
+	S1        Space
+	Cond      MkCond
+	S2        Space
+	Comment   *EscapableText
+	EndOfLine Space
 }
 
-func (p *MkParser) ParseSpace() Space {
-	lex := p.lex
-	start := p.Pos()
-	var sb strings.Builder
-again:
-	hspace := lex.NextHspace()
-	if hspace != "" {
-		sb.WriteString(hspace)
-		goto again
-	}
-	if lex.SkipString("\\\n") {
-		sb.WriteString(" ")
-		goto again
-	}
-	return Space{start, p.Pos(), sb.String()}
-}
+func (l *MkCondLine) Start() Pos { return l.Dot.Start() }
+func (l *MkCondLine) End() Pos   { return l.EndOfLine.End() }
 
-func (p *MkParser) ParseEndOfLine() Space {
-	lex := p.lex
-	start := p.Pos()
-	text := lex.NextString("\n")
-	return Space{start, p.Pos(), text}
+// MkIncludeLine is an '.include', '.sinclude' or '.-include' directive.
+type MkIncludeLine struct {
+	Dot       *Literal
+	S0        Space
+	Directive *Literal
+	S1        Space
+	Open      *Literal
+	Path      *EscapableText
+	Close     *Literal
+	S2        Space
+	Comment   *EscapableText
+	EndOfLine Space
 }
 
-func (p *MkParser) ParseLiteral(s string) *Literal {
-	lex := p.lex
-	start := p.Pos()
-	if !lex.SkipString(s) {
-		return nil
-	}
-	return &Literal{start, s}
-}
+func (l *MkIncludeLine) Start() Pos { return l.Dot.Start() }
+func (l *MkIncludeLine) End() Pos   { return l.EndOfLine.End() }
 
-func (p *MkParser) Pos() Pos {
-	return Pos(1) + Pos(p.textLen) - Pos(len(p.lex.Rest()))
+// MkDirectiveLine is any other directive, such as '.for', '.endfor',
+// '.else', '.endif', '.undef' or '.export'.
+// The arguments of the directive are not parsed any further.
+type MkDirectiveLine struct {
+	Dot       *Literal
+	S0        Space
+	Directive *Literal
+	S1        Space
+	Args      *EscapableText
+	S2        Space
+	Comment   *EscapableText
+	EndOfLine Space
 }
 
-type MkLine interface {
-	Node
-}
+func (l *MkDirectiveLine) Start() Pos { return l.Dot.Start() }
+func (l *MkDirectiveLine) End() Pos   { return l.EndOfLine.End() }
 
-type MkCondLine struct {
+// MkMessageLine is an '.info', '.warning' or '.error' directive.
+type MkMessageLine struct {
 	Dot       *Literal
 	S0        Space
 	Directive *Literal
 	S1        Space
-	Cond      MkCond
+	Message   *EscapableText
 	S2        Space
 	Comment   *EscapableText
 	EndOfLine Space
 }
 
-func (l *MkCondLine) Start() Pos { return l.Dot.Start() }
-func (l *MkCondLine) End() Pos   { return l.EndOfLine.End() }
+func (l *MkMessageLine) Start() Pos { return l.Dot.Start() }
+func (l *MkMessageLine) End() Pos   { return l.EndOfLine.End() }
 
+// MkAssignLine is a variable assignment such as 'VAR+= value # comment'.
 type MkAssignLine struct {
 	S0        Space
 	Name      *MkVarname
 	S1        Space
 	Op        *Literal
 	S2        Space
-	Value     *MkString
+	Value     *EscapableText
 	S3        Space
 	Comment   *EscapableText
 	EndOfLine Space
 }
 
 func (l *MkAssignLine) Start() Pos { return l.S0.Start() }
-func (l *MkAssignLine) End() Pos   { return l.Comment.Start() }
+func (l *MkAssignLine) End() Pos   { return l.EndOfLine.End() }
 
-type MkMessageLine struct {
-	Dot     *Literal
-	S0      Space
-	Message *EscapableText
-	S1      Space
-	Comment *EscapableText
+// MkDependencyLine declares the sources on which the targets depend,
+// such as 'pre-configure: ${WRKDIR}/stamp'.
+type MkDependencyLine struct {
+	S0        Space
+	Targets   *EscapableText
+	S1        Space
+	Op        *Literal
+	S2        Space
+	Sources   *EscapableText
+	S3        Space
+	Comment   *EscapableText
+	EndOfLine Space
 }
 
-func (l *MkMessageLine) Start() Pos { return l.S0.Start() }
-func (l *MkMessageLine) End() Pos   { return l.Comment.Start() }
+func (l *MkDependencyLine) Start() Pos { return l.S0.Start() }
+func (l *MkDependencyLine) End() Pos   { return l.EndOfLine.End() }
 
-type MkDependencyLine struct {
-	S0      Space
-	Targets []*MkWord
-	S1      Space
-	Op      *Literal
-	S2      Space
-	Sources []*MkWord
-	S3      Space
-	Comment *EscapableText
+// MkShellLine is a shell command that belongs to a target.
+// It starts with a tab, and comments are passed to the shell unmodified.
+type MkShellLine struct {
+	Tab       *Literal
+	Command   *EscapableText
+	EndOfLine Space
+}
+
+func (l *MkShellLine) Start() Pos { return l.Tab.Start() }
+func (l *MkShellLine) End() Pos   { return l.EndOfLine.End() }
+
+// MkUnknownLine is a line that cannot be parsed as any of the other line
+// types. It is kept in the tree to preserve the text of the file.
+type MkUnknownLine struct {
+	Text      *EscapableText
+	S0        Space
+	Comment   *EscapableText
+	EndOfLine Space
+}
+
+func (l *MkUnknownLine) Start() Pos { return l.Text.Start() }
+func (l *MkUnknownLine) End() Pos   { return l.EndOfLine.End() }
+
+// MkParser splits the text of a makefile into logical lines,
+// including their continuation lines, and parses each of them.
+type MkParser struct {
+	f   *File
+	pos int // The 0-based offset of the next line.
+}
+
+func NewMkParser(f *File) *MkParser {
+	return &MkParser{f, 0}
+}
+
+// EOF returns whether all lines of the file have been parsed.
+func (p *MkParser) EOF() bool { return p.pos >= len(p.f.text) }
+
+// ParseLines parses all remaining lines of the file.
+func (p *MkParser) ParseLines() []MkLine {
+	var lines []MkLine
+	for !p.EOF() {
+		lines = append(lines, p.ParseLine())
+	}
+	return lines
+}
+
+// ParseLine parses the next logical line, including its continuation lines
+// and the final newline.
+func (p *MkParser) ParseLine() MkLine {
+	text := p.f.text
+	end := p.pos
+	for end < len(text) && text[end] != '\n' {
+		end++
+		if end < len(text) && text[end] == '\n' && isContinuation(text[p.pos:end]) {
+			end++
+		}
+	}
+
+	lp := mkLineParser{text, p.pos, end, end}
+	line := lp.parse()
+
+	p.pos = end
+	if end < len(text) {
+		p.pos++
+	}
+	return line
+}
+
+// isContinuation returns whether the text ends with an odd number of
+// backslashes.
+func isContinuation(text string) bool {
+	n := 0
+	for n < len(text) && text[len(text)-1-n] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+// mkLineParser parses the main part of a single logical line,
+// that is, excluding the final newline.
+type mkLineParser struct {
+	text      string
+	pos       int // 0-based
+	end       int // 0-based, exclusive, just before the final newline
+	commentAt int // The start of the comment, or end if there is none.
+}
+
+func (p *mkLineParser) Pos() Pos { return Pos(p.pos + 1) }
+
+func (p *mkLineParser) parse() MkLine {
+	start := p.pos
+
+	if p.pos < p.end && p.text[p.pos] == '\t' && !p.isTabComment() {
+		tab := p.literal("\t")
+		command := p.escapableText(p.end, false)
+		return &MkShellLine{tab, command, p.endOfLine()}
+	}
+
+	p.commentAt = p.commentStart()
+	mainEnd := p.trailingSpaceStart(start, p.commentAt)
+
+	if p.pos < mainEnd && p.text[p.pos] == '.' {
+		return p.parseDirective(mainEnd)
+	}
+
+	if line := p.parseAssign(mainEnd); line != nil {
+		return line
+	}
+	p.pos = start
+
+	if mainEnd == start {
+		s0 := p.space(p.commentAt)
+		return &MkCommentLine{s0, p.comment(), p.endOfLine()}
+	}
+
+	s0 := p.space(mainEnd)
+	if line := p.parseDependency(s0, mainEnd); line != nil {
+		return line
+	}
+
+	p.pos = start
+	text := p.escapableText(mainEnd, true)
+	s := p.space(p.commentAt)
+	return &MkUnknownLine{text, s, p.comment(), p.endOfLine()}
+}
+
+// isTabComment returns whether the line starts with a tab, followed by
+// a comment, which pkglint treats as a comment instead of a shell command.
+func (p *mkLineParser) isTabComment() bool {
+	i := p.pos
+	for i < p.end && (p.text[i] == ' ' || p.text[i] == '\t') {
+		i++
+	}
+	return i < p.end && p.text[i] == '#'
+}
+
+func (p *mkLineParser) parseDirective(mainEnd int) MkLine {
+	dot := p.literal(".")
+	s0 := p.space(mainEnd)
+	directive := p.directive(mainEnd)
+	s1 := p.space(mainEnd)
+
+	switch directive.Text {
+	case "include", "sinclude", "-include", "dinclude":
+		if line := p.parseInclude(dot, s0, directive, s1, mainEnd); line != nil {
+			return line
+		}
+
+	case "if", "ifdef", "ifndef", "ifmake", "ifnmake",
+		"elif", "elifdef", "elifndef", "elifmake", "elifnmake":
+		cond := p.parseCond(mainEnd)
+		s2 := p.space(p.commentAt)
+		return &MkCondLine{dot, s0, directive, s1, cond, s2, p.comment(), p.endOfLine()}
+
+	case "info", "warning", "error":
+		message := p.escapableText(mainEnd, true)
+		s2 := p.space(p.commentAt)
+		return &MkMessageLine{dot, s0, directive, s1, message, s2, p.comment(), p.endOfLine()}
+	}
+
+	args := p.escapableText(mainEnd, true)
+	s2 := p.space(p.commentAt)
+	return &MkDirectiveLine{dot, s0, directive, s1, args, s2, p.comment(), p.endOfLine()}
+}
+
+func (p *mkLineParser) parseInclude(dot *Literal, s0 Space, directive *Literal, s1 Space, mainEnd int) MkLine {
+	mark := p.pos
+	if p.pos == mainEnd || (p.text[p.pos] != '"' && p.text[p.pos] != '<') {
+		return nil
+	}
+	closeStr := condStr(p.text[p.pos] == '"', "\"", ">")
+	closeIndex := strings.Index(p.text[p.pos+1:mainEnd], closeStr)
+	if closeIndex == -1 || p.pos+1+closeIndex+1 != mainEnd {
+		return nil
+	}
+
+	open := p.literal(p.text[p.pos : p.pos+1])
+	path := p.escapableText(mainEnd-1, true)
+	closeLit := p.literal(closeStr)
+	if closeLit == nil {
+		p.pos = mark
+		return nil
+	}
+	s2 := p.space(p.commentAt)
+	return &MkIncludeLine{dot, s0, directive, s1, open, path, closeLit, s2, p.comment(), p.endOfLine()}
+}
+
+var varnameEnd = textproc.NewByteSet("\t =:!+?#$")
+
+func (p *mkLineParser) parseAssign(mainEnd int) MkLine {
+	s0 := p.space(mainEnd)
+
+	nameStart := p.pos
+	for p.pos < mainEnd {
+		if p.text[p.pos] == '$' {
+			if !p.skipExpr(mainEnd) {
+				p.pos++
+			}
+			continue
+		}
+		if varnameEnd.Contains(p.text[p.pos]) {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == nameStart {
+		return nil
+	}
+	name := &MkVarname{*p.escapableTextFrom(nameStart, p.pos, true)}
+
+	s1 := p.space(mainEnd)
+	var op *Literal
+	for _, candidate := range [...]string{"::=", ":=", "+=", "?=", "!=", "="} {
+		if op = p.literal(candidate); op != nil {
+			break
+		}
+	}
+	if op == nil || p.pos > mainEnd {
+		return nil
+	}
+
+	s2 := p.space(mainEnd)
+	value := p.escapableText(mainEnd, true)
+	s3 := p.space(p.commentAt)
+	return &MkAssignLine{s0, name, s1, op, s2, value, s3, p.comment(), p.endOfLine()}
+}
+
+func (p *mkLineParser) parseDependency(s0 Space, mainEnd int) MkLine {
+	targetsStart := p.pos
+	opIndex := -1
+	for p.pos < mainEnd {
+		b := p.text[p.pos]
+		if b == '$' && p.skipExpr(mainEnd) {
+			continue
+		}
+		if b == ':' || b == '!' {
+			opIndex = p.pos
+			break
+		}
+		p.pos++
+	}
+	if opIndex == -1 {
+		return nil
+	}
+
+	targetsEnd := p.trailingSpaceStart(targetsStart, opIndex)
+	p.pos = targetsStart
+	targets := p.escapableText(targetsEnd, true)
+	s1 := p.space(opIndex)
+	op := p.literal(condStr(hasPrefix(p.text[p.pos:mainEnd], "::"), "::", p.text[p.pos:p.pos+1]))
+	s2 := p.space(mainEnd)
+	sources := p.escapableText(mainEnd, true)
+	s3 := p.space(p.commentAt)
+	return &MkDependencyLine{s0, targets, s1, op, s2, sources, s3, p.comment(), p.endOfLine()}
+}
+
+// parseCond parses the condition of an '.if' or '.elif' directive.
+// If the condition is malformed, it is returned as a single comparison
+// without an operator, to preserve the text.
+func (p *mkLineParser) parseCond(end int) MkCond {
+	start := p.pos
+	cond := p.parseCondOr(end)
+	if cond != nil && p.pos == end {
+		return cond
+	}
+	p.pos = start
+	return &MkCondComparison{Left: p.escapableText(end, true)}
+}
+
+func (p *mkLineParser) parseCondOr(end int) MkCond {
+	return p.parseCondBinary(end, "||", OR, p.parseCondAnd)
+}
+
+func (p *mkLineParser) parseCondAnd(end int) MkCond {
+	return p.parseCondBinary(end, "&&", AND, p.parseCondNot)
+}
+
+func (p *mkLineParser) parseCondBinary(end int, opText string, op MkCondBoolOp, operand func(int) MkCond) MkCond {
+	left := operand(end)
+	if left == nil {
+		return nil
+	}
+	for {
+		mark := p.pos
+		s1 := p.space(end)
+		lit := p.literal(opText)
+		if lit == nil || p.pos > end {
+			p.pos = mark
+			return left
+		}
+		s2 := p.space(end)
+		right := operand(end)
+		if right == nil {
+			return nil
+		}
+		left = &MkCondBinary{left, s1, lit, op, s2, right}
+	}
+}
+
+func (p *mkLineParser) parseCondNot(end int) MkCond {
+	if p.pos < end && p.text[p.pos] == '!' && !hasPrefix(p.text[p.pos:end], "!=") {
+		exclam := p.literal("!")
+		s := p.space(end)
+		x := p.parseCondNot(end)
+		if x == nil {
+			return nil
+		}
+		return &MkCondNot{exclam, s, x}
+	}
+	return p.parseCondAtom(end)
+}
+
+func (p *mkLineParser) parseCondAtom(end int) MkCond {
+	if p.pos < end && p.text[p.pos] == '(' {
+		open := p.literal("(")
+		s1 := p.space(end)
+		x := p.parseCondOr(end)
+		if x == nil {
+			return nil
+		}
+		s2 := p.space(end)
+		if p.pos >= end || p.text[p.pos] != ')' {
+			return nil
+		}
+		return &MkCondParen{open, s1, x, s2, p.literal(")")}
+	}
+
+	left := p.condOperand(end)
+	if left == nil {
+		return nil
+	}
+
+	mark := p.pos
+	s1 := p.space(end)
+	afterSpace := p.pos
+	// The two-character operators must be tried first.
+	candidates := [...]struct {
+		text string
+		op   MkCondCompareOp
+	}{{"<=", LE}, {">=", GE}, {"==", EQ}, {"!=", NE}, {"<", LT}, {">", GT}}
+	for _, candidate := range candidates {
+		p.pos = afterSpace
+		opText := p.literal(candidate.text)
+		if opText == nil || p.pos > end {
+			continue
+		}
+		s2 := p.space(end)
+		right := p.condOperand(end)
+		if right == nil {
+			return nil
+		}
+		return &MkCondComparison{left, s1, opText, candidate.op, s2, right}
+	}
+	p.pos = mark
+	return &MkCondComparison{Left: left}
+}
+
+var condOperandEnd = textproc.NewByteSet("\t \n\\()!&|=<>")
+
+// condOperand parses an operand of a comparison, which may be an expression,
+// a quoted string, a number or a function call like 'defined(VAR)'.
+func (p *mkLineParser) condOperand(end int) *EscapableText {
+	start := p.pos
+	for p.pos < end {
+		b := p.text[p.pos]
+		switch {
+		case b == '$':
+			if !p.skipExpr(end) {
+				p.pos++
+			}
+		case b == '"':
+			closing := strings.IndexByte(p.text[p.pos+1:end], '"')
+			if closing == -1 {
+				p.pos = start
+				return nil
+			}
+			p.pos += 1 + closing + 1
+		case b == '(' && p.pos > start:
+			closing := strings.IndexByte(p.text[p.pos:end], ')')
+			if closing == -1 {
+				p.pos = start
+				return nil
+			}
+			p.pos += closing + 1
+		case condOperandEnd.Contains(b):
+			if p.pos == start {
+				return nil
+			}
+			return p.escapableTextFrom(start, p.pos, true)
+		default:
+			p.pos++
+		}
+	}
+	if p.pos == start {
+		return nil
+	}
+	return p.escapableTextFrom(start, p.pos, true)
+}
+
+// skipExpr skips over an expression like '${VAR:Mpattern}' or '$(VAR)',
+// including nested expressions.
+func (p *mkLineParser) skipExpr(end int) bool {
+	if p.pos+1 >= end {
+		return false
+	}
+	var open, closing byte
+	switch p.text[p.pos+1] {
+	case '{':
+		open, closing = '{', '}'
+	case '(':
+		open, closing = '(', ')'
+	default:
+		p.pos += 2
+		return true
+	}
+
+	depth := 0
+	for i := p.pos + 1; i < end; i++ {
+		switch p.text[i] {
+		case open:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				p.pos = i + 1
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *mkLineParser) directive(end int) *Literal {
+	start := p.pos
+	if p.pos < end && p.text[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < end && textproc.Lower.Contains(p.text[p.pos]) {
+		p.pos++
+	}
+	return &Literal{Pos(start + 1), p.text[start:p.pos]}
+}
+
+// commentStart returns the index of the '#' that starts the comment,
+// or the end of the line if there is no comment.
+func (p *mkLineParser) commentStart() int {
+	for i := p.pos; i < p.end; i++ {
+		switch p.text[i] {
+		case '\\':
+			i++
+		case '#':
+			return i
+		}
+	}
+	return p.end
+}
+
+// trailingSpaceStart returns the index where the trailing whitespace
+// before the given end begins.
+func (p *mkLineParser) trailingSpaceStart(start, end int) int {
+	for end > start {
+		switch {
+		case p.text[end-1] == ' ' || p.text[end-1] == '\t':
+			end--
+		case p.text[end-1] == '\n' && end-2 >= start && p.text[end-2] == '\\':
+			end -= 2
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+func (p *mkLineParser) literal(s string) *Literal {
+	if !hasPrefix(p.text[p.pos:], s) {
+		return nil
+	}
+	lit := &Literal{p.Pos(), s}
+	p.pos += len(s)
+	return lit
 }
 
-type MkString struct {
-	Parts []MkStringPart
+// space parses horizontal whitespace and backslash-newline sequences.
+func (p *mkLineParser) space(end int) Space {
+	start := p.pos
+	var sb strings.Builder
+	for p.pos < end {
+		switch {
+		case p.text[p.pos] == ' ' || p.text[p.pos] == '\t':
+			sb.WriteByte(p.text[p.pos])
+			p.pos++
+		case p.pos+1 < end && p.text[p.pos] == '\\' && p.text[p.pos+1] == '\n':
+			sb.WriteString(" ")
+			p.pos += 2
+		default:
+			return Space{Pos(start + 1), p.Pos(), sb.String()}
+		}
+	}
+	return Space{Pos(start + 1), p.Pos(), sb.String()}
 }
 
-type MkStringPart interface {
+func (p *mkLineParser) escapableText(end int, unescapeHash bool) *EscapableText {
+	start := p.pos
+	if end < start {
+		end = start
+	}
+	p.pos = end
+	return p.escapableTextFrom(start, end, unescapeHash)
 }
 
-type MkWord struct {
+func (p *mkLineParser) escapableTextFrom(start, end int, unescapeHash bool) *EscapableText {
+	return &EscapableText{Pos(start + 1), Pos(end + 1), unescape(p.text[start:end], unescapeHash)}
+}
+
+func (p *mkLineParser) comment() *EscapableText {
+	if p.pos == p.end {
+		return nil
+	}
+	return p.escapableText(p.end, true)
+}
+
+func (p *mkLineParser) endOfLine() Space {
+	start := p.pos
+	if p.pos < len(p.text) && p.text[p.pos] == '\n' {
+		p.pos++
+	}
+	return Space{Pos(start + 1), p.Pos(), p.text[start:p.pos]}
+}
+
+// unescape replaces each backslash-newline sequence, including the
+// surrounding whitespace, with a single space, as bmake does.
+// If unescapeHash is true, '\#' is replaced with '#'.
+func unescape(text string, unescapeHash bool) string {
+	if !strings.Contains(text, "\\") {
+		return text
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			trimmed := strings.TrimRight(sb.String(), " \t")
+			sb.Reset()
+			sb.WriteString(trimmed)
+			sb.WriteString(" ")
+			i++
+			for i+1 < len(text) && (text[i+1] == ' ' || text[i+1] == '\t') {
+				i++
+			}
+		case text[i] == '\\' && i+1 < len(text) && text[i+1] == '#' && unescapeHash:
+			sb.WriteByte('#')
+			i++
+		case text[i] == '\\' && i+1 < len(text):
+			sb.WriteString(text[i : i+2])
+			i++
+		default:
+			sb.WriteByte(text[i])
+		}
+	}
+	return sb.String()
+}
+
+func hasPrefix(s, prefix string) bool { return strings.HasPrefix(s, prefix) }
+
+func condStr(cond bool, a, b string) string {
+	if cond {
+		return a
+	}
+	return b
 }
@@ -1,12 +1,23 @@
 package ast
 
 import (
-	"fmt"
-	"go/ast"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+func parseLines(text string) (*File, []MkLine) {
+	f := NewFile(text)
+	return f, NewMkParser(f).ParseLines()
+}
+
+func checkEquals(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
 func Test(t *testing.T) {
 	f := NewFile("" +
 		".\\\n" +
@@ -16,18 +27,259 @@ func Test(t *testing.T) {
 
 	line := par.ParseLine()
 
-	switch line := line.(type) {
-	case *MkCondLine:
-		fmt.Println("directive", line.Directive)
-	case *MkAssignLine:
-		fmt.Println("assign", line.Name, "=", line.Value)
-	default:
-		fmt.Println("other", reflect.ValueOf(line).Type().Name())
+	cond, ok := line.(*MkCondLine)
+	if !ok {
+		t.Fatalf("got %T, want *MkCondLine", line)
 	}
+	checkEquals(t, cond.Directive.Text, "if")
+	checkEquals(t, cond.S0.LogicalText, " \t")
+	checkEquals(t, f.Text(line), ".\\\n\tif ${COND}\n")
+}
 
-	ast.Print(nil, line)
+func Test_File_Text__round_trip(t *testing.T) {
+	text := "" +
+		"# $NetBSD$\n" +
+		"\n" +
+		"DISTNAME=\tpackage-1.0 # comment\n" +
+		"CONFIGURE_ARGS+=\t--prefix=${PREFIX:Q} \\\n" +
+		"\t\t\t--enable-feature \\\n" +
+		"\t\t\t--disable-other\n" +
+		".if ${OPSYS} == NetBSD && !defined(VAR) # comment \\\n" +
+		"  continued comment\n" +
+		".  include \"../../mk/bsd.prefs.mk\"\n" +
+		".elif (${A:Mpattern} || \"${B}\" != \"\")\n" +
+		".for f in a b c\n" +
+		".endfor\n" +
+		".endif\n" +
+		"pre-configure: ${WRKDIR}/stamp\n" +
+		"\t${RUN} echo '#' \\\n" +
+		"\t\tcontinued\n" +
+		"\t# comment in shell line\n" +
+		"   \n" +
+		".info message\n" +
+		"VAR=\tvalue with \\# escaped hash\n" +
+		"???\n" +
+		"VAR=\tcontinued at EOF \\\n"
+	f, lines := parseLines(text)
 
-	if want, got := ".\\\n\tif ${COND}\n", f.Text(line); got != want {
-		t.Errorf("got %q, want %q", got, want)
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(f.Text(line))
 	}
+
+	checkEquals(t, sb.String(), text)
+
+	var types []string
+	for _, line := range lines {
+		types = append(types, reflect.TypeOf(line).Elem().Name())
+	}
+	checkEquals(t, types, []string{
+		"MkCommentLine", "MkCommentLine", "MkAssignLine", "MkAssignLine",
+		"MkCondLine", "MkIncludeLine", "MkCondLine", "MkDirectiveLine",
+		"MkDirectiveLine", "MkDirectiveLine", "MkDependencyLine", "MkShellLine",
+		"MkCommentLine", "MkCommentLine", "MkMessageLine", "MkAssignLine",
+		"MkUnknownLine", "MkAssignLine"})
+}
+
+func Test_File_Text__in_memory(t *testing.T) {
+	f := NewFile("text")
+
+	checkEquals(t, f.Text(NewLiteral("in-memory")), "in-memory")
+}
+
+func Test_File_Line(t *testing.T) {
+	f := NewFile("1\n2\n3\n")
+
+	checkEquals(t, f.Line(1), 0)
+	checkEquals(t, f.Line(3), 1)
+	checkEquals(t, f.Line(6), 2)
+}
+
+func Test_File_Lines(t *testing.T) {
+	f := NewFile("1\n\\\n3")
+
+	lines := f.Lines()
+
+	checkEquals(t, len(lines), 3)
+	checkEquals(t, f.Text(lines[1]), "\\\n")
+	checkEquals(t, lines[2].Start(), Pos(5))
+	checkEquals(t, len(NewFile("").Lines()), 0)
+}
+
+func Test_File_Find(t *testing.T) {
+	f, lines := parseLines(".include \"../../mk/bsd.prefs.mk\"\n")
+	include := lines[0].(*MkIncludeLine)
+
+	found := f.Find(include.Path, "bsd.prefs.mk")
+
+	checkEquals(t, found.Start(), Pos(20))
+	checkEquals(t, f.Text(found), "bsd.prefs.mk")
+	checkEquals(t, f.Find(include.Path, "include"), (*Literal)(nil))
+	checkEquals(t, f.Find(NewLiteral("in-memory"), "memory"), (*Literal)(nil))
+}
+
+func Test_File_Slice(t *testing.T) {
+	f := NewFile("1\n2345\n")
+	line := f.Lines()[1]
+
+	slice := f.Slice(line, 1, 3)
+
+	checkEquals(t, slice.Start(), Pos(4))
+	checkEquals(t, f.Text(slice), "34")
+	checkEquals(t, f.Text(f.Slice(line, 5, 5)), "")
+	checkEquals(t, f.Slice(line, 3, 7), (*Literal)(nil))
+	checkEquals(t, f.Slice(line, 2, 1), (*Literal)(nil))
+	checkEquals(t, f.Slice(NewLiteral("in-memory"), 0, 2), (*Literal)(nil))
+}
+
+func Test_MkParser_ParseLine__assignment(t *testing.T) {
+	f, lines := parseLines("CONFIGURE_ARGS+=\t--a \\\n\t--b\t# comment\n")
+
+	line := lines[0].(*MkAssignLine)
+	checkEquals(t, f.Text(line.Name), "CONFIGURE_ARGS")
+	checkEquals(t, line.Op.Text, "+=")
+	checkEquals(t, line.S2.LogicalText, "\t")
+	checkEquals(t, f.Text(line.Value), "--a \\\n\t--b")
+	checkEquals(t, line.Value.LogicalText, "--a --b")
+	checkEquals(t, line.S3.LogicalText, "\t")
+	checkEquals(t, line.Comment.LogicalText, "# comment")
+	checkEquals(t, line.EndOfLine.LogicalText, "\n")
+}
+
+func Test_MkParser_ParseLine__assignment_with_expression_in_name(t *testing.T) {
+	f, lines := parseLines("PKG_OPTIONS.${pkgbase:S,:,,}:=\n")
+
+	line := lines[0].(*MkAssignLine)
+	checkEquals(t, f.Text(line.Name), "PKG_OPTIONS.${pkgbase:S,:,,}")
+	checkEquals(t, line.Op.Text, ":=")
+	checkEquals(t, line.Value.LogicalText, "")
+	checkEquals(t, line.Comment, (*EscapableText)(nil))
+}
+
+func Test_MkParser_ParseLine__comment(t *testing.T) {
+	f, lines := parseLines("\t# comment\n\n")
+
+	comment := lines[0].(*MkCommentLine)
+	checkEquals(t, comment.S0.LogicalText, "\t")
+	checkEquals(t, f.Text(comment.Comment), "# comment")
+
+	empty := lines[1].(*MkCommentLine)
+	checkEquals(t, empty.Comment, (*EscapableText)(nil))
+	checkEquals(t, f.Text(empty), "\n")
+}
+
+func Test_MkParser_ParseLine__include(t *testing.T) {
+	f, lines := parseLines(".  sinclude <bsd.prefs.mk> # comment\n")
+
+	line := lines[0].(*MkIncludeLine)
+	checkEquals(t, line.S0.LogicalText, "  ")
+	checkEquals(t, line.Directive.Text, "sinclude")
+	checkEquals(t, line.Open.Text, "<")
+	checkEquals(t, f.Text(line.Path), "bsd.prefs.mk")
+	checkEquals(t, line.Close.Text, ">")
+	checkEquals(t, f.Text(line.Comment), "# comment")
+}
+
+func Test_MkParser_ParseLine__include_malformed(t *testing.T) {
+	f, lines := parseLines(".include \"unclosed\n")
+
+	line := lines[0].(*MkDirectiveLine)
+	checkEquals(t, f.Text(line.Args), "\"unclosed")
+}
+
+func Test_MkParser_ParseLine__directive(t *testing.T) {
+	f, lines := parseLines(".for i in 1 2 3\t# comment\n.endfor\n")
+
+	forLine := lines[0].(*MkDirectiveLine)
+	checkEquals(t, forLine.Directive.Text, "for")
+	checkEquals(t, f.Text(forLine.Args), "i in 1 2 3")
+	checkEquals(t, forLine.S2.LogicalText, "\t")
+
+	endfor := lines[1].(*MkDirectiveLine)
+	checkEquals(t, endfor.Directive.Text, "endfor")
+	checkEquals(t, f.Text(endfor.Args), "")
+}
+
+func Test_MkParser_ParseLine__message(t *testing.T) {
+	f, lines := parseLines(".warning Unknown ${VAR}\n")
+
+	line := lines[0].(*MkMessageLine)
+	checkEquals(t, line.Directive.Text, "warning")
+	checkEquals(t, f.Text(line.Message), "Unknown ${VAR}")
+}
+
+func Test_MkParser_ParseLine__dependency(t *testing.T) {
+	f, lines := parseLines("${WRKDIR}/a.o ${WRKDIR}/b.o :: ${WRKSRC}/a.c # comment\n")
+
+	line := lines[0].(*MkDependencyLine)
+	checkEquals(t, f.Text(line.Targets), "${WRKDIR}/a.o ${WRKDIR}/b.o")
+	checkEquals(t, line.S1.LogicalText, " ")
+	checkEquals(t, line.Op.Text, "::")
+	checkEquals(t, f.Text(line.Sources), "${WRKSRC}/a.c")
+	checkEquals(t, line.S3.LogicalText, " ")
+}
+
+func Test_MkParser_ParseLine__shell_command(t *testing.T) {
+	f, lines := parseLines("\techo '#' \\\n\t    continued\n")
+
+	line := lines[0].(*MkShellLine)
+	checkEquals(t, line.Tab.Text, "\t")
+	checkEquals(t, f.Text(line.Command), "echo '#' \\\n\t    continued")
+	checkEquals(t, line.Command.LogicalText, "echo '#' continued")
+}
+
+func Test_MkParser_ParseLine__unknown(t *testing.T) {
+	f, lines := parseLines("<<<>>> # comment")
+
+	line := lines[0].(*MkUnknownLine)
+	checkEquals(t, f.Text(line.Text), "<<<>>>")
+	checkEquals(t, f.Text(line.Comment), "# comment")
+	checkEquals(t, line.EndOfLine.LogicalText, "")
+}
+
+func Test_MkParser_ParseLine__condition(t *testing.T) {
+	f, lines := parseLines(".if !defined(A) && (${B} == \"b\" || ${C:Mc}) # comment\n")
+
+	line := lines[0].(*MkCondLine)
+	and := line.Cond.(*MkCondBinary)
+	checkEquals(t, and.Op, AND)
+	checkEquals(t, f.Text(and.Left), "!defined(A)")
+
+	not := and.Left.(*MkCondNot)
+	checkEquals(t, f.Text(not.X), "defined(A)")
+
+	paren := and.Right.(*MkCondParen)
+	or := paren.X.(*MkCondBinary)
+	checkEquals(t, or.Op, OR)
+
+	cmp := or.Left.(*MkCondComparison)
+	checkEquals(t, f.Text(cmp.Left), "${B}")
+	checkEquals(t, cmp.Op, EQ)
+	checkEquals(t, f.Text(cmp.Right), "\"b\"")
+
+	checkEquals(t, f.Text(or.Right), "${C:Mc}")
+	checkEquals(t, f.Text(line.Cond), "!defined(A) && (${B} == \"b\" || ${C:Mc})")
+}
+
+func Test_MkParser_ParseLine__malformed_condition(t *testing.T) {
+	f, lines := parseLines(".if ${A} == &&\n")
+
+	line := lines[0].(*MkCondLine)
+	cmp := line.Cond.(*MkCondComparison)
+	checkEquals(t, f.Text(cmp.Left), "${A} == &&")
+	checkEquals(t, cmp.OpText, (*Literal)(nil))
+}
+
+func Test_unescape(t *testing.T) {
+	test := func(text string, unescapeHash bool, want string) {
+		t.Helper()
+		checkEquals(t, unescape(text, unescapeHash), want)
+	}
+
+	test("plain", true, "plain")
+	test("a \\\n\t\tb", true, "a b")
+	test("a\\\nb", true, "a b")
+	test("\\#", true, "#")
+	test("\\#", false, "\\#")
+	test("\\\\", true, "\\\\")
 }
@@ -0,0 +1,100 @@
+package ast
+
+import (
+	"sort"
+	"strings"
+)
+
+// Editor allows manipulating the AST in-memory.
+type Editor interface {
+	Remove(Node)
+	Replace(Node, Node)
+	InsertBefore(Node, Node)
+	InsertAfter(Node, Node)
+}
+
+// FileEditor collects modifications to the nodes of a file and renders the
+// modified text. The nodes themselves are not modified.
+//
+// The nodes that are inserted or used as replacement either come from the
+// same file, which allows moving parts of the file around, or they are
+// created in-memory using NewLiteral.
+type FileEditor struct {
+	f     *File
+	edits []Edit
+}
+
+var _ Editor = (*FileEditor)(nil)
+
+// Edit describes a single modification of the text of a file.
+// The text between Start and End is replaced with the new text.
+// For insertions, Start equals End and Old is empty.
+type Edit struct {
+	Start Pos
+	End   Pos
+	Old   string
+	New   string
+}
+
+func NewFileEditor(f *File) *FileEditor { return &FileEditor{f, nil} }
+
+// File returns the file whose nodes are edited.
+func (e *FileEditor) File() *File { return e.f }
+
+func (e *FileEditor) Remove(n Node) {
+	e.add(n.Start(), n.End(), "")
+}
+
+func (e *FileEditor) Replace(old Node, n Node) {
+	e.add(old.Start(), old.End(), e.f.Text(n))
+}
+
+func (e *FileEditor) InsertBefore(at Node, n Node) {
+	e.add(at.Start(), at.Start(), e.f.Text(n))
+}
+
+func (e *FileEditor) InsertAfter(at Node, n Node) {
+	e.add(at.End(), at.End(), e.f.Text(n))
+}
+
+func (e *FileEditor) add(start, end Pos, text string) {
+	if start == NoPos {
+		panic("ast.FileEditor: the edited node must be part of the file")
+	}
+	old := e.f.text[start-1 : end-1]
+	if old == text {
+		return
+	}
+	for _, other := range e.edits {
+		if start < other.End && other.Start < end {
+			panic("ast.FileEditor: overlapping edits")
+		}
+	}
+	e.edits = append(e.edits, Edit{start, end, old, text})
+}
+
+// Edits returns the modifications, sorted by their position in the file.
+// Insertions at the same position keep the order in which they were added.
+func (e *FileEditor) Edits() []Edit {
+	edits := append([]Edit(nil), e.edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Start != edits[j].Start {
+			return edits[i].Start < edits[j].Start
+		}
+		return edits[i].End < edits[j].End
+	})
+	return edits
+}
+
+// Text returns the text of the file, after applying all modifications.
+func (e *FileEditor) Text() string {
+	var sb strings.Builder
+	pos := Pos(1)
+	for _, edit := range e.Edits() {
+		sb.WriteString(e.f.text[pos-1 : edit.Start-1])
+		sb.WriteString(edit.New)
+		pos = edit.End
+	}
+	sb.WriteString(e.f.text[pos-1:])
+	return sb.String()
+}
@@ -0,0 +1,109 @@
+package ast
+
+import "testing"
+
+func Test_FileEditor_Replace(t *testing.T) {
+	f, lines := parseLines(".include \"../../devel/lib/builtin.mk\"\n")
+	ed := NewFileEditor(f)
+
+	include := lines[0].(*MkIncludeLine)
+	ed.Replace(include.Path, NewLiteral("../../devel/lib/buildlink3.mk"))
+
+	checkEquals(t, ed.Text(), ".include \"../../devel/lib/buildlink3.mk\"\n")
+	checkEquals(t, ed.Edits(), []Edit{{11, 37, "../../devel/lib/builtin.mk", "../../devel/lib/buildlink3.mk"}})
+}
+
+func Test_FileEditor_Replace__unchanged(t *testing.T) {
+	f, lines := parseLines("VAR=\tvalue\n")
+	ed := NewFileEditor(f)
+
+	ed.Replace(lines[0].(*MkAssignLine).Value, NewLiteral("value"))
+
+	checkEquals(t, len(ed.Edits()), 0)
+}
+
+func Test_FileEditor_Replace__overlapping(t *testing.T) {
+	f, lines := parseLines("VAR=\tvalue\n")
+	ed := NewFileEditor(f)
+	line := lines[0].(*MkAssignLine)
+	ed.Replace(line.Value, NewLiteral("other"))
+
+	defer func() {
+		checkEquals(t, recover(), "ast.FileEditor: overlapping edits")
+	}()
+	ed.Replace(line, NewLiteral("replaced\n"))
+}
+
+func Test_FileEditor_File(t *testing.T) {
+	f, _ := parseLines("VAR=\tvalue\n")
+
+	checkEquals(t, NewFileEditor(f).File(), f)
+}
+
+func Test_FileEditor_Remove(t *testing.T) {
+	f, lines := parseLines("A=\t1\nB=\t2\nC=\t3\n")
+	ed := NewFileEditor(f)
+
+	ed.Remove(lines[1])
+
+	checkEquals(t, ed.Text(), "A=\t1\nC=\t3\n")
+}
+
+func Test_FileEditor_InsertBefore(t *testing.T) {
+	f, lines := parseLines("A=\t1\nB=\t2\n")
+	ed := NewFileEditor(f)
+
+	ed.InsertBefore(lines[1], NewLiteral("# first\n"))
+	ed.InsertBefore(lines[1], NewLiteral("# second\n"))
+
+	checkEquals(t, ed.Text(), "A=\t1\n# first\n# second\nB=\t2\n")
+}
+
+func Test_FileEditor_InsertAfter(t *testing.T) {
+	f, lines := parseLines("A=\t1\nB=\t2\n")
+	ed := NewFileEditor(f)
+
+	ed.InsertAfter(lines[1], NewLiteral("C=\t3\n"))
+
+	checkEquals(t, ed.Text(), "A=\t1\nB=\t2\nC=\t3\n")
+}
+
+// Moving a block of lines is done by inserting the lines from the same
+// file at the new position and removing them at the old position.
+func Test_FileEditor__move_lines(t *testing.T) {
+	f, lines := parseLines("" +
+		"COMMENT=\tComment\n" +
+		"LICENSE=\tmit \\\n" +
+		"\t\t# continued\n" +
+		"\n" +
+		"MAINTAINER=\tpkgsrc-users@NetBSD.org\n")
+	ed := NewFileEditor(f)
+
+	ed.InsertBefore(lines[0], lines[3])
+	ed.Remove(lines[3])
+
+	checkEquals(t, ed.Text(), ""+
+		"MAINTAINER=\tpkgsrc-users@NetBSD.org\n"+
+		"COMMENT=\tComment\n"+
+		"LICENSE=\tmit \\\n"+
+		"\t\t# continued\n"+
+		"\n")
+}
+
+func Test_FileEditor_Edits(t *testing.T) {
+	f, lines := parseLines("A=\t1\nB=\t2\n")
+	ed := NewFileEditor(f)
+
+	ed.Replace(lines[1].(*MkAssignLine).Value, NewLiteral("two"))
+	ed.Replace(lines[0].(*MkAssignLine).Value, NewLiteral("one"))
+
+	checkEquals(t, ed.Edits(), []Edit{
+		{4, 5, "1", "one"},
+		{9, 10, "2", "two"}})
+}
+
+func Test_FileEditor_Text(t *testing.T) {
+	f, _ := parseLines("unchanged\n")
+
+	checkEquals(t, NewFileEditor(f).Text(), "unchanged\n")
+}
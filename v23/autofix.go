@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"os"
 	"strconv"
 	"strings"
@@ -125,6 +126,9 @@ func (fix *Autofix) Explain(explanation ...string) {
 // Replace replaces "from" with "to", a single time.
 // If the text is not found exactly once, nothing is replaced at all.
 // The diagnostic is given nevertheless, to allow humans to fix it.
+//
+// Since the text may also occur in unrelated parts of the line,
+// the checks use ReplaceInTree or Edit instead.
 func (fix *Autofix) Replace(from string, to string) {
 	fix.ReplaceAfter("", from, to)
 }
@@ -174,6 +178,10 @@ func (fix *Autofix) ReplaceAfter(prefix, from string, to string) {
 
 // ReplaceAt replaces the text "from" with "to", a single time.
 // If the text at the given position does not match, ReplaceAt panics.
+//
+// Contrary to Edit, the text is replaced even if pkglint only reports
+// the diagnostics, since the alignment of variable values computes the
+// positions of its later edits from the already aligned text.
 func (fix *Autofix) ReplaceAt(rawIndex int, textIndex int, from string, to string) {
 	assert(from != to)
 	fix.assertRealLine()
@@ -182,24 +190,156 @@ func (fix *Autofix) ReplaceAt(rawIndex int, textIndex int, from string, to strin
 		return
 	}
 
-	text := fix.texts[rawIndex]
-	assert(textIndex < len(text))
-	assert(hasPrefix(text[textIndex:], from))
+	f, _ := fix.line.Tree()
+	old := f.Slice(f.Lines()[rawIndex], textIndex, textIndex+len(from))
+	assert(old != nil && old.Text == from)
 
-	replaced := text[:textIndex] + to + text[textIndex+len(from):]
+	ed := ast.NewFileEditor(f)
+	ed.Replace(old, ast.NewLiteral(to))
+	fix.setTexts(ed.Text())
 
-	fix.texts[rawIndex] = replaced
+	fix.Describef(rawIndex, "Replacing %q with %q.", from, to)
+}
+
+// Edit applies the modifications that have been collected by the editor.
+// The editor must operate on the file from Line.Tree.
+//
+// Contrary to Replace, the modifications refer to the nodes of the syntax
+// tree, therefore it doesn't matter how often the replaced text occurs
+// in the line.
+func (fix *Autofix) Edit(ed *ast.FileEditor) {
+	fix.assertRealLine()
+	if fix.skip() {
+		return
+	}
 
-	// Fix the parsed text as well.
-	// This is only approximate and won't work in some edge cases
-	// that involve escaped comments or replacements across line breaks.
-	//
-	// TODO: Do this properly by parsing the whole line again,
-	//  and ideally everything that depends on the parsed line.
-	//  This probably requires a generic notification mechanism.
-	_, fix.line.Text = replaceOnce(fix.line.Text, from, to)
+	edits := ed.Edits()
+	if len(edits) == 0 {
+		return
+	}
 
-	fix.Describef(rawIndex, "Replacing %q with %q.", from, to)
+	if G.Logger.IsAutofix() {
+		fix.setTexts(ed.Text())
+	}
+
+	for _, edit := range edits {
+		rawIndex := ed.File().Line(edit.Start)
+		switch {
+		case edit.Old == "":
+			fix.Describef(rawIndex, "Inserting %q.", edit.New)
+		case edit.New == "":
+			fix.Describef(rawIndex, "Deleting %q.", edit.Old)
+		default:
+			fix.Describef(rawIndex, "Replacing %q with %q.", edit.Old, edit.New)
+		}
+	}
+}
+
+// ReplaceInTree replaces the first occurrence of "from" in the node that
+// the given function selects from the syntax tree of the line, see
+// Line.Tree. If the function returns nil, or if the node doesn't contain
+// the text, nothing is replaced.
+//
+// Contrary to Replace, the text may occur several times in the line,
+// as long as its first occurrence in the node is the one to be replaced.
+func (fix *Autofix) ReplaceInTree(part func(ast.MkLine) ast.Node, from, to string) {
+	fix.assertRealLine()
+	if fix.skip() {
+		return
+	}
+
+	f, tree := fix.line.Tree()
+	node := part(tree)
+	if node == nil {
+		return
+	}
+	if found := f.Find(node, from); found != nil {
+		ed := ast.NewFileEditor(f)
+		ed.Replace(found, ast.NewLiteral(to))
+		fix.Edit(ed)
+	}
+}
+
+// treeLine selects the whole line, including its comment.
+// It is used for lines that are not from makefiles, such as PLIST
+// lines, since their syntax tree is not meaningful.
+func treeLine(tree ast.MkLine) ast.Node { return tree }
+
+// treeCode selects the part of the makefile line that may contain
+// expressions. For variable assignments and dependency lines, this is
+// the whole line since both sides may contain expressions.
+func treeCode(tree ast.MkLine) ast.Node {
+	switch tree := tree.(type) {
+	case *ast.MkCondLine:
+		return tree.Cond
+	case *ast.MkIncludeLine:
+		return tree.Path
+	case *ast.MkDirectiveLine:
+		return tree.Args
+	case *ast.MkMessageLine:
+		return tree.Message
+	case *ast.MkShellLine:
+		return tree.Command
+	case *ast.MkUnknownLine:
+		return tree.Text
+	case *ast.MkCommentLine:
+		return nil
+	}
+	return tree
+}
+
+// treeValue selects the value of a variable assignment.
+func treeValue(tree ast.MkLine) ast.Node {
+	if assign, ok := tree.(*ast.MkAssignLine); ok {
+		return assign.Value
+	}
+	return nil
+}
+
+// treeCond selects the condition of a conditional directive.
+func treeCond(tree ast.MkLine) ast.Node {
+	if cond, ok := tree.(*ast.MkCondLine); ok {
+		return cond.Cond
+	}
+	return nil
+}
+
+// setTexts replaces the raw lines with the given text, which consists of
+// the same number of lines or fewer. Additional lines are appended to the
+// last raw line.
+func (fix *Autofix) setTexts(text string) {
+	n := len(fix.texts)
+	if !hasSuffix(fix.texts[n-1], "\n") {
+		text = strings.TrimSuffix(text, "\n")
+	}
+
+	var rawLines []*RawLine
+	for _, rawText := range strings.SplitAfter(text, "\n") {
+		if rawText != "" {
+			rawLines = append(rawLines, &RawLine{rawText})
+		}
+	}
+
+	for i := range fix.texts {
+		switch {
+		case i >= len(rawLines):
+			fix.texts[i] = ""
+		case i == n-1:
+			var rest strings.Builder
+			for _, rawLine := range rawLines[i:] {
+				rest.WriteString(rawLine.orignl)
+			}
+			fix.texts[i] = rest.String()
+		default:
+			fix.texts[i] = rawLines[i].orignl
+		}
+	}
+
+	fix.line.Text = ""
+	if len(rawLines) > 0 {
+		logical, _ := nextLogicalLine(fix.line.Filename(), rawLines, 0)
+		fix.line.Text = logical.Text
+	}
 }
 
 // InsertAbove prepends a line above the current line.
@@ -338,7 +478,12 @@ func (fix *Autofix) Apply() {
 		linenos := fix.affectedLinenos()
 		msg := sprintf(fix.diagFormat, fix.diagArgs...)
 		if !logFix && G.Logger.FirstTime(line.Filename(), linenos, msg) {
-			G.Logger.writeSource(line)
+			if G.Logger.fragment != nil {
+				G.Logger.fragment.record(line, nil, fix.level, fix.diagFormat, msg)
+				G.Logger.suppressDiag = true
+			} else {
+				G.Logger.writeSource(line, nil)
+			}
 		}
 		G.Logger.Logf(fix.level, line.Filename(), linenos, fix.diagFormat, msg)
 	}
@@ -351,7 +496,7 @@ func (fix *Autofix) Apply() {
 			}
 			G.Logger.Logf(AutofixLogLevel, line.Filename(), lineno, autofixFormat, action.description)
 		}
-		G.Logger.writeSource(line)
+		G.Logger.writeSource(line, nil)
 	}
 
 	if logDiagnostic {
@@ -483,7 +628,7 @@ func SaveAutofixChanges(lines *Lines) (autofixed bool) {
 		return
 	}
 
-	if G.Testing {
+	if _, isOS := G.FileSystem.(OSFileSystem); G.Testing && isOS {
 		abs := G.Abs(lines.Filename)
 		absTmp := G.Abs(NewCurrPathSlash(os.TempDir()))
 
@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/regex"
 	"gopkg.in/check.v1"
 	"os"
@@ -643,9 +644,12 @@ func (s *Suite) Test_Autofix_ReplaceAfter__autofix_one_time(c *check.C) {
 
 	mklines.Check()
 
-	// Nothing is replaced since, as of June 2019, pkglint doesn't
-	// know which of the two "$(var)" should be replaced.
-	t.CheckOutputEmpty()
+	// Since the lexer knows the position of the expression,
+	// the "$(var)" from the escaped "$$(var)" is not replaced.
+	t.CheckOutputLines(
+		"AUTOFIX: ~/Makefile:2: Replacing \"$(var)\" with \"${var}\".",
+		"-\tVAR=\t$$(var) $(var)",
+		"+\tVAR=\t$$(var) ${var}")
 }
 
 // When an autofix replaces text, it does not touch those
@@ -846,6 +850,215 @@ func (s *Suite) Test_Autofix_ReplaceAt__only(c *check.C) {
 		"# remark")
 }
 
+func (s *Suite) Test_Autofix_Edit(c *check.C) {
+	t := s.Init(c)
+
+	t.Chdir(".")
+	doTest := func(bool) {
+		mklines := t.SetUpFileMkLines("filename.mk",
+			MkCvsID,
+			"VAR=\tvalue value \\",
+			"\tvalue # value")
+
+		mkline := mklines.mklines[1]
+		f, tree := mkline.Tree()
+		assign := tree.(*ast.MkAssignLine)
+		ed := ast.NewFileEditor(f)
+		ed.Replace(assign.Value, ast.NewLiteral("replaced"))
+		ed.Remove(&assign.S3)
+		ed.Remove(assign.Comment)
+
+		fix := mkline.Autofix()
+		fix.Warnf("Warning.")
+		fix.Edit(ed)
+		fix.Apply()
+
+		mklines.SaveAutofixChanges()
+	}
+
+	// The value occurs 4 times in the line, which makes a plain Replace
+	// impossible. Since the edits refer to the nodes of the tree,
+	// they are unambiguous.
+	t.ExpectDiagnosticsAutofix(
+		doTest,
+		"WARN: filename.mk:2--3: Warning.",
+		"AUTOFIX: filename.mk:2: Replacing \"value value \\\\\\n\\tvalue\" with \"replaced\".",
+		"AUTOFIX: filename.mk:3: Deleting \" \".",
+		"AUTOFIX: filename.mk:3: Deleting \"# value\".")
+
+	t.CheckFileLines("filename.mk",
+		MkCvsID,
+		"VAR=\treplaced")
+}
+
+func (s *Suite) Test_Autofix_Edit__insert(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mkline := t.NewMkLine("filename.mk", 123, ".include \"other.mk\"")
+
+	f, tree := mkline.Tree()
+	ed := ast.NewFileEditor(f)
+	ed.InsertAfter(tree.(*ast.MkIncludeLine).Close, ast.NewLiteral(" # comment"))
+
+	fix := mkline.Autofix()
+	fix.Notef("Inserting.")
+	fix.Edit(ed)
+	fix.Apply()
+
+	t.CheckEquals(mkline.Text, ".include \"other.mk\" # comment")
+	t.CheckOutputLines(
+		"NOTE: filename.mk:123: Inserting.",
+		"AUTOFIX: filename.mk:123: Inserting \" # comment\".")
+}
+
+func (s *Suite) Test_Autofix_Edit__no_edits(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mkline := t.NewMkLine("filename.mk", 123, "VAR=\tvalue")
+
+	f, _ := mkline.Tree()
+
+	fix := mkline.Autofix()
+	fix.Notef("Nothing to do.")
+	fix.Edit(ast.NewFileEditor(f))
+	fix.Apply()
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_Autofix_ReplaceInTree(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mkline := t.NewMkLine("filename.mk", 123, "VAR=\tvalue # value")
+
+	test := func(part func(ast.MkLine) ast.Node, from, to string, diagnostics ...string) {
+		fix := mkline.Autofix()
+		fix.Notef("Replacing.")
+		fix.ReplaceInTree(part, from, to)
+		fix.Apply()
+		t.CheckOutput(diagnostics)
+	}
+
+	// The value occurs twice in the line, which makes a plain Replace
+	// impossible. In the value of the assignment, it is unambiguous.
+	test(treeValue, "value", "replaced",
+		"NOTE: filename.mk:123: Replacing.",
+		"AUTOFIX: filename.mk:123: Replacing \"value\" with \"replaced\".")
+
+	t.CheckEquals(mkline.Text, "VAR=\treplaced # value")
+
+	// The line has no condition.
+	test(treeCond, "value", "other",
+		nil...)
+
+	test(treeLine, "unknown", "other",
+		nil...)
+}
+
+func (s *Suite) Test_treeLine(c *check.C) {
+	t := s.Init(c)
+
+	line := t.NewLine("PLIST", 123, "bin/program # comment")
+	f, tree := line.Tree()
+
+	t.CheckEquals(f.Text(treeLine(tree)), "bin/program # comment\n")
+}
+
+func (s *Suite) Test_treeCode(c *check.C) {
+	t := s.Init(c)
+
+	test := func(text string, expected string) {
+		f, tree := t.NewMkLine("filename.mk", 123, text).Tree()
+		node := treeCode(tree)
+		if node == nil {
+			t.CheckEquals("<nil>", expected)
+		} else {
+			t.CheckEquals(f.Text(node), expected)
+		}
+	}
+
+	test("VAR=\t${VALUE} # comment", "VAR=\t${VALUE} # comment\n")
+	test("target: source # comment", "target: source # comment\n")
+	test(".if ${COND} # comment", "${COND}")
+	test(".include \"${PATH}\" # comment", "${PATH}")
+	test(".for i in ${LIST} # comment", "i in ${LIST}")
+	test(".info ${MESSAGE} # comment", "${MESSAGE}")
+	test("\t${COMMAND} # comment", "${COMMAND} # comment")
+	test("# comment", "<nil>")
+}
+
+func (s *Suite) Test_treeValue(c *check.C) {
+	t := s.Init(c)
+
+	f, tree := t.NewMkLine("filename.mk", 123, "VAR=\tvalue # comment").Tree()
+
+	t.CheckEquals(f.Text(treeValue(tree)), "value")
+
+	_, tree = t.NewMkLine("filename.mk", 123, ".if ${COND}").Tree()
+
+	t.CheckEquals(treeValue(tree), nil)
+}
+
+func (s *Suite) Test_treeCond(c *check.C) {
+	t := s.Init(c)
+
+	f, tree := t.NewMkLine("filename.mk", 123, ".if ${COND} # comment").Tree()
+
+	t.CheckEquals(f.Text(treeCond(tree)), "${COND}")
+
+	_, tree = t.NewMkLine("filename.mk", 123, "VAR=\tvalue").Tree()
+
+	t.CheckEquals(treeCond(tree), nil)
+}
+
+func (s *Suite) Test_Autofix_setTexts(c *check.C) {
+	t := s.Init(c)
+
+	test := func(texts []string, text string, expectedTexts []string, expectedText string) {
+		line := t.NewLines("filename.mk", texts...).Lines[0]
+		if len(texts) > 1 {
+			line = t.NewMkLines("filename.mk", texts...).mklines[0].Line
+		}
+		fix := line.Autofix()
+
+		fix.setTexts(text)
+
+		t.CheckDeepEquals(fix.texts, expectedTexts)
+		t.CheckEquals(line.Text, expectedText)
+	}
+
+	// Same number of lines.
+	test(
+		[]string{"VAR=\tvalue \\", "\tcontinued"},
+		"VAR=\tother \\\n\tcontinued\n",
+		[]string{"VAR=\tother \\\n", "\tcontinued\n"},
+		"VAR=\tother continued")
+
+	// Additional lines are appended to the last raw line.
+	test(
+		[]string{"VAR=\tvalue"},
+		"VAR=\tvalue \\\n\tcontinued\n",
+		[]string{"VAR=\tvalue \\\n\tcontinued\n"},
+		"VAR=\tvalue continued")
+
+	// Fewer lines leave the remaining raw lines empty.
+	test(
+		[]string{"VAR=\tvalue \\", "\tcontinued"},
+		"VAR=\tvalue\n",
+		[]string{"VAR=\tvalue\n", ""},
+		"VAR=\tvalue")
+
+	// Removing the line completely.
+	test(
+		[]string{"VAR=\tvalue"},
+		"",
+		[]string{""},
+		"")
+}
+
 func (s *Suite) Test_Autofix_InsertAbove(c *check.C) {
 	t := s.Init(c)
 
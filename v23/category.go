@@ -149,8 +149,19 @@ func CheckdirCategory(dir CurrPath, recurse bool) {
 			mName := mRest[0].name
 			if !fCheck[mName] &&
 				seenLower[strings.ToLower(mName.String())].name == mName {
-				fix := mRest[0].line.Autofix()
-				fix.Errorf("%q does not contain a package.", mName)
+				mkline := mRest[0].line
+				fix := mkline.Autofix()
+				pkgpath := G.Pkgsrc.Rel(dir.JoinNoClean(mName))
+				if change := G.Pkgsrc.changes.Relocation(pkgpath); change == nil {
+					fix.Errorf("%q does not contain a package.", mName)
+				} else if change.Action == Removed {
+					fix.Errorf("The package %s has been removed (see %s).",
+						pkgpath.String(), mkline.Line.RelLocation(change.Location))
+				} else {
+					fix.Errorf("The package %s has been %s to %s (see %s).",
+						pkgpath.String(), strings.ToLower(change.Action.String()),
+						change.Target().String(), mkline.Line.RelLocation(change.Location))
+				}
 				fix.Delete()
 				fix.Apply()
 			}
@@ -157,6 +157,38 @@ func (s *Suite) Test_CheckdirCategory__only_in_Makefile(c *check.C) {
 			"\"only-in-makefile\" does not contain a package.")
 }
 
+func (s *Suite) Test_CheckdirCategory__relocated(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("doc/CHANGES-2018",
+		CvsID,
+		"",
+		"\tMoved category/moved to other/moved [author 2018-01-01]",
+		"\tRemoved category/removed [author 2018-01-02]")
+	t.CreateFileLines("mk/misc/category.mk")
+	t.CreateFileLines("category/both/Makefile")
+	t.CreateFileLines("category/Makefile",
+		MkCvsID,
+		"",
+		"COMMENT=\tCategory comment",
+		"",
+		"SUBDIR+=\tboth",
+		"SUBDIR+=\tmoved",
+		"SUBDIR+=\tremoved",
+		"",
+		".include \"../mk/misc/category.mk\"")
+	t.FinishSetUp()
+
+	CheckdirCategory(t.File("category"), false)
+
+	t.CheckOutputLines(
+		"ERROR: ~/category/Makefile:6: The package category/moved "+
+			"has been moved to other/moved (see ../doc/CHANGES-2018:3).",
+		"ERROR: ~/category/Makefile:7: The package category/removed "+
+			"has been removed (see ../doc/CHANGES-2018:4).")
+}
+
 func (s *Suite) Test_CheckdirCategory__only_in_file_system(c *check.C) {
 	t := s.Init(c)
 
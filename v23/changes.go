@@ -46,6 +46,34 @@ func (ch *Changes) load(src *Pkgsrc) {
 	ch.checkRemovedAfterLastFreeze(src)
 }
 
+// Relocation returns the change that moved, renamed or removed the
+// package, or nil if the package is still at this location.
+//
+// If the package has been moved several times, the last of these
+// changes is returned, so that its target is the current location.
+func (ch *Changes) Relocation(pkgpath PkgsrcPath) *Change {
+	var relocation *Change
+	seen := make(map[PkgsrcPath]bool)
+	for !seen[pkgpath] {
+		seen[pkgpath] = true
+
+		change := ch.LastChange[pkgpath]
+		if change == nil || change.Pkgpath != pkgpath {
+			break
+		}
+		switch change.Action {
+		case Renamed, Moved:
+			relocation = change
+			pkgpath = change.Target()
+			continue
+		case Removed:
+			return change
+		}
+		break
+	}
+	return relocation
+}
+
 func (ch *Changes) parseFile(filename CurrPath, direct bool) []*Change {
 
 	warn := direct || G.CheckGlobal && !G.Wip
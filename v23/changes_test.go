@@ -33,6 +33,55 @@ func (s *Suite) Test_Changes_load__not_found(c *check.C) {
 		"FATAL: ~/doc: Cannot be read for loading the package changes.")
 }
 
+func (s *Suite) Test_Changes_Relocation(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("doc/CHANGES-2018",
+		CvsID,
+		"",
+		"\tUpdated category/updated to 1.0 [author 2018-01-01]",
+		"\tMoved category/moved to other/moved [author 2018-01-02]",
+		"\tRenamed other/moved to other/renamed [author 2018-01-03]",
+		"\tRenamed category/once to category/twice [author 2018-01-04]",
+		"\tRemoved category/removed [author 2018-01-05]",
+		"\tMoved category/gone to other/gone [author 2018-01-06]",
+		"\tRemoved other/gone successor other/new [author 2018-01-07]",
+		"\tMoved category/old to category/new [author 2018-01-08]",
+		"\tAdded category/old version 2.0 [author 2018-01-09]",
+		"\tMoved category/loop to other/loop [author 2018-01-10]",
+		"\tMoved other/loop to category/loop [author 2018-01-11]")
+	t.FinishSetUp()
+
+	test := func(pkgpath PkgsrcPath, expectedAction ChangeAction, expectedLineno int) {
+		change := G.Pkgsrc.changes.Relocation(pkgpath)
+		if expectedAction == 0 {
+			t.CheckEquals(change, (*Change)(nil))
+		} else if t.CheckNotNil(change) {
+			t.CheckEquals(change.Action, expectedAction)
+			t.CheckEquals(change.Location.lineno, expectedLineno)
+		}
+	}
+
+	test("category/unknown", 0, 0)
+	test("category/updated", 0, 0)
+
+	// The package has been moved and then renamed.
+	test("category/moved", Renamed, 5)
+	test("other/renamed", 0, 0)
+	test("category/once", Renamed, 6)
+	test("category/removed", Removed, 7)
+	test("category/gone", Removed, 9)
+
+	// A new package has been added at the old location.
+	test("category/old", 0, 0)
+	test("category/new", 0, 0)
+
+	// The package has been moved back to its old location.
+	test("category/loop", 0, 0)
+	test("other/loop", Moved, 13)
+}
+
 func (s *Suite) Test_Changes_parseFile(c *check.C) {
 	t := s.Init(c)
 
@@ -2,6 +2,8 @@ package pkglint
 
 import (
 	"bytes"
+	"debug/elf"
+	"encoding/binary"
 	"fmt"
 	"github.com/rillig/pkglint/v23/intqa"
 	"github.com/rillig/pkglint/v23/regex"
@@ -579,6 +581,19 @@ line:
 	return t.File(pkgpath)
 }
 
+// SetUpPackageOwnPlist sets up a package like SetUpPackage, except that
+// its PLIST only contains bin/ followed by the package directory name.
+//
+// This allows several packages to be checked together without warnings
+// about files that are installed by several packages.
+func (t *Tester) SetUpPackageOwnPlist(pkgpath RelPath, makefileLines ...string) CurrPath {
+	pkgdir := t.SetUpPackage(pkgpath, makefileLines...)
+	t.CreateFileLines(pkgpath.JoinNoClean("PLIST"),
+		PlistCvsID,
+		"bin/"+pkgpath.Base().String())
+	return pkgdir
+}
+
 // CreateFileLines creates a file in the temporary directory and writes the
 // given lines to it.
 //
@@ -600,6 +615,20 @@ func (t *Tester) CreateFileLines(filename RelPath, lines ...string) CurrPath {
 	return abs
 }
 
+// CreateFileTree creates the directory in the temporary directory,
+// with an empty file for each of the given entries.
+// The entries ending in a slash are created as empty directories.
+func (t *Tester) CreateFileTree(dirname RelPath, entries ...RelPath) {
+	t.AssertNil(os.MkdirAll(t.File(dirname).String(), 0777))
+	for _, entry := range entries {
+		if entry.HasSuffixText("/") {
+			t.CreateFileTree(dirname.JoinNoClean(entry))
+		} else {
+			t.CreateFileLines(dirname.JoinNoClean(entry))
+		}
+	}
+}
+
 // CreateFileDummyPatch creates a patch file with the given name in the
 // temporary directory.
 func (t *Tester) CreateFileDummyPatch(filename RelPath) {
@@ -667,6 +696,66 @@ func (t *Tester) CreateFileBuildlink3Id(filename RelPath, id string, customLines
 	t.CreateFileLines(filename, lines...)
 }
 
+// CreateFileElf creates a minimal 64-bit ELF file that only contains
+// the dynamic section, with the given SONAME, RPATH and NEEDED entries.
+// Empty strings are left out.
+func (t *Tester) CreateFileElf(filename RelPath, typ elf.Type, soname, rpath string, needed ...string) {
+	var dynstr bytes.Buffer
+	var dynamic bytes.Buffer
+	dynstr.WriteByte(0)
+	addDyn := func(tag elf.DynTag, value string) {
+		if value == "" {
+			return
+		}
+		t.AssertNil(binary.Write(&dynamic, binary.LittleEndian, [2]uint64{uint64(tag), uint64(dynstr.Len())}))
+		dynstr.WriteString(value + "\x00")
+	}
+	for _, lib := range needed {
+		addDyn(elf.DT_NEEDED, lib)
+	}
+	addDyn(elf.DT_SONAME, soname)
+	addDyn(elf.DT_RPATH, rpath)
+	t.AssertNil(binary.Write(&dynamic, binary.LittleEndian, [2]uint64{uint64(elf.DT_NULL), 0}))
+	shstrtab := "\x00.dynstr\x00.dynamic\x00.shstrtab\x00"
+
+	const headerSize = 64
+	dynstrOff := uint64(headerSize)
+	dynamicOff := dynstrOff + uint64(dynstr.Len())
+	shstrtabOff := dynamicOff + uint64(dynamic.Len())
+	shoff := shstrtabOff + uint64(len(shstrtab))
+
+	var out bytes.Buffer
+	write := func(data interface{}) {
+		t.AssertNil(binary.Write(&out, binary.LittleEndian, data))
+	}
+	out.WriteString(elf.ELFMAG)
+	out.Write([]byte{byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)})
+	out.Write(make([]byte, 9))
+	write(uint16(typ))
+	write(uint16(elf.EM_X86_64))
+	write(uint32(elf.EV_CURRENT))
+	write([3]uint64{0, 0, shoff}) // entry, phoff, shoff
+	write(uint32(0))              // flags
+	write([6]uint16{headerSize, 56, 0, 64, 4, 3})
+	out.Write(dynstr.Bytes())
+	out.Write(dynamic.Bytes())
+	out.WriteString(shstrtab)
+
+	section := func(name uint32, typ elf.SectionType, off uint64, size int, link uint32, entsize uint64) {
+		write([2]uint32{name, uint32(typ)})
+		write([4]uint64{0, 0, off, uint64(size)})
+		write([2]uint32{link, 0})
+		write([2]uint64{1, entsize})
+	}
+	section(0, elf.SHT_NULL, 0, 0, 0, 0)
+	section(1, elf.SHT_STRTAB, dynstrOff, dynstr.Len(), 0, 0)
+	section(9, elf.SHT_DYNAMIC, dynamicOff, dynamic.Len(), 1, 16)
+	section(18, elf.SHT_STRTAB, shstrtabOff, len(shstrtab), 0, 0)
+
+	abs := t.CreateFileLines(filename)
+	t.AssertNil(os.WriteFile(abs.String(), out.Bytes(), 0666))
+}
+
 // File returns the absolute path to the given file in the
 // temporary directory. It doesn't check whether that file exists.
 // Calls to Tester.Chdir change the base directory for the relative filename.
@@ -861,6 +950,15 @@ func (t *Tester) FinishSetUp() {
 	}
 }
 
+// LoadPackage loads the package from the given directory, after
+// finishing the setup, and returns it together with all its lines.
+func (t *Tester) LoadPackage(pkgpath RelPath) (*Package, *MkLines) {
+	t.FinishSetUp()
+	pkg := NewPackage(t.File(pkgpath))
+	_, _, allLines := pkg.load()
+	return pkg, allLines
+}
+
 // Main runs the pkglint main program with the given command line arguments.
 // Other than in the other tests, the -Wall option is not added implicitly.
 //
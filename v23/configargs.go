@@ -0,0 +1,251 @@
+package pkglint
+
+import (
+	"github.com/rillig/pkglint/v23/regex"
+	"strings"
+)
+
+// ConfigureArgsChecker checks the arguments for the configure script,
+// for CMake and for Meson against the options that the upstream build
+// files define, for --wrksrc and --distdir.
+//
+// After updating a package, some of the upstream options may have been
+// renamed or removed. The build systems often ignore unknown options,
+// or they only mention them in the middle of a long build log, which is
+// easily overlooked.
+type ConfigureArgsChecker struct {
+	pkg *Package
+	src *PatchSource
+}
+
+func NewConfigureArgsChecker(pkg *Package, src *PatchSource) *ConfigureArgsChecker {
+	return &ConfigureArgsChecker{pkg, src}
+}
+
+func (ck *ConfigureArgsChecker) Check(allLines *MkLines) {
+	var configure, cmake, meson []*MkLine
+	allLines.ForEach(func(mkline *MkLine) {
+		if !mkline.IsVarassign() || G.Pkgsrc.IsInfra(mkline.Filename()) ||
+			mkline.Basename == "buildlink3.mk" || mkline.Basename == "builtin.mk" {
+			return
+		}
+
+		switch mkline.Varname() {
+		case "CONFIGURE_ARGS":
+			configure = append(configure, mkline)
+		case "CMAKE_ARGS", "CMAKE_CONFIGURE_ARGS":
+			cmake = append(cmake, mkline)
+		case "MESON_ARGS":
+			meson = append(meson, mkline)
+		}
+	})
+
+	if len(configure) > 0 {
+		if options := ck.loadConfigureOptions(); options != nil {
+			ck.checkArgs(configure, "configure script", func(arg string) bool {
+				return ck.knowsConfigureArg(options, arg)
+			})
+		}
+	}
+	if len(cmake) > 0 {
+		if names := ck.loadCMakeNames(); names != nil {
+			ck.checkArgs(cmake, "CMake files", func(arg string) bool {
+				return ck.knowsCMakeArg(names, arg)
+			})
+		}
+	}
+	if len(meson) > 0 {
+		if options := ck.loadMesonOptions(); options != nil {
+			ck.checkArgs(meson, "Meson options", func(arg string) bool {
+				return ck.knowsMesonArg(options, arg)
+			})
+		}
+	}
+}
+
+// checkArgs warns about the arguments that the upstream build files
+// don't recognize.
+func (ck *ConfigureArgsChecker) checkArgs(mklines []*MkLine, upstream string, known func(arg string) bool) {
+	for _, mkline := range mklines {
+		for _, arg := range mkline.ValueFields(resolveExprs(mkline.Value(), nil, ck.pkg)) {
+			if containsExpr(arg) || known(arg) {
+				continue
+			}
+
+			mkline.Warnf("The option %q is not recognized by the upstream %s.", arg, upstream)
+			mkline.Explain(
+				"After updating a package, the upstream options may have been",
+				"renamed or removed.",
+				"Unknown options are typically ignored by the build system,",
+				"which means that the feature is silently enabled or disabled",
+				"differently than intended.")
+		}
+	}
+}
+
+// loadConfigureOptions returns the options from the GNU configure
+// scripts in the CONFIGURE_DIRS, such as "enable_nls" or "with_ssl",
+// or nil if there is no configure script generated by GNU autoconf.
+func (ck *ConfigureArgsChecker) loadConfigureOptions() map[string]bool {
+	dirs := []string{"."}
+	if ck.pkg.vars.IsDefined("CONFIGURE_DIRS") {
+		dirs = strings.Fields(resolveExprs(ck.pkg.vars.LastValue("CONFIGURE_DIRS"), nil, ck.pkg))
+	}
+
+	var options map[string]bool
+	for _, dir := range dirs {
+		dir = strings.TrimPrefix(strings.TrimPrefix(dir, "${WRKSRC}"), "/")
+		if containsExpr(dir) || NewPath(dir).IsAbs() {
+			continue
+		}
+		rel := NewRelPathString(dir).JoinNoClean("configure").Clean()
+		data, err := ck.src.ReadFile(rel)
+		if err != nil {
+			continue
+		}
+
+		// Since autoconf 2.64, the configure script lists all its
+		// options for the --enable-option-checking option.
+		m, userOpts := match1(string(data), `(?m)^ac_user_opts='([^']*)'`)
+		if !m {
+			continue
+		}
+		if options == nil {
+			options = make(map[string]bool)
+		}
+		for _, option := range strings.Fields(userOpts) {
+			options[option] = true
+		}
+	}
+	return options
+}
+
+func (ck *ConfigureArgsChecker) knowsConfigureArg(options map[string]bool, arg string) bool {
+	m, kind, name := match2(arg, `^--(enable|disable|with|without)-([^=]+)`)
+	if !m {
+		return true
+	}
+	kind = condStr(kind == "enable" || kind == "disable", "enable", "with")
+	return options[kind+"_"+strings.NewReplacer("-", "_", ".", "_", "+", "_").Replace(name)]
+}
+
+// loadCMakeNames returns the names that the CMake files of the upstream
+// sources define, or nil if there are no CMake files.
+//
+// These are the cache variables from option, cmake_dependent_option and
+// set with CACHE, and the packages from find_package, whose variables
+// are defined by the find modules.
+func (ck *ConfigureArgsChecker) loadCMakeNames() map[string]bool {
+	var names map[string]bool
+	for _, file := range ck.src.Find(`^CMakeLists\.txt$|\.cmake$`) {
+		data, err := ck.src.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if names == nil {
+			names = make(map[string]bool)
+		}
+		for _, m := range regcomp(cmakeDefinition).FindAllStringSubmatch(string(data), -1) {
+			names[m[1]+m[2]] = true
+		}
+	}
+	return names
+}
+
+// cmakeDefinition matches the CMake commands that define a cache
+// variable or look for a package. CMake commands are case-insensitive.
+const cmakeDefinition regex.Pattern = `(?i)\b(?:option|cmake_dependent_option|find_package)\s*\(\s*(\w+)` +
+	`|\bset\s*\(\s*(\w+)\s[^()]*\bCACHE\b`
+
+func (ck *ConfigureArgsChecker) knowsCMakeArg(names map[string]bool, arg string) bool {
+	m, name := match1(arg, `^-D([^:=]+)`)
+	if !m || names[name] || cmakeBuiltinVariables[name] || hasPrefix(name, "CMAKE_") {
+		return true
+	}
+
+	// The variables for the find modules that come with CMake,
+	// such as Python3_EXECUTABLE for find_package(Python3).
+	for i := 1; i < len(name); i++ {
+		if name[i] == '_' && names[name[:i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// cmakeBuiltinVariables are the variables that CMake itself or its
+// standard modules use, other than those starting with CMAKE_.
+var cmakeBuiltinVariables = map[string]bool{
+	"BUILD_SHARED_LIBS": true,
+	"BUILD_TESTING":     true,
+}
+
+// loadMesonOptions returns the options from the meson.options or
+// meson_options.txt file of the upstream sources, or nil if there is
+// no such file.
+func (ck *ConfigureArgsChecker) loadMesonOptions() map[string]bool {
+	var options map[string]bool
+	for _, basename := range [...]RelPath{"meson.options", "meson_options.txt"} {
+		data, err := ck.src.ReadFile(basename)
+		if err != nil {
+			continue
+		}
+		if options == nil {
+			options = make(map[string]bool)
+		}
+		for _, m := range regcomp(`\boption\(\s*'([^']+)'`).FindAllStringSubmatch(string(data), -1) {
+			options[m[1]] = true
+		}
+	}
+	return options
+}
+
+func (ck *ConfigureArgsChecker) knowsMesonArg(options map[string]bool, arg string) bool {
+	m, name := match1(arg, `^-D([^=]+)`)
+	if !m || options[name] || mesonBuiltinOptions[name] {
+		return true
+	}
+
+	// Options for subprojects, for the compilers and the base options.
+	return contains(name, ":") || matches(name, mesonCompilerOption) || hasPrefix(name, "b_")
+}
+
+// mesonBuiltinOptions are the options that Meson itself defines,
+// in addition to the compiler options and the base options.
+var mesonBuiltinOptions = map[string]bool{
+	"auto_features":      true,
+	"backend":            true,
+	"bindir":             true,
+	"buildtype":          true,
+	"cmake_prefix_path":  true,
+	"datadir":            true,
+	"debug":              true,
+	"default_library":    true,
+	"errorlogs":          true,
+	"force_fallback_for": true,
+	"includedir":         true,
+	"infodir":            true,
+	"install_umask":      true,
+	"layout":             true,
+	"libdir":             true,
+	"libexecdir":         true,
+	"localedir":          true,
+	"localstatedir":      true,
+	"mandir":             true,
+	"optimization":       true,
+	"pkg_config_path":    true,
+	"prefer_static":      true,
+	"prefix":             true,
+	"sbindir":            true,
+	"sharedstatedir":     true,
+	"stdsplit":           true,
+	"strip":              true,
+	"sysconfdir":         true,
+	"unity":              true,
+	"unity_size":         true,
+	"warning_level":      true,
+	"werror":             true,
+	"wrap_mode":          true,
+}
+
+const mesonCompilerOption regex.Pattern = `^(c|cpp|cuda|d|fortran|objc|objcpp|rust|vala)_\w+$|^(pkgconfig|python)\.`
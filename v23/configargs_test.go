@@ -0,0 +1,216 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_NewConfigureArgsChecker(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	t.CheckEquals(ck.pkg.Pkgpath, PkgsrcPath("category/package"))
+	t.CheckEquals(ck.src.dir, t.File("work/package-1.0"))
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"CONFIGURE_ARGS+=\t--enable-nls --with-ssl=${BUILDLINK_PREFIX.openssl}",
+		"CONFIGURE_ARGS+=\t--disable-renamed",
+		"CMAKE_ARGS+=\t-DENABLE_DOCS=OFF -DENABLE_REMOVED=ON",
+		"MESON_ARGS+=\t-Dtests=false -Dremoved=true")
+	t.CreateFileLines("work/package-1.0/configure",
+		"#! /bin/sh",
+		"ac_user_opts='",
+		"enable_option_checking",
+		"enable_nls",
+		"with_ssl",
+		"enable_new_name",
+		"'")
+	t.CreateFileLines("work/package-1.0/CMakeLists.txt",
+		"option(ENABLE_DOCS \"Build the documentation\" ON)")
+	t.CreateFileLines("work/package-1.0/meson.options",
+		"option('tests', type: 'boolean', value: true)")
+	t.Chdir(".")
+
+	t.Main("--wrksrc=work/package-1.0", "-q", "--only=upstream", "category/package")
+
+	t.CheckOutputLines(
+		"WARN: category/package/Makefile:21: "+
+			"The option \"--disable-renamed\" is not recognized by the upstream configure script.",
+		"WARN: category/package/Makefile:22: "+
+			"The option \"-DENABLE_REMOVED=ON\" is not recognized by the upstream CMake files.",
+		"WARN: category/package/Makefile:23: "+
+			"The option \"-Dremoved=true\" is not recognized by the upstream Meson options.")
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_Check__without_upstream_files(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"CONFIGURE_ARGS+=\t--disable-unknown",
+		"CMAKE_ARGS+=\t-DUNKNOWN=ON",
+		"MESON_ARGS+=\t-Dunknown=true")
+	t.CreateFileLines("work/package-1.0/configure",
+		"#! /bin/sh",
+		"echo 'not generated by GNU autoconf'")
+	pkg, allLines := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	ck.Check(allLines)
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_checkArgs(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"CONFIGURE_ARGS+=\tknown unknown ${UNRESOLVED}")
+	pkg, allLines := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+	mkline := allLines.mklines[len(allLines.mklines)-3]
+
+	ck.checkArgs([]*MkLine{mkline}, "build files", func(arg string) bool { return arg == "known" })
+
+	t.CheckOutputLines(
+		"WARN: ~/category/package/Makefile:20: " +
+			"The option \"unknown\" is not recognized by the upstream build files.")
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_loadConfigureOptions(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"CONFIGURE_DIRS=\t${WRKSRC}/lib src missing ${UNRESOLVED}")
+	t.CreateFileLines("work/package-1.0/lib/configure",
+		"ac_user_opts='",
+		"enable_lib",
+		"'")
+	t.CreateFileLines("work/package-1.0/src/configure",
+		"ac_user_opts='",
+		"with_src",
+		"'")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	options := ck.loadConfigureOptions()
+
+	t.CheckDeepEquals(options, map[string]bool{"enable_lib": true, "with_src": true})
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_knowsConfigureArg(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+	options := map[string]bool{"enable_feature_x": true, "with_lib": true}
+
+	test := func(arg string, expected bool) {
+		t.CheckEquals(ck.knowsConfigureArg(options, arg), expected)
+	}
+
+	test("--enable-feature-x", true)
+	test("--disable-feature-x", true)
+	test("--enable-feature.x=yes", true)
+	test("--with-lib=/usr/pkg", true)
+	test("--without-lib", true)
+	test("--with-feature-x", false)
+	test("--enable-lib", false)
+	test("--prefix=/usr/pkg", true)
+	test("CFLAGS=-O2", true)
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_loadCMakeNames(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("work/package-1.0/CMakeLists.txt",
+		"add_subdirectory(src)",
+		"OPTION(ENABLE_DOCS \"Build the documentation\" ON)",
+		"cmake_dependent_option(ENABLE_GUI \"Build the GUI\" ON \"WITH_X11\" OFF)",
+		"find_package(Python3 REQUIRED)",
+		"set(LOCAL_VARIABLE ON)",
+		"if(UNDECLARED)",
+		"endif()")
+	t.CreateFileLines("work/package-1.0/src/options.cmake",
+		"set(WITH_X11 ON CACHE BOOL \"\")",
+		"set(INSTALL_DIR \"${CMAKE_INSTALL_PREFIX}/lib\"",
+		"    CACHE PATH \"Installation directory\")")
+	t.CreateFileLines("work/package-1.0/src/README",
+		"NOT_CMAKE")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	names := ck.loadCMakeNames()
+
+	// Only the cache variables and the packages are known,
+	// not the plain variables.
+	t.CheckDeepEquals(names, map[string]bool{
+		"ENABLE_DOCS": true, "ENABLE_GUI": true, "Python3": true,
+		"WITH_X11": true, "INSTALL_DIR": true})
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_knowsCMakeArg(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+	names := map[string]bool{"WITH_X11": true, "Python3": true}
+
+	test := func(arg string, expected bool) {
+		t.CheckEquals(ck.knowsCMakeArg(names, arg), expected)
+	}
+
+	test("-DWITH_X11=ON", true)
+	test("-DWITH_X11:BOOL=ON", true)
+	test("-DCMAKE_BUILD_TYPE=Release", true)
+	test("-DPython3_EXECUTABLE=/usr/pkg/bin/python3.12", true)
+	test("-DBUILD_SHARED_LIBS=ON", true)
+	test("-DWITH_GTK=ON", false)
+	test("-G", true)
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_loadMesonOptions(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("work/package-1.0/meson_options.txt",
+		"option('docs', type: 'boolean', value: false)",
+		"option(",
+		"  'x11',",
+		"  type: 'feature')")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	options := ck.loadMesonOptions()
+
+	t.CheckDeepEquals(options, map[string]bool{"docs": true, "x11": true})
+}
+
+func (s *Suite) Test_ConfigureArgsChecker_knowsMesonArg(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewConfigureArgsChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+	options := map[string]bool{"x11": true}
+
+	test := func(arg string, expected bool) {
+		t.CheckEquals(ck.knowsMesonArg(options, arg), expected)
+	}
+
+	test("-Dx11=enabled", true)
+	test("-Dwayland=enabled", false)
+	test("-Dbuildtype=release", true)
+	test("-Db_lto=true", true)
+	test("-Dc_args=-O2", true)
+	test("-Dpython.install_env=prefix", true)
+	test("-Dsubproject:option=true", true)
+	test("--buildtype=release", true)
+}
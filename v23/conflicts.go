@@ -0,0 +1,294 @@
+package pkglint
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// ConflictChecker collects the CONFLICTS declarations and the files
+// from the PLIST of the checked packages, to check them against each
+// other and against the packages from the pkgsrc tree.
+//
+// It is only done in -Cglobal mode.
+type ConflictChecker struct {
+	packages  []PkgsrcPath
+	pkgnames  map[PkgsrcPath]string
+	conflicts map[PkgsrcPath][]*Conflict
+
+	// The first package that installs the file.
+	owners map[RelPath]*plistOwner
+	// The files that are installed by more than one package.
+	shared []*sharedFile
+
+	// Whether the whole pkgsrc tree is checked,
+	// so that the checked packages are all packages from the tree.
+	fullTree bool
+}
+
+// Conflict is a single pattern from the CONFLICTS of a package.
+type Conflict struct {
+	Pattern string
+	Line    *Line
+}
+
+type plistOwner struct {
+	pkgpath PkgsrcPath
+	line    *Line
+}
+
+type sharedFile struct {
+	path   RelPath
+	first  *plistOwner
+	second *plistOwner
+}
+
+func NewConflictChecker() *ConflictChecker {
+	return &ConflictChecker{
+		nil,
+		make(map[PkgsrcPath]string),
+		make(map[PkgsrcPath][]*Conflict),
+		make(map[RelPath]*plistOwner),
+		nil,
+		false}
+}
+
+// AddPackage remembers the CONFLICTS and the installed files of the
+// checked package.
+func (ck *ConflictChecker) AddPackage(pkgpath PkgsrcPath, pkgname string,
+	conflicts []*Conflict, plist map[RelPath]*PlistLine) {
+
+	if _, found := ck.pkgnames[pkgpath]; found {
+		return
+	}
+	ck.packages = append(ck.packages, pkgpath)
+	ck.pkgnames[pkgpath] = pkgname
+	ck.conflicts[pkgpath] = conflicts
+
+	paths := make([]RelPath, 0, len(plist))
+	for filename := range plist {
+		if !containsExpr(filename.String()) {
+			paths = append(paths, filename)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+
+	for _, filename := range paths {
+		owner := plistOwner{pkgpath, plist[filename].Line}
+		if first := ck.owners[filename]; first != nil {
+			ck.shared = append(ck.shared, &sharedFile{filename, first, &owner})
+		} else {
+			ck.owners[filename] = &owner
+		}
+	}
+}
+
+// Check reports the CONFLICTS that are not declared in both directions,
+// the CONFLICTS patterns that don't match any package from the tree,
+// and the files that are installed by several packages that don't
+// conflict with each other.
+func (ck *ConflictChecker) Check() {
+	for _, pkgpath := range ck.packages {
+		for _, conflict := range ck.conflicts[pkgpath] {
+			ck.checkConflict(pkgpath, conflict)
+		}
+	}
+	ck.checkSharedFiles()
+}
+
+func (ck *ConflictChecker) checkConflict(pkgpath PkgsrcPath, conflict *Conflict) {
+	candidates, ok := ck.candidates(conflict.Pattern)
+	if !ok {
+		return
+	}
+
+	pkgname := ck.pkgnames[pkgpath]
+	_, pkgbase, _ := matchPkgname(pkgname)
+	line := conflict.Line
+
+	matched := false
+	for _, other := range candidates {
+		if other == pkgpath {
+			continue
+		}
+		otherName := ck.pkgname(other)
+		if _, matches := packagePatternMatches(conflict.Pattern, otherName); !matches {
+			continue
+		}
+		matched = true
+
+		// Packages with the same package base conflict implicitly.
+		_, otherBase, _ := matchPkgname(otherName)
+		if pkgname == "" || otherBase == pkgbase {
+			continue
+		}
+
+		// Packages outside wip don't need to know about the
+		// packages from wip.
+		if G.Pkgsrc.IsWip(G.Pkgsrc.File(pkgpath)) && !G.Pkgsrc.IsWip(G.Pkgsrc.File(other)) {
+			continue
+		}
+
+		otherConflicts, ok := ck.patterns(other)
+		if !ok || conflictsWith(otherConflicts, pkgname) {
+			continue
+		}
+
+		line.Warnf("The package %q from %s should also conflict with %q.",
+			otherName, line.Rel(G.Pkgsrc.File(other)), pkgbase+"-[0-9]*")
+		line.Explain(
+			"Conflicts between packages are symmetric.",
+			"When the other package is installed first,",
+			"it must also prevent this package from being installed.",
+			"",
+			"To fix this, add a corresponding CONFLICTS entry",
+			"to the Makefile of the other package.")
+	}
+
+	if !matched && ck.fullTree {
+		line.Notef("No package from the pkgsrc tree matches the CONFLICTS pattern %q.",
+			conflict.Pattern)
+		line.Explain(
+			"The pattern may refer to a package that has been renamed or removed.",
+			"If that package has been removed for a long time,",
+			"the pattern can be removed as well.",
+			"If it has been renamed,",
+			"the pattern may need to be adjusted to the new name.")
+	}
+}
+
+func (ck *ConflictChecker) checkSharedFiles() {
+	reported := make(map[[2]PkgsrcPath]bool)
+
+	for _, shared := range ck.shared {
+		first, second := shared.first.pkgpath, shared.second.pkgpath
+		pair := [2]PkgsrcPath{first, second}
+		if reported[pair] {
+			continue
+		}
+
+		if G.Pkgsrc.IsWip(G.Pkgsrc.File(first)) != G.Pkgsrc.IsWip(G.Pkgsrc.File(second)) {
+			continue
+		}
+
+		firstName, secondName := ck.pkgnames[first], ck.pkgnames[second]
+		_, firstBase, _ := matchPkgname(firstName)
+		_, secondBase, _ := matchPkgname(secondName)
+		if firstName == "" || secondName == "" || firstBase == secondBase ||
+			conflictsWith(ck.conflictPatterns(first), secondName) ||
+			conflictsWith(ck.conflictPatterns(second), firstName) {
+			continue
+		}
+		reported[pair] = true
+
+		line := shared.second.line
+		line.Warnf("The file %q is also installed by %s, without a declared conflict.",
+			shared.path.String(), line.Rel(G.Pkgsrc.File(first)))
+		line.Explain(
+			"Two packages that install the same file",
+			"cannot be installed at the same time.",
+			"",
+			"If the packages are alternatives to each other,",
+			"each of them should declare a CONFLICTS with the other.",
+			"Otherwise, the file should be renamed in one of the packages.")
+	}
+}
+
+// candidates returns the packages whose package base matches one of
+// the alternatives of the pattern, or false if the pattern cannot be
+// evaluated.
+//
+// Evaluating all packages from the tree would take too long,
+// therefore only the checked packages and those packages whose
+// directory is named like the package base are considered.
+// When the whole tree is checked, the checked packages are complete,
+// which also allows wildcards in the package base.
+func (ck *ConflictChecker) candidates(pattern string) ([]PkgsrcPath, bool) {
+	if containsExpr(pattern) {
+		return nil, false
+	}
+
+	var candidates []PkgsrcPath
+	seen := make(map[PkgsrcPath]bool)
+	add := func(pkgpath PkgsrcPath, pkgbase string) {
+		if !seen[pkgpath] && ck.hasPkgbase(pkgpath, pkgbase) {
+			seen[pkgpath] = true
+			candidates = append(candidates, pkgpath)
+		}
+	}
+
+	for _, alternative := range expandCurlyBraces(pattern) {
+		parser := NewMkParser(nil, alternative)
+		pp := ParsePackagePattern(parser)
+		if pp == nil || parser.Rest() != "" {
+			return nil, false
+		}
+
+		for _, pkgpath := range ck.packages {
+			add(pkgpath, pp.Pkgbase)
+		}
+		if ck.fullTree {
+			continue
+		}
+		if strings.ContainsAny(pp.Pkgbase, "*?[") {
+			return nil, false
+		}
+		for _, category := range G.Pkgsrc.ReadDir(".") {
+			pkgpath := NewPkgsrcPath(NewPath(category.Name())).JoinNoClean(NewRelPathString(pp.Pkgbase))
+			if category.IsDir() && G.Pkgsrc.File(pkgpath.JoinNoClean("Makefile")).IsFile() {
+				add(pkgpath, pp.Pkgbase)
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+	return candidates, true
+}
+
+// hasPkgbase returns whether the package base of the package matches
+// the given pattern, which may contain shell wildcards.
+func (ck *ConflictChecker) hasPkgbase(pkgpath PkgsrcPath, pattern string) bool {
+	_, pkgbase, _ := matchPkgname(ck.pkgname(pkgpath))
+	ok, err := path.Match(pattern, pkgbase)
+	return pkgbase != "" && ok && err == nil
+}
+
+// pkgname returns the package name of the package, preferring the
+// name from the checked package over the evaluated one.
+func (ck *ConflictChecker) pkgname(pkgpath PkgsrcPath) string {
+	if pkgname, found := ck.pkgnames[pkgpath]; found {
+		return pkgname
+	}
+	return G.Pkgsrc.Pkgname(pkgpath)
+}
+
+// patterns returns the CONFLICTS patterns of the package, or false if
+// they cannot be determined.
+func (ck *ConflictChecker) patterns(pkgpath PkgsrcPath) ([]string, bool) {
+	if _, found := ck.pkgnames[pkgpath]; found {
+		return ck.conflictPatterns(pkgpath), true
+	}
+	return G.Pkgsrc.Evaluate(pkgpath).Conflicts()
+}
+
+func (ck *ConflictChecker) conflictPatterns(pkgpath PkgsrcPath) []string {
+	var patterns []string
+	for _, conflict := range ck.conflicts[pkgpath] {
+		patterns = append(patterns, conflict.Pattern)
+	}
+	return patterns
+}
+
+// conflictsWith returns whether one of the patterns matches the
+// package name.
+//
+// Patterns that cannot be evaluated are assumed to match,
+// to avoid wrong warnings.
+func conflictsWith(patterns []string, pkgname string) bool {
+	for _, pattern := range patterns {
+		if _, matches := packagePatternMatches(pattern, pkgname); matches {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+// newConflictsForTest creates a CONFLICTS declaration for each pattern,
+// at consecutive lines of the given Makefile.
+func newConflictsForTest(t *Tester, filename CurrPath, patterns ...string) []*Conflict {
+	var conflicts []*Conflict
+	for i, pattern := range patterns {
+		line := t.NewLine(filename, 20+i, "CONFLICTS+=\t"+pattern)
+		conflicts = append(conflicts, &Conflict{pattern, line})
+	}
+	return conflicts
+}
+
+// newPlistForTest creates the PLIST content for the given paths.
+func newPlistForTest(t *Tester, filename CurrPath, paths ...RelPath) map[RelPath]*PlistLine {
+	plist := make(map[RelPath]*PlistLine)
+	for i, p := range paths {
+		line := t.NewLine(filename, 2+i, p.String())
+		plist[p] = &PlistLine{line, nil, p.String()}
+	}
+	return plist
+}
+
+func (s *Suite) Test_NewConflictChecker(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewConflictChecker()
+
+	t.CheckLen(ck.packages, 0)
+	t.CheckLen(ck.shared, 0)
+}
+
+func (s *Suite) Test_ConflictChecker_AddPackage(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewConflictChecker()
+	plistA := newPlistForTest(t, t.File("category/a/PLIST"),
+		"bin/a", "bin/common", "${PKGMANDIR}/man1/common.1")
+	plistB := newPlistForTest(t, t.File("category/b/PLIST"),
+		"bin/b", "bin/common", "${PKGMANDIR}/man1/common.1")
+
+	ck.AddPackage("category/a", "a-1.0", nil, plistA)
+	ck.AddPackage("category/b", "b-1.0", nil, plistB)
+	ck.AddPackage("category/b", "b-1.0", nil, plistB)
+
+	// The second call for category/b is ignored.
+	// Paths containing expressions are not compared.
+	t.CheckDeepEquals(ck.packages, []PkgsrcPath{"category/a", "category/b"})
+	t.CheckLen(ck.shared, 1)
+	t.CheckEquals(ck.shared[0].path, RelPath("bin/common"))
+	t.CheckEquals(ck.shared[0].first.pkgpath, PkgsrcPath("category/a"))
+	t.CheckEquals(ck.shared[0].second.line.Linenos(), "3")
+}
+
+func (s *Suite) Test_ConflictChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/a",
+		"CONFLICTS+=\tb-[0-9]*")
+	t.SetUpPackage("category/b")
+	t.SetUpPackage("category/c",
+		"CONFLICTS+=\tremoved-[0-9]*")
+	t.SetUpPackage("category/d")
+	t.CreateFileLines("category/c/PLIST",
+		PlistCvsID,
+		"bin/c")
+	t.CreateFileLines("category/d/PLIST",
+		PlistCvsID,
+		"bin/c")
+	t.CreateFileLines("Makefile",
+		MkCvsID,
+		"SUBDIR+=\tcategory")
+	t.CreateFileLines("mk/misc/category.mk")
+	t.Chdir(".")
+
+	t.Main("-Cglobal", "-r", "-q", ".")
+
+	// The note about the pattern that doesn't match any package is only
+	// given when the whole tree is checked.
+	t.CheckOutputLines(
+		"WARN: category/b/DESCR: DESCR file is the same as \"../../category/a/DESCR\".",
+		"WARN: category/c/DESCR: DESCR file is the same as \"../../category/b/DESCR\".",
+		"WARN: category/d/DESCR: DESCR file is the same as \"../../category/c/DESCR\".",
+		"WARN: category/a/Makefile:20: The package \"b-1.0\" from ../../category/b "+
+			"should also conflict with \"a-[0-9]*\".",
+		"NOTE: category/c/Makefile:20: No package from the pkgsrc tree "+
+			"matches the CONFLICTS pattern \"removed-[0-9]*\".",
+		"WARN: category/d/PLIST:2: The file \"bin/c\" is also installed "+
+			"by ../../category/c, without a declared conflict.",
+		"WARN: licenses/gnu-gpl-v2: This license seems to be unused.")
+}
+
+func (s *Suite) Test_ConflictChecker_checkConflict(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/a")
+	t.SetUpPackage("category/symmetric",
+		"CONFLICTS+=\ta-[0-9]*")
+	t.SetUpPackage("category/asymmetric")
+	t.SetUpPackage("category/indeterminate",
+		".if ${OPSYS} == NetBSD",
+		"CONFLICTS+=\ta-[0-9]*",
+		".endif")
+	t.SetUpPackage("category/a-old",
+		"PKGNAME=\ta-0.9")
+	t.FinishSetUp()
+	filename := t.File("category/a/Makefile")
+	ck := NewConflictChecker()
+	ck.AddPackage("category/a", "a-1.0", nil, nil)
+
+	test := func(pattern string, diagnostics ...string) {
+		conflicts := newConflictsForTest(t, filename, pattern)
+		ck.checkConflict("category/a", conflicts[0])
+		t.CheckOutput(diagnostics)
+	}
+
+	test("symmetric-[0-9]*",
+		nil...)
+
+	test("asymmetric-[0-9]*",
+		"WARN: ~/category/a/Makefile:20: The package \"asymmetric-1.0\" "+
+			"from ../../category/asymmetric should also conflict with \"a-[0-9]*\".")
+
+	// The package itself doesn't count as a match, and packages with
+	// the same PKGBASE conflict implicitly.
+	test("a-[0-9]*",
+		nil...)
+
+	// When the CONFLICTS of the other package cannot be determined,
+	// there is no warning.
+	test("indeterminate-[0-9]*",
+		nil...)
+
+	// Patterns that cannot be evaluated are skipped.
+	test("asymmetric-[0-9]*-suffix-",
+		nil...)
+
+	// Unless the whole tree is checked, a package with the given
+	// package base may still exist in a differently named directory.
+	test("removed-[0-9]*",
+		nil...)
+
+	ck.AddPackage("category/asymmetric", "asymmetric-1.0", nil, nil)
+	ck.fullTree = true
+
+	test("removed-[0-9]*",
+		"NOTE: ~/category/a/Makefile:20: No package from the pkgsrc tree "+
+			"matches the CONFLICTS pattern \"removed-[0-9]*\".")
+
+	// Only version 1.0 of the package is in the tree.
+	test("asymmetric<1.0",
+		"NOTE: ~/category/a/Makefile:20: No package from the pkgsrc tree "+
+			"matches the CONFLICTS pattern \"asymmetric<1.0\".")
+}
+
+func (s *Suite) Test_ConflictChecker_checkConflict__wip(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/other")
+	t.SetUpPackage("wip/other-wip")
+	t.FinishSetUp()
+	ck := NewConflictChecker()
+	ck.AddPackage("wip/package", "package-1.0",
+		newConflictsForTest(t, t.File("wip/package/Makefile"),
+			"other-[0-9]*",
+			"other-wip-[0-9]*"),
+		nil)
+
+	ck.Check()
+
+	// Packages outside wip don't need to know about the packages
+	// from wip, therefore only the second pattern produces a warning.
+	t.CheckOutputLines(
+		"WARN: ~/wip/package/Makefile:21: The package \"other-wip-1.0\" " +
+			"from ../../wip/other-wip should also conflict with \"package-[0-9]*\".")
+}
+
+func (s *Suite) Test_ConflictChecker_checkSharedFiles(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewConflictChecker()
+	add := func(pkgpath PkgsrcPath, pkgname string, conflicts []string, paths ...RelPath) {
+		dir := t.File(pkgpath.AsRelPath())
+		ck.AddPackage(pkgpath, pkgname,
+			newConflictsForTest(t, dir.JoinNoClean("Makefile"), conflicts...),
+			newPlistForTest(t, dir.JoinNoClean("PLIST"), paths...))
+	}
+
+	add("category/a", "a-1.0", nil, "bin/a", "bin/b", "bin/c", "bin/d", "bin/e")
+	add("category/b", "b-1.0", nil, "bin/b", "bin/bb")
+	add("category/c", "c-1.0", []string{"a-[0-9]*"}, "bin/c")
+	add("category/d", "d-1.0", nil, "bin/d")
+	add("category/a2", "a-2.0", nil, "bin/e")
+	add("wip/e", "e-1.0", nil, "bin/a")
+	add("category/bb", "bb-1.0", nil, "bin/bb", "bin/b")
+
+	ck.checkSharedFiles()
+
+	// The packages category/c and category/a declare a conflict.
+	// The packages category/a2 and category/a have the same PKGBASE.
+	// Files from wip packages are not compared to the main packages.
+	// The conflict between category/bb and category/b is reported
+	// only once.
+	t.CheckOutputLines(
+		"WARN: ~/category/b/PLIST:2: The file \"bin/b\" is also installed "+
+			"by ../../category/a, without a declared conflict.",
+		"WARN: ~/category/d/PLIST:2: The file \"bin/d\" is also installed "+
+			"by ../../category/a, without a declared conflict.",
+		"WARN: ~/category/bb/PLIST:3: The file \"bin/b\" is also installed "+
+			"by ../../category/a, without a declared conflict.",
+		"WARN: ~/category/bb/PLIST:2: The file \"bin/bb\" is also installed "+
+			"by ../../category/b, without a declared conflict.")
+}
+
+func (s *Suite) Test_ConflictChecker_candidates(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/lib")
+	t.SetUpPackage("category/lib2",
+		"PKGNAME=\tlib-2.0")
+	t.SetUpPackage("category/libx")
+	t.SetUpPackage("category/other")
+	t.FinishSetUp()
+	ck := NewConflictChecker()
+
+	test := func(pattern string, expected []PkgsrcPath, expectedOK bool) {
+		candidates, ok := ck.candidates(pattern)
+		t.CheckDeepEquals(candidates, expected)
+		t.CheckEquals(ok, expectedOK)
+	}
+
+	// Only the packages whose directory is named like the package base
+	// are looked up in the tree, plus the checked packages.
+	test("lib-[0-9]*", []PkgsrcPath{"category/lib"}, true)
+	test("{lib,other}-[0-9]*", []PkgsrcPath{"category/lib", "category/other"}, true)
+	test("unknown-[0-9]*", []PkgsrcPath(nil), true)
+	test("lib*-[0-9]*", []PkgsrcPath(nil), false)
+	test("${PKGBASE}-[0-9]*", []PkgsrcPath(nil), false)
+	test("lib-[0-9]*-suffix-", []PkgsrcPath(nil), false)
+
+	ck.AddPackage("category/lib2", "lib-2.0", nil, nil)
+
+	test("lib>=2", []PkgsrcPath{"category/lib", "category/lib2"}, true)
+
+	// When the whole tree is checked, all packages have been added
+	// to the checker, and the tree is not consulted anymore.
+	ck.AddPackage("category/libx", "libx-1.0", nil, nil)
+	ck.fullTree = true
+
+	test("lib-[0-9]*", []PkgsrcPath{"category/lib2"}, true)
+	test("lib*-[0-9]*", []PkgsrcPath{"category/lib2", "category/libx"}, true)
+}
+
+func (s *Suite) Test_ConflictChecker_hasPkgbase(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewConflictChecker()
+	ck.AddPackage("category/lib", "lib-1.0nb2", nil, nil)
+	ck.AddPackage("category/unknown", "", nil, nil)
+
+	t.CheckEquals(ck.hasPkgbase("category/lib", "lib"), true)
+	t.CheckEquals(ck.hasPkgbase("category/lib", "l*"), true)
+	t.CheckEquals(ck.hasPkgbase("category/lib", "lib-1.0"), false)
+	t.CheckEquals(ck.hasPkgbase("category/lib", "[malformed"), false)
+	t.CheckEquals(ck.hasPkgbase("category/unknown", "*"), false)
+}
+
+func (s *Suite) Test_ConflictChecker_pkgname(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+	ck := NewConflictChecker()
+	ck.AddPackage("category/checked", "checked-1.0nb1", nil, nil)
+
+	t.CheckEquals(ck.pkgname("category/checked"), "checked-1.0nb1")
+	t.CheckEquals(ck.pkgname("category/package"), "package-1.0")
+	t.CheckEquals(ck.pkgname("category/unknown"), "")
+}
+
+func (s *Suite) Test_ConflictChecker_patterns(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"CONFLICTS=\tother-[0-9]*")
+	t.FinishSetUp()
+	ck := NewConflictChecker()
+	ck.AddPackage("category/checked", "checked-1.0",
+		newConflictsForTest(t, t.File("category/checked/Makefile"), "checked-old-[0-9]*"),
+		nil)
+
+	test := func(pkgpath PkgsrcPath, expected []string) {
+		patterns, ok := ck.patterns(pkgpath)
+		t.CheckDeepEquals(patterns, expected)
+		t.CheckEquals(ok, true)
+	}
+
+	test("category/checked", []string{"checked-old-[0-9]*"})
+	test("category/package", []string{"other-[0-9]*"})
+}
+
+func (s *Suite) Test_ConflictChecker_conflictPatterns(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewConflictChecker()
+	ck.AddPackage("category/package", "package-1.0",
+		newConflictsForTest(t, "Makefile", "a-[0-9]*", "b>=2"),
+		nil)
+
+	t.CheckDeepEquals(ck.conflictPatterns("category/package"), []string{"a-[0-9]*", "b>=2"})
+	t.CheckDeepEquals(ck.conflictPatterns("category/unknown"), []string(nil))
+}
+
+func (s *Suite) Test_conflictsWith(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(conflictsWith(nil, "package-1.0"), false)
+	t.CheckEquals(conflictsWith([]string{"other-[0-9]*", "package-[0-9]*"}, "package-1.0"), true)
+	t.CheckEquals(conflictsWith([]string{"package>=2"}, "package-1.0"), false)
+
+	// Patterns that cannot be evaluated are assumed to match.
+	t.CheckEquals(conflictsWith([]string{"${PKGBASE}-[0-9]*"}, "package-1.0"), true)
+}
@@ -0,0 +1,273 @@
+package pkglint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Dependency is an edge in the DependencyGraph.
+type Dependency struct {
+	// Kind is DEPENDS, BUILD_DEPENDS, TOOL_DEPENDS, TEST_DEPENDS
+	// or buildlink3 for an included buildlink3.mk file.
+	Kind string
+
+	// Pattern is the package pattern, such as "pkgbase>=1.0".
+	// For buildlink3 inclusions, it is the BUILDLINK_API_DEPENDS
+	// from the buildlink3.mk file, if known.
+	Pattern string
+
+	Target PkgsrcPath // e.g. "devel/gettext-lib"
+	Line   *Line      // Where the dependency is declared
+
+	// Conditional is true if the dependency is only added in some
+	// cases, such as for a package option or an operating system.
+	Conditional bool
+
+	// BuildOnly is true for buildlink3 inclusions whose
+	// BUILDLINK_DEPMETHOD is "build".
+	BuildOnly bool
+}
+
+// DependencyGraph collects the dependencies between the packages,
+// for finding dependency cycles and for --dump-depgraph.
+type DependencyGraph struct {
+	deps     map[PkgsrcPath][]*Dependency
+	packages []PkgsrcPath // In the order in which they have been added
+}
+
+func NewDependencyGraph() *DependencyGraph {
+	return &DependencyGraph{make(map[PkgsrcPath][]*Dependency), nil}
+}
+
+// AddPackage adds the package to the graph, even if it has no
+// dependencies at all.
+func (g *DependencyGraph) AddPackage(pkgpath PkgsrcPath) {
+	if _, found := g.deps[pkgpath]; !found {
+		g.deps[pkgpath] = nil
+		g.packages = append(g.packages, pkgpath)
+	}
+}
+
+// Add remembers that the package depends on dep.Target.
+// Of several dependencies of the same kind on the same target,
+// only the first one is kept.
+func (g *DependencyGraph) Add(pkgpath PkgsrcPath, dep *Dependency) {
+	g.AddPackage(pkgpath)
+	for _, existing := range g.deps[pkgpath] {
+		if existing.Kind == dep.Kind && existing.Target == dep.Target {
+			return
+		}
+	}
+	g.deps[pkgpath] = append(g.deps[pkgpath], dep)
+}
+
+// Packages returns the packages from the graph, sorted by name.
+func (g *DependencyGraph) Packages() []PkgsrcPath {
+	packages := append([]PkgsrcPath(nil), g.packages...)
+	sort.Slice(packages, func(i, j int) bool { return packages[i] < packages[j] })
+	return packages
+}
+
+// Contains returns whether the package has been added to the graph.
+func (g *DependencyGraph) Contains(pkgpath PkgsrcPath) bool {
+	_, found := g.deps[pkgpath]
+	return found
+}
+
+// Dependencies returns the direct dependencies of the package,
+// in the order in which they appear in the package files.
+func (g *DependencyGraph) Dependencies(pkgpath PkgsrcPath) []*Dependency {
+	return g.deps[pkgpath]
+}
+
+// Dependents returns the packages that have a direct dependency of
+// the given kind on the target package, sorted by package path.
+func (g *DependencyGraph) Dependents(target PkgsrcPath, kind string) []PkgsrcPath {
+	var dependents []PkgsrcPath
+	for _, pkgpath := range g.Packages() {
+		for _, dep := range g.deps[pkgpath] {
+			if dep.Kind == kind && dep.Target == target {
+				dependents = append(dependents, pkgpath)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// Cycles returns the dependency cycles, one for each group of packages
+// that depend on each other. Each cycle starts and ends with the
+// alphabetically first package of the group.
+//
+// Test dependencies are ignored since they don't prevent the
+// packages from being built.
+func (g *DependencyGraph) Cycles() [][]*Dependency {
+	var cycles [][]*Dependency
+	for _, component := range g.stronglyConnected() {
+		start := component[0]
+		cycle := g.shortestCycle(start, component)
+		if cycle != nil {
+			cycles = append(cycles, cycle)
+		}
+	}
+	return cycles
+}
+
+// edges returns the dependencies that are relevant for building the
+// package, each target only once.
+func (g *DependencyGraph) edges(pkgpath PkgsrcPath) []*Dependency {
+	var edges []*Dependency
+	seen := make(map[PkgsrcPath]bool)
+	for _, dep := range g.deps[pkgpath] {
+		if dep.Kind != "TEST_DEPENDS" && !seen[dep.Target] {
+			seen[dep.Target] = true
+			edges = append(edges, dep)
+		}
+	}
+	return edges
+}
+
+// stronglyConnected returns the groups of packages that depend on each
+// other, using Tarjan's algorithm. Each group is sorted, and the groups
+// are sorted by their first package.
+func (g *DependencyGraph) stronglyConnected() [][]PkgsrcPath {
+	index := make(map[PkgsrcPath]int)
+	lowlink := make(map[PkgsrcPath]int)
+	onStack := make(map[PkgsrcPath]bool)
+	var stack []PkgsrcPath
+	var components [][]PkgsrcPath
+
+	var visit func(pkgpath PkgsrcPath)
+	visit = func(pkgpath PkgsrcPath) {
+		index[pkgpath] = len(index)
+		lowlink[pkgpath] = index[pkgpath]
+		stack = append(stack, pkgpath)
+		onStack[pkgpath] = true
+
+		for _, dep := range g.edges(pkgpath) {
+			if _, visited := index[dep.Target]; !visited {
+				visit(dep.Target)
+				lowlink[pkgpath] = min(lowlink[pkgpath], lowlink[dep.Target])
+			} else if onStack[dep.Target] {
+				lowlink[pkgpath] = min(lowlink[pkgpath], index[dep.Target])
+			}
+		}
+
+		if lowlink[pkgpath] != index[pkgpath] {
+			return
+		}
+		var component []PkgsrcPath
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == pkgpath {
+				break
+			}
+		}
+		components = append(components, component)
+	}
+
+	for _, pkgpath := range g.Packages() {
+		if _, visited := index[pkgpath]; !visited {
+			visit(pkgpath)
+		}
+	}
+
+	for _, component := range components {
+		sort.Slice(component, func(i, j int) bool { return component[i] < component[j] })
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+// shortestCycle returns the shortest path from start back to start,
+// staying inside the component, or nil if there is none, which happens
+// for a single package that doesn't depend on itself.
+func (g *DependencyGraph) shortestCycle(start PkgsrcPath, component []PkgsrcPath) []*Dependency {
+	inComponent := make(map[PkgsrcPath]bool)
+	for _, pkgpath := range component {
+		inComponent[pkgpath] = true
+	}
+
+	via := make(map[PkgsrcPath]*Dependency)
+	from := make(map[*Dependency]PkgsrcPath)
+	queue := []PkgsrcPath{start}
+	for len(queue) > 0 {
+		pkgpath := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.edges(pkgpath) {
+			if !inComponent[dep.Target] || via[dep.Target] != nil {
+				continue
+			}
+			via[dep.Target] = dep
+			from[dep] = pkgpath
+			if dep.Target == start {
+				var cycle []*Dependency
+				for d := dep; ; d = via[from[d]] {
+					cycle = append([]*Dependency{d}, cycle...)
+					if from[d] == start {
+						return cycle
+					}
+				}
+			}
+			queue = append(queue, dep.Target)
+		}
+	}
+	return nil
+}
+
+// Dump writes the graph in the given format, which is either "dot"
+// for Graphviz or "json".
+func (g *DependencyGraph) Dump(out io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return g.dumpDot(out)
+	case "json":
+		return g.dumpJSON(out)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func (g *DependencyGraph) dumpDot(out io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph dependencies {\n")
+	for _, pkgpath := range g.Packages() {
+		sb.WriteString(sprintf("\t%q;\n", pkgpath.String()))
+		for _, dep := range g.deps[pkgpath] {
+			sb.WriteString(sprintf("\t%q -> %q [label=%q];\n",
+				pkgpath.String(), dep.Target.String(), dep.Kind))
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(out, sb.String())
+	return err
+}
+
+func (g *DependencyGraph) dumpJSON(out io.Writer) error {
+	type jsonDependency struct {
+		Kind     string `json:"kind"`
+		Pattern  string `json:"pattern,omitempty"`
+		Target   string `json:"target"`
+		Location string `json:"location"`
+	}
+
+	packages := make(map[string][]jsonDependency)
+	for _, pkgpath := range g.Packages() {
+		deps := make([]jsonDependency, 0, len(g.deps[pkgpath]))
+		for _, dep := range g.deps[pkgpath] {
+			loc := G.Pkgsrc.Rel(dep.Line.Filename()).String() + ":" + dep.Line.Linenos()
+			deps = append(deps, jsonDependency{dep.Kind, dep.Pattern, dep.Target.String(), loc})
+		}
+		packages[pkgpath.String()] = deps
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(map[string]interface{}{"packages": packages})
+}
@@ -0,0 +1,262 @@
+package pkglint
+
+import (
+	"gopkg.in/check.v1"
+	"strings"
+)
+
+// newDependencyGraphForTest creates a graph from edges of the form
+// "from KIND to".
+func newDependencyGraphForTest(t *Tester, edges ...string) *DependencyGraph {
+	g := NewDependencyGraph()
+	for i, edge := range edges {
+		fields := strings.Fields(edge)
+		t.CheckEquals(len(fields), 3)
+		line := t.NewLine("Makefile", i+1, edge)
+		g.Add(PkgsrcPath(fields[0]), &Dependency{fields[1], "", PkgsrcPath(fields[2]), line, false, false})
+	}
+	return g
+}
+
+// cyclePaths returns the cycles in the form "a -> b -> a".
+func cyclePaths(cycles [][]*Dependency) []string {
+	var paths []string
+	for _, cycle := range cycles {
+		path := []string{cycle[len(cycle)-1].Target.String()}
+		for _, dep := range cycle {
+			path = append(path, dep.Target.String())
+		}
+		paths = append(paths, strings.Join(path, " -> "))
+	}
+	return paths
+}
+
+func (s *Suite) Test_NewDependencyGraph(c *check.C) {
+	t := s.Init(c)
+
+	g := NewDependencyGraph()
+
+	t.CheckLen(g.Packages(), 0)
+}
+
+func (s *Suite) Test_DependencyGraph_AddPackage(c *check.C) {
+	t := s.Init(c)
+
+	g := NewDependencyGraph()
+	g.AddPackage("devel/b")
+	g.AddPackage("devel/a")
+	g.AddPackage("devel/b")
+
+	t.CheckDeepEquals(g.packages, []PkgsrcPath{"devel/b", "devel/a"})
+}
+
+func (s *Suite) Test_DependencyGraph_Add(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/a DEPENDS devel/b",
+		"devel/a BUILD_DEPENDS devel/b",
+		"devel/a DEPENDS devel/b")
+
+	deps := g.Dependencies("devel/a")
+
+	// The duplicate DEPENDS is ignored.
+	t.CheckLen(deps, 2)
+	t.CheckEquals(deps[0].Kind, "DEPENDS")
+	t.CheckEquals(deps[0].Line.Linenos(), "1")
+	t.CheckEquals(deps[1].Kind, "BUILD_DEPENDS")
+}
+
+func (s *Suite) Test_DependencyGraph_Packages(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"x11/b DEPENDS devel/a")
+	g.AddPackage("devel/c")
+
+	// The target devel/a is only added when it is checked itself.
+	t.CheckDeepEquals(g.Packages(), []PkgsrcPath{"devel/c", "x11/b"})
+}
+
+func (s *Suite) Test_DependencyGraph_Contains(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"x11/b DEPENDS devel/a")
+	g.AddPackage("devel/c")
+
+	t.CheckEquals(g.Contains("x11/b"), true)
+	t.CheckEquals(g.Contains("devel/c"), true)
+	t.CheckEquals(g.Contains("devel/a"), false)
+}
+
+func (s *Suite) Test_DependencyGraph_Dependencies(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/a DEPENDS devel/c",
+		"devel/a DEPENDS devel/b")
+
+	deps := g.Dependencies("devel/a")
+
+	t.CheckLen(deps, 2)
+	t.CheckEquals(deps[0].Target, PkgsrcPath("devel/c"))
+	t.CheckEquals(deps[1].Target, PkgsrcPath("devel/b"))
+	t.CheckLen(g.Dependencies("devel/unknown"), 0)
+}
+
+func (s *Suite) Test_DependencyGraph_Dependents(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"x11/b DEPENDS devel/a",
+		"devel/c BUILD_DEPENDS devel/a",
+		"devel/d DEPENDS devel/a",
+		"devel/d DEPENDS devel/c")
+
+	t.CheckDeepEquals(g.Dependents("devel/a", "DEPENDS"),
+		[]PkgsrcPath{"devel/d", "x11/b"})
+	t.CheckDeepEquals(g.Dependents("devel/a", "BUILD_DEPENDS"),
+		[]PkgsrcPath{"devel/c"})
+	t.CheckDeepEquals(g.Dependents("devel/unknown", "DEPENDS"),
+		[]PkgsrcPath(nil))
+}
+
+func (s *Suite) Test_DependencyGraph_Cycles(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/c DEPENDS devel/b",
+		"devel/b BUILD_DEPENDS devel/a",
+		"devel/a TOOL_DEPENDS devel/c",
+		"devel/a DEPENDS devel/d",
+		"devel/d DEPENDS devel/d",
+		"devel/e TEST_DEPENDS devel/f",
+		"devel/f DEPENDS devel/e")
+
+	cycles := g.Cycles()
+
+	t.CheckDeepEquals(cyclePaths(cycles), []string{
+		"devel/a -> devel/c -> devel/b -> devel/a",
+		"devel/d -> devel/d"})
+	t.CheckEquals(cycles[0][0].Line.Linenos(), "3")
+}
+
+func (s *Suite) Test_DependencyGraph_Cycles__shortest(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/a DEPENDS devel/b",
+		"devel/b DEPENDS devel/c",
+		"devel/c DEPENDS devel/a",
+		"devel/b DEPENDS devel/a")
+
+	cycles := g.Cycles()
+
+	// Only one cycle is reported per group of packages.
+	t.CheckDeepEquals(cyclePaths(cycles), []string{
+		"devel/a -> devel/b -> devel/a"})
+}
+
+func (s *Suite) Test_DependencyGraph_edges(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/a DEPENDS devel/b",
+		"devel/a BUILD_DEPENDS devel/b",
+		"devel/a TEST_DEPENDS devel/c",
+		"devel/a buildlink3 devel/d")
+
+	edges := g.edges("devel/a")
+
+	t.CheckLen(edges, 2)
+	t.CheckEquals(edges[0].Kind, "DEPENDS")
+	t.CheckEquals(edges[1].Target, PkgsrcPath("devel/d"))
+}
+
+func (s *Suite) Test_DependencyGraph_stronglyConnected(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/z DEPENDS devel/y",
+		"devel/y DEPENDS devel/z",
+		"devel/a DEPENDS devel/y")
+
+	components := g.stronglyConnected()
+
+	t.CheckDeepEquals(components, [][]PkgsrcPath{
+		{"devel/a"},
+		{"devel/y", "devel/z"}})
+}
+
+func (s *Suite) Test_DependencyGraph_shortestCycle(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/a DEPENDS devel/b",
+		"devel/b DEPENDS devel/a",
+		"devel/c DEPENDS devel/d")
+
+	t.CheckDeepEquals(
+		cyclePaths([][]*Dependency{g.shortestCycle("devel/a", []PkgsrcPath{"devel/a", "devel/b"})}),
+		[]string{"devel/a -> devel/b -> devel/a"})
+	t.CheckLen(g.shortestCycle("devel/c", []PkgsrcPath{"devel/c"}), 0)
+}
+
+func (s *Suite) Test_DependencyGraph_Dump(c *check.C) {
+	t := s.Init(c)
+
+	g := NewDependencyGraph()
+	var sb strings.Builder
+
+	t.CheckEquals(g.Dump(&sb, "dot"), nil)
+	t.CheckEquals(g.Dump(&sb, "svg").Error(), "unknown format \"svg\"")
+	t.CheckEquals(sb.String(), "digraph dependencies {\n}\n")
+}
+
+func (s *Suite) Test_DependencyGraph_dumpDot(c *check.C) {
+	t := s.Init(c)
+
+	g := newDependencyGraphForTest(t,
+		"devel/a DEPENDS devel/b",
+		"devel/a TEST_DEPENDS devel/c")
+	var sb strings.Builder
+
+	t.CheckEquals(g.dumpDot(&sb), nil)
+
+	t.CheckEquals(sb.String(), ""+
+		"digraph dependencies {\n"+
+		"\t\"devel/a\";\n"+
+		"\t\"devel/a\" -> \"devel/b\" [label=\"DEPENDS\"];\n"+
+		"\t\"devel/a\" -> \"devel/c\" [label=\"TEST_DEPENDS\"];\n"+
+		"}\n")
+}
+
+func (s *Suite) Test_DependencyGraph_dumpJSON(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	g := NewDependencyGraph()
+	g.AddPackage("devel/b")
+	line := t.NewLine(t.File("devel/a/buildlink3.mk"), 13, "")
+	g.Add("devel/a", &Dependency{"buildlink3", "b>=1.0", "devel/b", line, false, false})
+	var sb strings.Builder
+
+	t.CheckEquals(g.dumpJSON(&sb), nil)
+
+	t.CheckEquals(sb.String(), ""+
+		"{\n"+
+		"\t\"packages\": {\n"+
+		"\t\t\"devel/a\": [\n"+
+		"\t\t\t{\n"+
+		"\t\t\t\t\"kind\": \"buildlink3\",\n"+
+		"\t\t\t\t\"pattern\": \"b>=1.0\",\n"+
+		"\t\t\t\t\"target\": \"devel/b\",\n"+
+		"\t\t\t\t\"location\": \"devel/a/buildlink3.mk:13\"\n"+
+		"\t\t\t}\n"+
+		"\t\t],\n"+
+		"\t\t\"devel/b\": []\n"+
+		"\t}\n"+
+		"}\n")
+}
@@ -0,0 +1,364 @@
+package pkglint
+
+import (
+	"github.com/rillig/pkglint/v23/regex"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DestdirChecker compares the PLIST files of a package with the files
+// that are installed in a staged installation directory, for --destdir.
+//
+// The directory is the one that corresponds to ${PREFIX} in the staged
+// installation, such as work/.destdir/usr/pkg.
+//
+// Which of the PLIST conditions are enabled is not known from the
+// package Makefile alone. Therefore, a condition counts as enabled if
+// at least one of its files is installed.
+type DestdirChecker struct {
+	pkg    *Package
+	prefix CurrPath
+
+	// The installed files and directories, relative to the prefix.
+	files     map[RelPath]bool
+	dirs      map[RelPath]bool
+	emptyDirs map[RelPath]bool
+
+	// The values of the variables from PLIST_SUBST.
+	subst map[string]string
+
+	// The paths and @pkgdir directories from all PLIST files of the
+	// package, with the PLIST_SUBST variables expanded.
+	listed  map[RelPath]bool
+	pkgdirs map[RelPath]bool
+
+	// The paths that contain unresolved expressions, as patterns.
+	patterns []regex.Pattern
+
+	// The PLIST conditions for which at least one file is installed.
+	enabled map[string]bool
+}
+
+func NewDestdirChecker(pkg *Package, prefix CurrPath) *DestdirChecker {
+	ck := DestdirChecker{
+		pkg,
+		prefix,
+		make(map[RelPath]bool),
+		make(map[RelPath]bool),
+		make(map[RelPath]bool),
+		make(map[string]string),
+		make(map[RelPath]bool),
+		make(map[RelPath]bool),
+		nil,
+		make(map[string]bool)}
+	ck.scan(prefix, ".")
+	ck.loadSubst()
+	ck.loadPlists()
+	return &ck
+}
+
+// Check compares the lines from a single PLIST file of the package with
+// the installed files.
+//
+// Only the primary PLIST file gets the installed files that are not
+// listed in any of the PLIST files. The returned lines include these
+// files, so that PlistSortChecker.Sort moves them to their place.
+func (ck *DestdirChecker) Check(lines *Lines, plines []*PlistLine) []*PlistLine {
+	vars := ck.pkg.vars
+	if vars.IsDefined("PLIST_SRC") || vars.IsDefined("GENERATE_PLIST") {
+		return plines
+	}
+
+	for _, pline := range plines {
+		switch {
+		case pline.IsPath():
+			ck.checkPath(pline)
+		case hasPrefix(pline.text, "@pkgdir "):
+			ck.checkPkgdir(pline)
+		case hasPrefix(pline.text, "@dirrm "):
+			ck.checkDirrm(pline)
+		}
+	}
+
+	if lines.Len() > 0 && lines.Filename.Clean() == ck.primary().Clean() {
+		plines = ck.addFiles(lines, plines)
+		ck.addDirs(lines)
+	}
+	return plines
+}
+
+func (ck *DestdirChecker) checkPath(pline *PlistLine) {
+	path, ok := ck.expand(pline.text)
+	if !ok || ck.files[path] || !ck.isEnabled(pline.conditions) {
+		return
+	}
+
+	// The files that are only installed on some platforms
+	// cannot be compared.
+	if NewPlistRank(pline.Line.Basename).Rank == 3 {
+		return
+	}
+
+	fix := pline.Autofix()
+	fix.Warnf("The file %q is listed but not installed.", path.String())
+	if len(pline.conditions) == 0 {
+		fix.Delete()
+	}
+	fix.Apply()
+}
+
+func (ck *DestdirChecker) checkPkgdir(pline *PlistLine) {
+	dir, ok := ck.expand(strings.TrimSpace(pline.text[len("@pkgdir "):]))
+	if !ok || ck.dirs[dir] || !ck.isEnabled(pline.conditions) {
+		return
+	}
+
+	fix := pline.Autofix()
+	fix.Warnf("The directory %q is listed as @pkgdir but not installed.", dir.String())
+	if len(pline.conditions) == 0 {
+		fix.Delete()
+	}
+	fix.Apply()
+}
+
+func (ck *DestdirChecker) checkDirrm(pline *PlistLine) {
+	dir, ok := ck.expand(strings.TrimSpace(pline.text[len("@dirrm "):]))
+	if !ok || !ck.emptyDirs[dir] || ck.pkgdirs[dir] {
+		return
+	}
+
+	fix := pline.Autofix()
+	fix.Warnf("The empty directory %q should be listed as @pkgdir instead of @dirrm.", dir.String())
+	fix.Explain(
+		"Directories are removed automatically when they are empty.",
+		"To keep an empty directory in the package, list it as @pkgdir.")
+	if len(pline.conditions) == 0 {
+		// The @pkgdir is added by addDirs.
+		fix.Delete()
+	} else {
+		ck.pkgdirs[dir] = true
+	}
+	fix.Apply()
+}
+
+// addFiles adds the installed files that are not listed in any of
+// the PLIST files to the PLIST, below the header comments.
+// From there, PlistSortChecker.Sort moves them to their place.
+func (ck *DestdirChecker) addFiles(lines *Lines, plines []*PlistLine) []*PlistLine {
+	headerEnd := 0
+	for headerEnd < len(plines) && hasPrefix(plines[headerEnd].text, "@comment") {
+		headerEnd++
+	}
+	anchor := lines.Lines[0]
+	if headerEnd > 0 {
+		anchor = lines.Lines[headerEnd-1]
+	}
+
+	var added []*PlistLine
+	for _, file := range ck.unlisted() {
+		fix := anchor.Autofix()
+		fix.Warnf("The installed file %q is not listed in the PLIST.", file.String())
+		fix.Explain(
+			"Each file that is installed by the package must be listed",
+			"in one of the PLIST files, otherwise it is not registered",
+			"in the package and remains in the file system when the",
+			"package is deinstalled.")
+		fix.Custom(func(showAutofix, autofix bool) {
+			text := file.String()
+			line := NewLine(lines.Filename, anchor.Location.lineno+1, text, &RawLine{text + "\n"})
+			added = append(added, &PlistLine{line, nil, text})
+			fix.Describef(0, "Adding %q to the PLIST.", text)
+		})
+		fix.Apply()
+	}
+	if len(added) == 0 {
+		return plines
+	}
+
+	var addedLines []*Line
+	for _, pline := range added {
+		addedLines = append(addedLines, pline.Line)
+	}
+	lines.Lines = append(lines.Lines[:headerEnd:headerEnd], append(addedLines, lines.Lines[headerEnd:]...)...)
+	return append(plines[:headerEnd:headerEnd], append(added, plines[headerEnd:]...)...)
+}
+
+// addDirs adds the installed empty directories that are not listed
+// as @pkgdir to the end of the PLIST.
+func (ck *DestdirChecker) addDirs(lines *Lines) {
+	var dirs []RelPath
+	for dir := range ck.emptyDirs {
+		if !ck.pkgdirs[dir] {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i] < dirs[j] })
+
+	for _, dir := range dirs {
+		fix := lines.LastLine().Autofix()
+		fix.Warnf("The installed empty directory %q is not listed as @pkgdir.", dir.String())
+		fix.InsertBelow("@pkgdir " + dir.String())
+		fix.Apply()
+	}
+}
+
+// unlisted returns the installed files that are not listed in any of
+// the PLIST files, sorted by path.
+func (ck *DestdirChecker) unlisted() []RelPath {
+	var unlisted []RelPath
+	for file := range ck.files {
+		if !ck.listed[file] && !ck.matchesPattern(file) && file != "info/dir" {
+			unlisted = append(unlisted, file)
+		}
+	}
+	sort.Slice(unlisted, func(i, j int) bool { return unlisted[i] < unlisted[j] })
+	return unlisted
+}
+
+// primary returns the PLIST file to which the unlisted files are added.
+func (ck *DestdirChecker) primary() CurrPath {
+	plist := ck.pkg.File(ck.pkg.Pkgdir.JoinNoClean("PLIST"))
+	if plist.IsFile() {
+		return plist
+	}
+	return ck.pkg.File(ck.pkg.Pkgdir.JoinNoClean("PLIST.common"))
+}
+
+func (ck *DestdirChecker) isEnabled(conditions []string) bool {
+	for _, cond := range conditions {
+		if !ck.enabled[cond] {
+			return false
+		}
+	}
+	return true
+}
+
+func (ck *DestdirChecker) matchesPattern(file RelPath) bool {
+	for _, pattern := range ck.patterns {
+		if matches(file.String(), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces the PLIST_SUBST variables in the path.
+// If some expressions remain unresolved, it returns false.
+func (ck *DestdirChecker) expand(text string) (RelPath, bool) {
+	expanded := replaceAllFunc(text, `\$\{([\w.\-]+)\}`, func(expr string) string {
+		if value, found := ck.subst[expr[2:len(expr)-1]]; found {
+			return value
+		}
+		return expr
+	})
+	if containsExpr(expanded) {
+		return NewRelPathString(expanded), false
+	}
+	return destdirPath(NewRelPathString(expanded)), true
+}
+
+// scan collects the installed files and directories below dir.
+func (ck *DestdirChecker) scan(dir CurrPath, rel RelPath) {
+	entries, err := dir.ReadDir()
+	if err != nil {
+		G.Logger.TechFatalf(dir, "Cannot read directory: %s", err)
+	}
+
+	if len(entries) == 0 && rel != "." {
+		ck.emptyDirs[rel] = true
+	}
+	for _, entry := range entries {
+		name := NewRelPathString(entry.Name())
+		if entry.IsDir() {
+			ck.dirs[rel.JoinNoClean(name).CleanDot()] = true
+			ck.scan(dir.JoinNoClean(name), rel.JoinNoClean(name).CleanDot())
+		} else {
+			ck.files[destdirPath(rel.JoinNoClean(name).CleanDot())] = true
+		}
+	}
+}
+
+// loadSubst collects the variables from PLIST_SUBST, together with the
+// variables that the pkgsrc infrastructure provides by default.
+func (ck *DestdirChecker) loadSubst() {
+	pkg := ck.pkg
+	ck.subst["PKGMANDIR"] = "man"
+	ck.subst["PKGLOCALEDIR"] = "share"
+	if pkg.EffectivePkgbase != "" && pkg.EffectivePkgversion != "" {
+		ck.subst["PKGBASE"] = pkg.EffectivePkgbase
+		ck.subst["PKGVERSION"] = pkg.EffectivePkgversion
+		ck.subst["PKGNAME"] = pkg.EffectivePkgbase + "-" + pkg.EffectivePkgversion
+	}
+
+	for _, field := range strings.Fields(pkg.vars.LastValue("PLIST_SUBST")) {
+		m, varname, value := match2(field, `^([\w.\-]+)=(.*)$`)
+		if !m {
+			continue
+		}
+		value = strings.Trim(resolveExprs(value, nil, pkg), `"'`)
+		if !containsExpr(value) {
+			ck.subst[varname] = value
+		}
+	}
+}
+
+// loadPlists collects the listed paths and the enabled conditions
+// from all PLIST files of the package.
+func (ck *DestdirChecker) loadPlists() {
+	var plines []*PlistLine
+	for _, filename := range ck.pkg.File(ck.pkg.Pkgdir).ReadPaths() {
+		if filename.Base().HasPrefixText("PLIST") && filename.IsFile() {
+			if lines := Load(filename, 0); lines != nil {
+				plines = append(plines, extractPlistConditions(lines)...)
+			}
+		}
+	}
+
+	for _, pline := range plines {
+		var text string
+		switch {
+		case pline.IsPath():
+			text = pline.text
+		case hasPrefix(pline.text, "@pkgdir "):
+			text = strings.TrimSpace(pline.text[len("@pkgdir "):])
+		default:
+			continue
+		}
+
+		path, ok := ck.expand(text)
+		switch {
+		case !ok:
+			ck.patterns = append(ck.patterns, destdirPattern(path))
+		case pline.IsPath():
+			ck.listed[path] = true
+			if ck.files[path] {
+				for _, cond := range pline.conditions {
+					ck.enabled[cond] = true
+				}
+			}
+		default:
+			ck.pkgdirs[path] = true
+		}
+	}
+}
+
+// destdirPath returns the path without the .gz extension of manual
+// pages, since whether they are compressed is configured by the
+// pkgsrc user.
+func destdirPath(path RelPath) RelPath {
+	if path.HasPrefixPath("man") && path.HasSuffixText(".gz") {
+		return NewRelPathString(strings.TrimSuffix(path.String(), ".gz"))
+	}
+	return path
+}
+
+// destdirPattern returns a pattern that matches the path, in which
+// each unresolved expression can stand for any text.
+func destdirPattern(path RelPath) regex.Pattern {
+	parts := regcomp(`\$\{[^}]*\}`).Split(path.String(), -1)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regex.Pattern("^" + strings.Join(parts, ".*") + "$")
+}
@@ -0,0 +1,518 @@
+package pkglint
+
+import (
+	"github.com/rillig/pkglint/v23/regex"
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) Test_NewDestdirChecker(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"${PLIST.doc}share/doc/package/README",
+		"@pkgdir var/empty")
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"share/doc/package/README",
+		"var/empty/")
+	t.Chdir(".")
+
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+
+	t.CheckDeepEquals(ck.files, map[RelPath]bool{
+		"bin/program":              true,
+		"share/doc/package/README": true})
+	t.CheckDeepEquals(ck.emptyDirs, map[RelPath]bool{"var/empty": true})
+	t.CheckDeepEquals(ck.listed, map[RelPath]bool{
+		"bin/program":              true,
+		"share/doc/package/README": true})
+	t.CheckDeepEquals(ck.pkgdirs, map[RelPath]bool{"var/empty": true})
+	t.CheckDeepEquals(ck.enabled, map[string]bool{"PLIST.doc": true})
+}
+
+func (s *Suite) Test_DestdirChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/missing",
+		"bin/program",
+		"man/man1/program.1",
+		"@pkgdir share/empty",
+		"@dirrm share/removed")
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"bin/extra",
+		"man/man1/program.1.gz",
+		"lib/libprogram.a",
+		"share/empty/",
+		"share/other/",
+		"share/removed/")
+	t.Chdir(".")
+
+	t.Main("--destdir=destdir", "-q", "category/package")
+	t.Main("--destdir=destdir", "-q", "--autofix", "category/package")
+
+	// The missing files are added below the header,
+	// and from there, the PLIST is sorted as a whole.
+	t.CheckOutputLines(
+		"WARN: category/package/PLIST:6: @dirrm is obsolete. Remove this line.",
+		"WARN: category/package/PLIST:2: "+
+			"The file \"bin/missing\" is listed but not installed.",
+		"WARN: category/package/PLIST:6: "+
+			"The empty directory \"share/removed\" should be listed as @pkgdir instead of @dirrm.",
+		"WARN: category/package/PLIST:1: "+
+			"The installed file \"bin/extra\" is not listed in the PLIST.",
+		"WARN: category/package/PLIST:1: "+
+			"The installed file \"lib/libprogram.a\" is not listed in the PLIST.",
+		"WARN: category/package/PLIST:6: "+
+			"The installed empty directory \"share/other\" is not listed as @pkgdir.",
+		"WARN: category/package/PLIST:6: "+
+			"The installed empty directory \"share/removed\" is not listed as @pkgdir.",
+		"AUTOFIX: category/package/PLIST:2: Deleting this line.",
+		"AUTOFIX: category/package/PLIST:6: Deleting this line.",
+		"AUTOFIX: category/package/PLIST:1: Adding \"bin/extra\" to the PLIST.",
+		"AUTOFIX: category/package/PLIST:1: Adding \"lib/libprogram.a\" to the PLIST.",
+		"AUTOFIX: category/package/PLIST:6: "+
+			"Inserting a line \"@pkgdir share/other\" below this line.",
+		"AUTOFIX: category/package/PLIST:6: "+
+			"Inserting a line \"@pkgdir share/removed\" below this line.",
+		"AUTOFIX: category/package/PLIST:2: Sorting the whole file.")
+	t.CheckFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/extra",
+		"bin/program",
+		"lib/libprogram.a",
+		"man/man1/program.1",
+		"@pkgdir share/empty",
+		"@pkgdir share/other",
+		"@pkgdir share/removed")
+}
+
+func (s *Suite) Test_DestdirChecker_Check__generated(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/missing")
+	t.CreateFileTree("destdir",
+		"bin/extra")
+	t.Chdir(".")
+	t.CreateFileLines("category/package/Makefile",
+		MkCvsID,
+		"",
+		"DISTNAME=\tpackage-1.0",
+		"CATEGORIES=\tcategory",
+		"",
+		"MAINTAINER=\tpkgsrc-users@NetBSD.org",
+		"HOMEPAGE=\t# none",
+		"COMMENT=\tDummy package",
+		"LICENSE=\t2-clause-bsd",
+		"",
+		"GENERATE_PLIST+=\techo bin/extra;",
+		"",
+		".include \"../../mk/bsd.pkg.mk\"")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	lines := Load("category/package/PLIST", MustSucceed)
+
+	ck.Check(lines, extractPlistConditions(lines))
+
+	// Since the PLIST is extended by the package Makefile,
+	// it cannot be compared to the installed files.
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_DestdirChecker_checkPath(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/missing",
+		"${PLIST.doc}share/doc/installed",
+		"${PLIST.doc}share/doc/missing",
+		"${PLIST.x11}share/x11/missing",
+		"${UNKNOWN}/missing")
+	t.CreateFileTree("destdir",
+		"share/doc/installed")
+	t.Chdir(".")
+	t.CreateFileLines("category/package/PLIST.Linux",
+		PlistCvsID,
+		"lib/linux-only")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+
+	for _, filename := range []RelPath{"PLIST", "PLIST.Linux"} {
+		lines := Load(NewCurrPath("category/package").JoinNoClean(filename), MustSucceed)
+		for _, pline := range extractPlistConditions(lines) {
+			if pline.IsPath() {
+				ck.checkPath(pline)
+			}
+		}
+	}
+
+	// The files from disabled conditions, with unresolved expressions
+	// and from platform-specific PLIST files are not reported.
+	t.CheckOutputLines(
+		"WARN: category/package/PLIST:2: "+
+			"The file \"bin/missing\" is listed but not installed.",
+		"WARN: category/package/PLIST:4: "+
+			"The file \"share/doc/missing\" is listed but not installed.")
+}
+
+func (s *Suite) Test_DestdirChecker_checkPkgdir(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"@pkgdir bin",
+		"@pkgdir var/missing",
+		"${PLIST.x11}@pkgdir share/x11")
+	t.CreateFileTree("destdir",
+		"bin/program")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	lines := Load("category/package/PLIST", MustSucceed)
+
+	for _, pline := range extractPlistConditions(lines)[2:] {
+		ck.checkPkgdir(pline)
+	}
+
+	t.CheckOutputLines(
+		"WARN: category/package/PLIST:4: " +
+			"The directory \"var/missing\" is listed as @pkgdir but not installed.")
+}
+
+func (s *Suite) Test_DestdirChecker_checkDirrm(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"@dirrm bin",
+		"@dirrm var/empty",
+		"${PLIST.x11}@dirrm var/x11")
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"var/empty/",
+		"var/x11/")
+	t.Chdir(".")
+	t.SetUpCommandLine("-Wall", "--show-autofix")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	lines := Load("category/package/PLIST", MustSucceed)
+
+	for _, pline := range extractPlistConditions(lines)[2:] {
+		ck.checkDirrm(pline)
+	}
+
+	t.CheckOutputLines(
+		"WARN: category/package/PLIST:4: "+
+			"The empty directory \"var/empty\" should be listed as @pkgdir instead of @dirrm.",
+		"AUTOFIX: category/package/PLIST:4: Deleting this line.")
+
+	// The unconditional @pkgdir is added by addDirs.
+	// The conditional one must be fixed manually,
+	// therefore its warning is not shown with --show-autofix.
+	t.CheckEquals(ck.pkgdirs["var/empty"], false)
+	t.CheckEquals(ck.pkgdirs["var/x11"], true)
+}
+
+func (s *Suite) Test_DestdirChecker_addFiles(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"share/package/file")
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"bin/other",
+		"lib/library",
+		"share/package/file",
+		"share/package/later")
+	t.Chdir(".")
+	t.SetUpCommandLine("-Wall", "--show-autofix")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	lines := Load("category/package/PLIST", MustSucceed)
+
+	plines := ck.addFiles(lines, extractPlistConditions(lines))
+
+	t.CheckOutputLines(
+		"WARN: category/package/PLIST:1: "+
+			"The installed file \"bin/other\" is not listed in the PLIST.",
+		"AUTOFIX: category/package/PLIST:1: "+
+			"Adding \"bin/other\" to the PLIST.",
+		"WARN: category/package/PLIST:1: "+
+			"The installed file \"lib/library\" is not listed in the PLIST.",
+		"AUTOFIX: category/package/PLIST:1: "+
+			"Adding \"lib/library\" to the PLIST.",
+		"WARN: category/package/PLIST:1: "+
+			"The installed file \"share/package/later\" is not listed in the PLIST.",
+		"AUTOFIX: category/package/PLIST:1: "+
+			"Adding \"share/package/later\" to the PLIST.")
+
+	// The files are added below the header.
+	// PlistSortChecker.Sort moves them to their place.
+	var texts []string
+	for _, pline := range plines {
+		texts = append(texts, pline.text)
+	}
+	t.CheckDeepEquals(texts, []string{
+		"@comment $" + "NetBSD$",
+		"bin/other",
+		"lib/library",
+		"share/package/later",
+		"bin/program",
+		"share/package/file"})
+	t.CheckEquals(lines.Len(), 6)
+}
+
+func (s *Suite) Test_DestdirChecker_addDirs(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"@pkgdir var/listed")
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"var/listed/",
+		"var/unlisted/",
+		"var/another/")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	lines := Load("category/package/PLIST", MustSucceed)
+
+	ck.addDirs(lines)
+
+	t.CheckOutputLines(
+		"WARN: category/package/PLIST:3: "+
+			"The installed empty directory \"var/another\" is not listed as @pkgdir.",
+		"WARN: category/package/PLIST:3: "+
+			"The installed empty directory \"var/unlisted\" is not listed as @pkgdir.")
+}
+
+func (s *Suite) Test_DestdirChecker_unlisted(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"${PLIST.doc}share/doc/listed",
+		"lib/${UNKNOWN}/module.so")
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"bin/unlisted",
+		"info/dir",
+		"lib/python3.12/module.so",
+		"share/doc/listed")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+
+	t.CheckDeepEquals(ck.unlisted(), []RelPath{"bin/unlisted"})
+}
+
+func (s *Suite) Test_DestdirChecker_primary(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+
+	t.CheckEquals(ck.primary(), NewCurrPath("category/package/PLIST"))
+
+	t.Remove("category/package/PLIST")
+
+	t.CheckEquals(ck.primary(), NewCurrPath("category/package/PLIST.common"))
+}
+
+func (s *Suite) Test_DestdirChecker_isEnabled(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	ck.enabled["PLIST.doc"] = true
+
+	t.CheckEquals(ck.isEnabled(nil), true)
+	t.CheckEquals(ck.isEnabled([]string{"PLIST.doc"}), true)
+	t.CheckEquals(ck.isEnabled([]string{"PLIST.doc", "PLIST.x11"}), false)
+}
+
+func (s *Suite) Test_DestdirChecker_matchesPattern(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	ck.patterns = append(ck.patterns, destdirPattern("lib/${PYVERSION}/module.so"))
+
+	t.CheckEquals(ck.matchesPattern("lib/3.12/module.so"), true)
+	t.CheckEquals(ck.matchesPattern("lib/module.so"), false)
+}
+
+func (s *Suite) Test_DestdirChecker_expand(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+	ck.subst["SUBDIR"] = "sub"
+
+	test := func(text string, expected RelPath, expectedOK bool) {
+		actual, ok := ck.expand(text)
+		t.CheckEquals(actual, expected)
+		t.CheckEquals(ok, expectedOK)
+	}
+
+	test("bin/program", "bin/program", true)
+	test("${PKGMANDIR}/man1/program.1.gz", "man/man1/program.1", true)
+	test("share/${SUBDIR}/file", "share/sub/file", true)
+	test("share/${UNKNOWN}/file", "share/${UNKNOWN}/file", false)
+}
+
+func (s *Suite) Test_DestdirChecker_scan(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"man/man1/program.1.gz",
+		"share/empty/")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+
+	t.CheckDeepEquals(ck.files, map[RelPath]bool{
+		"bin/program":        true,
+		"man/man1/program.1": true})
+	t.CheckDeepEquals(ck.dirs, map[RelPath]bool{
+		"bin":         true,
+		"man":         true,
+		"man/man1":    true,
+		"share":       true,
+		"share/empty": true})
+	t.CheckDeepEquals(ck.emptyDirs, map[RelPath]bool{"share/empty": true})
+}
+
+func (s *Suite) Test_DestdirChecker_loadSubst(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	t.CreateFileLines("category/package/Makefile",
+		MkCvsID,
+		"",
+		"DISTNAME=\tpackage-1.0",
+		"CATEGORIES=\tcategory",
+		"",
+		"MAINTAINER=\tpkgsrc-users@NetBSD.org",
+		"HOMEPAGE=\t# none",
+		"COMMENT=\tDummy package",
+		"LICENSE=\t2-clause-bsd",
+		"",
+		"PLIST_SUBST+=\tSUBDIR=${PKGBASE_SUBDIR}",
+		"PLIST_SUBST+=\tQUOTED=\"quoted\"",
+		"PLIST_SUBST+=\tUNRESOLVED=${UNKNOWN}",
+		"PKGBASE_SUBDIR=\tsub",
+		"",
+		".include \"../../mk/bsd.pkg.mk\"")
+	pkg, _ := t.LoadPackage("category/package")
+	pkg.determineEffectivePkgVars()
+
+	ck := NewDestdirChecker(pkg, "destdir")
+
+	t.CheckDeepEquals(ck.subst, map[string]string{
+		"PKGMANDIR":    "man",
+		"PKGLOCALEDIR": "share",
+		"PKGBASE":      "package",
+		"PKGVERSION":   "1.0",
+		"PKGNAME":      "package-1.0",
+		"SUBDIR":       "sub",
+		"QUOTED":       "quoted"})
+}
+
+func (s *Suite) Test_DestdirChecker_loadPlists(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"${PLIST.doc}share/doc/file",
+		"${PLIST.x11}share/x11/file",
+		"lib/${UNKNOWN}/module.so",
+		"@pkgdir var/empty")
+	t.CreateFileTree("destdir",
+		"bin/program",
+		"share/doc/file")
+	t.Chdir(".")
+	t.CreateFileLines("category/package/PLIST.common",
+		PlistCvsID,
+		"share/common")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewDestdirChecker(pkg, "destdir")
+
+	t.CheckDeepEquals(ck.listed, map[RelPath]bool{
+		"bin/program":    true,
+		"share/doc/file": true,
+		"share/x11/file": true,
+		"share/common":   true})
+	t.CheckDeepEquals(ck.pkgdirs, map[RelPath]bool{"var/empty": true})
+	t.CheckDeepEquals(ck.patterns, []regex.Pattern{`^lib/.*/module\.so$`})
+	t.CheckDeepEquals(ck.enabled, map[string]bool{"PLIST.doc": true})
+}
+
+func (s *Suite) Test_destdirPath(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(destdirPath("man/man1/program.1.gz"), NewRelPathString("man/man1/program.1"))
+	t.CheckEquals(destdirPath("man/man1/program.1"), NewRelPathString("man/man1/program.1"))
+	t.CheckEquals(destdirPath("share/file.gz"), NewRelPathString("share/file.gz"))
+}
+
+func (s *Suite) Test_destdirPattern(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(destdirPattern("lib/${PYSITELIB}/module.so"), regex.Pattern(`^lib/.*/module\.so$`))
+	t.CheckEquals(destdirPattern("${A}${B}"), regex.Pattern(`^.*.*$`))
+}
@@ -80,3 +80,25 @@ func (d *DeferredDiagnoser) Notef(format string, args ...interface{}) {
 func (d *DeferredDiagnoser) Explain(explanation ...string) {
 	d.diagnostics.explain(d.line, explanation...)
 }
+
+// SpanDiagnoser logs diagnostics that point to a specific part of a line.
+type SpanDiagnoser struct {
+	line *Line
+	span Span
+}
+
+func (d *SpanDiagnoser) Errorf(format string, args ...interface{}) {
+	G.Logger.DiagAt(d.line, d.span, Error, format, args...)
+}
+
+func (d *SpanDiagnoser) Warnf(format string, args ...interface{}) {
+	G.Logger.DiagAt(d.line, d.span, Warn, format, args...)
+}
+
+func (d *SpanDiagnoser) Notef(format string, args ...interface{}) {
+	G.Logger.DiagAt(d.line, d.span, Note, format, args...)
+}
+
+func (d *SpanDiagnoser) Explain(explanation ...string) {
+	G.Logger.Explain(explanation...)
+}
@@ -166,3 +166,52 @@ func (s *Suite) Test_DeferredDiagnoser_Explain(c *check.C) {
 		"",
 	)
 }
+
+func (s *Suite) Test_SpanDiagnoser_Errorf(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	line := t.NewLine("filename.mk", 123, "Text with error")
+	line.At(Span{10, 15}).Errorf("Line must not contain %q.", "error")
+
+	t.CheckOutputLines(
+		"filename.mk:123:11: error: Line must not contain \"error\".")
+}
+
+func (s *Suite) Test_SpanDiagnoser_Warnf(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	line := t.NewLine("filename.mk", 123, "Text with warning")
+	line.At(Span{10, 17}).Warnf("Line should not contain %q.", "warning")
+
+	t.CheckOutputLines(
+		"filename.mk:123:11: warning: Line should not contain \"warning\".")
+}
+
+func (s *Suite) Test_SpanDiagnoser_Notef(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	line := t.NewLine("filename.mk", 123, "Text with note")
+	line.At(Span{10, 14}).Notef("Line may also contain %q.", "note text")
+
+	t.CheckOutputLines(
+		"filename.mk:123:11: note: Line may also contain \"note text\".")
+}
+
+func (s *Suite) Test_SpanDiagnoser_Explain(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--explain")
+
+	line := t.NewLine("filename.mk", 123, "Text")
+	diag := line.At(Span{0, 4})
+	diag.Errorf("Line must contain %q.", "error text")
+	diag.Explain("Explanation")
+
+	t.CheckOutputLines(
+		"ERROR: filename.mk:123: Line must contain \"error text\".",
+		"",
+		"\tExplanation",
+		"")
+}
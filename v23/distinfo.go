@@ -246,44 +246,12 @@ func (ck *distinfoLinesChecker) checkAlgorithmsDistfile(info distinfoFileInfo) {
 		return
 	}
 
-	computeHash := func(hasher hashpkg.Hash) string {
-		f, err := distfile.Open()
-		assertNil(err, "Opening distfile")
-
-		// Don't load the distfile into memory since some of them
-		// are hundreds of MB in size.
-		_, err = io.Copy(hasher, f)
-		assertNil(err, "Computing hash of distfile")
-
-		hexHash := hex.EncodeToString(hasher.Sum(nil))
-
-		err = f.Close()
-		assertNil(err, "Closing distfile")
-
-		return hexHash
-	}
-
-	compute := func(alg string) string {
-		switch alg {
-		case "BLAKE2s":
-			blake, err := blake2s.New256(nil)
-			assertNil(err, "blake2s")
-			return computeHash(blake)
-		case "SHA512":
-			return computeHash(sha512.New())
-		default:
-			fileInfo, err := distfile.Lstat()
-			assertNil(err, "Inaccessible distfile info")
-			return sprintf("%d bytes", fileInfo.Size())
-		}
-	}
-
 	for _, hash := range info.hashes {
 		alg := hash.algorithm
 		if !seen[alg] {
 			continue
 		}
-		computed := compute(alg)
+		computed := computeDistfileHash(distfile, alg)
 
 		if computed != hash.hash {
 			// Do not try to autofix anything in this situation,
@@ -303,7 +271,7 @@ func (ck *distinfoLinesChecker) checkAlgorithmsDistfile(info distinfoFileInfo) {
 	var remainingHashes = info.hashes
 	for _, alg := range algorithms {
 		if missing[alg] {
-			computed := compute(alg)
+			computed := computeDistfileHash(distfile, alg)
 
 			if insertion == nil {
 				fix := line.Autofix()
@@ -438,7 +406,7 @@ func (ck *distinfoLinesChecker) checkPatchSha1(line *Line, patchFileName Package
 		fix.Explain(
 			"To fix the hashes, either let pkglint --autofix do the work",
 			sprintf("or run %q.", bmake("makepatchsum")))
-		fix.Replace(distinfoSha1Hex, fileSha1Hex)
+		fix.ReplaceInTree(treeLine, distinfoSha1Hex, fileSha1Hex)
 		fix.Apply()
 	}
 }
@@ -492,3 +460,37 @@ func computePatchSha1Hex(lines *Lines) string {
 	}
 	return sprintf("%x", hasher.Sum(nil))
 }
+
+// computeDistfileHash computes the hash of the distfile for the given
+// algorithm from distinfo, which is one of BLAKE2s, SHA512 or Size.
+func computeDistfileHash(distfile CurrPath, alg string) string {
+	computeHash := func(hasher hashpkg.Hash) string {
+		f, err := distfile.Open()
+		assertNil(err, "Opening distfile")
+
+		// Don't load the distfile into memory since some of them
+		// are hundreds of MB in size.
+		_, err = io.Copy(hasher, f)
+		assertNil(err, "Computing hash of distfile")
+
+		hexHash := hex.EncodeToString(hasher.Sum(nil))
+
+		err = f.Close()
+		assertNil(err, "Closing distfile")
+
+		return hexHash
+	}
+
+	switch alg {
+	case "BLAKE2s":
+		blake, err := blake2s.New256(nil)
+		assertNil(err, "blake2s")
+		return computeHash(blake)
+	case "SHA512":
+		return computeHash(sha512.New())
+	default:
+		fileInfo, err := distfile.Lstat()
+		assertNil(err, "Inaccessible distfile info")
+		return sprintf("%d bytes", fileInfo.Size())
+	}
+}
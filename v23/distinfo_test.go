@@ -788,9 +788,11 @@ func (s *Suite) Test_distinfoLinesChecker_checkGlobalDistfileMismatch(c *check.C
 			"Expected BLAKE2s, SHA512, Size checksums for \"encoding-error.tar.gz\", got SHA512.",
 		"ERROR: ~/category/package2/distinfo:5: "+
 			"The SHA512 hash for encoding-error.tar.gz contains a non-hex character.",
+		"WARN: ~/category/package2/PLIST:2: The file \"bin/program\" is also installed "+
+			"by ../../category/package1, without a declared conflict.",
 
 		"WARN: ~/licenses/gnu-gpl-v2: This license seems to be unused.",
-		"8 errors and 2 warnings found.",
+		"8 errors and 3 warnings found.",
 		t.Shquote("(Run \"pkglint -e -r -Wall -Call %s\" to show explanations.)", "."))
 
 	// Ensure that hex.DecodeString does not waste memory here.
@@ -1022,3 +1024,18 @@ func (s *Suite) Test_computePatchSha1Hex(c *check.C) {
 		"+++ new",
 	)
 }
+
+func (s *Suite) Test_computeDistfileHash(c *check.C) {
+	t := s.Init(c)
+
+	distfile := t.CreateFileLines("distfiles/package-1.0.txt",
+		"hello, world")
+
+	t.CheckEquals(computeDistfileHash(distfile, "BLAKE2s"),
+		"ee494623e60caeda840ed7de4fb70db4a36bc92b445b09f12b9ed46094e9bd59")
+	t.CheckEquals(computeDistfileHash(distfile, "SHA512"),
+		"f65f341b35981fda842b09b2c8af9bcdb7602a4c2e6fa1f7d41f0974d3e3122f"+
+			"268fc79d5a4af66358f5133885cd1c165c916f80ab25e5d8d95db46f803c782c")
+	t.CheckEquals(computeDistfileHash(distfile, "Size"),
+		"13 bytes")
+}
@@ -0,0 +1,290 @@
+package pkglint
+
+import (
+	"debug/elf"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// ElfChecker inspects the ELF files in the staged installation directory
+// of --destdir, similar to the check-shlibs and check-wrkref targets of
+// the pkgsrc infrastructure.
+//
+// The libraries that a file needs must be installed by the package
+// itself or by one of the packages whose buildlink3.mk file the package
+// includes, directly or indirectly. Otherwise, the file only works by
+// accident, as long as the library happens to be installed.
+type ElfChecker struct {
+	destdir *DestdirChecker
+
+	// The WRKDIR in which the package has been built,
+	// or empty if it cannot be determined from the prefix.
+	wrkdir string
+
+	// The libraries that are installed by the package itself and
+	// by its buildlink3 dependencies, by library name, such as "libz".
+	provided map[string]bool
+}
+
+func NewElfChecker(destdir *DestdirChecker) *ElfChecker {
+	ck := ElfChecker{destdir, "", make(map[string]bool)}
+
+	abs := G.Abs(destdir.prefix).String() + "/"
+	if i := strings.Index(abs, "/.destdir/"); i > 0 {
+		ck.wrkdir = abs[:i]
+	}
+
+	for file := range destdir.files {
+		ck.provide(file)
+	}
+	for _, data := range destdir.pkg.bl3Data {
+		ck.loadDependency(data)
+	}
+	return &ck
+}
+
+// Check checks the shared libraries from the PLIST file.
+// For the primary PLIST file, it also checks the ELF files that are
+// installed by the package.
+func (ck *ElfChecker) Check(lines *Lines, plines []*PlistLine) {
+	for _, pline := range plines {
+		if pline.IsPath() {
+			ck.checkSymlinks(pline)
+		}
+	}
+
+	if lines.Filename.Clean() != ck.destdir.primary().Clean() {
+		return
+	}
+
+	var files []RelPath
+	for file := range ck.destdir.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
+
+	for _, file := range files {
+		filename := ck.destdir.prefix.JoinNoClean(file)
+		if f := openElf(filename); f != nil {
+			ck.checkNeeded(filename, f.File)
+			ck.checkRpath(filename, f.File)
+			f.Close()
+		}
+	}
+}
+
+// checkSymlinks checks that a versioned shared library from the PLIST
+// is accompanied by the symlinks for its SONAME and for linking.
+func (ck *ElfChecker) checkSymlinks(pline *PlistLine) {
+	path, ok := ck.destdir.expand(pline.text)
+	if !ok || !path.HasPrefixPath("lib") || !matches(path.Base().String(), `\.so\.\d`) {
+		return
+	}
+
+	f := openElf(ck.destdir.prefix.JoinNoClean(path))
+	if f == nil {
+		return
+	}
+	isLibrary := f.Type == elf.ET_DYN
+	sonames, _ := f.DynString(elf.DT_SONAME)
+	f.Close()
+	if !isLibrary {
+		return
+	}
+
+	var symlinks []RelPath
+	for _, soname := range sonames {
+		if soname != path.Base().String() {
+			symlinks = append(symlinks, path.Dir().JoinNoClean(NewRelPathString(soname)))
+		}
+	}
+	if name := libraryName(path.Base().String()); name != "" {
+		symlinks = append(symlinks, path.Dir().JoinNoClean(NewRelPathString(name+".so")))
+	}
+
+	for _, symlink := range symlinks {
+		if !ck.destdir.listed[symlink] {
+			pline.Warnf("The shared library %q needs the symlink %q in the PLIST.",
+				path.String(), symlink.String())
+			pline.Explain(
+				"The symlink named after the SONAME of the library is needed",
+				"for running the programs that use the library.",
+				"The symlink ending in .so is needed for linking against",
+				"the library.")
+		}
+	}
+}
+
+func (ck *ElfChecker) checkNeeded(filename CurrPath, f *elf.File) {
+	needed, _ := f.ImportedLibraries()
+	for _, lib := range needed {
+		name := libraryName(lib)
+		if name == "" || ck.provided[name] || elfSystemLibraries[name] {
+			continue
+		}
+
+		line := NewLineWhole(filename)
+		line.Warnf("The library %q is neither installed by this package "+
+			"nor by one of its buildlink3 dependencies.", lib)
+		line.Explain(
+			"Each library that is needed by a program or library must be",
+			"provided by the package itself or by one of its dependencies.",
+			"Otherwise, the program only works if the library happens to be",
+			"installed.",
+			"",
+			"To fix this, include the buildlink3.mk file of the package",
+			"that provides this library.")
+	}
+}
+
+func (ck *ElfChecker) checkRpath(filename CurrPath, f *elf.File) {
+	var rpaths []string
+	for _, tag := range []elf.DynTag{elf.DT_RPATH, elf.DT_RUNPATH} {
+		values, _ := f.DynString(tag)
+		for _, value := range values {
+			rpaths = append(rpaths, strings.Split(value, ":")...)
+		}
+	}
+
+	for _, rpath := range rpaths {
+		inWrkdir := ck.wrkdir != "" && (rpath == ck.wrkdir || hasPrefix(rpath, ck.wrkdir+"/"))
+		if !inWrkdir && !matches(rpath, `(^|/)\.(buildlink|wrapper)(/|$)`) {
+			continue
+		}
+
+		line := NewLineWhole(filename)
+		line.Warnf("The RPATH %q points into the build directory.", rpath)
+		line.Explain(
+			"The directories from the WRKDIR, such as the BUILDLINK_DIR,",
+			"are removed after the package has been built.",
+			"Libraries from these directories are not found at run time,",
+			"and a malicious user could place other libraries there.")
+	}
+}
+
+// provide remembers that the package or one of its dependencies
+// installs the file.
+func (ck *ElfChecker) provide(file RelPath) {
+	if name := libraryName(file.Base().String()); name != "" {
+		ck.provided[name] = true
+	}
+}
+
+// loadDependency remembers the libraries that the package of the
+// buildlink3.mk file lists in its PLIST files.
+func (ck *ElfChecker) loadDependency(data *Buildlink3Data) {
+	if data.pkgsrcdir == "" || containsExpr(data.pkgsrcdir.String()) {
+		return
+	}
+
+	pkgdir := ck.destdir.pkg.File(data.pkgsrcdir)
+	for _, filename := range pkgdir.ReadPaths() {
+		if !filename.Base().HasPrefixText("PLIST") {
+			continue
+		}
+		lines := Load(filename, 0)
+		if lines == nil {
+			continue
+		}
+		for _, pline := range extractPlistConditions(lines) {
+			if pline.IsPath() {
+				ck.provide(pline.Path())
+			}
+		}
+	}
+}
+
+// elfSystemLibraries are the libraries that come with the base system
+// or with the compiler, rather than with a package.
+//
+// The check-shlibs target of the infrastructure looks for the needed
+// libraries in the system library directories. Pkglint cannot do that
+// since the staged installation may come from another machine, or even
+// from another operating system. Therefore, this list contains the
+// libraries that are part of the base system on all platforms where
+// they exist, and the runtime libraries of GCC and Clang. A library from
+// this list that is missing on a platform is reported by check-shlibs.
+var elfSystemLibraries = map[string]bool{
+	"ld-linux":         true,
+	"ld-linux-aarch64": true,
+	"ld-linux-x86-64":  true,
+	"libc":             true,
+	"libc++":           true,
+	"libc++abi":        true,
+	"libcrypt":         true,
+	"libdl":            true,
+	"libexecinfo":      true,
+	"libgcc_s":         true,
+	"libkvm":           true,
+	"libm":             true,
+	"libnsl":           true,
+	"libpthread":       true,
+	"libresolv":        true,
+	"librt":            true,
+	"libsocket":        true,
+	"libstdc++":        true,
+	"libutil":          true,
+}
+
+// libraryName returns the name of the library from the filename of a
+// shared library or a libtool archive, such as "libz" for "libz.so.1"
+// or "libz.la". For other files, it returns an empty string.
+func libraryName(filename string) string {
+	if i := strings.Index(filename, ".so"); i > 0 &&
+		(len(filename) == i+3 || filename[i+3] == '.') {
+		return filename[:i]
+	}
+	if hasSuffix(filename, ".la") {
+		return strings.TrimSuffix(filename, ".la")
+	}
+	return ""
+}
+
+// elfFile is an ELF file that has been opened by openElf.
+// Since debug/elf reads the sections lazily, the underlying file
+// stays open until Close is called.
+type elfFile struct {
+	*elf.File
+	file fs.File
+}
+
+func (f *elfFile) Close() { _ = f.file.Close() }
+
+// openElf returns the parsed ELF file, or nil if the file is not a
+// regular ELF file.
+//
+// Only the header is read before deciding whether the file is an ELF
+// file; the rest of the file is read on demand.
+func openElf(filename CurrPath) *elfFile {
+	st, err := filename.Lstat()
+	if err != nil || !st.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := filename.Open()
+	if err != nil {
+		return nil
+	}
+	magic := make([]byte, len(elf.ELFMAG))
+	ra, isReaderAt := f.(io.ReaderAt)
+	if !isReaderAt {
+		_ = f.Close()
+		return nil
+	}
+	if _, err := ra.ReadAt(magic, 0); err != nil || string(magic) != elf.ELFMAG {
+		_ = f.Close()
+		return nil
+	}
+
+	ef, err := elf.NewFile(ra)
+	if err != nil {
+		if trace.Tracing {
+			trace.Stepf("Cannot parse ELF file %q: %s", filename, err)
+		}
+		_ = f.Close()
+		return nil
+	}
+	return &elfFile{ef, f}
+}
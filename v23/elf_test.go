@@ -0,0 +1,273 @@
+package pkglint
+
+import (
+	"debug/elf"
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) Test_NewElfChecker(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"lib/libprogram.la")
+	t.CreateFileTree("destdir",
+		"lib/libprogram.la")
+	t.Chdir(".")
+	t.CreateFileLines("category/package/Makefile",
+		MkCvsID,
+		"",
+		"DISTNAME=\tpackage-1.0",
+		"CATEGORIES=\tcategory",
+		"",
+		"MAINTAINER=\tpkgsrc-users@NetBSD.org",
+		"HOMEPAGE=\t# none",
+		"COMMENT=\tDummy package",
+		"LICENSE=\t2-clause-bsd",
+		"",
+		".include \"../../category/lib/buildlink3.mk\"",
+		".include \"../../mk/bsd.pkg.mk\"")
+	t.SetUpPackage("category/lib")
+	t.CreateFileLines("category/lib/PLIST",
+		PlistCvsID,
+		"lib/liblib.so.1")
+	t.CreateFileBuildlink3("category/lib/buildlink3.mk")
+	pkg, _ := t.LoadPackage("category/package")
+	destdir := NewDestdirChecker(pkg, "destdir")
+
+	ck := NewElfChecker(destdir)
+
+	t.CheckEquals(ck.wrkdir, "")
+	t.CheckDeepEquals(ck.provided, map[string]bool{
+		"libprogram": true,
+		"liblib":     true})
+}
+
+func (s *Suite) Test_NewElfChecker__wrkdir(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	t.CreateFileTree("work/.destdir/usr/pkg")
+	pkg, _ := t.LoadPackage("category/package")
+
+	ck := NewElfChecker(NewDestdirChecker(pkg, "work/.destdir/usr/pkg"))
+
+	t.CheckEquals(ck.wrkdir, G.Abs("work").String())
+}
+
+func (s *Suite) Test_ElfChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"lib/libprogram.so",
+		"lib/libprogram.so.1",
+		"lib/libprogram.so.1.2")
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	t.CreateFileElf("destdir/bin/program", elf.ET_EXEC, "", "/usr/pkg/lib",
+		"libprogram.so.1", "libc.so.12", "libmissing.so.3")
+	t.CreateFileElf("destdir/lib/libprogram.so.1.2", elf.ET_DYN, "libprogram.so.1", "")
+	t.CreateFileLines("destdir/lib/libprogram.so.1")
+	t.CreateFileLines("destdir/lib/libprogram.so")
+
+	t.Main("--destdir=destdir", "-q", "category/package")
+
+	t.CheckOutputLines(
+		"WARN: destdir/bin/program: The library \"libmissing.so.3\" " +
+			"is neither installed by this package nor by one of its buildlink3 dependencies.")
+}
+
+func (s *Suite) Test_ElfChecker_checkSymlinks(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"lib/libcomplete.so",
+		"lib/libcomplete.so.1",
+		"lib/libcomplete.so.1.2",
+		"lib/libincomplete.so.2.0",
+		"lib/plugins/plugin.so",
+		"lib/libnotelf.so.1")
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	t.CreateFileElf("destdir/lib/libcomplete.so.1.2", elf.ET_DYN, "libcomplete.so.1", "")
+	t.CreateFileElf("destdir/lib/libincomplete.so.2.0", elf.ET_DYN, "libincomplete.so.2", "")
+	t.CreateFileElf("destdir/lib/plugins/plugin.so", elf.ET_DYN, "", "")
+	t.CreateFileLines("destdir/lib/libnotelf.so.1")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewElfChecker(NewDestdirChecker(pkg, "destdir"))
+	lines := Load("category/package/PLIST", MustSucceed)
+
+	for _, pline := range extractPlistConditions(lines)[1:] {
+		ck.checkSymlinks(pline)
+	}
+
+	t.CheckOutputLines(
+		"WARN: category/package/PLIST:5: The shared library \"lib/libincomplete.so.2.0\" "+
+			"needs the symlink \"lib/libincomplete.so.2\" in the PLIST.",
+		"WARN: category/package/PLIST:5: The shared library \"lib/libincomplete.so.2.0\" "+
+			"needs the symlink \"lib/libincomplete.so\" in the PLIST.")
+}
+
+func (s *Suite) Test_ElfChecker_checkNeeded(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID,
+		"bin/program",
+		"lib/libown.la")
+	t.CreateFileTree("destdir",
+		"lib/libown.la")
+	t.Chdir(".")
+	t.CreateFileElf("destdir/bin/program", elf.ET_EXEC, "", "",
+		"libown.so.1", "libc.so.12", "libstdc++.so.7", "libmissing.so.3", "ld-linux-x86-64.so.2")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewElfChecker(NewDestdirChecker(pkg, "destdir"))
+	filename := NewCurrPath("destdir/bin/program")
+
+	f := openElf(filename)
+	defer f.Close()
+
+	ck.checkNeeded(filename, f.File)
+
+	t.CheckOutputLines(
+		"WARN: destdir/bin/program: The library \"libmissing.so.3\" " +
+			"is neither installed by this package nor by one of its buildlink3 dependencies.")
+}
+
+func (s *Suite) Test_ElfChecker_checkRpath(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	t.CreateFileElf("destdir/bin/program", elf.ET_EXEC, "", "/usr/pkg/lib:/tmp/work/.buildlink/lib:$ORIGIN/../lib")
+	t.CreateFileElf("destdir/bin/wrkdir", elf.ET_EXEC, "", "/home/pbulk/work/lib:/home/pbulk/work2/lib")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewElfChecker(NewDestdirChecker(pkg, "destdir"))
+	ck.wrkdir = "/home/pbulk/work"
+
+	for _, filename := range []CurrPath{"destdir/bin/program", "destdir/bin/wrkdir"} {
+		f := openElf(filename)
+		ck.checkRpath(filename, f.File)
+		f.Close()
+	}
+
+	t.CheckOutputLines(
+		"WARN: destdir/bin/program: The RPATH \"/tmp/work/.buildlink/lib\" "+
+			"points into the build directory.",
+		"WARN: destdir/bin/wrkdir: The RPATH \"/home/pbulk/work/lib\" "+
+			"points into the build directory.")
+}
+
+func (s *Suite) Test_ElfChecker_provide(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewElfChecker(NewDestdirChecker(pkg, "destdir"))
+
+	ck.provide("lib/libshared.so.1.2.3")
+	ck.provide("lib/libtool.la")
+	ck.provide("lib/libstatic.a")
+	ck.provide("bin/program")
+
+	t.CheckDeepEquals(ck.provided, map[string]bool{
+		"libshared": true,
+		"libtool":   true})
+}
+
+func (s *Suite) Test_ElfChecker_loadDependency(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/PLIST",
+		PlistCvsID)
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+	t.SetUpPackage("category/lib")
+	t.CreateFileLines("category/lib/PLIST",
+		PlistCvsID,
+		"${PLIST.shared}lib/libshared.so.1",
+		"lib/libtool.la")
+	t.CreateFileLines("category/lib/PLIST.Linux",
+		PlistCvsID,
+		"lib/liblinux.so")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewElfChecker(NewDestdirChecker(pkg, "destdir"))
+
+	ck.loadDependency(&Buildlink3Data{pkgsrcdir: "../../category/lib"})
+	ck.loadDependency(&Buildlink3Data{pkgsrcdir: "${LIB_PKGSRCDIR}"})
+	ck.loadDependency(&Buildlink3Data{})
+
+	t.CheckDeepEquals(ck.provided, map[string]bool{
+		"libshared": true,
+		"libtool":   true,
+		"liblinux":  true})
+}
+
+func (s *Suite) Test_libraryName(c *check.C) {
+	t := s.Init(c)
+
+	test := func(filename string, expected string) {
+		t.CheckEquals(libraryName(filename), expected)
+	}
+
+	test("libz.so", "libz")
+	test("libz.so.1", "libz")
+	test("libz.so.1.2.13", "libz")
+	test("libz.la", "libz")
+	test("libz.a", "")
+	test("libsomething.sock", "")
+	test(".so", "")
+}
+
+func (s *Suite) Test_elfFile_Close(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileElf("elf", elf.ET_DYN, "libelf.so.1", "")
+	t.Chdir(".")
+	f := openElf("elf")
+
+	f.Close()
+
+	// The underlying file is closed, not only the parsed ELF file.
+	_, err := f.file.Read(make([]byte, 1))
+	t.CheckEquals(err != nil, true)
+}
+
+func (s *Suite) Test_openElf(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileElf("elf", elf.ET_DYN, "libelf.so.1", "")
+	t.CreateFileLines("text")
+	t.CreateFileLines("broken", "\x7fELF, but nothing else")
+	t.Chdir(".")
+
+	f := openElf("elf")
+	defer f.Close()
+	sonames, err := f.DynString(elf.DT_SONAME)
+
+	t.CheckDeepEquals(sonames, []string{"libelf.so.1"})
+	t.CheckNil(err)
+	t.CheckNil(openElf("text"))
+	t.CheckNil(openElf("broken"))
+	t.CheckNil(openElf("nonexistent"))
+	t.CheckNil(openElf("."))
+}
@@ -0,0 +1,385 @@
+package pkglint
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// FileSystem provides read access to the files that pkglint checks.
+//
+// It follows the interfaces from io/fs, except that the names are
+// the string representations of CurrPath, which may be absolute and
+// may contain "..". Therefore, fs.ValidPath does not apply.
+type FileSystem interface {
+	fs.FS
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+
+	// Lstat returns information about the named file,
+	// without following symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// WritableFileSystem extends FileSystem with the operations that are
+// needed for saving the changes from the --autofix mode.
+type WritableFileSystem interface {
+	FileSystem
+
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Rename(oldName, newName string) error
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// writableFileSystem returns the file system of pkglint if it can be
+// written to, or an error for the given file otherwise.
+func writableFileSystem(op string, name string) (WritableFileSystem, error) {
+	if wfs, ok := G.FileSystem.(WritableFileSystem); ok {
+		return wfs, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+// OSFileSystem is the default file system, which accesses the files
+// of the operating system.
+type OSFileSystem struct{}
+
+var _ WritableFileSystem = OSFileSystem{}
+
+func (OSFileSystem) Open(name string) (fs.File, error) { return os.Open(name) }
+
+func (OSFileSystem) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (OSFileSystem) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+
+func (OSFileSystem) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+func (OSFileSystem) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (OSFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OSFileSystem) Rename(oldName, newName string) error { return os.Rename(oldName, newName) }
+
+func (OSFileSystem) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+
+// MemFileSystem is a file system that is held completely in memory.
+// It is useful for checking files that have not been saved yet,
+// such as the buffers of an editor, and for testing.
+//
+// Directories are created implicitly when a file is written to them.
+// Symbolic links are not supported, therefore Lstat is the same as Stat.
+type MemFileSystem struct {
+	files map[string]*memFile
+}
+
+var _ WritableFileSystem = (*MemFileSystem)(nil)
+
+// memFile is a file or directory in a MemFileSystem.
+// It also serves as the fs.FileInfo for itself.
+type memFile struct {
+	base string
+	data []byte
+	mode fs.FileMode
+}
+
+var _ fs.FileInfo = (*memFile)(nil)
+
+func (f *memFile) Name() string       { return f.base }
+func (f *memFile) Size() int64        { return int64(len(f.data)) }
+func (f *memFile) Mode() fs.FileMode  { return f.mode }
+func (f *memFile) ModTime() time.Time { return time.Time{} }
+func (f *memFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *memFile) Sys() interface{}   { return nil }
+
+func NewMemFileSystem() *MemFileSystem {
+	return &MemFileSystem{map[string]*memFile{".": {".", nil, fs.ModeDir | 0755}}}
+}
+
+func (m *MemFileSystem) Open(name string) (fs.File, error) {
+	f, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memOpenFile{f, bytes.NewReader(f.data)}, nil
+}
+
+func (m *MemFileSystem) Stat(name string) (fs.FileInfo, error) {
+	f, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (m *MemFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	f, err := m.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (m *MemFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	dir, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !dir.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	key := m.key(name)
+	var entries []fs.DirEntry
+	for filename, f := range m.files {
+		if filename != key && path.Dir(filename) == key {
+			entries = append(entries, fs.FileInfoToDirEntry(f))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemFileSystem) ReadFile(name string) ([]byte, error) {
+	f, err := m.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (m *MemFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	key := m.key(name)
+	if f := m.files[key]; f != nil {
+		if f.mode.IsDir() {
+			return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+		}
+		f.data = append([]byte(nil), data...)
+		return nil
+	}
+
+	if err := m.mkdirAll(path.Dir(key)); err != nil {
+		return &fs.PathError{Op: "write", Path: name, Err: err}
+	}
+	m.files[key] = &memFile{path.Base(key), append([]byte(nil), data...), perm & fs.ModePerm}
+	return nil
+}
+
+func (m *MemFileSystem) Rename(oldName, newName string) error {
+	oldKey, newKey := m.key(oldName), m.key(newName)
+	f := m.files[oldKey]
+	if f == nil {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrInvalid}
+	}
+	if err := m.mkdirAll(path.Dir(newKey)); err != nil {
+		return &fs.PathError{Op: "rename", Path: newName, Err: err}
+	}
+	delete(m.files, oldKey)
+	f.base = path.Base(newKey)
+	m.files[newKey] = f
+	return nil
+}
+
+func (m *MemFileSystem) Chmod(name string, mode fs.FileMode) error {
+	f, err := m.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	f.mode = f.mode&^fs.ModePerm | mode&fs.ModePerm
+	return nil
+}
+
+func (m *MemFileSystem) lookup(op string, name string) (*memFile, error) {
+	if f := m.files[m.key(name)]; f != nil {
+		return f, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *MemFileSystem) mkdirAll(dir string) error {
+	if f := m.files[dir]; f != nil {
+		if !f.mode.IsDir() {
+			return fs.ErrExist
+		}
+		return nil
+	}
+	if parent := path.Dir(dir); parent != dir {
+		if err := m.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	m.files[dir] = &memFile{path.Base(dir), nil, fs.ModeDir | 0755}
+	return nil
+}
+
+// key returns the canonical form of the name, which is used as the key
+// in the map of files.
+func (*MemFileSystem) key(name string) string {
+	return path.Clean(name)
+}
+
+// memOpenFile is a file from a MemFileSystem that has been opened for
+// reading.
+type memOpenFile struct {
+	f *memFile
+	r *bytes.Reader
+}
+
+func (o *memOpenFile) Stat() (fs.FileInfo, error) { return o.f, nil }
+
+func (o *memOpenFile) Read(b []byte) (int, error) {
+	if o.f.mode.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: o.f.base, Err: fs.ErrInvalid}
+	}
+	return o.r.Read(b)
+}
+
+// ReadAt allows the file to be parsed by debug/elf, like an *os.File.
+func (o *memOpenFile) ReadAt(b []byte, off int64) (int, error) {
+	if o.f.mode.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: o.f.base, Err: fs.ErrInvalid}
+	}
+	return o.r.ReadAt(b, off)
+}
+
+func (o *memOpenFile) Close() error { return nil }
+
+// OverlayFileSystem shows the files from a MemFileSystem on top of
+// the files from another file system.
+// Directories that exist in both file systems are merged,
+// unless the upper directory replaces the lower directory.
+//
+// Files can be removed from the overlay, without affecting the lower
+// file system.
+//
+// The names are resolved lexically, that is, "dir/.." is the same as ".",
+// even if dir only exists in the upper file system.
+//
+// It is read-only, to make sure that the lower file system stays
+// untouched.
+type OverlayFileSystem struct {
+	upper *MemFileSystem
+	lower FileSystem
+
+	// The names from the lower file system that are hidden,
+	// including everything below them.
+	hidden map[string]bool
+}
+
+var _ FileSystem = (*OverlayFileSystem)(nil)
+
+func NewOverlayFileSystem(upper *MemFileSystem, lower FileSystem) *OverlayFileSystem {
+	return &OverlayFileSystem{upper, lower, make(map[string]bool)}
+}
+
+func (o *OverlayFileSystem) Open(name string) (fs.File, error) {
+	if _, err := o.upper.lookup("open", name); err == nil {
+		return o.upper.Open(name)
+	}
+	if o.isHidden(o.upper.key(name)) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.lower.Open(o.upper.key(name))
+}
+
+func (o *OverlayFileSystem) Stat(name string) (fs.FileInfo, error) {
+	if f, err := o.upper.lookup("stat", name); err == nil {
+		return f, nil
+	}
+	if o.isHidden(o.upper.key(name)) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.lower.Stat(o.upper.key(name))
+}
+
+func (o *OverlayFileSystem) Lstat(name string) (fs.FileInfo, error) {
+	if f, err := o.upper.lookup("lstat", name); err == nil {
+		return f, nil
+	}
+	if o.isHidden(o.upper.key(name)) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.lower.Lstat(o.upper.key(name))
+}
+
+func (o *OverlayFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, upperErr := o.upper.ReadDir(name)
+	if upperErr != nil && !errors.Is(upperErr, fs.ErrNotExist) {
+		return nil, upperErr
+	}
+	key := o.upper.key(name)
+	if o.isHidden(key) {
+		return upper, upperErr
+	}
+	lower, lowerErr := o.lower.ReadDir(key)
+	if upperErr != nil && lowerErr != nil {
+		return nil, lowerErr
+	}
+
+	seen := make(map[string]bool)
+	entries := upper
+	for _, entry := range upper {
+		seen[entry.Name()] = true
+	}
+	for _, entry := range lower {
+		if !seen[entry.Name()] && !o.hidden[path.Join(key, entry.Name())] {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (o *OverlayFileSystem) ReadFile(name string) ([]byte, error) {
+	if _, err := o.upper.lookup("read", name); err == nil {
+		return o.upper.ReadFile(name)
+	}
+	if o.isHidden(o.upper.key(name)) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.lower.ReadFile(o.upper.key(name))
+}
+
+// Remove hides the file or directory, including everything below it,
+// no matter whether it comes from the upper or the lower file system.
+func (o *OverlayFileSystem) Remove(name string) {
+	key := o.upper.key(name)
+	for upperKey := range o.upper.files {
+		if upperKey == key || hasPrefix(upperKey, key+"/") {
+			delete(o.upper.files, upperKey)
+		}
+	}
+	o.hidden[key] = true
+}
+
+// Replace hides the directory from the lower file system, including
+// everything below it, so that only the files from the upper directory
+// are visible.
+func (o *OverlayFileSystem) Replace(dir string) {
+	o.hidden[o.upper.key(dir)] = true
+}
+
+func (o *OverlayFileSystem) isHidden(key string) bool {
+	for {
+		if o.hidden[key] {
+			return true
+		}
+		parent := path.Dir(key)
+		if parent == key {
+			return false
+		}
+		key = parent
+	}
+}
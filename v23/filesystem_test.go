@@ -0,0 +1,695 @@
+package pkglint
+
+import (
+	"errors"
+	"gopkg.in/check.v1"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// readOnlyFileSystem hides the write methods of the underlying file system.
+type readOnlyFileSystem struct {
+	FileSystem
+}
+
+// Ensures that a complete package can be checked and fixed without
+// touching the files of the operating system.
+func (s *Suite) Test_FileSystem__package_in_memory(c *check.C) {
+	t := s.Init(c)
+
+	mem := NewMemFileSystem()
+	G.FileSystem = mem
+	t.SetUpPackage("category/package",
+		"COMMENT= Comment")
+
+	t.Main("-Wall", "--autofix", "category/package")
+
+	t.CheckOutputLines(
+		"AUTOFIX: ~/category/package/Makefile:10: Replacing \" \" with \"\\t\".")
+	makefile := t.File("category/package/Makefile").String()
+	data, err := mem.ReadFile(makefile)
+	t.CheckEquals(err, nil)
+	t.CheckEquals(strings.Contains(string(data), "COMMENT=\tComment\n"), true)
+	_, err = OSFileSystem{}.Stat(makefile)
+	t.CheckEquals(errors.Is(err, fs.ErrNotExist), true)
+}
+
+func (s *Suite) Test_writableFileSystem(c *check.C) {
+	t := s.Init(c)
+
+	wfs, err := writableFileSystem("write", "file")
+
+	t.CheckEquals(wfs, WritableFileSystem(OSFileSystem{}))
+	t.CheckEquals(err, nil)
+
+	G.FileSystem = readOnlyFileSystem{NewMemFileSystem()}
+
+	wfs, err = writableFileSystem("write", "file")
+
+	t.CheckEquals(wfs, nil)
+	t.CheckEquals(err.Error(), "write file: permission denied")
+	t.CheckEquals(errors.Is(err, fs.ErrPermission), true)
+}
+
+func (s *Suite) Test_OSFileSystem_Open(c *check.C) {
+	t := s.Init(c)
+
+	filename := t.CreateFileLines("file", "content")
+
+	f, err := OSFileSystem{}.Open(filename.String())
+	t.AssertNil(err)
+	data, err := io.ReadAll(f)
+	t.CheckEquals(f.Close(), nil)
+
+	t.CheckEquals(string(data), "content\n")
+	t.CheckEquals(err, nil)
+}
+
+func (s *Suite) Test_OSFileSystem_Stat(c *check.C) {
+	t := s.Init(c)
+
+	filename := t.CreateFileLines("file", "content")
+
+	info, err := OSFileSystem{}.Stat(filename.String())
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.Size(), int64(8))
+}
+
+func (s *Suite) Test_OSFileSystem_Lstat(c *check.C) {
+	t := s.Init(c)
+
+	filename := t.CreateFileLines("file", "content")
+
+	info, err := OSFileSystem{}.Lstat(filename.String())
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.Mode().IsRegular(), true)
+}
+
+func (s *Suite) Test_OSFileSystem_ReadDir(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("dir/b")
+	t.CreateFileLines("dir/a")
+
+	entries, err := OSFileSystem{}.ReadDir(t.File("dir").String())
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(entries), 2)
+	t.CheckEquals(entries[0].Name(), "a")
+	t.CheckEquals(entries[1].Name(), "b")
+}
+
+func (s *Suite) Test_OSFileSystem_ReadFile(c *check.C) {
+	t := s.Init(c)
+
+	filename := t.CreateFileLines("file", "content")
+
+	data, err := OSFileSystem{}.ReadFile(filename.String())
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(string(data), "content\n")
+}
+
+func (s *Suite) Test_OSFileSystem_WriteFile(c *check.C) {
+	t := s.Init(c)
+
+	filename := t.File("file")
+
+	err := OSFileSystem{}.WriteFile(filename.String(), []byte("written\n"), 0666)
+
+	t.CheckEquals(err, nil)
+	t.CheckFileLines("file",
+		"written")
+}
+
+func (s *Suite) Test_OSFileSystem_Rename(c *check.C) {
+	t := s.Init(c)
+
+	oldName := t.CreateFileLines("old", "content")
+	newName := t.File("new")
+
+	err := OSFileSystem{}.Rename(oldName.String(), newName.String())
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(oldName.Exists(), false)
+	t.CheckFileLines("new",
+		"content")
+}
+
+func (s *Suite) Test_OSFileSystem_Chmod(c *check.C) {
+	t := s.Init(c)
+
+	filename := t.CreateFileLines("file", "content")
+
+	err := OSFileSystem{}.Chmod(filename.String(), 0444)
+
+	t.CheckEquals(err, nil)
+	info, _ := filename.Stat()
+	t.CheckEquals(info.Mode().Perm(), fs.FileMode(0444))
+}
+
+func (s *Suite) Test_memFile_Name(c *check.C) {
+	t := s.Init(c)
+
+	f := memFile{"file.txt", nil, 0644}
+
+	t.CheckEquals(f.Name(), "file.txt")
+}
+
+func (s *Suite) Test_memFile_Size(c *check.C) {
+	t := s.Init(c)
+
+	f := memFile{"file.txt", []byte("content"), 0644}
+
+	t.CheckEquals(f.Size(), int64(7))
+}
+
+func (s *Suite) Test_memFile_Mode(c *check.C) {
+	t := s.Init(c)
+
+	f := memFile{"dir", nil, fs.ModeDir | 0755}
+
+	t.CheckEquals(f.Mode(), fs.ModeDir|0755)
+}
+
+func (s *Suite) Test_memFile_ModTime(c *check.C) {
+	t := s.Init(c)
+
+	f := memFile{"file.txt", nil, 0644}
+
+	t.CheckEquals(f.ModTime(), time.Time{})
+}
+
+func (s *Suite) Test_memFile_IsDir(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals((&memFile{"dir", nil, fs.ModeDir | 0755}).IsDir(), true)
+	t.CheckEquals((&memFile{"file", nil, 0644}).IsDir(), false)
+}
+
+func (s *Suite) Test_memFile_Sys(c *check.C) {
+	t := s.Init(c)
+
+	f := memFile{"file.txt", nil, 0644}
+
+	t.CheckEquals(f.Sys(), nil)
+}
+
+func (s *Suite) Test_NewMemFileSystem(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+
+	entries, err := m.ReadDir(".")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(entries), 0)
+}
+
+func (s *Suite) Test_MemFileSystem_Open(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("dir/file", []byte("content"), 0644))
+
+	f, err := m.Open("dir/file")
+	t.AssertNil(err)
+	data, err := io.ReadAll(f)
+
+	t.CheckEquals(string(data), "content")
+	t.CheckEquals(err, nil)
+
+	_, err = m.Open("missing")
+
+	t.CheckEquals(err.Error(), "open missing: file does not exist")
+}
+
+func (s *Suite) Test_MemFileSystem_Stat(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("/pkgsrc/dir/file", []byte("content"), 0644))
+
+	info, err := m.Stat("/pkgsrc/dir/../dir/file")
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.Name(), "file")
+	t.CheckEquals(info.Size(), int64(7))
+
+	info, err = m.Stat("/pkgsrc/dir")
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.IsDir(), true)
+
+	_, err = m.Stat("/pkgsrc/missing")
+
+	t.CheckEquals(errors.Is(err, fs.ErrNotExist), true)
+}
+
+func (s *Suite) Test_MemFileSystem_Lstat(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("file", nil, 0644))
+
+	info, err := m.Lstat("file")
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.Mode(), fs.FileMode(0644))
+
+	_, err = m.Lstat("missing")
+
+	t.CheckEquals(err.Error(), "lstat missing: file does not exist")
+}
+
+func (s *Suite) Test_MemFileSystem_ReadDir(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("dir/b", nil, 0644))
+	t.AssertNil(m.WriteFile("dir/a", nil, 0644))
+	t.AssertNil(m.WriteFile("dir/sub/c", nil, 0644))
+
+	entries, err := m.ReadDir("dir")
+
+	t.CheckEquals(err, nil)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	t.CheckDeepEquals(names, []string{"a", "b", "sub"})
+	t.CheckEquals(entries[2].IsDir(), true)
+
+	_, err = m.ReadDir("dir/a")
+
+	t.CheckEquals(err.Error(), "readdir dir/a: invalid argument")
+
+	_, err = m.ReadDir("missing")
+
+	t.CheckEquals(err.Error(), "readdir missing: file does not exist")
+}
+
+func (s *Suite) Test_MemFileSystem_ReadFile(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("dir/file", []byte("content"), 0644))
+
+	data, err := m.ReadFile("dir/file")
+
+	t.CheckEquals(string(data), "content")
+	t.CheckEquals(err, nil)
+
+	// The returned data is a copy.
+	data[0] = 'C'
+	data, _ = m.ReadFile("dir/file")
+	t.CheckEquals(string(data), "content")
+
+	_, err = m.ReadFile("dir")
+
+	t.CheckEquals(err.Error(), "read dir: invalid argument")
+
+	_, err = m.ReadFile("missing")
+
+	t.CheckEquals(err.Error(), "read missing: file does not exist")
+}
+
+func (s *Suite) Test_MemFileSystem_WriteFile(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+
+	t.CheckEquals(m.WriteFile("dir/file", []byte("1"), fs.ModeDir|0640), nil)
+	t.CheckEquals(m.WriteFile("dir/file", []byte("2"), 0600), nil)
+
+	info, _ := m.Stat("dir/file")
+	data, _ := m.ReadFile("dir/file")
+	t.CheckEquals(info.Mode(), fs.FileMode(0640))
+	t.CheckEquals(string(data), "2")
+
+	err := m.WriteFile("dir", nil, 0644)
+
+	t.CheckEquals(err.Error(), "write dir: invalid argument")
+
+	err = m.WriteFile("dir/file/sub", nil, 0644)
+
+	t.CheckEquals(err.Error(), "write dir/file/sub: file already exists")
+}
+
+func (s *Suite) Test_MemFileSystem_Rename(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("old", []byte("content"), 0644))
+
+	err := m.Rename("old", "dir/new")
+
+	t.CheckEquals(err, nil)
+	_, err = m.Stat("old")
+	t.CheckEquals(errors.Is(err, fs.ErrNotExist), true)
+	info, _ := m.Stat("dir/new")
+	t.CheckEquals(info.Name(), "new")
+
+	t.CheckEquals(m.Rename("missing", "new").Error(),
+		"rename missing: file does not exist")
+	t.CheckEquals(m.Rename("dir", "other").Error(),
+		"rename dir: invalid argument")
+	t.CheckEquals(m.Rename("dir/new", "dir/new/sub").Error(),
+		"rename dir/new/sub: file already exists")
+}
+
+func (s *Suite) Test_MemFileSystem_Chmod(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("dir/file", nil, 0644))
+
+	t.CheckEquals(m.Chmod("dir/file", 0755), nil)
+	t.CheckEquals(m.Chmod("dir", 0700), nil)
+
+	file, _ := m.Stat("dir/file")
+	dir, _ := m.Stat("dir")
+	t.CheckEquals(file.Mode(), fs.FileMode(0755))
+	t.CheckEquals(dir.Mode(), fs.ModeDir|0700)
+
+	t.CheckEquals(m.Chmod("missing", 0644).Error(),
+		"chmod missing: file does not exist")
+}
+
+func (s *Suite) Test_MemFileSystem_lookup(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("/dir/file", nil, 0644))
+
+	f, err := m.lookup("op", "/dir/./file")
+
+	t.CheckEquals(f.base, "file")
+	t.CheckEquals(err, nil)
+
+	_, err = m.lookup("op", "dir/file")
+
+	t.CheckEquals(err.Error(), "op dir/file: file does not exist")
+}
+
+func (s *Suite) Test_MemFileSystem_mkdirAll(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+
+	t.CheckEquals(m.mkdirAll("/a/b/c"), nil)
+	t.CheckEquals(m.mkdirAll("/a/b"), nil)
+
+	for _, dir := range []string{"/", "/a", "/a/b", "/a/b/c"} {
+		t.CheckEquals(m.files[dir].IsDir(), true)
+	}
+
+	t.AssertNil(m.WriteFile("/a/file", nil, 0644))
+
+	t.CheckEquals(m.mkdirAll("/a/file/sub"), fs.ErrExist)
+}
+
+func (s *Suite) Test_MemFileSystem_key(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+
+	t.CheckEquals(m.key("dir/../file"), "file")
+	t.CheckEquals(m.key("./dir//file/"), "dir/file")
+	t.CheckEquals(m.key("/pkgsrc/./category"), "/pkgsrc/category")
+	t.CheckEquals(m.key(""), ".")
+}
+
+func (s *Suite) Test_memOpenFile_Stat(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("file", []byte("content"), 0644))
+	f, _ := m.Open("file")
+
+	info, err := f.Stat()
+
+	t.CheckEquals(info.Size(), int64(7))
+	t.CheckEquals(err, nil)
+}
+
+func (s *Suite) Test_memOpenFile_Read(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("dir/file", []byte("content"), 0644))
+	f, _ := m.Open("dir/file")
+
+	buf := make([]byte, 4)
+	n, err := f.Read(buf)
+
+	t.CheckEquals(string(buf[:n]), "cont")
+	t.CheckEquals(err, nil)
+
+	dir, _ := m.Open("dir")
+
+	_, err = dir.Read(buf)
+
+	t.CheckEquals(err.Error(), "read dir: invalid argument")
+}
+
+func (s *Suite) Test_memOpenFile_ReadAt(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("dir/file", []byte("content"), 0644))
+	f, _ := m.Open("dir/file")
+
+	buf := make([]byte, 4)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 3)
+
+	t.CheckEquals(string(buf[:n]), "tent")
+	t.CheckEquals(err, nil)
+
+	dir, _ := m.Open("dir")
+
+	_, err = dir.(io.ReaderAt).ReadAt(buf, 0)
+
+	t.CheckEquals(err.Error(), "read dir: invalid argument")
+}
+
+func (s *Suite) Test_memOpenFile_Close(c *check.C) {
+	t := s.Init(c)
+
+	m := NewMemFileSystem()
+	t.AssertNil(m.WriteFile("file", nil, 0644))
+	f, _ := m.Open("file")
+
+	t.CheckEquals(f.Close(), nil)
+}
+
+func (s *Suite) Test_NewOverlayFileSystem(c *check.C) {
+	t := s.Init(c)
+
+	upper := NewMemFileSystem()
+	lower := NewMemFileSystem()
+
+	o := NewOverlayFileSystem(upper, lower)
+
+	t.CheckEquals(o.upper, upper)
+	t.CheckEquals(o.lower, FileSystem(lower))
+}
+
+// newOverlayForTest returns an overlay in which the upper file "both"
+// hides the lower file "both".
+func newOverlayForTest(t *Tester) *OverlayFileSystem {
+	upper := NewMemFileSystem()
+	lower := NewMemFileSystem()
+	t.AssertNil(upper.WriteFile("dir/upper", []byte("upper"), 0644))
+	t.AssertNil(upper.WriteFile("dir/both", []byte("upper"), 0644))
+	t.AssertNil(lower.WriteFile("dir/both", []byte("lower"), 0644))
+	t.AssertNil(lower.WriteFile("dir/lower", []byte("lower"), 0600))
+	t.AssertNil(lower.WriteFile("lower/file", []byte("lower"), 0644))
+	return NewOverlayFileSystem(upper, lower)
+}
+
+func (s *Suite) Test_OverlayFileSystem_Open(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	test := func(name, expected string) {
+		f, err := o.Open(name)
+		t.AssertNil(err)
+		data, _ := io.ReadAll(f)
+		t.CheckEquals(string(data), expected)
+	}
+
+	test("dir/both", "upper")
+	test("dir/lower", "lower")
+	test("dir/upper/../lower", "lower")
+
+	_, err := o.Open("dir/missing")
+	t.CheckEquals(err.Error(), "open dir/missing: file does not exist")
+}
+
+func (s *Suite) Test_OverlayFileSystem_Stat(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	info, err := o.Stat("dir/both")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.Size(), int64(5))
+
+	info, err = o.Stat("dir/lower")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.Mode(), fs.FileMode(0600))
+}
+
+func (s *Suite) Test_OverlayFileSystem_Lstat(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	info, err := o.Lstat("dir/upper")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.Name(), "upper")
+
+	info, err = o.Lstat("dir/upper/../../lower")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(info.IsDir(), true)
+}
+
+func (s *Suite) Test_OverlayFileSystem_ReadDir(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	test := func(name string, expected ...string) {
+		entries, err := o.ReadDir(name)
+		t.CheckEquals(err, nil)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.CheckDeepEquals(names, expected)
+	}
+
+	test(".", "dir", "lower")
+	test("dir", "both", "lower", "upper")
+	test("lower", "file")
+
+	_, err := o.ReadDir("dir/upper")
+	t.CheckEquals(err.Error(), "readdir dir/upper: invalid argument")
+
+	_, err = o.ReadDir("missing")
+	t.CheckEquals(err.Error(), "readdir missing: file does not exist")
+}
+
+func (s *Suite) Test_OverlayFileSystem_ReadFile(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	test := func(name, expected string) {
+		data, err := o.ReadFile(name)
+		t.CheckEquals(err, nil)
+		t.CheckEquals(string(data), expected)
+	}
+
+	test("dir/both", "upper")
+	test("dir/upper", "upper")
+	test("lower/file", "lower")
+}
+
+func (s *Suite) Test_OverlayFileSystem_Remove(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	o.Remove("dir/both")
+	o.Remove("./lower/file")
+
+	_, err := o.Stat("dir/both")
+	t.CheckEquals(err.Error(), "stat dir/both: file does not exist")
+	_, err = o.Lstat("dir/both")
+	t.CheckEquals(err.Error(), "lstat dir/both: file does not exist")
+	_, err = o.Open("lower/file")
+	t.CheckEquals(err.Error(), "open lower/file: file does not exist")
+	_, err = o.ReadFile("lower/file")
+	t.CheckEquals(err.Error(), "read lower/file: file does not exist")
+
+	entries, err := o.ReadDir("dir")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(entries), 2)
+	entries, err = o.ReadDir("lower")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(entries), 0)
+
+	// The lower file system is not modified.
+	data, err := o.lower.ReadFile("lower/file")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(string(data), "lower")
+}
+
+func (s *Suite) Test_OverlayFileSystem_Remove__directory(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	o.Remove("dir")
+
+	// The directory is gone from both file systems.
+	_, err := o.Stat("dir")
+	t.CheckEquals(err.Error(), "stat dir: file does not exist")
+	_, err = o.ReadFile("dir/upper")
+	t.CheckEquals(err.Error(), "read dir/upper: file does not exist")
+	_, err = o.ReadFile("dir/lower")
+	t.CheckEquals(err.Error(), "read dir/lower: file does not exist")
+	_, err = o.ReadDir("dir")
+	t.CheckEquals(err.Error(), "readdir dir: file does not exist")
+
+	// Files that are created afterwards are visible again.
+	t.AssertNil(o.upper.WriteFile("dir/new", []byte("new"), 0644))
+	entries, err := o.ReadDir("dir")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(entries), 1)
+}
+
+func (s *Suite) Test_OverlayFileSystem_Replace(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+
+	o.Replace("./dir")
+
+	entries, err := o.ReadDir("dir")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(entries), 2)
+	_, err = o.Stat("dir/lower")
+	t.CheckEquals(err.Error(), "stat dir/lower: file does not exist")
+	data, err := o.ReadFile("dir/both")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(string(data), "upper")
+
+	o.Replace("lower")
+
+	// The directory doesn't exist in the upper file system.
+	_, err = o.ReadDir("lower")
+	t.CheckEquals(err.Error(), "readdir lower: file does not exist")
+	entries, err = o.ReadDir(".")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(len(entries), 1)
+}
+
+func (s *Suite) Test_OverlayFileSystem_isHidden(c *check.C) {
+	t := s.Init(c)
+
+	o := newOverlayForTest(t)
+	o.Replace("dir")
+
+	t.CheckEquals(o.isHidden("dir"), true)
+	t.CheckEquals(o.isHidden("dir/lower"), true)
+	t.CheckEquals(o.isHidden("lower/file"), false)
+	t.CheckEquals(o.isHidden("."), false)
+	t.CheckEquals(o.isHidden("/"), false)
+}
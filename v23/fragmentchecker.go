@@ -0,0 +1,181 @@
+package pkglint
+
+import (
+	"sort"
+	"strings"
+)
+
+// FragmentChecker checks a shared makefile fragment, such as a
+// Makefile.common or lang/php/ext.mk, once in the context of each
+// package that includes it.
+//
+// The diagnostics that appear in the context of all these packages are
+// logged as usual. The others are logged together with the packages in
+// whose context they appear, since these are the packages that break
+// when the fragment is changed without looking at all of its users.
+type FragmentChecker struct {
+	fragment PkgsrcPath
+	filename CurrPath
+
+	// The package in whose directory the fragment is located, or nil.
+	// It provides the context when no package includes the fragment.
+	pkg *Package
+
+	// The packages that include the fragment, sorted by package path.
+	includers []PkgsrcPath
+
+	// The package in whose context the fragment is currently checked.
+	pkgpath PkgsrcPath
+
+	diags []*fragmentDiag
+	byKey map[string]*fragmentDiag
+
+	// The diagnostic to which a following explanation belongs,
+	// or nil if the diagnostic is not about the fragment.
+	last *fragmentDiag
+}
+
+// fragmentDiag is a diagnostic about the fragment, together with the
+// packages in whose context it appears.
+type fragmentDiag struct {
+	line        *Line
+	span        *Span
+	level       *LogLevel
+	format      string
+	msg         string
+	explanation []string
+	pkgpaths    []PkgsrcPath
+}
+
+func NewFragmentChecker(filename CurrPath, pkg *Package) *FragmentChecker {
+	return &FragmentChecker{G.Pkgsrc.Rel(filename), filename, pkg, nil, "", nil, make(map[string]*fragmentDiag), nil}
+}
+
+// Check checks the fragment in the context of each package from the
+// tree that includes it. If no package includes the fragment, it is
+// checked in the context of its own package, if any.
+func (ck *FragmentChecker) Check() {
+	ck.includers = G.InterPackage.IncludersInTree(ck.fragment)
+	if len(ck.includers) == 0 {
+		CheckFileMk(ck.filename, ck.pkg)
+		return
+	}
+
+	logger := &G.Logger
+	logged := logger.logged
+	logger.fragment = ck
+	for _, pkgpath := range ck.includers {
+		ck.pkgpath = pkgpath
+		ck.last = nil
+
+		// Each context gets its own chance to produce the diagnostics.
+		logger.logged = OncePerStringSlice{}
+
+		pkg := NewPackage(G.Pkgsrc.File(pkgpath))
+		if files, _, _ := pkg.load(); files != nil {
+			CheckFileMk(ck.filename, pkg)
+		}
+	}
+	logger.fragment = nil
+	logger.logged = logged
+
+	ck.report()
+}
+
+// record remembers a diagnostic from checking the fragment in the
+// context of the current package. Diagnostics about other files,
+// such as the package Makefile, are discarded.
+func (ck *FragmentChecker) record(line *Line, span *Span, level *LogLevel, format, msg string) {
+	ck.last = nil
+	if G.Pkgsrc.Rel(line.Filename()) != ck.fragment {
+		return
+	}
+
+	key := strings.Join([]string{level.TraditionalName, line.Linenos(), msg}, "\x00")
+	d := ck.byKey[key]
+	if d == nil {
+		if line.Filename() != ck.filename {
+			// When the fragment is loaded by the package, its
+			// filename is relative to the including file.
+			copied := *line
+			copied.Location.Filename = ck.filename
+			line = &copied
+		}
+		d = &fragmentDiag{line, span, level, format, msg, nil, nil}
+		ck.byKey[key] = d
+		ck.diags = append(ck.diags, d)
+	}
+	if n := len(d.pkgpaths); n == 0 || d.pkgpaths[n-1] != ck.pkgpath {
+		d.pkgpaths = append(d.pkgpaths, ck.pkgpath)
+	}
+	ck.last = d
+}
+
+func (ck *FragmentChecker) explain(explanation []string) {
+	if ck.last != nil && ck.last.explanation == nil {
+		ck.last.explanation = explanation
+	}
+}
+
+// report logs the recorded diagnostics, ordered by line number.
+func (ck *FragmentChecker) report() {
+	lineno := func(d *fragmentDiag) int {
+		if lineno := d.line.Location.lineno; lineno >= 0 {
+			return lineno
+		}
+		return int(^uint(0) >> 1) // EOF comes last.
+	}
+	sort.SliceStable(ck.diags, func(i, j int) bool {
+		return lineno(ck.diags[i]) < lineno(ck.diags[j])
+	})
+
+	logger := &G.Logger
+	for _, d := range ck.diags {
+		msg := d.msg
+		if len(d.pkgpaths) < len(ck.includers) {
+			msg += " " + ck.contexts(d.pkgpaths)
+		}
+
+		logger.Unsuppress()
+		logger.log(d.line, d.span, d.level, d.format, msg)
+		if d.explanation != nil {
+			logger.Explain(d.explanation...)
+		}
+	}
+}
+
+// contexts describes the packages in whose context a diagnostic appears,
+// naming whichever group is smaller.
+func (ck *FragmentChecker) contexts(pkgpaths []PkgsrcPath) string {
+	n := len(ck.includers)
+	if 2*len(pkgpaths) <= n {
+		return sprintf("(In %d of %d includers: %s.)",
+			len(pkgpaths), n, joinPkgpaths(pkgpaths))
+	}
+
+	var others []PkgsrcPath
+	for _, includer := range ck.includers {
+		if !containsPkgpath(pkgpaths, includer) {
+			others = append(others, includer)
+		}
+	}
+	return sprintf("(In %d of %d includers, not in %s.)",
+		len(pkgpaths), n, joinPkgpaths(others))
+}
+
+func joinPkgpaths(pkgpaths []PkgsrcPath) string {
+	strs := make([]string, len(pkgpaths))
+	for i, pkgpath := range pkgpaths {
+		strs[i] = pkgpath.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+func containsPkgpath(pkgpaths []PkgsrcPath, pkgpath PkgsrcPath) bool {
+	for _, p := range pkgpaths {
+		if p == pkgpath {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+// setUpFragmentIncluders creates the packages category/a, category/b
+// and category/c, which include category/common/Makefile.common.
+// Only category/a defines COMMON_DIR.
+func setUpFragmentIncluders(t *Tester) {
+	t.SetUpPackage("category/a",
+		"COMMON_DIR=\t${.CURDIR}",
+		".include \"../../category/common/Makefile.common\"")
+	t.SetUpPackage("category/b",
+		".include \"../../category/common/Makefile.common\"")
+	t.SetUpPackage("category/c",
+		".include \"../../category/common/Makefile.common\"")
+	t.SetUpPackage("category/other")
+	t.CreateFileLines("category/common/Makefile.common",
+		MkCvsID,
+		"# used by category/a/Makefile",
+		"# used by category/b/Makefile",
+		"# used by category/c/Makefile",
+		"",
+		"CONFIGURE_ARGS+=\t--dir=${COMMON_DIR}",
+		"CONFIGURE_ARGS+=\t--undefined=${UNDEFINED}")
+}
+
+func (s *Suite) Test_NewFragmentChecker(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+
+	ck := NewFragmentChecker(t.File("category/common/Makefile.common"), nil)
+
+	t.CheckEquals(ck.fragment, NewPkgsrcPath("category/common/Makefile.common"))
+	t.CheckLen(ck.diags, 0)
+}
+
+func (s *Suite) Test_FragmentChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	setUpFragmentIncluders(t)
+	t.Chdir(".")
+
+	t.Main("-Wall", "-Cincluders", "-q", "category/common/Makefile.common",
+		"category/a", "category/b", "category/c", "category/other")
+
+	// The fragment is checked after the packages, in the context of
+	// those packages that include it.
+	t.CheckOutputLines(
+		"WARN: category/common/Makefile.common:6: "+
+			"Variable \"COMMON_DIR\" is used but not defined. "+
+			"(In 2 of 3 includers, not in category/a.)",
+		"WARN: category/common/Makefile.common:7: "+
+			"Variable \"UNDEFINED\" is used but not defined.")
+}
+
+// Without the -Cincluders option, the fragment is checked on its own.
+func (s *Suite) Test_FragmentChecker_Check__disabled(c *check.C) {
+	t := s.Init(c)
+
+	setUpFragmentIncluders(t)
+	t.Chdir(".")
+
+	t.Main("-Wall", "-q", "category/common/Makefile.common")
+
+	t.CheckOutputLines(
+		"WARN: category/common/Makefile.common:6: "+
+			"Variable \"COMMON_DIR\" is used but not defined.",
+		"WARN: category/common/Makefile.common:7: "+
+			"Variable \"UNDEFINED\" is used but not defined.")
+}
+
+// Diagnostics that come with an autofix are aggregated as well.
+func (s *Suite) Test_FragmentChecker_Check__autofix_diagnostic(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/a",
+		".include \"../../category/common/Makefile.common\"")
+	t.SetUpPackage("category/b",
+		".include \"../../category/common/Makefile.common\"")
+	t.CreateFileLines("category/common/Makefile.common",
+		MkCvsID,
+		"# used by category/a/Makefile",
+		"",
+		"CONFIGURE_ARGS+=\t--enable-feature")
+	t.Chdir(".")
+
+	t.Main("-Cincluders", "-q", "category/a", "category/b", "category/common/Makefile.common")
+
+	// The first warning comes from checking category/b itself.
+	t.CheckOutputLines(
+		"WARN: category/b/../../category/common/Makefile.common:2: "+
+			"Add a line \"# used by category/b/Makefile\" here.",
+		"WARN: category/common/Makefile.common:2: "+
+			"Add a line \"# used by category/b/Makefile\" here. "+
+			"(In 1 of 2 includers: category/b.)")
+}
+
+// The includers are searched in the whole tree, not only among the
+// packages that are given in the command line.
+func (s *Suite) Test_FragmentChecker_Check__unchecked_includers(c *check.C) {
+	t := s.Init(c)
+
+	setUpFragmentIncluders(t)
+	t.Chdir(".")
+
+	t.Main("-Wall", "-Cincluders", "-q", "category/common/Makefile.common")
+
+	t.CheckOutputLines(
+		"WARN: category/common/Makefile.common:6: "+
+			"Variable \"COMMON_DIR\" is used but not defined. "+
+			"(In 2 of 3 includers, not in category/a.)",
+		"WARN: category/common/Makefile.common:7: "+
+			"Variable \"UNDEFINED\" is used but not defined.")
+}
+
+// A fragment from a package directory is checked as part of the
+// package, and again in the context of the packages that include it.
+func (s *Suite) Test_FragmentChecker_Check__package_fragment(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/a",
+		".include \"Makefile.common\"")
+	t.SetUpPackage("category/b",
+		"COMMON_DIR=\t${.CURDIR}",
+		".include \"../../category/a/Makefile.common\"")
+	t.CreateFileLines("category/a/Makefile.common",
+		MkCvsID,
+		"# used by category/a/Makefile",
+		"# used by category/b/Makefile",
+		"",
+		"CONFIGURE_ARGS+=\t--dir=${COMMON_DIR}")
+	t.Chdir(".")
+
+	t.Main("-Wall", "-Cincluders", "-q", "category/a")
+
+	t.CheckOutputLines(
+		"WARN: category/a/Makefile.common:5: " +
+			"Variable \"COMMON_DIR\" is used but not defined. " +
+			"(In 1 of 2 includers: category/a.)")
+}
+
+func (s *Suite) Test_FragmentChecker_Check__no_includers(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/common/Makefile.common",
+		MkCvsID,
+		"",
+		"CONFIGURE_ARGS+=\t--undefined=${UNDEFINED}")
+	t.Chdir(".")
+
+	t.Main("-Wall", "-Cincluders", "-q", "category/common/Makefile.common")
+
+	t.CheckOutputLines(
+		"WARN: category/common/Makefile.common:3: " +
+			"Variable \"UNDEFINED\" is used but not defined.")
+}
+
+func (s *Suite) Test_FragmentChecker_record(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewFragmentChecker(t.File("category/common/Makefile.common"), nil)
+	line := t.NewLine(t.File("category/a/../common/Makefile.common"), 3, "")
+	other := t.NewLine(t.File("category/a/Makefile"), 3, "")
+
+	ck.pkgpath = "category/a"
+	ck.record(line, nil, Warn, "Warning.", "Warning.")
+	ck.record(line, nil, Warn, "Warning.", "Warning.")
+	ck.record(line, nil, Note, "Note.", "Note.")
+	ck.record(other, nil, Warn, "Warning.", "Warning.")
+	ck.pkgpath = "category/b"
+	ck.record(line, nil, Warn, "Warning.", "Warning.")
+
+	t.CheckLen(ck.diags, 2)
+	t.CheckEquals(ck.diags[0].line.Filename(), t.File("category/common/Makefile.common"))
+	t.CheckDeepEquals(ck.diags[0].pkgpaths, []PkgsrcPath{"category/a", "category/b"})
+	t.CheckDeepEquals(ck.diags[1].pkgpaths, []PkgsrcPath{"category/a"})
+	t.CheckEquals(ck.last, ck.diags[0])
+
+	// The line from the package Makefile has reset the last diagnostic.
+	ck.record(other, nil, Warn, "Warning.", "Warning.")
+
+	t.CheckEquals(ck.last, (*fragmentDiag)(nil))
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_FragmentChecker_explain(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewFragmentChecker(t.File("category/common/Makefile.common"), nil)
+	line := t.NewLine(t.File("category/common/Makefile.common"), 3, "")
+
+	ck.explain([]string{"Without diagnostic."})
+	ck.record(line, nil, Warn, "Warning.", "Warning.")
+	ck.explain([]string{"First."})
+	ck.record(line, nil, Warn, "Warning.", "Warning.")
+	ck.explain([]string{"Second."})
+
+	t.CheckDeepEquals(ck.diags[0].explanation, []string{"First."})
+}
+
+func (s *Suite) Test_FragmentChecker_report(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewFragmentChecker(t.File("Makefile.common"), nil)
+	ck.includers = []PkgsrcPath{"category/a", "category/b"}
+	eof := t.NewLine(t.File("Makefile.common"), -1, "")
+	line := t.NewLine(t.File("Makefile.common"), 3, "")
+
+	ck.pkgpath = "category/a"
+	ck.record(eof, nil, Note, "Note.", "Note.")
+	ck.record(line, nil, Warn, "Warning.", "Warning.")
+	ck.explain([]string{"Explanation."})
+	ck.pkgpath = "category/b"
+	ck.record(line, nil, Warn, "Warning.", "Warning.")
+
+	ck.report()
+
+	t.CheckOutputLines(
+		"WARN: ~/Makefile.common:3: Warning.",
+		"",
+		"\tExplanation.",
+		"",
+		"NOTE: ~/Makefile.common:EOF: Note. (In 1 of 2 includers: category/a.)")
+}
+
+func (s *Suite) Test_FragmentChecker_contexts(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewFragmentChecker(t.File("Makefile.common"), nil)
+	ck.includers = []PkgsrcPath{"category/a", "category/b", "category/c"}
+
+	t.CheckEquals(ck.contexts([]PkgsrcPath{"category/b"}),
+		"(In 1 of 3 includers: category/b.)")
+	t.CheckEquals(ck.contexts([]PkgsrcPath{"category/a", "category/c"}),
+		"(In 2 of 3 includers, not in category/b.)")
+}
+
+func (s *Suite) Test_joinPkgpaths(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(joinPkgpaths(nil), "")
+	t.CheckEquals(joinPkgpaths([]PkgsrcPath{"category/a", "category/b"}),
+		"category/a, category/b")
+}
+
+func (s *Suite) Test_containsPkgpath(c *check.C) {
+	t := s.Init(c)
+
+	pkgpaths := []PkgsrcPath{"category/a", "category/b"}
+
+	t.CheckEquals(containsPkgpath(pkgpaths, "category/b"), true)
+	t.CheckEquals(containsPkgpath(pkgpaths, "category/c"), false)
+	t.CheckEquals(containsPkgpath(nil, "category/a"), false)
+}
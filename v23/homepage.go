@@ -97,7 +97,7 @@ func (ck *HomepageChecker) checkBasedOnMasterSites() {
 		"",
 		"Defining MASTER_SITES=${HOMEPAGE} is ok, though.")
 	if baseURL != "" {
-		fix.Replace(wrong, fixedURL)
+		fix.ReplaceInTree(treeValue, wrong, fixedURL)
 	}
 	fix.Apply()
 }
@@ -129,7 +129,7 @@ func (ck *HomepageChecker) checkHttp() {
 	fix := ck.MkLine.Autofix()
 	fix.Rationale(ck.MkLine, "http", "https")
 	fix.Warnf("HOMEPAGE should migrate from %s to %s.", from, to)
-	fix.Replace(from, to)
+	fix.ReplaceInTree(treeValue, from, to)
 	if from == "http" {
 		fix.Explain(
 			"To provide secure communication by default,",
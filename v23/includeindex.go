@@ -0,0 +1,205 @@
+package pkglint
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// IncludeIndex records which makefile fragments include which other
+// fragments, for finding the packages that are affected by a change to
+// a shared file, such as mk/compiler.mk or the buildlink3.mk file of a
+// library.
+//
+// The includes from the checked packages are resolved in the context of
+// the package. The files that are not loaded by the package, such as
+// the infrastructure files, are scanned on demand, without a package
+// context.
+type IncludeIndex struct {
+	// The Makefiles of the packages, e.g. "devel/app/Makefile".
+	makefiles map[PkgsrcPath]bool
+
+	// For each file, the files that include it directly.
+	includers map[PkgsrcPath][]PkgsrcPath
+	edges     map[[2]PkgsrcPath]bool
+
+	// The files whose includes have already been added.
+	scanned map[PkgsrcPath]bool
+
+	// Whether the packages from the whole tree have been added.
+	tree bool
+}
+
+func NewIncludeIndex() *IncludeIndex {
+	return &IncludeIndex{
+		make(map[PkgsrcPath]bool),
+		make(map[PkgsrcPath][]PkgsrcPath),
+		make(map[[2]PkgsrcPath]bool),
+		make(map[PkgsrcPath]bool),
+		false}
+}
+
+// AddPackage adds the includes from the package Makefile and all
+// files that have been loaded for it.
+func (ix *IncludeIndex) AddPackage(pkgpath PkgsrcPath, allLines *MkLines, pkg *Package) {
+	makefile := pkgpath.JoinNoClean("Makefile")
+	if ix.makefiles[makefile] {
+		return
+	}
+	ix.makefiles[makefile] = true
+	ix.addIncludes(allLines, pkg)
+}
+
+// AddTree adds the package Makefiles from the whole tree, so that
+// Includers also finds the packages that have not been checked.
+//
+// The includes of these packages are resolved without the context of
+// the package, therefore the includes whose path depends on a variable
+// are missed.
+func (ix *IncludeIndex) AddTree() {
+	if ix.tree {
+		return
+	}
+	ix.tree = true
+
+	for _, pkgpath := range G.Pkgsrc.Packages() {
+		makefile := pkgpath.JoinNoClean("Makefile")
+		if ix.makefiles[makefile] {
+			continue
+		}
+		if mklines := LoadMk(G.Pkgsrc.File(makefile), nil, 0); mklines != nil {
+			ix.AddPackage(pkgpath, mklines, nil)
+		}
+	}
+}
+
+// Includers returns the include chains from the package Makefiles to
+// the given file, one for each package, sorted by package path.
+// Each chain starts with the package Makefile and ends with the file.
+// If there are several chains for a package, the shortest is returned.
+func (ix *IncludeIndex) Includers(file PkgsrcPath) [][]PkgsrcPath {
+	ix.scanAll()
+
+	// For each file, the next file on the way to the given file.
+	next := map[PkgsrcPath]PkgsrcPath{file: ""}
+	queue := []PkgsrcPath{file}
+	var found []PkgsrcPath
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if ix.makefiles[curr] {
+			found = append(found, curr)
+		}
+		for _, includer := range ix.includers[curr] {
+			if _, seen := next[includer]; !seen {
+				next[includer] = curr
+				queue = append(queue, includer)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+
+	var chains [][]PkgsrcPath
+	for _, makefile := range found {
+		var chain []PkgsrcPath
+		for curr := makefile; curr != ""; curr = next[curr] {
+			chain = append(chain, curr)
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
+// Write writes the packages that include the given file, together
+// with the include chain, one package per line.
+func (ix *IncludeIndex) Write(out io.Writer, file PkgsrcPath) error {
+	for _, chain := range ix.Includers(file) {
+		paths := make([]string, len(chain))
+		for i, p := range chain {
+			paths[i] = p.String()
+		}
+		_, err := io.WriteString(out, sprintf("%s: %s\n",
+			chain[0].Dir().String(), strings.Join(paths, " -> ")))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ix *IncludeIndex) addIncludes(mklines *MkLines, pkg *Package) {
+	for _, mkline := range mklines.mklines {
+		from := G.Pkgsrc.Rel(mkline.Filename())
+		ix.scanned[from] = true
+
+		if !mkline.IsInclude() {
+			continue
+		}
+		to := ix.resolve(mkline, pkg)
+		if to == "" {
+			continue
+		}
+		if edge := [2]PkgsrcPath{from, to}; !ix.edges[edge] {
+			ix.edges[edge] = true
+			ix.includers[to] = append(ix.includers[to], from)
+		}
+	}
+}
+
+// resolve returns the file that is included by the .include directive,
+// or an empty path if it cannot be determined.
+func (ix *IncludeIndex) resolve(mkline *MkLine, pkg *Package) PkgsrcPath {
+	included := mkline.ResolveExprsInRelPath(mkline.IncludedFile(), pkg)
+	text := included.String()
+	if pkg != nil {
+		text = resolveExprs(text, nil, pkg)
+	}
+	if containsExpr(text) {
+		return ""
+	}
+	rel := NewRelPathString(text)
+
+	// Like bmake, look in the directory of the including file first.
+	// Then, look in the package directory or, if there is no package,
+	// assume that the path is relative to a package directory,
+	// as is common in the infrastructure files.
+	if file := mkline.File(rel); file.IsFile() {
+		return G.Pkgsrc.Rel(file)
+	}
+	if pkg != nil {
+		if file := pkg.File(NewPackagePath(rel)); file.IsFile() {
+			return G.Pkgsrc.Rel(file)
+		}
+	} else if rel.HasPrefixPath("../..") {
+		pkgsrcRel := NewPkgsrcPath(NewPath(strings.TrimPrefix(rel.String(), "../../")))
+		if G.Pkgsrc.File(pkgsrcRel).IsFile() {
+			return pkgsrcRel
+		}
+	}
+	return ""
+}
+
+// scanAll adds the includes from the files that are included by the
+// checked packages but have not been loaded for them.
+func (ix *IncludeIndex) scanAll() {
+	for {
+		var unscanned []PkgsrcPath
+		for to := range ix.includers {
+			if !ix.scanned[to] {
+				unscanned = append(unscanned, to)
+			}
+		}
+		if len(unscanned) == 0 {
+			return
+		}
+		sort.Slice(unscanned, func(i, j int) bool { return unscanned[i] < unscanned[j] })
+
+		for _, file := range unscanned {
+			ix.scanned[file] = true
+			if mklines := LoadMk(G.Pkgsrc.File(file), nil, 0); mklines != nil {
+				ix.addIncludes(mklines, nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package pkglint
+
+import (
+	"gopkg.in/check.v1"
+	"strings"
+)
+
+// newIncludeIndexForTest creates an index from edges of the form
+// "from to", where the files ending in "/Makefile" are the packages.
+func newIncludeIndexForTest(edges ...string) *IncludeIndex {
+	ix := NewIncludeIndex()
+	for _, edge := range edges {
+		fields := strings.Fields(edge)
+		from, to := PkgsrcPath(fields[0]), PkgsrcPath(fields[1])
+		if from.Base() == "Makefile" {
+			ix.makefiles[from] = true
+		}
+		ix.scanned[from] = true
+		ix.scanned[to] = true
+		ix.includers[to] = append(ix.includers[to], from)
+	}
+	return ix
+}
+
+func (s *Suite) Test_NewIncludeIndex(c *check.C) {
+	t := s.Init(c)
+
+	ix := NewIncludeIndex()
+
+	t.CheckLen(ix.makefiles, 0)
+	t.CheckLen(ix.includers, 0)
+	t.CheckLen(ix.edges, 0)
+	t.CheckLen(ix.scanned, 0)
+	t.CheckEquals(ix.tree, false)
+}
+
+func (s *Suite) Test_IncludeIndex_AddPackage(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		".include \"../../category/package/options.mk\"")
+	t.CreateFileLines("category/package/options.mk",
+		MkCvsID)
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+	files, mklines, allLines := pkg.load()
+	t.CheckNotNil(files)
+	t.CheckNotNil(mklines)
+	ix := NewIncludeIndex()
+
+	ix.AddPackage("category/package", allLines, pkg)
+	ix.AddPackage("category/package", allLines, pkg)
+
+	// The second call is ignored.
+	t.CheckDeepEquals(ix.makefiles, map[PkgsrcPath]bool{
+		"category/package/Makefile": true})
+	t.CheckDeepEquals(ix.includers["category/package/options.mk"],
+		[]PkgsrcPath{"category/package/Makefile"})
+}
+
+func (s *Suite) Test_IncludeIndex_AddTree(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/checked",
+		".include \"../../category/lib/buildlink3.mk\"")
+	t.SetUpPackage("category/unchecked",
+		".include \"../../category/lib/buildlink3.mk\"")
+	t.SetUpPackage("category/lib")
+	t.CreateFileBuildlink3("category/lib/buildlink3.mk")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/checked"))
+	_, _, allLines := pkg.load()
+	ix := NewIncludeIndex()
+	ix.AddPackage("category/checked", allLines, pkg)
+
+	ix.AddTree()
+
+	t.CheckDeepEquals(ix.includers["category/lib/buildlink3.mk"], []PkgsrcPath{
+		"category/checked/Makefile",
+		"category/unchecked/Makefile"})
+	t.CheckEquals(ix.tree, true)
+
+	// The tree is only scanned once.
+	delete(ix.makefiles, "category/unchecked/Makefile")
+	ix.AddTree()
+
+	t.CheckEquals(ix.makefiles["category/unchecked/Makefile"], false)
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_IncludeIndex_Includers(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ix := newIncludeIndexForTest(
+		"devel/a/Makefile mk/bsd.pkg.mk",
+		"devel/a/Makefile devel/lib/buildlink3.mk",
+		"devel/lib/buildlink3.mk mk/compiler.mk",
+		"mk/bsd.pkg.mk mk/bsd.prefs.mk",
+		"mk/bsd.prefs.mk mk/compiler.mk",
+		"devel/b/Makefile mk/bsd.pkg.mk",
+		"devel/lib/Makefile devel/lib/Makefile.common",
+		"devel/lib/Makefile.common devel/lib/Makefile.common")
+
+	test := func(file PkgsrcPath, expected ...string) {
+		var actual []string
+		for _, chain := range ix.Includers(file) {
+			var paths []string
+			for _, p := range chain {
+				paths = append(paths, p.String())
+			}
+			actual = append(actual, strings.Join(paths, " -> "))
+		}
+		t.CheckDeepEquals(actual, expected)
+	}
+
+	// For devel/a, the shorter chain via the buildlink3.mk file is used.
+	test("mk/compiler.mk",
+		"devel/a/Makefile -> devel/lib/buildlink3.mk -> mk/compiler.mk",
+		"devel/b/Makefile -> mk/bsd.pkg.mk -> mk/bsd.prefs.mk -> mk/compiler.mk")
+
+	// Cyclic includes don't lead to endless loops.
+	test("devel/lib/Makefile.common",
+		"devel/lib/Makefile -> devel/lib/Makefile.common")
+
+	// A package Makefile includes itself.
+	test("devel/b/Makefile",
+		"devel/b/Makefile")
+
+	test("mk/unknown.mk",
+		nil...)
+}
+
+func (s *Suite) Test_IncludeIndex_Write(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ix := newIncludeIndexForTest(
+		"devel/a/Makefile mk/bsd.pkg.mk",
+		"devel/b/Makefile devel/a/Makefile.common",
+		"devel/a/Makefile.common mk/bsd.pkg.mk")
+
+	var sb strings.Builder
+	err := ix.Write(&sb, "mk/bsd.pkg.mk")
+
+	t.CheckEquals(err, nil)
+	t.CheckEquals(sb.String(), ""+
+		"devel/a: devel/a/Makefile -> mk/bsd.pkg.mk\n"+
+		"devel/b: devel/b/Makefile -> devel/a/Makefile.common -> mk/bsd.pkg.mk\n")
+}
+
+func (s *Suite) Test_IncludeIndex_addIncludes(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("category/package/options.mk",
+		MkCvsID)
+	mklines := t.SetUpFileMkLines("category/package/Makefile",
+		MkCvsID,
+		".include \"options.mk\"",
+		".include \"options.mk\"",
+		".include \"${UNKNOWN}/file.mk\"",
+		".include \"../../mk/bsd.pkg.mk\"")
+	t.FinishSetUp()
+	ix := NewIncludeIndex()
+
+	ix.addIncludes(mklines, nil)
+
+	// Duplicate includes are recorded only once.
+	// Unresolvable includes are skipped.
+	t.CheckDeepEquals(ix.includers, map[PkgsrcPath][]PkgsrcPath{
+		"category/package/options.mk": {"category/package/Makefile"},
+		"mk/bsd.pkg.mk":               {"category/package/Makefile"}})
+	t.CheckDeepEquals(ix.scanned, map[PkgsrcPath]bool{
+		"category/package/Makefile": true})
+}
+
+func (s *Suite) Test_IncludeIndex_resolve(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/options.mk",
+		MkCvsID)
+	t.CreateFileLines("mk/compiler.mk",
+		MkCvsID)
+	t.CreateFileLines("mk/compiler/gcc.mk",
+		MkCvsID)
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+	ix := NewIncludeIndex()
+
+	test := func(filename CurrPath, include string, pkg *Package, expected PkgsrcPath) {
+		mkline := t.NewMkLine(filename, 123, ".include \""+include+"\"")
+		t.CheckEquals(ix.resolve(mkline, pkg), expected)
+	}
+
+	test(t.File("category/package/Makefile"), "options.mk", pkg,
+		"category/package/options.mk")
+	test(t.File("category/package/Makefile"), "../../mk/compiler.mk", pkg,
+		"mk/compiler.mk")
+
+	// The fallback to the package directory.
+	test(t.File("mk/compiler.mk"), "options.mk", pkg,
+		"category/package/options.mk")
+
+	// Without a package, a path starting with ../.. is assumed to be
+	// relative to a package directory.
+	test(t.File("mk/compiler.mk"), "../../mk/compiler/gcc.mk", nil,
+		"mk/compiler/gcc.mk")
+	test(t.File("mk/compiler.mk"), "compiler/gcc.mk", nil,
+		"mk/compiler/gcc.mk")
+	test(t.File("mk/compiler.mk"), "../../mk/nonexistent.mk", nil,
+		"")
+	test(t.File("mk/compiler.mk"), "options.mk", nil,
+		"")
+	test(t.File("mk/compiler.mk"), "${UNKNOWN}.mk", nil,
+		"")
+}
+
+func (s *Suite) Test_IncludeIndex_scanAll(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("mk/bsd.pkg.mk",
+		MkCvsID,
+		".include \"bsd.prefs.mk\"")
+	t.CreateFileLines("mk/bsd.prefs.mk",
+		MkCvsID,
+		".include \"../../mk/compiler.mk\"")
+	t.CreateFileLines("mk/compiler.mk",
+		MkCvsID)
+	t.FinishSetUp()
+	ix := newIncludeIndexForTest()
+	ix.includers["mk/bsd.pkg.mk"] = []PkgsrcPath{"category/package/Makefile"}
+	ix.includers["mk/nonexistent.mk"] = []PkgsrcPath{"category/package/Makefile"}
+
+	ix.scanAll()
+
+	// The included files are scanned transitively.
+	t.CheckDeepEquals(ix.includers["mk/bsd.prefs.mk"], []PkgsrcPath{"mk/bsd.pkg.mk"})
+	t.CheckDeepEquals(ix.includers["mk/compiler.mk"], []PkgsrcPath{"mk/bsd.prefs.mk"})
+	t.CheckEquals(ix.scanned["mk/nonexistent.mk"], true)
+}
@@ -14,7 +14,9 @@ package pkglint
 // used in the --autofix mode.
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/regex"
+	"github.com/rillig/pkglint/v23/textproc"
 	"strconv"
 	"strings"
 )
@@ -51,6 +53,13 @@ func (loc *Location) File(rel RelPath) CurrPath {
 	return loc.Filename.Dir().JoinNoClean(rel)
 }
 
+// Span is a range of bytes in the logical text of a line (Line.Text).
+// It is used for pointing diagnostics to the relevant part of a line.
+type Span struct {
+	Start int
+	End   int
+}
+
 // Line represents a line of text from a file.
 // In makefiles, a single "logical" line can consist of multiple "raw" lines,
 // which happens when a line ends with an odd number of backslashes.
@@ -166,6 +175,60 @@ func (line *Line) RawText(rawIndex int) string {
 	return strings.TrimSuffix(textnl, "\n")
 }
 
+// Tree parses the raw text of the line, including any previous autofixes,
+// into a lossless syntax tree.
+//
+// To modify the line, create an ast.FileEditor for the returned file
+// and pass it to Autofix.Edit.
+func (line *Line) Tree() (*ast.File, ast.MkLine) {
+	var text strings.Builder
+	for rawIndex := range line.raw {
+		text.WriteString(line.RawText(rawIndex))
+		text.WriteString("\n")
+	}
+	f := ast.NewFile(text.String())
+	return f, ast.NewMkParser(f).ParseLine()
+}
+
+// Position maps a byte offset in the logical text of the line to the
+// raw line in which it appears, and to the column in that raw line.
+// Columns are counted in bytes, starting at 1.
+//
+// Offsets at the space that joins two continuation lines are mapped to
+// the end of the first of these lines.
+func (line *Line) Position(offset int) (rawIndex int, column int) {
+	assert(len(line.raw) > 0)
+
+	// This must stay in sync with nextLogicalLine.
+	logical := 0
+	trim := ""
+	last := len(line.raw) - 1
+	for i, rawLine := range line.raw {
+		indent, rawText, _, _ := matchContinuationLine(rawLine.Orig())
+		text := indent + rawText
+		rawStart := 0
+		if i > 0 {
+			text = strings.TrimPrefix(rawText, trim)
+			rawStart = len(indent) + len(rawText) - len(text)
+		}
+
+		if i == last || offset <= logical+len(text) {
+			return i, rawStart + offset - logical + 1
+		}
+
+		logical += len(text) + 1
+		trim = textproc.NewLexer(rawText).NextString("#")
+	}
+	panic("unreachable")
+}
+
+// At returns a diagnoser whose diagnostics point to the given part of
+// the line, which is given as byte offsets into Line.Text.
+func (line *Line) At(span Span) Diagnoser {
+	assert(0 <= span.Start && span.Start <= span.End && span.End <= len(line.Text))
+	return &SpanDiagnoser{line, span}
+}
+
 func (line *Line) IsCvsID(prefixRe regex.Pattern) (found bool, expanded bool) {
 	m, exp := match1(line.Text, `^`+prefixRe+`\$`+`NetBSD(:[^\$]+)?\$$`)
 	return m, exp != ""
@@ -204,8 +267,8 @@ func (line *Line) String() string {
 //	    "Explanation ...",
 //	    "... end of explanation.")
 //
-//	fix.Replace("from", "to")
-//	fix.ReplaceAfter("prefix", "from", "to")
+//	fix.ReplaceInTree(treeCode, "from", "to")
+//	fix.Edit(editor)
 //	fix.InsertAbove("new line")
 //	fix.InsertBelow("new line")
 //	fix.Delete()
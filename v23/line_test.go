@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/regex"
 	"gopkg.in/check.v1"
 )
@@ -161,6 +162,93 @@ func (s *Suite) Test_Line_RawText(c *check.C) {
 		"AUTOFIX: filename:123: Replacing \"text\" with \"replaced\".")
 }
 
+func (s *Suite) Test_Line_Tree(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mklines := t.NewMkLines("filename.mk",
+		"VAR=\tvalue \\",
+		"\tcontinued # comment")
+	line := mklines.mklines[0].Line
+
+	f, tree := line.Tree()
+
+	assign := tree.(*ast.MkAssignLine)
+	t.CheckEquals(f.Text(assign.Value), "value \\\n\tcontinued")
+	t.CheckEquals(assign.Value.LogicalText, "value continued")
+
+	fix := line.Autofix()
+	fix.Notef("Replacing.")
+	fix.Replace("value", "other")
+	fix.Apply()
+
+	// The tree is created from the text after applying the autofixes.
+	f, tree = line.Tree()
+
+	t.CheckEquals(f.Text(tree.(*ast.MkAssignLine).Value), "other \\\n\tcontinued")
+	t.CheckOutputLines(
+		"NOTE: filename.mk:1: Replacing.",
+		"AUTOFIX: filename.mk:1: Replacing \"value\" with \"other\".")
+}
+
+func (s *Suite) Test_Line_Position(c *check.C) {
+	t := s.Init(c)
+
+	mklines := t.NewMkLines("filename.mk",
+		"CONFIGURE_ARGS+=\t--first \\",
+		"\t\t\t--second \\",
+		"\t\t\t--third")
+	line := mklines.mklines[0].Line
+
+	t.CheckEquals(line.Text, "CONFIGURE_ARGS+=\t--first --second --third")
+
+	test := func(offset int, rawIndex int, column int) {
+		actualRawIndex, actualColumn := line.Position(offset)
+		t.CheckDeepEquals(
+			[]int{offset, actualRawIndex, actualColumn},
+			[]int{offset, rawIndex, column})
+	}
+
+	test(0, 0, 1)
+	test(17, 0, 18)  // --first
+	test(24, 0, 25)  // the space between the lines
+	test(25, 1, 4)   // --second
+	test(33, 1, 12)  // the space between the lines
+	test(34, 2, 4)   // --third
+	test(41, 2, 11)  // the end of the text
+	test(100, 2, 70) // beyond the end of the text
+}
+
+func (s *Suite) Test_Line_Position__single_line(c *check.C) {
+	t := s.Init(c)
+
+	line := t.NewLine("filename", 123, "  indented text \\")
+
+	rawIndex, column := line.Position(2)
+
+	t.CheckEquals(rawIndex, 0)
+	t.CheckEquals(column, 3)
+}
+
+func (s *Suite) Test_Line_At(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	mklines := t.NewMkLines("filename.mk",
+		"CONFIGURE_ARGS+=\t--first \\",
+		"\t\t\t--second")
+	line := mklines.mklines[0].Line
+
+	line.At(Span{25, 33}).Warnf("The second argument is suspicious.")
+	line.Warnf("The line has no column.")
+
+	t.CheckOutputLines(
+		"filename.mk:2:4: warning: The second argument is suspicious.",
+		"filename.mk:1--2: warning: The line has no column.")
+
+	t.ExpectAssert(func() { line.At(Span{25, 34}) })
+}
+
 func (s *Suite) Test_Line_IsCvsID(c *check.C) {
 	t := s.Init(c)
 
@@ -68,7 +68,7 @@ func (ls *Lines) CheckCvsID(index int, prefixRe regex.Pattern, suggestedPrefix s
 				"",
 				"To preserve the history of the CVS Id, should that ever be needed,",
 				"remove the leading $.")
-			fix.Replace(line.Text, suggestedPrefix+"$"+"NetBSD$")
+			fix.ReplaceInTree(treeLine, line.Text, suggestedPrefix+"$"+"NetBSD$")
 			fix.Apply()
 		}
 
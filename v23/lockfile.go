@@ -0,0 +1,255 @@
+package pkglint
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// LockfileChecker compares the GO_MODULE_FILES and the
+// CARGO_CRATE_DEPENDS, which are generated by "make show-go-modules"
+// and "make print-cargo-depends", with the go.sum and the Cargo.lock
+// from the upstream sources, for --wrksrc and --distdir.
+//
+// After updating a package, these lists often still contain the
+// dependencies from the previous version, which is only noticed when
+// fetching the distfiles fails or the build tries to access the network.
+type LockfileChecker struct {
+	pkg *Package
+	src *PatchSource
+}
+
+func NewLockfileChecker(pkg *Package, src *PatchSource) *LockfileChecker {
+	return &LockfileChecker{pkg, src}
+}
+
+func (ck *LockfileChecker) Check(allLines *MkLines) {
+	if ck.pkg.included.Seen("../../lang/go/go-module.mk") {
+		ck.check(allLines, "GO_MODULE_FILES", "go.sum", ck.goModuleFiles)
+	}
+	if ck.pkg.included.Seen("../../lang/rust/cargo.mk") {
+		if lockfile := ck.cargoLock(); lockfile != "" {
+			ck.check(allLines, "CARGO_CRATE_DEPENDS", lockfile, ck.cargoCrateDepends)
+		}
+	}
+}
+
+// check compares the entries of the variable with those that are
+// generated from the lockfile. If they differ, the list is regenerated
+// in sorted order.
+func (ck *LockfileChecker) check(allLines *MkLines, varname string, lockfile RelPath, generate func(data string) []string) {
+	data, err := ck.src.ReadFile(lockfile)
+	if err != nil {
+		return
+	}
+
+	var filename CurrPath
+	allLines.ForEach(func(mkline *MkLine) {
+		if filename.IsEmpty() && mkline.IsVarassign() && mkline.Varname() == varname {
+			filename = mkline.Filename()
+		}
+	})
+	if filename.IsEmpty() || G.Pkgsrc.IsInfra(filename) {
+		return
+	}
+
+	var list []*MkLine
+	actual := make(map[string]*MkLine)
+	regenerable := true
+	allLines.ForEach(func(mkline *MkLine) {
+		if !mkline.IsVarassign() || mkline.Varname() != varname {
+			return
+		}
+		if mkline.Filename() != filename {
+			regenerable = false
+		} else {
+			list = append(list, mkline)
+		}
+		fields := mkline.ValueFields(mkline.Value())
+		regenerable = regenerable && len(fields) == 1 &&
+			mkline.Op() == opAssignAppend && !allLines.indentation.IsConditional()
+		for _, entry := range fields {
+			actual[entry] = mkline
+		}
+	})
+
+	expected := generate(string(data))
+	sort.Strings(expected)
+	wanted := make(map[string]bool)
+	for _, entry := range expected {
+		wanted[entry] = true
+	}
+
+	// An extra entry whose module or crate is also among the missing
+	// entries is reported as a version mismatch.
+	missingNames := make(map[string]string)
+	for _, entry := range expected {
+		if actual[entry] == nil {
+			missingNames[ck.key(varname, entry)] = entry
+		}
+	}
+
+	changed := false
+	reported := make(map[string]bool)
+	for _, mkline := range list {
+		for _, entry := range mkline.ValueFields(mkline.Value()) {
+			if wanted[entry] || containsExpr(entry) {
+				continue
+			}
+			changed = true
+			if other := missingNames[ck.key(varname, entry)]; other != "" && !reported[other] {
+				reported[other] = true
+				mkline.Warnf("The entry %q from %s has a different version than %q from %s.",
+					entry, varname, other, lockfile.String())
+			} else {
+				mkline.Warnf("The entry %q from %s is not in %s.",
+					entry, varname, lockfile.String())
+			}
+			ck.explain(mkline, varname)
+		}
+	}
+	for _, entry := range expected {
+		if actual[entry] == nil && !reported[entry] {
+			changed = true
+			last := list[len(list)-1]
+			last.Warnf("The entry %q from %s is missing in %s.",
+				entry, lockfile.String(), varname)
+			ck.explain(last, varname)
+		}
+	}
+
+	if changed && regenerable {
+		ck.regenerate(allLines, list, varname, expected)
+	}
+}
+
+func (ck *LockfileChecker) explain(mkline *MkLine, varname string) {
+	target := condStr(varname == "GO_MODULE_FILES", "show-go-modules", "print-cargo-depends")
+	mkline.Explain(
+		sprintf("The %s must correspond to the dependencies", varname),
+		"from the upstream sources, otherwise fetching the distfiles",
+		"fails, or the build tries to download the dependencies.",
+		"",
+		"To regenerate the list, run",
+		sprintf("%q,", bmake(target)),
+		"or let pkglint --autofix do the work.")
+}
+
+// regenerate replaces the list with the expected entries, in sorted order.
+func (ck *LockfileChecker) regenerate(allLines *MkLines, list []*MkLine, varname string, expected []string) {
+	fix := list[0].Autofix()
+	fix.Silent()
+	for _, entry := range expected {
+		fix.InsertAbove(alignWith(varname+"+=", list[0].ValueAlign()) + entry)
+	}
+	fix.Delete()
+	fix.Apply()
+
+	for _, mkline := range list[1:] {
+		fix := mkline.Autofix()
+		fix.Silent()
+		fix.Delete()
+		fix.Apply()
+	}
+
+	// Only the file containing the list is saved, since allLines also
+	// contains the lines from the infrastructure files.
+	filename := list[0].Filename()
+	var lines []*Line
+	for _, line := range allLines.lines.Lines {
+		if line.Filename() == filename {
+			lines = append(lines, line)
+		}
+	}
+	SaveAutofixChanges(NewLines(filename, lines))
+}
+
+// key returns the module path or the crate name of the entry, without
+// its version, to find the entries that only differ in their version.
+// For Go modules, the .mod and .zip files are distinguished.
+func (ck *LockfileChecker) key(varname string, entry string) string {
+	if varname == "GO_MODULE_FILES" {
+		return strings.SplitN(entry, "/@v/", 2)[0] + path.Ext(entry)
+	}
+	if m, name := match1(entry, `^(.+?)-\d+\.\d+\.\d+`); m {
+		return name
+	}
+	return entry
+}
+
+// goModuleFiles returns the files that "make show-go-modules" generates
+// from the go.sum file, in the case-encoded form of the module proxy.
+func (ck *LockfileChecker) goModuleFiles(goSum string) []string {
+	var files []string
+	for _, line := range strings.Split(goSum, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+
+		module, version := fields[0], fields[1]
+		ext := ".zip"
+		if hasSuffix(version, "/go.mod") {
+			version = strings.TrimSuffix(version, "/go.mod")
+			ext = ".mod"
+		}
+		file := goModuleEscape(module) + "/@v/" + goModuleEscape(version) + ext
+		if !containsStr(files, file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// goModuleEscape encodes each uppercase letter as an exclamation mark
+// followed by the lowercase letter, like the Go module proxy does.
+func goModuleEscape(path string) string {
+	var sb strings.Builder
+	for _, r := range path {
+		if 'A' <= r && r <= 'Z' {
+			sb.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// cargoLock returns the path of the Cargo.lock file in the sources,
+// based on CARGO_WRKSRC, or an empty path if it cannot be determined.
+func (ck *LockfileChecker) cargoLock() RelPath {
+	dir := "."
+	if ck.pkg.vars.IsDefined("CARGO_WRKSRC") {
+		dir = resolveExprs(ck.pkg.vars.LastValue("CARGO_WRKSRC"), nil, ck.pkg)
+		if dir != "${WRKSRC}" && !hasPrefix(dir, "${WRKSRC}/") {
+			return ""
+		}
+		dir = strings.TrimPrefix(strings.TrimPrefix(dir, "${WRKSRC}"), "/")
+		if containsExpr(dir) {
+			return ""
+		}
+		if dir == "" {
+			dir = "."
+		}
+	}
+	return NewRelPathString(dir).JoinNoClean("Cargo.lock").Clean()
+}
+
+// cargoCrateDepends returns the crates from the Cargo.lock file that
+// come from the crates.io registry, as "make print-cargo-depends" does.
+func (ck *LockfileChecker) cargoCrateDepends(cargoLock string) []string {
+	var crates []string
+	name, version := "", ""
+	for _, line := range strings.Split(cargoLock, "\n") {
+		if line == "[[package]]" {
+			name, version = "", ""
+		} else if m, value := match1(line, `^name = "(.*)"$`); m {
+			name = value
+		} else if m, value := match1(line, `^version = "(.*)"$`); m {
+			version = value
+		} else if hasPrefix(line, "source = \"registry+") && name != "" && version != "" {
+			crates = append(crates, name+"-"+version)
+		}
+	}
+	return crates
+}
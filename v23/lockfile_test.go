@@ -0,0 +1,363 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+// setUpGoModules creates the package category/package, which uses
+// lang/go/go-module.mk and lists the given GO_MODULE_FILES in
+// go-modules.mk, and the go.sum file in work/package-1.0.
+func setUpGoModules(t *Tester, moduleFiles []string, goSum ...string) {
+	t.CreateFileLines("lang/go/go-module.mk",
+		MkCvsID)
+	t.SetUpPackage("category/package",
+		".include \"go-modules.mk\"",
+		".include \"../../lang/go/go-module.mk\"")
+	var lines []string
+	for _, file := range moduleFiles {
+		lines = append(lines, "GO_MODULE_FILES+=\t"+file)
+	}
+	t.CreateFileLines("category/package/go-modules.mk",
+		append([]string{MkCvsID, ""}, lines...)...)
+	t.CreateFileLines("work/package-1.0/go.sum",
+		goSum...)
+}
+
+func (s *Suite) Test_NewLockfileChecker(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+
+	pkg, _ := t.LoadPackage("category/package")
+
+	ck := NewLockfileChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	t.CheckEquals(ck.pkg.Pkgpath, PkgsrcPath("category/package"))
+	t.CheckEquals(ck.src.dir, t.File("work/package-1.0"))
+}
+
+func (s *Suite) Test_LockfileChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	setUpGoModules(t,
+		[]string{
+			"github.com/!burnt!sushi/toml/@v/v0.3.1.mod",
+			"github.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+			"golang.org/x/sys/@v/v0.1.0.mod",
+			"golang.org/x/removed/@v/v1.0.0.mod"},
+		"github.com/BurntSushi/toml v0.3.1 h1:WXkYYl6Yr3qBf1K79EBnL4mak0OimBfB0XUf9Vl28OQ=",
+		"github.com/BurntSushi/toml v0.3.1/go.mod h1:xHWCNGjB5oqiDr8zfno3MHue2Ht5sIBksp03qcyfWMU=",
+		"golang.org/x/sys v0.2.0/go.mod h1:oPkhp1MJrh7nUepCBck5+mAzfO9JrbApNNgaTdGDITg=",
+		"golang.org/x/text v0.3.0/go.mod h1:NqM8EUOU14njkJ3fqMW+pc6Ldnwhi/IjpwHt7yyuwOQ=")
+	t.Chdir(".")
+
+	t.Main("--wrksrc=work/package-1.0", "-q", "--only=The entry", "category/package")
+
+	t.CheckOutputLines(
+		"WARN: category/package/go-modules.mk:5: "+
+			"The entry \"golang.org/x/sys/@v/v0.1.0.mod\" from GO_MODULE_FILES "+
+			"has a different version than \"golang.org/x/sys/@v/v0.2.0.mod\" from go.sum.",
+		"WARN: category/package/go-modules.mk:6: "+
+			"The entry \"golang.org/x/removed/@v/v1.0.0.mod\" from GO_MODULE_FILES "+
+			"is not in go.sum.",
+		"WARN: category/package/go-modules.mk:6: "+
+			"The entry \"golang.org/x/text/@v/v0.3.0.mod\" from go.sum "+
+			"is missing in GO_MODULE_FILES.")
+
+	t.Main("--wrksrc=work/package-1.0", "-q", "--autofix", "category/package")
+
+	t.CheckOutputLines(
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\tgithub.com/!burnt!sushi/toml/@v/v0.3.1.mod\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\tgithub.com/!burnt!sushi/toml/@v/v0.3.1.zip\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\tgolang.org/x/sys/@v/v0.2.0.mod\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\tgolang.org/x/text/@v/v0.3.0.mod\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: Deleting this line.",
+		"AUTOFIX: category/package/go-modules.mk:4: Deleting this line.",
+		"AUTOFIX: category/package/go-modules.mk:5: Deleting this line.",
+		"AUTOFIX: category/package/go-modules.mk:6: Deleting this line.")
+	t.CheckFileLines("category/package/go-modules.mk",
+		MkCvsID,
+		"",
+		"GO_MODULE_FILES+=\tgithub.com/!burnt!sushi/toml/@v/v0.3.1.mod",
+		"GO_MODULE_FILES+=\tgithub.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+		"GO_MODULE_FILES+=\tgolang.org/x/sys/@v/v0.2.0.mod",
+		"GO_MODULE_FILES+=\tgolang.org/x/text/@v/v0.3.0.mod")
+
+	t.Main("--wrksrc=work/package-1.0", "-q", "--only=The entry", "category/package")
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_LockfileChecker_Check__cargo(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("lang/rust/cargo.mk",
+		MkCvsID)
+	t.SetUpPackage("category/package",
+		"CARGO_WRKSRC=\t${WRKSRC}/src",
+		".include \"cargo-depends.mk\"",
+		".include \"../../lang/rust/cargo.mk\"")
+	t.CreateFileLines("category/package/cargo-depends.mk",
+		MkCvsID,
+		"",
+		"CARGO_CRATE_DEPENDS+=\taho-corasick-1.0.0",
+		"CARGO_CRATE_DEPENDS+=\tmemchr-2.7.1")
+	t.CreateFileLines("work/package-1.0/src/Cargo.lock",
+		"version = 3",
+		"",
+		"[[package]]",
+		"name = \"aho-corasick\"",
+		"version = \"1.1.2\"",
+		"source = \"registry+https://github.com/rust-lang/crates.io-index\"",
+		"",
+		"[[package]]",
+		"name = \"memchr\"",
+		"version = \"2.7.1\"",
+		"source = \"registry+https://github.com/rust-lang/crates.io-index\"")
+	t.Chdir(".")
+
+	t.Main("--wrksrc=work/package-1.0", "-q", "--only=The entry", "category/package")
+
+	t.CheckOutputLines(
+		"WARN: category/package/cargo-depends.mk:3: " +
+			"The entry \"aho-corasick-1.0.0\" from CARGO_CRATE_DEPENDS " +
+			"has a different version than \"aho-corasick-1.1.2\" from src/Cargo.lock.")
+}
+
+func (s *Suite) Test_LockfileChecker_check(c *check.C) {
+	t := s.Init(c)
+
+	setUpGoModules(t,
+		[]string{
+			"example.org/a/@v/v1.0.0.mod",
+			"example.org/b/@v/v1.0.0.mod"},
+		"example.org/b v1.0.0/go.mod h1:hash=",
+		"example.org/a v1.0.0/go.mod h1:hash=")
+	pkg, allLines := t.LoadPackage("category/package")
+	ck := NewLockfileChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	ck.check(allLines, "GO_MODULE_FILES", "go.sum", ck.goModuleFiles)
+	ck.check(allLines, "GO_MODULE_FILES", "missing.sum", ck.goModuleFiles)
+	ck.check(allLines, "UNDEFINED", "go.sum", ck.goModuleFiles)
+
+	// The order of the entries doesn't matter.
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_LockfileChecker_check__not_regenerable(c *check.C) {
+	t := s.Init(c)
+
+	setUpGoModules(t,
+		nil,
+		"example.org/a v1.0.0/go.mod h1:hash=")
+	t.CreateFileLines("category/package/go-modules.mk",
+		MkCvsID,
+		"",
+		"GO_MODULE_FILES=\texample.org/old/@v/v1.0.0.mod example.org/a/@v/v1.0.0.mod")
+	t.SetUpCommandLine("--show-autofix")
+	pkg, allLines := t.LoadPackage("category/package")
+	ck := NewLockfileChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	ck.check(allLines, "GO_MODULE_FILES", "go.sum", ck.goModuleFiles)
+
+	// Since the list doesn't have the usual form of one entry per line,
+	// it is not regenerated.
+	t.CheckOutputEmpty()
+
+	t.SetUpCommandLine("-Wall")
+
+	ck.check(allLines, "GO_MODULE_FILES", "go.sum", ck.goModuleFiles)
+
+	t.CheckOutputLines(
+		"WARN: ~/category/package/go-modules.mk:3: " +
+			"The entry \"example.org/old/@v/v1.0.0.mod\" from GO_MODULE_FILES is not in go.sum.")
+}
+
+func (s *Suite) Test_LockfileChecker_check__autofix(c *check.C) {
+	t := s.Init(c)
+
+	setUpGoModules(t,
+		[]string{
+			"example.org/b/@v/v1.0.0.mod",
+			"example.org/old/@v/v1.0.0.mod"},
+		"example.org/b v1.0.0/go.mod h1:hash=",
+		"example.org/a v1.0.0/go.mod h1:hash=")
+	t.SetUpCommandLine("--autofix")
+	t.Chdir(".")
+	pkg, allLines := t.LoadPackage("category/package")
+	ck := NewLockfileChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+
+	ck.check(allLines, "GO_MODULE_FILES", "go.sum", ck.goModuleFiles)
+
+	t.CheckOutputLines(
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\texample.org/a/@v/v1.0.0.mod\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\texample.org/b/@v/v1.0.0.mod\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: Deleting this line.",
+		"AUTOFIX: category/package/go-modules.mk:4: Deleting this line.")
+	t.CheckFileLines("category/package/go-modules.mk",
+		MkCvsID,
+		"",
+		"GO_MODULE_FILES+=\texample.org/a/@v/v1.0.0.mod",
+		"GO_MODULE_FILES+=\texample.org/b/@v/v1.0.0.mod")
+}
+
+func (s *Suite) Test_LockfileChecker_explain(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+	t.SetUpPackage("category/package")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewLockfileChecker(pkg, nil)
+	mkline := t.NewMkLine("cargo-depends.mk", 3, "CARGO_CRATE_DEPENDS+=\tmemchr-2.7.1")
+
+	mkline.Warnf("Warning.")
+	ck.explain(mkline, "CARGO_CRATE_DEPENDS")
+
+	t.CheckOutputLines(
+		"WARN: cargo-depends.mk:3: Warning.",
+		"",
+		"\tThe CARGO_CRATE_DEPENDS must correspond to the dependencies from the",
+		"\tupstream sources, otherwise fetching the distfiles fails, or the",
+		"\tbuild tries to download the dependencies.",
+		"",
+		"\tTo regenerate the list, run \"@BMAKE@ print-cargo-depends\", or let",
+		"\tpkglint --autofix do the work.",
+		"")
+}
+
+func (s *Suite) Test_LockfileChecker_regenerate(c *check.C) {
+	t := s.Init(c)
+
+	setUpGoModules(t,
+		[]string{
+			"example.org/b/@v/v1.0.0.mod",
+			"example.org/old/@v/v1.0.0.mod"},
+		"example.org/b v1.0.0/go.mod h1:hash=",
+		"example.org/a v1.0.0/go.mod h1:hash=")
+	t.SetUpCommandLine("--show-autofix")
+	t.Chdir(".")
+	pkg, allLines := t.LoadPackage("category/package")
+	ck := NewLockfileChecker(pkg, &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+	var list []*MkLine
+	allLines.ForEach(func(mkline *MkLine) {
+		if mkline.IsVarassign() && mkline.Varname() == "GO_MODULE_FILES" {
+			list = append(list, mkline)
+		}
+	})
+
+	ck.regenerate(allLines, list, "GO_MODULE_FILES",
+		[]string{"example.org/a/@v/v1.0.0.mod", "example.org/b/@v/v1.0.0.mod"})
+
+	t.CheckOutputLines(
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\texample.org/a/@v/v1.0.0.mod\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: "+
+			"Inserting a line \"GO_MODULE_FILES+=\\texample.org/b/@v/v1.0.0.mod\" above this line.",
+		"AUTOFIX: category/package/go-modules.mk:3: Deleting this line.",
+		"AUTOFIX: category/package/go-modules.mk:4: Deleting this line.")
+	t.CheckFileLines("category/package/go-modules.mk",
+		MkCvsID,
+		"",
+		"GO_MODULE_FILES+=\texample.org/b/@v/v1.0.0.mod",
+		"GO_MODULE_FILES+=\texample.org/old/@v/v1.0.0.mod")
+}
+
+func (s *Suite) Test_LockfileChecker_key(c *check.C) {
+	t := s.Init(c)
+
+	var ck LockfileChecker
+
+	test := func(varname, entry, key string) {
+		t.CheckEquals(ck.key(varname, entry), key)
+	}
+
+	test("GO_MODULE_FILES", "github.com/!burnt!sushi/toml/@v/v0.3.1.mod", "github.com/!burnt!sushi/toml.mod")
+	test("GO_MODULE_FILES", "golang.org/x/sys/@v/v0.0.0-20200116001909-b77594299b42.zip", "golang.org/x/sys.zip")
+	test("CARGO_CRATE_DEPENDS", "aho-corasick-1.1.2", "aho-corasick")
+	test("CARGO_CRATE_DEPENDS", "windows_x86_64_gnu-0.48.5", "windows_x86_64_gnu")
+	test("CARGO_CRATE_DEPENDS", "crate-1.0.0-beta.1", "crate")
+	test("CARGO_CRATE_DEPENDS", "no-version", "no-version")
+}
+
+func (s *Suite) Test_LockfileChecker_goModuleFiles(c *check.C) {
+	t := s.Init(c)
+
+	var ck LockfileChecker
+
+	files := ck.goModuleFiles("" +
+		"github.com/BurntSushi/toml v0.3.1 h1:WXkYYl6Yr3qBf1K79EBnL4mak0OimBfB0XUf9Vl28OQ=\n" +
+		"github.com/BurntSushi/toml v0.3.1/go.mod h1:xHWCNGjB5oqiDr8zfno3MHue2Ht5sIBksp03qcyfWMU=\n" +
+		"github.com/BurntSushi/toml v0.3.1/go.mod h1:xHWCNGjB5oqiDr8zfno3MHue2Ht5sIBksp03qcyfWMU=\n" +
+		"\n" +
+		"invalid line\n")
+
+	t.CheckDeepEquals(files, []string{
+		"github.com/!burnt!sushi/toml/@v/v0.3.1.zip",
+		"github.com/!burnt!sushi/toml/@v/v0.3.1.mod"})
+}
+
+func (s *Suite) Test_goModuleEscape(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(goModuleEscape("github.com/BurntSushi/toml"), "github.com/!burnt!sushi/toml")
+	t.CheckEquals(goModuleEscape("v1.0.0-RC1"), "v1.0.0-!r!c1")
+	t.CheckEquals(goModuleEscape("golang.org/x/sys"), "golang.org/x/sys")
+}
+
+func (s *Suite) Test_LockfileChecker_cargoLock(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+
+	test := func(cargoWrksrc string, expected RelPath) {
+		pkg := NewPackage(t.File("category/package"))
+		if cargoWrksrc != "" {
+			pkg.vars.Define("CARGO_WRKSRC", t.NewMkLine(pkg.File("Makefile"), 20,
+				"CARGO_WRKSRC=\t"+cargoWrksrc))
+		}
+		ck := NewLockfileChecker(pkg, nil)
+
+		t.CheckEquals(ck.cargoLock(), expected)
+	}
+
+	test("", "Cargo.lock")
+	test("${WRKSRC}", "Cargo.lock")
+	test("${WRKSRC}/rust", "rust/Cargo.lock")
+	test("${WRKDIR}/other", "")
+	test("${WRKSRC}/${UNKNOWN}", "")
+}
+
+func (s *Suite) Test_LockfileChecker_cargoCrateDepends(c *check.C) {
+	t := s.Init(c)
+
+	var ck LockfileChecker
+
+	crates := ck.cargoCrateDepends("" +
+		"# This file is automatically @generated by Cargo.\n" +
+		"version = 3\n" +
+		"\n" +
+		"[[package]]\n" +
+		"name = \"local\"\n" +
+		"version = \"0.1.0\"\n" +
+		"dependencies = [\n" +
+		" \"memchr\",\n" +
+		"]\n" +
+		"\n" +
+		"[[package]]\n" +
+		"name = \"from-git\"\n" +
+		"version = \"1.0.0\"\n" +
+		"source = \"git+https://github.com/example/from-git#0123456789abcdef\"\n" +
+		"\n" +
+		"[[package]]\n" +
+		"name = \"memchr\"\n" +
+		"version = \"2.7.1\"\n" +
+		"source = \"registry+https://github.com/rust-lang/crates.io-index\"\n" +
+		"checksum = \"523dc4f511e55ab87b694dc30d0f820d60906ef06413f93d4d7a1385599cc149\"\n")
+
+	t.CheckDeepEquals(crates, []string{"memchr-2.7.1"})
+}
@@ -33,6 +33,8 @@ type Logger struct {
 	logged    OncePerStringSlice
 	explained OncePerStringSlice
 	histo     *histogram.Histogram
+	baseline  *DiagBaseline    // for --apply-diff, to report only new diagnostics
+	fragment  *FragmentChecker // for -Cincluders, to aggregate the diagnostics
 
 	errors                int
 	warnings              int
@@ -78,6 +80,11 @@ func (l *Logger) Explain(explanation ...string) {
 		return
 	}
 
+	if l.fragment != nil {
+		l.fragment.explain(explanation)
+		return
+	}
+
 	l.explanationsAvailable = true
 	if !l.Opts.Explain {
 		return
@@ -111,6 +118,17 @@ func (l *Logger) Explain(explanation ...string) {
 //
 // See Logf for logging arbitrary messages.
 func (l *Logger) Diag(line *Line, level *LogLevel, format string, args ...interface{}) {
+	l.diag(line, nil, level, format, args...)
+}
+
+// DiagAt logs a diagnostic that points to a specific part of the line.
+// In the gcc output format, the column is added to the line number,
+// and in the --source mode, the part of the line is underlined.
+func (l *Logger) DiagAt(line *Line, span Span, level *LogLevel, format string, args ...interface{}) {
+	l.diag(line, &span, level, format, args...)
+}
+
+func (l *Logger) diag(line *Line, span *Span, level *LogLevel, format string, args ...interface{}) {
 	if G.Testing {
 		for _, arg := range args {
 			switch arg.(type) {
@@ -148,22 +166,43 @@ func (l *Logger) Diag(line *Line, level *LogLevel, format string, args ...interf
 		return
 	}
 
+	if l.fragment != nil {
+		l.fragment.record(line, span, level, format, msg)
+		return
+	}
+
+	l.log(line, span, level, format, msg)
+}
+
+// log logs a diagnostic that has passed the filters from Relevant and
+// FirstTime.
+func (l *Logger) log(line *Line, span *Span, level *LogLevel, format, msg string) {
 	if l.Opts.ShowSource {
-		if line != l.prevLine {
+		if line != l.prevLine || span != nil {
 			l.out.Separate()
 		}
-		l.writeSource(line)
+		l.writeSource(line, span)
 	}
 
-	l.Logf(level, filename, linenos, format, msg)
+	location := line.Linenos()
+	if span != nil && l.Opts.GccOutput {
+		rawIndex, column := line.Position(span.Start)
+		location = sprintf("%d:%d", line.Location.Lineno(rawIndex), column)
+	}
+	l.Logf(level, line.Filename(), location, format, msg)
 }
 
+// FirstTime returns whether the diagnostic should be logged, that is,
+// whether it has not been logged before and is not part of the baseline
+// from the --apply-diff option.
 func (l *Logger) FirstTime(filename CurrPath, linenos, msg string) bool {
-	if l.verbose {
-		return true
+	if !l.verbose && !l.logged.FirstTime(filename.Clean().String(), linenos, msg) {
+		l.suppressDiag = true
+		l.suppressExpl = true
+		return false
 	}
 
-	if !l.logged.FirstTime(filename.Clean().String(), linenos, msg) {
+	if l.baseline != nil && !l.baseline.IsNew(filename, msg) {
 		l.suppressDiag = true
 		l.suppressExpl = true
 		return false
@@ -172,6 +211,16 @@ func (l *Logger) FirstTime(filename CurrPath, linenos, msg string) bool {
 	return true
 }
 
+// Unsuppress allows the following explanation to be logged, independent
+// of whether the previous diagnostic has been suppressed.
+//
+// This is needed when logging a diagnostic directly via Logger.log,
+// such as the diagnostics that have been recorded for -Cincluders.
+func (l *Logger) Unsuppress() {
+	l.suppressDiag = false
+	l.suppressExpl = false
+}
+
 // Relevant decides and remembers whether the given diagnostic is relevant and should be logged.
 //
 // The result of the decision affects all log items until Relevant is called for the next time.
@@ -199,13 +248,13 @@ func (l *Logger) shallBeLogged(format string) bool {
 	return false
 }
 
-func (l *Logger) writeSource(line *Line) {
+func (l *Logger) writeSource(line *Line, span *Span) {
 	if !G.Logger.Opts.ShowSource {
 		return
 	}
 
 	if !l.IsAutofix() {
-		if line == l.prevLine {
+		if line == l.prevLine && span == nil {
 			return
 		}
 		l.prevLine = line
@@ -218,19 +267,19 @@ func (l *Logger) writeSource(line *Line) {
 		for _, above := range line.fix.above {
 			l.writeLine("+\t", above)
 		}
-		l.writeDiff(line)
+		l.writeDiff(line, nil)
 		for _, below := range line.fix.below {
 			l.writeLine("+\t", below)
 		}
 	} else {
-		l.writeDiff(line)
+		l.writeDiff(line, span)
 	}
 	if l.IsAutofix() {
 		l.out.Separate()
 	}
 }
 
-func (l *Logger) writeDiff(line *Line) {
+func (l *Logger) writeDiff(line *Line, span *Span) {
 	showAsChanged := func(rawIndex int, rawLine *RawLine) bool {
 		return l.IsAutofix() &&
 			line.fix.texts[rawIndex] != rawLine.orignl
@@ -254,7 +303,46 @@ func (l *Logger) writeDiff(line *Line) {
 		} else {
 			l.writeLine(prefix, rawLine.orignl)
 		}
+		if span != nil {
+			l.writeCaret(line, rawIndex, *span)
+		}
+	}
+}
+
+// writeCaret underlines the part of the raw line that is covered by the
+// span, in the style of the GCC diagnostics.
+func (l *Logger) writeCaret(line *Line, rawIndex int, span Span) {
+	startIndex, startColumn := line.Position(span.Start)
+	endIndex, endColumn := line.Position(span.End)
+	if rawIndex < startIndex || rawIndex > endIndex {
+		return
+	}
+
+	rawText := line.raw[rawIndex].Orig()
+	indent, text, _, _ := matchContinuationLine(rawText)
+	from := len(indent)
+	if rawIndex == startIndex {
+		from = startColumn - 1
+	}
+	to := len(indent) + len(text)
+	if rawIndex == endIndex {
+		to = endColumn - 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\t")
+	for i := 0; i < from; i++ {
+		if i < len(rawText) && rawText[i] == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	sb.WriteString(condStr(rawIndex == startIndex, "^", "~"))
+	if to > from+1 {
+		sb.WriteString(strings.Repeat("~", to-from-1))
 	}
+	l.out.WriteLine(sb.String())
 }
 
 func (l *Logger) writeLine(prefix, line string) {
@@ -399,7 +487,8 @@ func (l *Logger) ShowSummary(args []string) {
 	if l.explanationsAvailable && !l.Opts.Explain {
 		l.out.WriteLine(sprintf("(Run \"%s\" to show explanations.)", commandLine("-e")))
 	}
-	if l.autofixAvailable {
+	// With --apply-diff, the files are patched in memory only.
+	if l.autofixAvailable && l.baseline == nil {
 		if !l.Opts.ShowAutofix {
 			l.out.WriteLine(sprintf("(Run \"%s\" to show what can be fixed automatically.)", commandLine("-fs")))
 		}
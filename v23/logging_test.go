@@ -341,6 +341,84 @@ func (s *Suite) Test_Logger_Diag__wrong_type(c *check.C) {
 		`interface conversion: interface {} is \*errors.errorString, not string`)
 }
 
+func (s *Suite) Test_Logger_DiagAt(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--source")
+	mklines := t.NewMkLines("filename.mk",
+		"CONFIGURE_ARGS+=\t--first \\",
+		"\t\t\t--second \\",
+		"\t\t\t--third")
+	line := mklines.mklines[0].Line
+
+	G.Logger.DiagAt(line, Span{25, 33}, Warn, "The second argument is suspicious.")
+
+	t.CheckOutputLines(
+		">\tCONFIGURE_ARGS+=\t--first \\",
+		">\t\t\t\t--second \\",
+		"\t\t\t\t^~~~~~~~",
+		">\t\t\t\t--third",
+		"WARN: filename.mk:1--3: The second argument is suspicious.")
+}
+
+func (s *Suite) Test_Logger_DiagAt__gcc_format(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--gcc-output-format")
+	mklines := t.NewMkLines("filename.mk",
+		"CONFIGURE_ARGS+=\t--first \\",
+		"\t\t\t--second \\",
+		"\t\t\t--third")
+	line := mklines.mklines[0].Line
+
+	G.Logger.DiagAt(line, Span{34, 41}, Error, "The third argument is wrong.")
+
+	t.CheckOutputLines(
+		"filename.mk:3:4: error: The third argument is wrong.")
+}
+
+// When there are several diagnostics for the same line, the source
+// is only repeated for those that point to a specific part of the line.
+func (s *Suite) Test_Logger_diag(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--source")
+	line := t.NewLine("filename", 123, "word1 word2")
+
+	line.Warnf("Warning for the whole line.")
+	line.Warnf("Another warning for the whole line.")
+	line.At(Span{6, 11}).Warnf("Warning for %q.", "word2")
+
+	t.CheckOutputLines(
+		">\tword1 word2",
+		"WARN: filename:123: Warning for the whole line.",
+		"WARN: filename:123: Another warning for the whole line.",
+		"",
+		">\tword1 word2",
+		"\t      ^~~~~",
+		"WARN: filename:123: Warning for \"word2\".")
+}
+
+// The log method bypasses the filters, as these have already been
+// applied by the caller.
+func (s *Suite) Test_Logger_log(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--source", "--gcc-output-format")
+	line := t.NewLine("filename", 123, "word1 word2")
+
+	G.Logger.log(line, nil, Warn, "Warning.", "Warning.")
+	G.Logger.log(line, &Span{6, 11}, Warn, "Warning.", "Warning.")
+
+	t.CheckOutputLines(
+		">\tword1 word2",
+		"filename:123: warning: Warning.",
+		"",
+		">\tword1 word2",
+		"\t      ^~~~~",
+		"filename:123:7: warning: Warning.")
+}
+
 func (s *Suite) Test_Logger_FirstTime__not_verbose(c *check.C) {
 	t := s.Init(c)
 
@@ -353,6 +431,38 @@ func (s *Suite) Test_Logger_FirstTime__not_verbose(c *check.C) {
 	t.CheckEquals(G.Logger.FirstTime("filename", "124", "Message."), false)
 }
 
+func (s *Suite) Test_Logger_FirstTime__baseline(c *check.C) {
+	t := s.Init(c)
+
+	G.Logger.baseline = NewDiagBaseline()
+	line := t.NewLine("filename", 123, "text")
+
+	line.Warnf("Old warning.")
+	line.Explain("Explanation.")
+	G.Logger.baseline.Finish()
+	G.Logger.logged = OncePerStringSlice{}
+
+	line.Warnf("Old warning.")
+	line.Explain("Explanation.")
+	line.Warnf("New warning.")
+
+	t.CheckOutputLines(
+		"WARN: filename:123: New warning.")
+}
+
+func (s *Suite) Test_Logger_Unsuppress(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--only", "whitespace")
+
+	t.CheckEquals(G.Logger.Relevant("Options should not contain space."), false)
+
+	G.Logger.Unsuppress()
+
+	t.CheckEquals(G.Logger.suppressDiag, false)
+	t.CheckEquals(G.Logger.suppressExpl, false)
+}
+
 func (s *Suite) Test_Logger_Relevant(c *check.C) {
 	t := s.Init(c)
 
@@ -749,7 +859,7 @@ func (s *Suite) Test_Logger_writeDiff(c *check.C) {
 	fix.Replace("before", "after")
 	fix.Apply()
 
-	G.Logger.writeDiff(line)
+	G.Logger.writeDiff(line, nil)
 
 	// The diff lines are indented with a tab so that the indentation
 	// from the actual lines is properly represented in the output.
@@ -761,6 +871,37 @@ func (s *Suite) Test_Logger_writeDiff(c *check.C) {
 		"+\tafter")
 }
 
+func (s *Suite) Test_Logger_writeCaret(c *check.C) {
+	t := s.Init(c)
+
+	mklines := t.NewMkLines("filename.mk",
+		"VAR=\tfirst \\",
+		"\tsecond")
+	line := mklines.mklines[0].Line
+
+	test := func(rawIndex int, span Span, expected ...string) {
+		G.Logger.writeCaret(line, rawIndex, span)
+		t.CheckOutput(expected)
+	}
+
+	// The tab is copied to the caret line to keep the columns aligned.
+	test(0, Span{5, 10},
+		"\t    \t^~~~~")
+
+	// A span that covers both lines is underlined in both lines.
+	test(0, Span{5, 17},
+		"\t    \t^~~~~")
+	test(1, Span{5, 17},
+		"\t\t~~~~~~")
+
+	// An empty span is shown as a single caret.
+	test(1, Span{11, 11},
+		"\t\t^")
+
+	// Raw lines outside the span are not underlined.
+	test(1, Span{5, 10})
+}
+
 func (s *Suite) Test_Logger_writeLine(c *check.C) {
 	t := s.Init(c)
 
@@ -1280,6 +1421,19 @@ func (s *Suite) Test_Logger_ShowSummary__autofix_available_with_autofix_option(c
 	t.CheckOutputEmpty()
 }
 
+func (s *Suite) Test_Logger_ShowSummary__autofix_available_with_baseline(c *check.C) {
+	t := s.Init(c)
+
+	G.Logger.autofixAvailable = true
+	G.Logger.baseline = NewDiagBaseline()
+
+	G.Logger.ShowSummary([]string{"pkglint", "--apply-diff", "proposed.diff"})
+
+	// The fixes cannot be applied since the diff only exists in memory.
+	t.CheckOutputLines(
+		"Looks fine.")
+}
+
 func (s *Suite) Test_Logger_ShowSummary__quoting(c *check.C) {
 	t := s.Init(c)
 
@@ -0,0 +1,121 @@
+package pkglint
+
+import (
+	"sort"
+	"strings"
+)
+
+// Makesum regenerates the distinfo file of a package for --makesum,
+// like "make distinfo" does, from the distfiles in the --distdir and
+// the patches of the package.
+//
+// Contrary to "make distinfo", it neither needs network access nor a
+// working pkgsrc infrastructure.
+type Makesum struct {
+	pkg     *Package
+	distdir CurrPath
+}
+
+func NewMakesum(pkg *Package, distdir CurrPath) *Makesum {
+	return &Makesum{pkg, distdir}
+}
+
+// Run writes the distinfo file, unless the distfiles cannot be
+// determined or some of them are missing from the distdir.
+func (ms *Makesum) Run() {
+	distinfo := ms.pkg.File(ms.pkg.DistinfoFile)
+
+	distfiles, ok := G.Pkgsrc.Evaluate(ms.pkg.Pkgpath).Distfiles()
+	if !ok {
+		line := NewLineWhole(ms.pkg.File("Makefile"))
+		line.Errorf("The distfiles cannot be determined, therefore %s is not regenerated.",
+			line.Rel(distinfo))
+		line.Explain(
+			"The DISTFILES or PATCHFILES of this package depend on",
+			"conditions or on variables from the pkgsrc infrastructure,",
+			"which pkglint does not evaluate.",
+			"",
+			"To regenerate the distinfo file, run",
+			sprintf("%q.", bmake("distinfo")))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ms.header(distinfo))
+	sb.WriteString("\n\n")
+
+	complete := true
+	sort.Strings(distfiles)
+	for _, distfile := range distfiles {
+		filename := ms.distdir.JoinNoClean(NewRelPathString(distfile)).CleanPath()
+		if !filename.IsFile() {
+			NewLineWhole(distinfo).Errorf("The distfile %q is not in %q.",
+				distfile, ms.distdir.String())
+			complete = false
+			continue
+		}
+		for _, alg := range [...]string{"BLAKE2s", "SHA512", "Size"} {
+			sb.WriteString(sprintf("%s (%s) = %s\n", alg, distfile, computeDistfileHash(filename, alg)))
+		}
+	}
+
+	patches := ms.patches()
+	for _, patch := range patches {
+		lines := Load(ms.pkg.File(ms.pkg.Patchdir.JoinNoClean(patch)), 0)
+		if lines != nil {
+			sb.WriteString(sprintf("SHA1 (%s) = %s\n", patch, computePatchSha1Hex(lines)))
+		}
+	}
+
+	if !complete || len(distfiles) == 0 && len(patches) == 0 && !distinfo.IsFile() {
+		return
+	}
+	ms.write(distinfo, sb.String())
+}
+
+// header returns the first line of the existing distinfo file if it
+// is a CVS Id, to keep its history, and the unexpanded CVS Id otherwise.
+func (ms *Makesum) header(distinfo CurrPath) string {
+	lines := Load(distinfo, 0)
+	if lines != nil && lines.Len() > 0 {
+		if m, _ := lines.Lines[0].IsCvsID(``); m {
+			return lines.Lines[0].Text
+		}
+	}
+	return "$" + "NetBSD$"
+}
+
+// patches returns the names of the patch files that are recorded in
+// the distinfo file, in sorted order.
+func (ms *Makesum) patches() []RelPath {
+	var patches []RelPath
+	for _, filename := range ms.pkg.File(ms.pkg.Patchdir).ReadPaths() {
+		patch := filename.Base()
+		if filename.IsFile() && (&distinfoLinesChecker{}).isPatch(patch) {
+			patches = append(patches, patch)
+		}
+	}
+	sort.Slice(patches, func(i, j int) bool { return patches[i] < patches[j] })
+	return patches
+}
+
+// write replaces the distinfo file with the regenerated text,
+// if the text differs.
+func (ms *Makesum) write(distinfo CurrPath, text string) {
+	if prev, err := distinfo.ReadString(); err == nil && prev == text {
+		return
+	}
+
+	tmpName := distinfo + ".pkglint.tmp"
+	if err := tmpName.WriteString(text); err != nil {
+		G.Logger.TechErrorf(tmpName, "Cannot write: %s", err)
+		return
+	}
+	if err := tmpName.Rename(distinfo); err != nil {
+		G.Logger.TechErrorf(tmpName, "Cannot overwrite with the regenerated content: %s", err)
+		return
+	}
+	G.fileCache.Evict(distinfo)
+
+	NewLineWhole(distinfo).Notef("Regenerated the distinfo file.")
+}
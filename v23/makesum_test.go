@@ -0,0 +1,177 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_NewMakesum(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+
+	ms := NewMakesum(pkg, t.File("distfiles"))
+
+	t.CheckEquals(ms.pkg, pkg)
+	t.CheckEquals(ms.distdir, t.File("distfiles"))
+}
+
+func (s *Suite) Test_Makesum_Run(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"DISTFILES=\t${DISTNAME}.txt",
+		"PATCHFILES=\tfix.diff",
+		"DIST_SUBDIR=\t${DISTNAME}")
+	t.CreateFileDummyPatch("category/package/patches/patch-aa")
+	t.CreateFileLines("category/package/patches/patch-aa.orig")
+	t.CreateFileLines("distfiles/package-1.0/package-1.0.txt",
+		"hello, world")
+	t.CreateFileLines("distfiles/package-1.0/fix.diff",
+		"hello, world")
+	t.Chdir(".")
+
+	t.Main("-q", "--makesum", "--distdir=distfiles", "category/package")
+
+	t.CheckOutputLines(
+		"NOTE: category/package/distinfo: Regenerated the distinfo file.")
+	t.CheckFileLines("category/package/distinfo",
+		CvsID,
+		"",
+		"BLAKE2s (package-1.0/fix.diff) = "+
+			"ee494623e60caeda840ed7de4fb70db4a36bc92b445b09f12b9ed46094e9bd59",
+		"SHA512 (package-1.0/fix.diff) = "+
+			"f65f341b35981fda842b09b2c8af9bcdb7602a4c2e6fa1f7d41f0974d3e3122f"+
+			"268fc79d5a4af66358f5133885cd1c165c916f80ab25e5d8d95db46f803c782c",
+		"Size (package-1.0/fix.diff) = 13 bytes",
+		"BLAKE2s (package-1.0/package-1.0.txt) = "+
+			"ee494623e60caeda840ed7de4fb70db4a36bc92b445b09f12b9ed46094e9bd59",
+		"SHA512 (package-1.0/package-1.0.txt) = "+
+			"f65f341b35981fda842b09b2c8af9bcdb7602a4c2e6fa1f7d41f0974d3e3122f"+
+			"268fc79d5a4af66358f5133885cd1c165c916f80ab25e5d8d95db46f803c782c",
+		"Size (package-1.0/package-1.0.txt) = 13 bytes",
+		"SHA1 (patch-aa) = 9a93207561abfef7e7550598c5a08f2c3226995b")
+
+	// The second run finds the distinfo file up to date.
+	t.Main("-q", "--makesum", "--distdir=distfiles", "category/package")
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_Makesum_Run__new_distinfo(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"DISTFILES=\t# none")
+	t.CreateFileDummyPatch("category/package/patches/patch-aa")
+	t.Remove("category/package/distinfo")
+	t.CreateFileLines("distfiles/README")
+	t.Chdir(".")
+
+	t.Main("-q", "--makesum", "--distdir=distfiles", "--only=distinfo", "category/package")
+
+	// The newly created distinfo file is checked as well,
+	// therefore there is no warning about the missing distinfo file.
+	t.CheckOutputLines(
+		"NOTE: category/package/distinfo: Regenerated the distinfo file.")
+	t.CheckFileLines("category/package/distinfo",
+		CvsID,
+		"",
+		"SHA1 (patch-aa) = 9a93207561abfef7e7550598c5a08f2c3226995b")
+}
+
+func (s *Suite) Test_Makesum_Run__indeterminate(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		".if ${MACHINE_ARCH} == x86_64",
+		"DISTFILES=\tpackage-1.0-amd64.tar.gz",
+		".endif")
+	t.CreateFileLines("distfiles/package-1.0.tar.gz")
+	t.Chdir(".")
+
+	t.Main("-q", "--makesum", "--distdir=distfiles", "--only=regenerated", "category/package")
+
+	t.CheckOutputLines(
+		"ERROR: category/package/Makefile: The distfiles cannot be determined, " +
+			"therefore distinfo is not regenerated.")
+}
+
+func (s *Suite) Test_Makesum_Run__missing_distfile(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("distfiles/other-1.0.tar.gz")
+	t.Chdir(".")
+
+	t.Main("-q", "--makesum", "--distdir=distfiles", "--only=distfile", "category/package")
+
+	// The distinfo file from SetUpPackage is left unchanged.
+	t.CheckOutputLines(
+		"ERROR: category/package/distinfo: "+
+			"The distfile \"package-1.0.tar.gz\" is not in \"distfiles\".",
+		"NOTE: category/package/Makefile: "+
+			"The distfile \"package-1.0.tar.gz\" is not in \"distfiles\", "+
+			"therefore the patches are not checked.")
+	t.CheckFileLines("category/package/distinfo",
+		CvsID,
+		"",
+		"BLAKE2s (distfile-1.0.tar.gz) = 12341234",
+		"SHA512 (distfile-1.0.tar.gz) = 12341234",
+		"Size (distfile-1.0.tar.gz) = 12341234")
+}
+
+func (s *Suite) Test_Makesum_header(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/expanded",
+		"$"+"NetBSD: distinfo,v 1.5 2024/01/01 00:00:00 user Exp $",
+		"")
+	t.CreateFileLines("category/package/other",
+		"BLAKE2s (package-1.0.tar.gz) = 12341234")
+	t.FinishSetUp()
+	ms := NewMakesum(NewPackage(t.File("category/package")), t.File("distfiles"))
+
+	t.CheckEquals(ms.header(t.File("category/package/expanded")),
+		"$"+"NetBSD: distinfo,v 1.5 2024/01/01 00:00:00 user Exp $")
+	t.CheckEquals(ms.header(t.File("category/package/other")), CvsID)
+	t.CheckEquals(ms.header(t.File("category/package/nonexistent")), CvsID)
+}
+
+func (s *Suite) Test_Makesum_patches(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileDummyPatch("category/package/patches/patch-b")
+	t.CreateFileDummyPatch("category/package/patches/patch-a")
+	t.CreateFileLines("category/package/patches/patch-a.orig")
+	t.CreateFileLines("category/package/patches/patch-local-c")
+	t.CreateFileLines("category/package/patches/patch-dir/patch-d")
+	t.CreateFileLines("category/package/patches/README")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+	pkg.load()
+
+	ms := NewMakesum(pkg, t.File("distfiles"))
+
+	t.CheckDeepEquals(ms.patches(), []RelPath{"patch-a", "patch-b"})
+}
+
+func (s *Suite) Test_Makesum_write(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+	ms := NewMakesum(NewPackage(t.File("category/package")), t.File("distfiles"))
+	distinfo := t.File("category/package/distinfo")
+
+	ms.write(distinfo, CvsID+"\n\nSHA1 (patch-aa) = 1234\n")
+	ms.write(distinfo, CvsID+"\n\nSHA1 (patch-aa) = 1234\n")
+
+	t.CheckOutputLines(
+		"NOTE: ~/category/package/distinfo: Regenerated the distinfo file.")
+	t.CheckFileLines("category/package/distinfo",
+		CvsID,
+		"",
+		"SHA1 (patch-aa) = 1234")
+}
@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/textproc"
 	"strconv"
 	"strings"
@@ -339,7 +340,8 @@ func (ck *MkAssignChecker) checkLeftAbiDepends() {
 		"",
 		"The only version that matters is the API of the dependency,",
 		"which is selected by specifying BUILDLINK_API_DEPENDS.")
-	fix.Replace("BUILDLINK_ABI_DEPENDS", "BUILDLINK_API_DEPENDS")
+	ck.replaceInTree(fix, func(assign *ast.MkAssignLine) ast.Node { return assign.Name },
+		"BUILDLINK_ABI_DEPENDS", "BUILDLINK_API_DEPENDS")
 	fix.Apply()
 }
 
@@ -557,7 +559,8 @@ func (ck *MkAssignChecker) checkRightCategory() {
 		"pkgsrc directory tree, to make it easy to find the package.",
 		"All other categories may be added after this primary category.")
 	if len(categories) > 1 && categories[1] == dir.String() {
-		fix.Replace(primary+" "+categories[1], categories[1]+" "+primary)
+		ck.replaceInTree(fix, func(assign *ast.MkAssignLine) ast.Node { return assign.Value },
+			primary+" "+categories[1], categories[1]+" "+primary)
 	}
 	fix.Apply()
 }
@@ -817,3 +820,14 @@ func (ck *MkAssignChecker) mayBeDefined(varname string) bool {
 
 	return false
 }
+
+// replaceInTree replaces the first occurrence of the text in the part of
+// the variable assignment that is selected by the given function.
+func (ck *MkAssignChecker) replaceInTree(fix *Autofix, part func(*ast.MkAssignLine) ast.Node, from, to string) {
+	fix.ReplaceInTree(func(tree ast.MkLine) ast.Node {
+		if assign, ok := tree.(*ast.MkAssignLine); ok {
+			return part(assign)
+		}
+		return nil
+	}, from, to)
+}
@@ -1,6 +1,9 @@
 package pkglint
 
-import "gopkg.in/check.v1"
+import (
+	"github.com/rillig/pkglint/v23/ast"
+	"gopkg.in/check.v1"
+)
 
 func (s *Suite) Test_NewMkAssignChecker(c *check.C) {
 	t := s.Init(c)
@@ -1476,3 +1479,34 @@ func (s *Suite) Test_MkAssignChecker_mayBeDefined(c *check.C) {
 		"WARN: filename.mk:4: Variable \"_BAD_PREFIX\" is defined but not used.",
 		"WARN: filename.mk:4: Variable _BAD_PREFIX is defined but not mentioned in the _VARGROUPS section.")
 }
+
+func (s *Suite) Test_MkAssignChecker_replaceInTree(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mklines := t.NewMkLines("filename.mk",
+		"VALUE=\tVALUE # VALUE",
+		".include \"VALUE\"")
+	value := func(assign *ast.MkAssignLine) ast.Node { return assign.Value }
+
+	test := func(mkline *MkLine, from string, diagnostics ...string) {
+		fix := mkline.Autofix()
+		fix.Notef("Note.")
+		NewMkAssignChecker(mkline, mklines).replaceInTree(fix, value, from, "replaced")
+		fix.Apply()
+		t.CheckOutput(diagnostics)
+	}
+
+	// Only the selected part of the line is modified,
+	// not the variable name and not the comment.
+	test(mklines.mklines[0], "VALUE",
+		"NOTE: filename.mk:1: Note.",
+		"AUTOFIX: filename.mk:1: Replacing \"VALUE\" with \"replaced\".")
+
+	test(mklines.mklines[0], "other",
+		nil...)
+
+	// Lines other than variable assignments are not modified.
+	test(mklines.mklines[1], "VALUE",
+		nil...)
+}
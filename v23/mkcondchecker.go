@@ -1,8 +1,10 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/makepat"
 	"github.com/rillig/pkglint/v23/textproc"
+	"strings"
 )
 
 // MkCondChecker checks conditions in Makefiles.
@@ -26,7 +28,9 @@ func (ck *MkCondChecker) Check() {
 	p := NewMkParser(nil, mkline.Args()) // No emitWarnings here, see the code below.
 	cond := p.MkCond()
 	if !p.EOF() || cond == nil {
-		mkline.Warnf("Invalid condition, unrecognized part \"%s\".", p.Rest())
+		args := mkline.Args()
+		span := mkline.ArgsSpan(len(args)-len(p.Rest()), len(args))
+		mkline.At(span).Warnf("Invalid condition, unrecognized part \"%s\".", p.Rest())
 		return
 	}
 
@@ -105,7 +109,19 @@ func (ck *MkCondChecker) checkAnd(conds []*MkCond) {
 			"The \"empty\" function treats an undefined variable",
 			"as empty, so there is no need to write the \"defined\"",
 			"explicitly.")
-		fix.Replace("defined("+conds[0].Defined+") && ", "")
+		defined := "defined(" + conds[0].Defined + ")"
+		ck.editCond(fix, func(f *ast.File, cond ast.MkCond, ed *ast.FileEditor) bool {
+			and, ok := cond.(*ast.MkCondBinary)
+			if !ok || and.Op != ast.AND || f.Text(and.Left) != defined {
+				return false
+			}
+			not, ok := and.Right.(*ast.MkCondNot)
+			if !ok || !hasPrefix(f.Text(not.X), "empty(") {
+				return false
+			}
+			ed.Remove(f.Slice(and, 0, int(and.Right.Start()-and.Start())))
+			return true
+		})
 		fix.Apply()
 	}
 }
@@ -136,7 +152,7 @@ func (ck *MkCondChecker) checkNotEmpty(not *MkCond) {
 		"Before querying a PKG_BUILD_OPTIONS variable,",
 		"that variable has to be defined by setting \"pkgbase\"",
 		"and then calling \"mk/pkg-build-options.mk\".")
-	fix.Replace(from, to)
+	ck.replaceCond(fix, from, to)
 	fix.Apply()
 }
 
@@ -310,7 +326,16 @@ func (ck *MkCondChecker) checkCompareWithNumPython(left *MkCondTerm, op string,
 			"",
 			"In addition, _PYTHON_VERSION can be \"none\",",
 			"which is not a number.")
-		fix.Replace("${_PYTHON_VERSION} "+op+" "+num, "${PYTHON_VERSION} "+op+" "+fixedNum)
+		ck.editCond(fix, func(f *ast.File, cond ast.MkCond, ed *ast.FileEditor) bool {
+			cmp, ok := cond.(*ast.MkCondComparison)
+			if !ok || cmp.Right == nil || f.Text(cmp.Left) != "${_PYTHON_VERSION}" ||
+				f.Text(cmp.OpText) != op || f.Text(cmp.Right) != num {
+				return false
+			}
+			operator := f.Slice(cmp, int(cmp.Left.End()-cmp.Start()), int(cmp.Right.Start()-cmp.Start()))
+			ed.Replace(cmp, ast.NewLiteral("${PYTHON_VERSION}"+operator.Text+fixedNum))
+			return true
+		})
 		fix.Apply()
 	}
 }
@@ -320,10 +345,6 @@ func (ck *MkCondChecker) checkCompareExprStrCompiler(op string, value string) {
 		return
 	}
 
-	// It would be nice if original text of the whole comparison expression
-	// were available at this point, to avoid guessing how much whitespace
-	// the package author really used.
-
 	matchOp := condStr(op == "==", "M", "N")
 
 	fix := ck.MkLine.Autofix()
@@ -331,11 +352,61 @@ func (ck *MkCondChecker) checkCompareExprStrCompiler(op string, value string) {
 	fix.Explain(
 		"The PKGSRC_COMPILER can be a list of chained compilers, e.g. \"ccache distcc clang\".",
 		"Therefore, comparing it using == or != leads to wrong results in these cases.")
-	fix.Replace("${PKGSRC_COMPILER} "+op+" "+value, "${PKGSRC_COMPILER:"+matchOp+value+"}")
-	fix.Replace("${PKGSRC_COMPILER} "+op+" \""+value+"\"", "${PKGSRC_COMPILER:"+matchOp+value+"}")
+	ck.editCond(fix, func(f *ast.File, cond ast.MkCond, ed *ast.FileEditor) bool {
+		cmp, ok := cond.(*ast.MkCondComparison)
+		if !ok || cmp.Right == nil || f.Text(cmp.Left) != "${PKGSRC_COMPILER}" ||
+			f.Text(cmp.OpText) != op || strings.Trim(f.Text(cmp.Right), "\"") != value {
+			return false
+		}
+		ed.Replace(cmp, ast.NewLiteral("${PKGSRC_COMPILER:"+matchOp+value+"}"))
+		return true
+	})
 	fix.Apply()
 }
 
+// replaceCond replaces the part of the condition whose text is exactly
+// "from" with "to".
+func (ck *MkCondChecker) replaceCond(fix *Autofix, from, to string) {
+	ck.editCond(fix, func(f *ast.File, cond ast.MkCond, ed *ast.FileEditor) bool {
+		if f.Text(cond) != from {
+			return false
+		}
+		ed.Replace(cond, ast.NewLiteral(to))
+		return true
+	})
+}
+
+// editCond calls the edit function for each part of the condition from
+// the syntax tree of the line, in depth-first order, until the function
+// returns true, and then applies the edits to the line.
+func (ck *MkCondChecker) editCond(fix *Autofix, edit func(*ast.File, ast.MkCond, *ast.FileEditor) bool) {
+	f, tree := ck.MkLine.Tree()
+	line, ok := tree.(*ast.MkCondLine)
+	if !ok {
+		return
+	}
+
+	ed := ast.NewFileEditor(f)
+	var visit func(cond ast.MkCond) bool
+	visit = func(cond ast.MkCond) bool {
+		if edit(f, cond, ed) {
+			return true
+		}
+		switch cond := cond.(type) {
+		case *ast.MkCondBinary:
+			return visit(cond.Left) || visit(cond.Right)
+		case *ast.MkCondParen:
+			return visit(cond.X)
+		case *ast.MkCondNot:
+			return visit(cond.X)
+		}
+		return false
+	}
+	if visit(line.Cond) {
+		fix.Edit(ed)
+	}
+}
+
 func (ck *MkCondChecker) checkNotCompare(not *MkCond) {
 	if not.Compare == nil {
 		return
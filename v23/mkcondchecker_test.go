@@ -1,6 +1,9 @@
 package pkglint
 
-import "gopkg.in/check.v1"
+import (
+	"github.com/rillig/pkglint/v23/ast"
+	"gopkg.in/check.v1"
+)
 
 func (s *Suite) Test_NewMkCondChecker(c *check.C) {
 	t := s.Init(c)
@@ -144,6 +147,21 @@ func (s *Suite) Test_MkCondChecker_Check(c *check.C) {
 		nil...)
 }
 
+func (s *Suite) Test_MkCondChecker_Check__location(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	mklines := t.NewMkLines("filename.mk",
+		MkCvsID,
+		".if defined(VAR) && empty{VAR}",
+		".endif")
+
+	mklines.Check()
+
+	t.CheckOutputLines(
+		"filename.mk:2:18: warning: Invalid condition, unrecognized part \"&& empty{VAR}\".")
+}
+
 func (s *Suite) Test_MkCondChecker_Check__tracing(c *check.C) {
 	t := s.Init(c)
 
@@ -459,7 +477,7 @@ func (s *Suite) Test_MkCondChecker_checkAnd(c *check.C) {
 	test(
 		".if defined(A) && !empty(A)",
 		"NOTE: filename.mk:6: Checking \"defined\" before \"!empty\" is redundant.",
-		"AUTOFIX: filename.mk:6: Replacing \"defined(A) && \" with \"\".")
+		"AUTOFIX: filename.mk:6: Deleting \"defined(A) && \".")
 
 	// The condition is only redundant if the variable names are the same.
 	test(
@@ -472,17 +490,19 @@ func (s *Suite) Test_MkCondChecker_checkAnd(c *check.C) {
 		"WARN: filename.mk:6: The empty() function "+
 			"takes a variable name plus optional modifiers as parameter, "+
 			"not the expression \"${varname}\".",
-		"AUTOFIX: filename.mk:6: Replacing \"defined(${varname}) && \" with \"\".")
+		"AUTOFIX: filename.mk:6: Deleting \"defined(${varname}) && \".")
 
 	test(
 		".if defined(VAR) && !empty(VAR:Mpattern)",
 		"NOTE: filename.mk:6: Checking \"defined\" before \"!empty\" is redundant.",
-		"AUTOFIX: filename.mk:6: Replacing \"defined(VAR) && \" with \"\".")
+		"AUTOFIX: filename.mk:6: Deleting \"defined(VAR) && \".")
 
-	// No automatic fix, as the string "defined(A) && " occurs twice.
+	// The string "defined(A) && " occurs twice,
+	// the syntax tree determines which of them is redundant.
 	test(
 		".if defined(A) && 1 || defined(A) && !empty(A:Mpattern)",
-		"NOTE: filename.mk:6: Checking \"defined\" before \"!empty\" is redundant.")
+		"NOTE: filename.mk:6: Checking \"defined\" before \"!empty\" is redundant.",
+		"AUTOFIX: filename.mk:6: Deleting \"defined(A) && \".")
 
 	// The redundancy requires a '&&', a '||' does not work.
 	test(
@@ -869,13 +889,16 @@ func (s *Suite) Test_MkCondChecker_checkCompareExprStrCompiler(c *check.C) {
 			"Replacing \"${PKGSRC_COMPILER} == gcc\" "+
 			"with \"${PKGSRC_COMPILER:Mgcc}\".")
 
-	// No autofix because of missing whitespace.
-	// TODO: Provide the autofix regardless of the whitespace.
+	// Since the comparison is taken from the syntax tree,
+	// the whitespace doesn't matter.
 	test(
 		"${PKGSRC_COMPILER}==gcc",
 
 		"ERROR: filename.mk:5: "+
-			"Use ${PKGSRC_COMPILER:Mgcc} instead of the == operator.")
+			"Use ${PKGSRC_COMPILER:Mgcc} instead of the == operator.",
+		"AUTOFIX: filename.mk:5: "+
+			"Replacing \"${PKGSRC_COMPILER}==gcc\" "+
+			"with \"${PKGSRC_COMPILER:Mgcc}\".")
 
 	// The comparison value can be with or without quotes.
 	test(
@@ -895,6 +918,89 @@ func (s *Suite) Test_MkCondChecker_checkCompareExprStrCompiler(c *check.C) {
 		nil...)
 }
 
+func (s *Suite) Test_MkCondChecker_replaceCond(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	test := func(text, from, to string, diagnostics ...string) {
+		mklines := t.NewMkLines("filename.mk",
+			text)
+		ck := NewMkCondChecker(mklines.mklines[0], mklines)
+
+		fix := ck.MkLine.Autofix()
+		fix.Warnf("Warning.")
+		ck.replaceCond(fix, from, to)
+		fix.Apply()
+
+		t.CheckOutput(diagnostics)
+	}
+
+	// Only complete parts of the condition are replaced.
+	test(".if ${A} && ${AB}",
+		"${A}", "${C}",
+
+		"WARN: filename.mk:1: Warning.",
+		"AUTOFIX: filename.mk:1: Replacing \"${A}\" with \"${C}\".")
+
+	test(".if ${AB}",
+		"${A", "${C",
+
+		nil...)
+
+	test(".if !(${A} || ${B})",
+		"${A} || ${B}", "${C}",
+
+		"WARN: filename.mk:1: Warning.",
+		"AUTOFIX: filename.mk:1: Replacing \"${A} || ${B}\" with \"${C}\".")
+}
+
+func (s *Suite) Test_MkCondChecker_editCond(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	test := func(text string, visited []string, diagnostics ...string) {
+		mklines := t.NewMkLines("filename.mk",
+			text)
+		ck := NewMkCondChecker(mklines.mklines[0], mklines)
+
+		var actual []string
+		fix := ck.MkLine.Autofix()
+		fix.Warnf("Warning.")
+		ck.editCond(fix, func(f *ast.File, cond ast.MkCond, ed *ast.FileEditor) bool {
+			actual = append(actual, f.Text(cond))
+			if _, ok := cond.(*ast.MkCondNot); ok {
+				ed.Remove(f.Find(cond, "!"))
+				return true
+			}
+			return false
+		})
+		fix.Apply()
+
+		t.CheckDeepEquals(actual, visited)
+		t.CheckOutput(diagnostics)
+	}
+
+	// The parts of the condition are visited in depth-first order,
+	// until the edit function returns true.
+	test(".if ${A} && (${B} || !${C}) && !${D}",
+		[]string{
+			"${A} && (${B} || !${C}) && !${D}",
+			"${A} && (${B} || !${C})",
+			"${A}",
+			"(${B} || !${C})",
+			"${B} || !${C}",
+			"${B}",
+			"!${C}"},
+
+		"WARN: filename.mk:1: Warning.",
+		"AUTOFIX: filename.mk:1: Deleting \"!\".")
+
+	// The edit function is not called for other lines.
+	test("VAR=\t!value",
+		nil,
+		nil...)
+}
+
 func (s *Suite) Test_MkCondChecker_checkNotCompare(c *check.C) {
 	t := s.Init(c)
 
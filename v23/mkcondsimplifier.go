@@ -97,7 +97,7 @@ func (s *MkCondSimplifier) simplifyWord(expr *MkExpr, fromEmpty bool, neg bool)
 		"An entirely different case is when the pattern contains",
 		"wildcards like *, ?, [].",
 		"In such a case, using the :M or :N modifiers is useful and preferred.")
-	fix.Replace(from, to)
+	fix.ReplaceInTree(treeCond, from, to)
 	fix.Apply()
 }
 
@@ -175,7 +175,7 @@ func (s *MkCondSimplifier) simplifyYesNo(expr *MkExpr, fromEmpty bool, neg bool)
 
 	fix := s.MkLine.Autofix()
 	fix.Notef("\"%s\" can be simplified to \"%s\".", from, to)
-	fix.Replace(from, to)
+	fix.ReplaceInTree(treeCond, from, to)
 	fix.Apply()
 	return true
 }
@@ -268,7 +268,7 @@ func (s *MkCondSimplifier) simplifyMatch(expr *MkExpr, fromEmpty bool, neg bool)
 		"This variable is guaranteed to be defined at this point.",
 		"Therefore, it may occur on the left-hand side of a comparison",
 		"and doesn't have to be guarded by the function 'empty'.")
-	fix.Replace(from, to)
+	fix.ReplaceInTree(treeCond, from, to)
 	fix.Apply()
 }
 
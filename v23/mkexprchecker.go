@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/textproc"
 	"strings"
 )
@@ -135,7 +136,7 @@ func (ck *MkExprChecker) checkModifiersRange() {
 		"The range modifier is much easier to understand than the",
 		"complicated regular expressions, which were needed before",
 		"the year 2006.")
-	fix.Replace(expr.Mod(), ":[1]")
+	fix.ReplaceInTree(treeCode, expr.Mod(), ":[1]")
 	fix.Apply()
 }
 
@@ -174,7 +175,7 @@ func (ck *MkExprChecker) checkModifierLoop(mod MkExprModifier) {
 		fix := ck.MkLine.Autofix()
 		fix.Notef("The modifier %q can be replaced with the simpler %q.",
 			str, simpler)
-		fix.Replace(str, simpler)
+		fix.ReplaceInTree(treeCode, str, simpler)
 		fix.Apply()
 	}
 
@@ -183,7 +184,7 @@ func (ck *MkExprChecker) checkModifierLoop(mod MkExprModifier) {
 		fix := ck.MkLine.Autofix()
 		fix.Notef("The modifier %q can be replaced with the simpler %q.",
 			str, simpler)
-		fix.Replace(str, simpler)
+		fix.ReplaceInTree(treeCode, str, simpler)
 		fix.Apply()
 	}
 }
@@ -200,7 +201,14 @@ func (ck *MkExprChecker) checkVarname(time EctxTime) {
 	if varname == "LOCALBASE" && !G.Infrastructure && time == EctxRunTime {
 		fix := ck.MkLine.Autofix()
 		fix.Warnf("Use PREFIX instead of LOCALBASE.")
-		fix.ReplaceAfter("${", "LOCALBASE", "PREFIX")
+		f, tree := ck.MkLine.Tree()
+		if code := treeCode(tree); code != nil {
+			if expr := f.Find(code, "${LOCALBASE"); expr != nil {
+				ed := ast.NewFileEditor(f)
+				ed.Replace(f.Find(expr, "LOCALBASE"), ast.NewLiteral("PREFIX"))
+				fix.Edit(ed)
+			}
+		}
 		fix.Explain(
 			"LOCALBASE is the user-settable variable,",
 			"while PREFIX is the effective base directory,",
@@ -725,7 +733,7 @@ func (ck *MkExprChecker) fixQuotingModifiers(correctMod string, mod string) {
 	fix.Warnf("Use ${%s%s} instead of ${%s%s}.", varname, correctMod, varname, mod)
 	fix.Explain(
 		seeGuide("Echoing a string exactly as-is", "echo-literal"))
-	fix.Replace("${"+varname+mod+"}", "${"+varname+correctMod+"}")
+	fix.ReplaceInTree(treeCode, "${"+varname+mod+"}", "${"+varname+correctMod+"}")
 	fix.Apply()
 }
 
@@ -785,7 +793,7 @@ func (ck *MkExprChecker) warnRedundantModifierQ(mod string) {
 		"\t* tool names and tool paths",
 		"\t* variable names",
 		"\t* package names (but not package patterns like pkg>=1.2)")
-	fix.Replace(bad, good)
+	fix.ReplaceInTree(treeCode, bad, good)
 	fix.Apply()
 }
 
@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/regex"
 	"github.com/rillig/pkglint/v23/textproc"
 	"strings"
@@ -20,10 +21,26 @@ import (
 type MkLexer struct {
 	lexer *textproc.Lexer
 	diag  Autofixer // optional
+
+	// The line in which the text appears, and the mapping from the
+	// offsets in the text to the offsets in the text of the line,
+	// or nil if the location of the text is unknown; see Locate.
+	line   *Line
+	offset func(int) int
+
+	exprStart int // The offset of the current expression in the text
 }
 
 func NewMkLexer(text string, diag Autofixer) *MkLexer {
-	return &MkLexer{textproc.NewLexer(text), diag}
+	return &MkLexer{textproc.NewLexer(text), diag, nil, nil, 0}
+}
+
+// Locate makes the diagnostics point to the expression in which they
+// occur, using the given function to map the offsets in the text to
+// the offsets in the text of the line.
+func (p *MkLexer) Locate(line *Line, offset func(int) int) {
+	p.line = line
+	p.offset = offset
 }
 
 // MkTokens splits a text like in the following example:
@@ -75,6 +92,10 @@ func (p *MkLexer) Expr() *MkExpr {
 		return nil
 	}
 
+	outerStart := p.exprStart
+	p.exprStart = p.lexer.Offset()
+	defer func() { p.exprStart = outerStart }()
+
 	switch rest[1] {
 	case '{', '(':
 		return p.exprBrace(rest[1] == '(')
@@ -108,10 +129,8 @@ func (p *MkLexer) Expr() *MkExpr {
 		if expr != nil {
 			return expr
 		}
-		if p.diag != nil {
-			p.diag.Warnf("Expression \"%s\" has unusual single-character variable name \"%s\".", rest[0:2], rest[1:2])
-		}
 		p.lexer.Skip(2)
+		p.Warnf("Expression \"%s\" has unusual single-character variable name \"%s\".", rest[0:2], rest[1:2])
 		return NewMkExpr(rest[1:2])
 	}
 }
@@ -157,7 +176,7 @@ func (p *MkLexer) exprBrace(usingRoundParen bool) *MkExpr {
 
 			fix := p.Autofix()
 			fix.Warnf("Use curly braces {} instead of round parentheses () for %s.", varExpr)
-			fix.Replace(parenExpr, bracesExpr)
+			p.replaceExpr(fix, parenExpr, bracesExpr)
 			fix.Explain(
 				"BSD-style makefiles use curly braces almost exclusively.",
 				"GNU-style makefiles, on the other hand,",
@@ -649,19 +668,19 @@ func (p *MkLexer) Rest() string {
 
 func (p *MkLexer) Errorf(format string, args ...interface{}) {
 	if p.HasDiag() {
-		p.diag.Errorf(format, args...)
+		p.diagnoser().Errorf(format, args...)
 	}
 }
 
 func (p *MkLexer) Warnf(format string, args ...interface{}) {
 	if p.HasDiag() {
-		p.diag.Warnf(format, args...)
+		p.diagnoser().Warnf(format, args...)
 	}
 }
 
 func (p *MkLexer) Notef(format string, args ...interface{}) {
 	if p.HasDiag() {
-		p.diag.Notef(format, args...)
+		p.diagnoser().Notef(format, args...)
 	}
 }
 
@@ -678,4 +697,34 @@ func (p *MkLexer) Autofix() *Autofix {
 
 func (p *MkLexer) HasDiag() bool { return p.diag != nil }
 
+// replaceExpr replaces the text of the current expression.
+// If the location of the expression is unknown, nothing is replaced.
+func (p *MkLexer) replaceExpr(fix *Autofix, from, to string) {
+	if p.offset == nil {
+		return
+	}
+
+	rawIndex, column := p.line.Position(p.offset(p.exprStart))
+	f, _ := p.line.Tree()
+	start := column - 1
+	expr := f.Slice(f.Lines()[rawIndex], start, start+len(from))
+	if expr == nil || expr.Text != from {
+		// The expression spans several lines, contains escape
+		// sequences or has been modified by a previous autofix.
+		return
+	}
+
+	ed := ast.NewFileEditor(f)
+	ed.Replace(expr, ast.NewLiteral(to))
+	fix.Edit(ed)
+}
+
+// diagnoser returns the diagnoser for the current expression.
+func (p *MkLexer) diagnoser() Diagnoser {
+	if p.offset == nil {
+		return p.diag
+	}
+	return p.line.At(Span{p.offset(p.exprStart), p.offset(p.lexer.Offset())})
+}
+
 var builtInVariable = textproc.NewByteSet(">!<%?*@")
@@ -30,6 +30,20 @@ func (s *Suite) Test_NewMkLexer__without_diag(c *check.C) {
 	t.CheckOutputEmpty()
 }
 
+func (s *Suite) Test_MkLexer_Locate(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	line := t.NewLine("filename.mk", 123, "VAR=\t${A} ${B")
+	lexer := NewMkLexer(line.Text[5:], line)
+	lexer.Locate(line, func(offset int) int { return 5 + offset })
+
+	lexer.MkTokens()
+
+	t.CheckOutputLines(
+		"filename.mk:123:11: warning: Missing closing \"}\" for \"B\".")
+}
+
 func (s *Suite) Test_MkLexer_MkTokens(c *check.C) {
 	t := s.Init(c)
 	b := NewMkTokenBuilder()
@@ -1412,3 +1426,71 @@ func (s *Suite) Test_MkLexer_HasDiag(c *check.C) {
 	test(nil, false)
 	test(t.NewLine("filename", 123, ""), true)
 }
+
+func (s *Suite) Test_MkLexer_replaceExpr(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+
+	// The "$(A)" in the escaped "$$(A)" is not an expression.
+	t.NewMkLines("filename.mk",
+		"VAR=\t$$(A) $(A)")
+
+	t.CheckOutputLines(
+		"WARN: filename.mk:1: Use curly braces {} instead of round parentheses () for A.",
+		"AUTOFIX: filename.mk:1: Replacing \"$(A)\" with \"${A}\".")
+
+	// The position of the expression is mapped to the continuation line.
+	mklines := t.NewMkLines("filename.mk",
+		"VAR=\t$(A) \\",
+		"\t$(A)")
+
+	t.CheckOutputLines(
+		"WARN: filename.mk:1: Use curly braces {} instead of round parentheses () for A.",
+		"AUTOFIX: filename.mk:1: Replacing \"$(A)\" with \"${A}\".",
+		"WARN: filename.mk:2: Use curly braces {} instead of round parentheses () for A.",
+		"AUTOFIX: filename.mk:2: Replacing \"$(A)\" with \"${A}\".")
+	t.CheckEquals(mklines.mklines[0].Line.RawText(1), "\t${A}")
+
+	// Without knowing the location of the text,
+	// the expression is not replaced.
+	// Since nothing is fixed, the warning is not shown either.
+	line := t.NewLine("filename.mk", 123, "$(A) $(A)")
+	NewMkLexer("$(A)", line).MkTokens()
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_MkLexer_diagnoser(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	test := func(text string, diagnostics ...string) {
+		line := t.NewLine("filename.mk", 123, text)
+		NewMkLineParser().Parse(line)
+		t.CheckOutput(diagnostics)
+	}
+
+	// The diagnostics point to the expression in which they occur.
+	test("VAR=\t${A} ${B:Xmod}",
+		"filename.mk:123:11: warning: Invalid variable modifier \"Xmod\" for \"B\".")
+
+	// In nested expressions, they point to the innermost one.
+	// The modifiers of the outer expression are parsed twice.
+	test("VAR=\t${A:${B:Xmod}}",
+		"filename.mk:123:10: warning: Invalid variable modifier \"Xmod\" for \"B\".",
+		"filename.mk:123:10: warning: Invalid variable modifier \"Xmod\" for \"B\".")
+
+	// Escaped number signs are unescaped before parsing.
+	test("VAR=\ts,\\#,, ${B:Xmod}",
+		"filename.mk:123:13: warning: Invalid variable modifier \"Xmod\" for \"B\".")
+
+	// In shell commands, the leading tab is skipped.
+	test("\techo $x",
+		"filename.mk:123:7: warning: $x is ambiguous. "+
+			"Use ${x} if you mean a Make variable or $$x if you mean a shell variable.")
+
+	// Commented variable assignments.
+	test("#VAR=\t${B:Xmod}",
+		"filename.mk:123:7: warning: Invalid variable modifier \"Xmod\" for \"B\".")
+}
@@ -35,6 +35,7 @@ type mkLineAssign struct {
 	spaceAfterVarname string
 	op                MkOperator //
 	value             string     // The trimmed value
+	valueOffset       int        // The offset of the value in the unescaped text, see ValueSpan
 	valueMk           []*MkToken // The value, sent through splitIntoMkWords
 	valueMkRest       string     // nonempty in case of parse errors
 	fields            []string   // The value, space-separated according to shell quoting rules
@@ -267,6 +268,36 @@ func (mkline *MkLine) ValueAlign() string {
 // the assignment.
 func (mkline *MkLine) Value() string { return mkline.data.(*mkLineAssign).value }
 
+// ValueSpan returns the location of a part of the value in the text of
+// the line, for pointing diagnostics to it. The start and end offsets
+// refer to the string returned by Value.
+func (mkline *MkLine) ValueSpan(start, end int) Span {
+	text := mkline.Text
+	valueOffset := mkline.data.(*mkLineAssign).valueOffset
+	return Span{escapedOffset(text, valueOffset+start), escapedOffset(text, valueOffset+end)}
+}
+
+// escapedOffset maps the offset from the main part of the line back to
+// the text, in which the # signs may be escaped,
+// see MkLineParser.unescapeComment.
+func escapedOffset(text string, mainIndex int) int {
+	i := 0
+	for mainIndex > 0 && i < len(text) {
+		switch {
+		case hasPrefix(text[i:], "\\#"):
+			i += 2
+			mainIndex--
+		case text[i] == '\\' && i+1 < len(text) && mainIndex >= 2:
+			i += 2
+			mainIndex -= 2
+		default:
+			i++
+			mainIndex--
+		}
+	}
+	return i
+}
+
 // FirstLineContainsValue returns whether the variable assignment of a
 // multiline contains a textual value in the first line.
 //
@@ -282,7 +313,7 @@ func (mkline *MkLine) FirstLineContainsValue() bool {
 	text := mkline.raw[0].Orig()
 	parser := NewMkLineParser()
 	splitResult := parser.split(text, true)
-	splitResult.tokens = parser.tokenize(splitResult.main, nil)
+	splitResult.tokens = parser.tokenize(splitResult.main, nil, nil)
 	_, a := parser.matchVarassign(mkline.Line, text, &splitResult)
 	return a.value != "\\"
 }
@@ -310,6 +341,15 @@ func (mkline *MkLine) Directive() string { return mkline.data.(*mkLineDirective)
 // Args returns the arguments from an .if, .ifdef, .ifndef, .elif, .for, .undef.
 func (mkline *MkLine) Args() string { return mkline.data.(*mkLineDirective).args }
 
+// ArgsSpan returns the location of a part of the directive arguments in
+// the text of the line, for pointing diagnostics to it. The start and end
+// offsets refer to the string returned by Args.
+func (mkline *MkLine) ArgsSpan(start, end int) Span {
+	text := mkline.Text
+	argsOffset := len(mkline.splitResult.main) - len(mkline.Args())
+	return Span{escapedOffset(text, argsOffset+start), escapedOffset(text, argsOffset+end)}
+}
+
 // Cond applies to an .if or .elif line and returns the parsed condition.
 //
 // If a parse error occurs, it is silently swallowed, returning a
@@ -318,7 +358,9 @@ func (mkline *MkLine) Cond() *MkCond {
 	cond := mkline.data.(*mkLineDirective).cond
 	if cond == nil {
 		assert(mkline.NeedsCond())
-		cond = NewMkParser(mkline.Line, mkline.Args()).MkCond()
+		p := NewMkParser(mkline.Line, mkline.Args())
+		p.mklex.Locate(mkline.Line, func(offset int) int { return mkline.ArgsSpan(offset, offset).Start })
+		cond = p.MkCond()
 		mkline.data.(*mkLineDirective).cond = cond
 	}
 	return cond
@@ -56,6 +56,38 @@ func (s *Suite) Test_MkLine_ValueAlign__commented(c *check.C) {
 	t.CheckEquals(valueAlign, "#SUBST_SED.${param}=\t")
 }
 
+func (s *Suite) Test_MkLine_ValueSpan(c *check.C) {
+	t := s.Init(c)
+
+	test := func(text string, start, end int, expected string) {
+		mkline := t.NewMkLine("filename.mk", 123, text)
+		span := mkline.ValueSpan(start, end)
+		t.CheckEquals(mkline.Text[span.Start:span.End], expected)
+	}
+
+	test("VAR=\tword1 word2", 6, 11, "word2")
+	test("#VAR=\tword1 word2", 0, 5, "word1")
+
+	// Escaped number signs are unescaped in the value,
+	// which shifts the offsets.
+	test("VAR=\ts,\\#,,g word2", 7, 12, "word2")
+	test("VAR=\ts,\\#,,g word2", 0, 6, "s,\\#,,g")
+
+	// Other escaped characters are kept as-is.
+	test("VAR=\ta\\\\b word2", 5, 10, "word2")
+}
+
+func (s *Suite) Test_escapedOffset(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(escapedOffset("VAR=\tvalue", 5), 5)
+	t.CheckEquals(escapedOffset("VAR=\t\\#value", 6), 7)
+	t.CheckEquals(escapedOffset("VAR=\t\\\\value", 7), 7)
+
+	// Offsets beyond the text are limited to its end.
+	t.CheckEquals(escapedOffset("VAR=", 10), 4)
+}
+
 func (s *Suite) Test_MkLine_FirstLineContainsValue(c *check.C) {
 	t := s.Init(c)
 
@@ -102,6 +134,20 @@ func (s *Suite) Test_MkLine_FirstLineContainsValue(c *check.C) {
 
 // Demonstrates how a simple condition is structured internally.
 // For most of the checks, using cond.Walk is the simplest way to go.
+func (s *Suite) Test_MkLine_ArgsSpan(c *check.C) {
+	t := s.Init(c)
+
+	test := func(text string, start, end int, expected string) {
+		mkline := t.NewMkLine("filename.mk", 123, text)
+		span := mkline.ArgsSpan(start, end)
+		t.CheckEquals(mkline.Text[span.Start:span.End], expected)
+	}
+
+	test(".if ${VAR} == value", 0, 6, "${VAR}")
+	test(".  if   ${VAR} == value", 10, 15, "value")
+	test(".if ${VAR:M\\#} == value # comment", 13, 18, "value")
+}
+
 func (s *Suite) Test_MkLine_Cond(c *check.C) {
 	t := s.Init(c)
 
@@ -114,6 +160,18 @@ func (s *Suite) Test_MkLine_Cond(c *check.C) {
 	t.CheckEquals(mkline.Cond(), cond)
 }
 
+func (s *Suite) Test_MkLine_Cond__location(c *check.C) {
+	t := s.Init(c)
+	t.SetUpCommandLine("--gcc-output-format")
+
+	mkline := t.NewMkLine("Makefile", 2, ".if ${VAR} == ${OTHER:Xmod}")
+
+	mkline.Cond()
+
+	t.CheckOutputLines(
+		"Makefile:2:15: warning: Invalid variable modifier \"Xmod\" for \"OTHER\".")
+}
+
 func (s *Suite) Test_MkLine_NeedsCond(c *check.C) {
 	t := s.Init(c)
 
@@ -1629,7 +1687,7 @@ func (s *Suite) Test_MatchMkInclude(c *check.C) {
 	test := func(input, expectedIndent, expectedDirective string, expectedFilename RelPath, expectedComment string) {
 		parser := NewMkLineParser()
 		splitResult := parser.split(input, true)
-		splitResult.tokens = parser.tokenize(splitResult.main, nil)
+		splitResult.tokens = parser.tokenize(splitResult.main, nil, nil)
 		m, indent, directive, args := MatchMkInclude(splitResult.main)
 		t.CheckDeepEquals(
 			[]interface{}{m, indent, directive, args, condStr(splitResult.hasComment, "#", "") + splitResult.comment},
@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/textproc"
 	"strings"
 )
@@ -179,7 +180,8 @@ func (ck MkLineChecker) checkVartype(varname string, op MkOperator, value, comme
 		}
 
 	case vartype.IsList() == no:
-		ck.CheckVartypeBasic(varname, vartype.basicType, op, value, comment, vartype.IsGuessed())
+		spans := ck.wordSpans(value, []string{value})
+		ck.CheckVartypeBasic(varname, vartype.basicType, op, value, comment, vartype.IsGuessed(), spans[0])
 
 	case value == "":
 		break
@@ -197,24 +199,51 @@ func (ck MkLineChecker) checkVartype(varname string, op MkOperator, value, comme
 			mkAssignChecker := NewMkAssignChecker(mkline, ck.MkLines)
 			mkAssignChecker.checkRightCategory()
 		}
-		for _, word := range words {
-			ck.CheckVartypeBasic(varname, vartype.basicType, op, word, comment, vartype.IsGuessed())
+		spans := ck.wordSpans(value, words)
+		for i, word := range words {
+			ck.CheckVartypeBasic(varname, vartype.basicType, op, word, comment, vartype.IsGuessed(), spans[i])
 		}
 	}
 }
 
+// wordSpans returns the location of each of the words in the text of the
+// line. If the value is not the value of the variable assignment from the
+// line, the locations are unknown.
+func (ck MkLineChecker) wordSpans(value string, words []string) []*Span {
+	spans := make([]*Span, len(words))
+
+	mkline := ck.MkLine
+	if !mkline.IsVarassignMaybeCommented() || value != mkline.Value() {
+		return spans
+	}
+
+	end := 0
+	for i, word := range words {
+		index := strings.Index(value[end:], word)
+		if index == -1 {
+			break
+		}
+		start := end + index
+		end = start + len(word)
+		span := mkline.ValueSpan(start, end)
+		spans[i] = &span
+	}
+	return spans
+}
+
 // CheckVartypeBasic checks a single list element of the given type.
 //
 // For some variables (like `BuildlinkDepth`), `op` influences the valid values.
 // The `comment` parameter comes from a variable assignment, when a part of the line is commented out.
-func (ck MkLineChecker) CheckVartypeBasic(varname string, checker *BasicType, op MkOperator, value, comment string, guessed bool) {
+// The `span` parameter is the location of the value in the line, if known.
+func (ck MkLineChecker) CheckVartypeBasic(varname string, checker *BasicType, op MkOperator, value, comment string, guessed bool, span *Span) {
 	if trace.Tracing {
 		defer trace.Call(varname, checker.name, op, value, comment, guessed)()
 	}
 
 	mkline := ck.MkLine
 	valueNoVar := mkline.WithoutMakeVariables(value)
-	ctx := VartypeCheck{ck.MkLines, mkline, varname, op, value, valueNoVar, comment, guessed}
+	ctx := VartypeCheck{ck.MkLines, mkline, varname, op, value, valueNoVar, comment, guessed, span}
 	checker.checker(&ctx)
 }
 
@@ -234,11 +263,14 @@ func (ck MkLineChecker) checkShellCommand() {
 			"there is no need to indent some of the commands,",
 			"or to use more horizontal space than necessary.")
 
-		for i := range mkline.raw {
-			if hasPrefix(mkline.RawText(i), tabs) {
-				fix.ReplaceAt(i, 0, tabs, "\t")
+		f, _ := mkline.Tree()
+		ed := ast.NewFileEditor(f)
+		for _, line := range f.Lines() {
+			if hasPrefix(line.Text, tabs) {
+				ed.Replace(f.Find(line, tabs), ast.NewLiteral("\t"))
 			}
 		}
+		fix.Edit(ed)
 		fix.Apply()
 	}
 
@@ -292,19 +324,19 @@ func (ck MkLineChecker) checkInclude() {
 	case mkline.Basename == "buildlink3.mk" && includedFile.HasBase("bsd.prefs.mk"):
 		fix := mkline.Autofix()
 		fix.Notef("For efficiency reasons, include bsd.fast.prefs.mk instead of bsd.prefs.mk.")
-		fix.Replace("bsd.prefs.mk", "bsd.fast.prefs.mk")
+		ck.replaceIncludedFile(fix, "bsd.prefs.mk", "bsd.fast.prefs.mk")
 		fix.Apply()
 
 	case includedFile.HasSuffixPath("pkgtools/x11-links/buildlink3.mk"):
 		fix := mkline.Autofix()
 		fix.Errorf("%q must not be included directly. Include \"../../mk/x11.buildlink3.mk\" instead.", includedFile)
-		fix.Replace("pkgtools/x11-links/buildlink3.mk", "mk/x11.buildlink3.mk")
+		ck.replaceIncludedFile(fix, "pkgtools/x11-links/buildlink3.mk", "mk/x11.buildlink3.mk")
 		fix.Apply()
 
 	case includedFile.HasSuffixPath("graphics/jpeg/buildlink3.mk"):
 		fix := mkline.Autofix()
 		fix.Errorf("%q must not be included directly. Include \"../../mk/jpeg.buildlink3.mk\" instead.", includedFile)
-		fix.Replace("graphics/jpeg/buildlink3.mk", "mk/jpeg.buildlink3.mk")
+		ck.replaceIncludedFile(fix, "graphics/jpeg/buildlink3.mk", "mk/jpeg.buildlink3.mk")
 		fix.Apply()
 
 	case includedFile.HasSuffixPath("intltool/buildlink3.mk"):
@@ -384,10 +416,25 @@ func (ck MkLineChecker) checkIncludeBuiltin() {
 	fix.Rationale(mkline, "builtin", "include", "included", "including")
 	fix.Errorf("%q must not be included directly. Include %q instead.",
 		includedFile, includeInstead)
-	fix.Replace("builtin.mk", "buildlink3.mk")
+	ck.replaceIncludedFile(fix, includedFile.String(), includeInstead.String())
 	fix.Apply()
 }
 
+// replaceIncludedFile replaces the text in the path of the
+// '.include' directive, leaving the rest of the line as is.
+func (ck MkLineChecker) replaceIncludedFile(fix *Autofix, from, to string) {
+	f, tree := ck.MkLine.Tree()
+	include, ok := tree.(*ast.MkIncludeLine)
+	if !ok {
+		return
+	}
+	if found := f.Find(include.Path, from); found != nil {
+		ed := ast.NewFileEditor(f)
+		ed.Replace(found, ast.NewLiteral(to))
+		fix.Edit(ed)
+	}
+}
+
 func (ck MkLineChecker) checkDirectiveIndentation(expectedDepth int) {
 	if ck.MkLines.stmts == nil {
 		return
@@ -398,7 +445,10 @@ func (ck MkLineChecker) checkDirectiveIndentation(expectedDepth int) {
 		fix := mkline.Autofix()
 		fix.Notef("This directive should be indented by %d spaces.", expectedDepth)
 		if hasPrefix(mkline.RawText(0), "."+indent) {
-			fix.ReplaceAt(0, 0, "."+indent, "."+expected)
+			f, tree := mkline.Tree()
+			ed := ast.NewFileEditor(f)
+			ed.Replace(f.Find(tree, "."+indent), ast.NewLiteral("."+expected))
+			fix.Edit(ed)
 		}
 		fix.Apply()
 	}
@@ -426,6 +476,9 @@ func (ck MkLineChecker) CheckRelativePath(rel RelPath, mustExist bool) {
 		abs = mkline.File(resolvedPath.AsRelPath())
 	}
 	if !abs.Exists() {
+		if ck.checkRelocatedPackage(rel, resolvedPath) {
+			return
+		}
 		pkgsrcPath := G.Pkgsrc.Rel(ck.MkLine.File(resolvedPath.AsRelPath()))
 		if mustExist && !ck.MkLines.indentation.HasExists(pkgsrcPath) {
 			mkline.Errorf("Relative path %q does not exist.", rel)
@@ -500,6 +553,51 @@ func (ck MkLineChecker) CheckPackageDir(pkgdir PackagePath) {
 	}
 }
 
+// checkRelocatedPackage checks whether the path refers to a package
+// that has been moved, renamed or removed according to doc/CHANGES-*.
+// It returns whether it has issued a diagnostic.
+func (ck MkLineChecker) checkRelocatedPackage(rel RelPath, resolvedPath PackagePath) bool {
+	m, category, pkgdir := match2(resolvedPath.String(), `^\.\./\.\./([^./][^/]*)/([^./][^/]*)`)
+	if !m {
+		return false
+	}
+	pkgpath := NewPkgsrcPath(NewPath(category + "/" + pkgdir))
+	change := G.Pkgsrc.changes.Relocation(pkgpath)
+	if change == nil {
+		return false
+	}
+
+	mkline := ck.MkLine
+	if change.Action == Removed {
+		mkline.Errorf("The package %s has been removed (see %s).",
+			pkgpath.String(), mkline.Line.RelLocation(change.Location))
+		mkline.Explain(
+			"The package doesn't exist anymore.",
+			"If there is a successor, it is mentioned in the doc/CHANGES file.",
+			"Otherwise, the reference to the package must be removed.")
+		return true
+	}
+
+	target := change.Target()
+	fix := mkline.Autofix()
+	fix.Errorf("The package %s has been %s to %s (see %s).",
+		pkgpath.String(), strings.ToLower(change.Action.String()),
+		target.String(), mkline.Line.RelLocation(change.Location))
+	fix.Explain(
+		"After a package has been moved or renamed,",
+		"all references to it must use its new location.")
+	if from := "../../" + pkgpath.String(); hasPrefix(rel.String(), from) {
+		f, tree := mkline.Tree()
+		if found := f.Find(tree, from); found != nil {
+			ed := ast.NewFileEditor(f)
+			ed.Replace(found, ast.NewLiteral("../../"+target.String()))
+			fix.Edit(ed)
+		}
+	}
+	fix.Apply()
+	return true
+}
+
 func (ck MkLineChecker) checkDirective(forVars map[string]bool, ind *Indentation) {
 	mkline := ck.MkLine
 
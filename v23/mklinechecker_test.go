@@ -384,6 +384,53 @@ func (s *Suite) Test_MkLineChecker_checkVartype__CFLAGS(c *check.C) {
 		"WARN: Makefile:2: Compiler flag \"%s\\\\\\\"\" has unbalanced double quotes.")
 }
 
+// The diagnostics for a single word from a long list point to that word.
+func (s *Suite) Test_MkLineChecker_checkVartype__column(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpVartypes()
+	t.SetUpCommandLine("-Wall", "--gcc-output-format", "--source")
+	mklines := t.NewMkLines("filename.mk",
+		MkCvsID,
+		"CONFIGURE_ARGS+=\t--prefix=${PREFIX} \\",
+		"\t\t\t--with-home=$$HOME \\",
+		"\t\t\t--disable-nls")
+
+	mklines.Check()
+
+	t.CheckOutputLines(
+		">\tCONFIGURE_ARGS+=\t--prefix=${PREFIX} \\",
+		">\t\t\t\t--with-home=$$HOME \\",
+		"\t\t\t\t^~~~~~~~~~~~~~~~~~",
+		">\t\t\t\t--disable-nls",
+		"filename.mk:3:4: warning: Unquoted shell variable \"HOME\".")
+}
+
+func (s *Suite) Test_MkLineChecker_wordSpans(c *check.C) {
+	t := s.Init(c)
+
+	mklines := t.NewMkLines("filename.mk",
+		"VAR=\tword \\",
+		"\t'quoted \\# word' word")
+	mkline := mklines.mklines[0]
+	ck := MkLineChecker{mklines, mkline}
+
+	test := func(value string, words []string, expected ...*Span) {
+		t.CheckDeepEquals(ck.wordSpans(value, words), expected)
+	}
+
+	t.CheckEquals(mkline.Value(), "word 'quoted # word' word")
+
+	test(mkline.Value(), mkline.ValueFields(mkline.Value()),
+		&Span{5, 9},
+		&Span{10, 26},
+		&Span{27, 31})
+
+	// The value of a condition does not appear in the line.
+	test("other", []string{"other"},
+		nil)
+}
+
 func (s *Suite) Test_MkLineChecker_CheckVartypeBasic(c *check.C) {
 	t := s.Init(c)
 
@@ -678,6 +725,60 @@ func (s *Suite) Test_MkLineChecker_checkIncludeBuiltin__rationale(c *check.C) {
 			"Include \"../../category/package/buildlink3.mk\" instead.")
 }
 
+func (s *Suite) Test_MkLineChecker_checkIncludeBuiltin__autofix(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.SetUpCommandLine("-Wall", "--autofix")
+	mklines := t.SetUpFileMkLines("category/package/options.mk",
+		MkCvsID,
+		".include \"../../category/lib/builtin.mk\"")
+	t.CreateFileLines("category/lib/builtin.mk",
+		MkCvsID)
+	t.FinishSetUp()
+
+	mklines.Check()
+	mklines.SaveAutofixChanges()
+
+	t.CheckOutputLines(
+		"AUTOFIX: ~/category/package/options.mk:2: " +
+			"Replacing \"../../category/lib/builtin.mk\" " +
+			"with \"../../category/lib/buildlink3.mk\".")
+	t.CheckFileLines("category/package/options.mk",
+		MkCvsID,
+		".include \"../../category/lib/buildlink3.mk\"")
+}
+
+func (s *Suite) Test_MkLineChecker_replaceIncludedFile(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mklines := t.NewMkLines("filename.mk",
+		".include \"bsd.prefs.mk\" # bsd.prefs.mk",
+		".include \"other.mk\"",
+		"VAR=\tbsd.prefs.mk")
+
+	test := func(mkline *MkLine, diagnostics ...string) {
+		fix := mkline.Autofix()
+		fix.Notef("Note.")
+		MkLineChecker{mklines, mkline}.replaceIncludedFile(fix, "bsd.prefs.mk", "bsd.fast.prefs.mk")
+		fix.Apply()
+		t.CheckOutput(diagnostics)
+	}
+
+	// Only the path is modified, not the comment.
+	test(mklines.mklines[0],
+		"NOTE: filename.mk:1: Note.",
+		"AUTOFIX: filename.mk:1: Replacing \"bsd.prefs.mk\" with \"bsd.fast.prefs.mk\".")
+
+	test(mklines.mklines[1],
+		nil...)
+
+	// Only '.include' directives are modified.
+	test(mklines.mklines[2],
+		nil...)
+}
+
 func (s *Suite) Test_MkLineChecker_checkDirectiveIndentation__autofix(c *check.C) {
 	t := s.Init(c)
 
@@ -925,6 +1026,71 @@ func (s *Suite) Test_MkLineChecker_CheckPackageDir(c *check.C) {
 		nil...)
 }
 
+func (s *Suite) Test_MkLineChecker_checkRelocatedPackage(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("doc/CHANGES-2018",
+		CvsID,
+		"",
+		"\tMoved category/moved to other/moved [author 2018-01-01]",
+		"\tRenamed category/lib to category/lib2 [author 2018-01-02]",
+		"\tRemoved category/removed successor category/new [author 2018-01-03]")
+	t.CreateFileLines("other/moved/Makefile")
+	t.CreateFileBuildlink3("category/lib2/buildlink3.mk")
+	mklines := t.SetUpFileMkLines("category/package/Makefile",
+		MkCvsID,
+		"DEPENDS+=\tmoved-[0-9]*:../../category/moved",
+		"DEPENDS+=\tremoved-[0-9]*:../../category/removed",
+		"DEPENDS+=\tmissing-[0-9]*:../../category/missing",
+		".include \"../../category/lib/buildlink3.mk\"")
+	t.FinishSetUp()
+
+	mklines.Check()
+
+	t.CheckOutputLines(
+		"ERROR: ~/category/package/Makefile:2: The package category/moved "+
+			"has been moved to other/moved (see ../../doc/CHANGES-2018:3).",
+		"ERROR: ~/category/package/Makefile:3: The package category/removed "+
+			"has been removed (see ../../doc/CHANGES-2018:5).",
+		"ERROR: ~/category/package/Makefile:4: Relative path "+
+			"\"../../category/missing/Makefile\" does not exist.",
+		"ERROR: ~/category/package/Makefile:5: The package category/lib "+
+			"has been renamed to category/lib2 (see ../../doc/CHANGES-2018:4).")
+}
+
+func (s *Suite) Test_MkLineChecker_checkRelocatedPackage__autofix(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.SetUpCommandLine("-Wall", "--autofix")
+	t.CreateFileLines("doc/CHANGES-2018",
+		CvsID,
+		"",
+		"\tMoved category/moved to other/moved [author 2018-01-01]",
+		"\tRenamed category/lib to category/lib2 [author 2018-01-02]")
+	t.CreateFileLines("other/moved/Makefile")
+	t.CreateFileBuildlink3("category/lib2/buildlink3.mk")
+	mklines := t.SetUpFileMkLines("category/package/Makefile",
+		MkCvsID,
+		"DEPENDS+=\tmoved-[0-9]*:../../category/moved",
+		".include \"../../category/lib/buildlink3.mk\"")
+	t.FinishSetUp()
+
+	mklines.Check()
+	mklines.SaveAutofixChanges()
+
+	t.CheckOutputLines(
+		"AUTOFIX: ~/category/package/Makefile:2: "+
+			"Replacing \"../../category/moved\" with \"../../other/moved\".",
+		"AUTOFIX: ~/category/package/Makefile:3: "+
+			"Replacing \"../../category/lib\" with \"../../category/lib2\".")
+	t.CheckFileLines("category/package/Makefile",
+		MkCvsID,
+		"DEPENDS+=\tmoved-[0-9]*:../../other/moved",
+		".include \"../../category/lib2/buildlink3.mk\"")
+}
+
 func (s *Suite) Test_MkLineChecker_checkDirective(c *check.C) {
 	t := s.Init(c)
 
@@ -31,8 +31,10 @@ func (p MkLineParser) Parse(line *Line) *MkLine {
 		lex := textproc.NewLexer(text)
 		lex.SkipHspace()
 
+		start := lex.Offset()
 		splitResult := p.split(lex.Rest(), false)
-		splitResult.tokens = p.tokenize(splitResult.main, line)
+		splitResult.tokens = p.tokenize(splitResult.main, line,
+			func(offset int) int { return start + offset })
 		if lex.PeekByte() == '#' {
 			return p.parseCommentOrEmpty(line, p.split(lex.Rest(), true))
 		}
@@ -44,7 +46,8 @@ func (p MkLineParser) Parse(line *Line) *MkLine {
 		return mkline
 	}
 
-	splitResult.tokens = p.tokenize(splitResult.main, line)
+	splitResult.tokens = p.tokenize(splitResult.main, line,
+		func(offset int) int { return escapedOffset(text, offset) })
 	if mkline := p.parseVarassign(line, text, splitResult); mkline != nil {
 		return mkline
 	}
@@ -91,7 +94,8 @@ func (p MkLineParser) matchVarassign(line *Line, text string, splitResult *mkLin
 			return false, nil
 		}
 		*splitResult = p.split(text[1:], true)
-		splitResult.tokens = p.tokenize(splitResult.main, line)
+		splitResult.tokens = p.tokenize(splitResult.main, line,
+			func(offset int) int { return 1 + escapedOffset(text[1:], offset) })
 	}
 
 	lexer := NewMkTokensLexer(splitResult.tokens)
@@ -143,6 +147,7 @@ func (p MkLineParser) matchVarassign(line *Line, text string, splitResult *mkLin
 		spaceAfterVarname: spaceAfterVarname,
 		op:                op,
 		value:             value,
+		valueOffset:       len(parsedValueAlign),
 		valueMk:           nil, // filled in lazily
 		valueMkRest:       "",  // filled in lazily
 		fields:            nil, // filled in lazily
@@ -169,7 +174,7 @@ func (p MkLineParser) fixSpaceAfterVarname(line *Line, a *mkLineAssign) {
 
 		fix := line.Autofix()
 		fix.Notef("Unnecessary space after variable name %q.", varname)
-		fix.Replace(before, after)
+		fix.ReplaceInTree(treeLine, before, after)
 		fix.Apply()
 	}
 }
@@ -309,8 +314,18 @@ func (MkLineParser) split(text string, trimComment bool) mkLineSplitResult {
 	return mkLineSplitResult{mainTrimmed, nil, spaceBeforeComment, hasComment, "", comment}
 }
 
-func (MkLineParser) tokenize(text string, diag Autofixer) []*MkToken {
+// tokenize splits the text into literal text and expressions.
+// If the line is given, the parse errors are reported there,
+// at the offsets that the given function maps from the text.
+func (MkLineParser) tokenize(text string, line *Line, offset func(int) int) []*MkToken {
+	var diag Autofixer
+	if line != nil {
+		diag = line
+	}
 	parser := NewMkLexer(text, diag)
+	if line != nil {
+		parser.Locate(line, offset)
+	}
 	lexer := parser.lexer
 
 	parseOther := func() string {
@@ -252,7 +252,7 @@ func (s *Suite) Test_MkLineParser_matchVarassign(c *check.C) {
 
 		parser := NewMkLineParser()
 		splitResult := parser.split(text, true)
-		splitResult.tokens = parser.tokenize(splitResult.main, nil)
+		splitResult.tokens = parser.tokenize(splitResult.main, nil, nil)
 		m, actual := parser.matchVarassign(line, text, &splitResult)
 
 		assert(m)
@@ -264,11 +264,15 @@ func (s *Suite) Test_MkLineParser_matchVarassign(c *check.C) {
 			spaceAfterVarname: spaceAfterVarname,
 			op:                NewMkOperator(op),
 			value:             value,
+			valueOffset:       actual.valueOffset, // see below
 			valueMk:           nil,
 			valueMkRest:       "",
 			fields:            nil,
 		}
 		t.CheckDeepEquals(*actual, expected)
+		if !contains(text, "\\") {
+			t.CheckEquals(text[actual.valueOffset:actual.valueOffset+len(value)], value)
+		}
 		t.CheckEquals(valueAlign, align)
 		t.CheckEquals(splitResult.spaceBeforeComment, spaceAfterValue)
 		t.CheckEquals(splitResult.hasComment, comment != "")
@@ -285,7 +289,7 @@ func (s *Suite) Test_MkLineParser_matchVarassign(c *check.C) {
 		line := t.NewLine("filename.mk", 123, text)
 		parser := NewMkLineParser()
 		splitResult := parser.split(text, true)
-		splitResult.tokens = parser.tokenize(splitResult.main, nil)
+		splitResult.tokens = parser.tokenize(splitResult.main, nil, nil)
 		m, _ := parser.matchVarassign(line, text, &splitResult)
 		if m {
 			c.Errorf("Text %q matches variable assignment but shouldn't.", text)
@@ -568,7 +572,7 @@ func (s *Suite) Test_MkLineParser_parseDirective(c *check.C) {
 		line := t.NewLine("filename.mk", 123, input)
 		parser := NewMkLineParser()
 		splitResult := parser.split(input, true)
-		splitResult.tokens = parser.tokenize(splitResult.main, line)
+		splitResult.tokens = parser.tokenize(splitResult.main, line, nil)
 		mkline := parser.parseDirective(line, splitResult)
 		if !t.CheckNotNil(mkline) {
 			return
@@ -689,7 +693,7 @@ func (s *Suite) Test_MkLineParser_split(c *check.C) {
 		line := t.NewLine("filename.mk", 123, text)
 		parser := NewMkLineParser()
 		actual := parser.split(text, true)
-		actual.tokens = parser.tokenize(actual.main, line)
+		actual.tokens = parser.tokenize(actual.main, line, nil)
 
 		t.CheckOutput(diagnostics)
 		t.CheckDeepEquals([]interface{}{text, actual}, []interface{}{text, expected})
@@ -1015,7 +1019,7 @@ func (s *Suite) Test_MkLineParser_split__preserve_comment(c *check.C) {
 		line := t.NewLine("filename.mk", 123, text)
 		parser := NewMkLineParser()
 		actual := parser.split(text, false)
-		actual.tokens = parser.tokenize(actual.main, line)
+		actual.tokens = parser.tokenize(actual.main, line, nil)
 
 		t.CheckDeepEquals(actual, expected)
 		t.CheckOutput(diagnostics)
@@ -1070,7 +1074,7 @@ func (s *Suite) Test_MkLineParser_split__unclosed_expr(c *check.C) {
 
 		parser := NewMkLineParser()
 		splitResult := parser.split(text, true)
-		splitResult.tokens = parser.tokenize(splitResult.main, line)
+		splitResult.tokens = parser.tokenize(splitResult.main, line, nil)
 
 		t.CheckDeepEquals(splitResult, expected)
 		t.CheckOutput(diagnostics)
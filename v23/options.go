@@ -1,5 +1,7 @@
 package pkglint
 
+import "strings"
+
 // Checks for 'options.mk' files, which define the options that are available
 // to a package, and the effects that these options have.
 
@@ -11,6 +13,8 @@ func CheckLinesOptionsMk(mklines *MkLines, buildlinkID string) {
 		make(map[string]*MkLine),
 		false,
 		make(map[string]*MkLine),
+		nil,
+		optionConditions{},
 		nil}
 
 	ck.Check()
@@ -28,6 +32,112 @@ type OptionsLinesChecker struct {
 	handledArbitrary          bool
 	handledOptions            map[string]*MkLine
 	optionsInDeclarationOrder []string
+
+	// The options from the conditions of the enclosing .if blocks.
+	optionsStack optionConditions
+	// The buildlink3.mk files that are only included for some options.
+	optionalBuildlinks []*optionalBuildlink
+}
+
+type optionalBuildlink struct {
+	mkline     *MkLine
+	conditions []optionCondition
+}
+
+// optionCondition says that an option is enabled or disabled
+// in a conditional block.
+type optionCondition struct {
+	option  string
+	enabled bool
+}
+
+func (c optionCondition) String() string {
+	return condStr(c.enabled, "", "!") + c.option
+}
+
+// optionConditions tracks the options from the conditions of the
+// enclosing .if blocks. All of these conditions hold at the same time.
+type optionConditions struct {
+	levels []*optionConditionLevel
+}
+
+// optionConditionLevel contains the option conditions for the current
+// branch of an .if/.elif/.else block. The conditions that cannot be
+// expressed in terms of single options are left out.
+type optionConditionLevel struct {
+	// The negated conditions from the previous branches.
+	previous []optionCondition
+	// The conditions from the current branch.
+	current []optionCondition
+	// Whether the condition of the current branch consists of a
+	// single option test, which means that its negation is known.
+	single bool
+}
+
+// Track updates the levels for the directive in the given line.
+// The options of a condition are determined by the given function.
+func (oc *optionConditions) Track(mkline *MkLine, options func(cond *MkCond) []optionCondition) {
+	if !mkline.IsDirective() {
+		return
+	}
+
+	level := func(previous []optionCondition) *optionConditionLevel {
+		cond := mkline.Cond()
+		if cond == nil {
+			return &optionConditionLevel{previous, nil, false}
+		}
+		current := options(cond)
+		for cond.Not != nil || cond.Paren != nil {
+			if cond.Not != nil {
+				cond = cond.Not
+			} else {
+				cond = cond.Paren
+			}
+		}
+		single := len(current) == 1 && (cond.Empty != nil || cond.Term != nil)
+		return &optionConditionLevel{previous, current, single}
+	}
+
+	directive := mkline.Directive()
+	switch {
+	case directive == "if":
+		oc.levels = append(oc.levels, level(nil))
+
+	case hasPrefix(directive, "if"):
+		oc.levels = append(oc.levels, &optionConditionLevel{})
+
+	case len(oc.levels) == 0:
+		break
+
+	case directive == "endif":
+		oc.levels = oc.levels[:len(oc.levels)-1]
+
+	case directive == "elif" || directive == "else":
+		// The negation of a single option test is known exactly,
+		// the negation of a combined condition is not.
+		top := oc.levels[len(oc.levels)-1]
+		previous := top.previous
+		if top.single {
+			c := top.current[0]
+			previous = append(append([]optionCondition(nil), previous...), optionCondition{c.option, !c.enabled})
+		}
+		if directive == "elif" {
+			oc.levels[len(oc.levels)-1] = level(previous)
+		} else {
+			oc.levels[len(oc.levels)-1] = &optionConditionLevel{previous, nil, false}
+		}
+	}
+}
+
+// All returns the option conditions from all levels,
+// from the outermost to the innermost.
+func (oc *optionConditions) All() []optionCondition {
+	var all []optionCondition
+	for _, level := range oc.levels {
+		all = append(all, level.previous...)
+		all = append(all, level.current...)
+	}
+	return all
 }
 
 func (ck *OptionsLinesChecker) Check() {
@@ -38,6 +148,7 @@ func (ck *OptionsLinesChecker) Check() {
 	ck.collect()
 
 	ck.checkOptionsMismatch()
+	ck.checkBuildlink3Options()
 
 	mklines.SaveAutofixChanges()
 }
@@ -135,10 +246,21 @@ func (ck *OptionsLinesChecker) handleUpperLine(mkline *MkLine, seenPkgOptionsVar
 }
 
 func (ck *OptionsLinesChecker) handleLowerLine(mkline *MkLine) {
+	if mkline.IsInclude() {
+		ck.handleLowerInclude(mkline)
+		return
+	}
+
 	if !mkline.IsDirective() {
 		return
 	}
 
+	ck.optionsStack.Track(mkline, func(cond *MkCond) []optionCondition {
+		return ck.conditionOptions(cond, func(varname string) bool {
+			return varname == "PKG_OPTIONS"
+		})
+	})
+
 	directive := mkline.Directive()
 	if directive != "if" && directive != "elif" {
 		return
@@ -152,6 +274,66 @@ func (ck *OptionsLinesChecker) handleLowerLine(mkline *MkLine) {
 	ck.handleLowerCondition(mkline, cond)
 }
 
+// handleLowerInclude remembers the buildlink3.mk files that are only
+// included if some of the options are enabled or disabled.
+func (ck *OptionsLinesChecker) handleLowerInclude(mkline *MkLine) {
+	if mkline.IncludedFile().Base() != "buildlink3.mk" {
+		return
+	}
+
+	if conditions := ck.optionsStack.All(); len(conditions) > 0 {
+		ck.optionalBuildlinks = append(ck.optionalBuildlinks,
+			&optionalBuildlink{mkline, conditions})
+	}
+}
+
+// conditionOptions returns the options that the condition tests,
+// based on the variables that contain the options, and whether each
+// of them must be enabled or disabled for the condition to hold.
+func (ck *OptionsLinesChecker) conditionOptions(cond *MkCond, isOptionsVar func(varname string) bool) []optionCondition {
+	var conditions []optionCondition
+
+	add := func(expr *MkExpr, enabled bool) {
+		if !isOptionsVar(expr.varname) {
+			return
+		}
+		options, _ := ck.exprOptions(expr)
+		for _, option := range options {
+			if !containsExpr(option) {
+				conditions = append(conditions, optionCondition{option, enabled})
+			}
+		}
+	}
+
+	// Only the conditions that must hold for the whole condition to
+	// hold are collected. For "a || b", neither "a" nor "b" must hold,
+	// while for "!(a || b)", both "!a" and "!b" must hold.
+	var visit func(cond *MkCond, enabled bool)
+	visit = func(cond *MkCond, enabled bool) {
+		switch {
+		case cond.Not != nil:
+			visit(cond.Not, !enabled)
+		case cond.Paren != nil:
+			visit(cond.Paren, enabled)
+		case cond.Empty != nil:
+			add(cond.Empty, !enabled)
+		case cond.Term != nil && cond.Term.Expr != nil:
+			add(cond.Term.Expr, enabled)
+		case cond.Or != nil && !enabled:
+			for _, or := range cond.Or {
+				visit(or, enabled)
+			}
+		case cond.And != nil && enabled:
+			for _, and := range cond.And {
+				visit(and, enabled)
+			}
+		}
+	}
+	visit(cond, true)
+
+	return conditions
+}
+
 func (ck *OptionsLinesChecker) handleLowerCondition(mkline *MkLine, cond *MkCond) {
 
 	recordOption := func(option string) {
@@ -165,34 +347,16 @@ func (ck *OptionsLinesChecker) handleLowerCondition(mkline *MkLine, cond *MkCond
 	}
 
 	recordExpr := func(expr *MkExpr) {
-		if expr.varname != "PKG_OPTIONS" || len(expr.modifiers) != 1 {
+		if expr.varname != "PKG_OPTIONS" {
 			return
 		}
 
-		m, positive, pattern, exact := expr.modifiers[0].MatchMatch()
-		if !m || !positive {
-			return
+		options, arbitrary := ck.exprOptions(expr)
+		for _, option := range options {
+			recordOption(option)
 		}
-
-		if optionExpr := ToExpr(pattern); optionExpr != nil {
-			for _, option := range ck.mklines.ExpandLoopVar(optionExpr.varname) {
-				recordOption(option)
-			}
-
-		} else if exact {
-			recordOption(pattern)
-
-		} else {
-			matched := false
-			for declaredOption := range ck.declaredOptions {
-				if pathMatches(pattern, declaredOption) {
-					matched = true
-					recordOption(declaredOption)
-				}
-			}
-			if !matched {
-				ck.handledArbitrary = true
-			}
+		if arbitrary {
+			ck.handledArbitrary = true
 		}
 	}
 
@@ -218,6 +382,35 @@ func (ck *OptionsLinesChecker) handleLowerCondition(mkline *MkLine, cond *MkCond
 	}
 }
 
+// exprOptions returns the options that the expression matches,
+// such as "opt" for ${PKG_OPTIONS:Mopt}. The patterns are matched
+// against the declared options. If the pattern doesn't match any
+// of them, the expression handles arbitrary options.
+func (ck *OptionsLinesChecker) exprOptions(expr *MkExpr) (options []string, arbitrary bool) {
+	if len(expr.modifiers) != 1 {
+		return nil, false
+	}
+
+	m, positive, pattern, exact := expr.modifiers[0].MatchMatch()
+	if !m || !positive {
+		return nil, false
+	}
+
+	if optionExpr := ToExpr(pattern); optionExpr != nil {
+		return ck.mklines.ExpandLoopVar(optionExpr.varname), false
+	}
+	if exact {
+		return []string{pattern}, false
+	}
+
+	for declaredOption := range ck.declaredOptions {
+		if pathMatches(pattern, declaredOption) {
+			options = append(options, declaredOption)
+		}
+	}
+	return options, len(options) == 0
+}
+
 func (ck *OptionsLinesChecker) checkOptionsMismatch() {
 	for _, option := range ck.optionsInDeclarationOrder {
 		declared := ck.declaredOptions[option]
@@ -240,6 +433,105 @@ func (ck *OptionsLinesChecker) checkOptionsMismatch() {
 	}
 }
 
+// checkBuildlink3Options checks that the buildlink3.mk files that are
+// only included for some options are also included by the buildlink3.mk
+// file of the package, under the same condition, since the packages that
+// link against this package need these libraries as well.
+func (ck *OptionsLinesChecker) checkBuildlink3Options() {
+	pkg := ck.mklines.pkg
+	if pkg == nil || len(ck.optionalBuildlinks) == 0 {
+		return
+	}
+
+	bl3 := pkg.File(pkg.Pkgdir.JoinNoClean("buildlink3.mk"))
+	if !bl3.IsFile() {
+		return
+	}
+	bl3Lines := LoadMk(bl3, pkg, MustSucceed)
+
+	// For each included file, whether it is included unconditionally,
+	// and the option conditions under which it is included.
+	unconditional := make(map[CurrPath]bool)
+	guards := make(map[CurrPath][]optionCondition)
+	var bl3Conditions optionConditions
+	bl3Lines.ForEach(func(mkline *MkLine) {
+		bl3Conditions.Track(mkline, func(cond *MkCond) []optionCondition {
+			return ck.conditionOptions(cond, func(varname string) bool {
+				return hasPrefix(varname, "PKG_BUILD_OPTIONS.")
+			})
+		})
+		if !mkline.IsInclude() {
+			return
+		}
+		included := mkline.IncludedFileFull()
+		if !bl3Lines.indentation.IsConditional() {
+			unconditional[included] = true
+		}
+		guards[included] = append(guards[included], bl3Conditions.All()...)
+	})
+
+	pkgbase := pkg.buildlinkID
+	if pkgbase == "" {
+		pkgbase = "pkgbase"
+	}
+
+	for _, optional := range ck.optionalBuildlinks {
+		mkline := optional.mkline
+		included := mkline.IncludedFileFull()
+		condition := optional.conditions[len(optional.conditions)-1]
+		if unconditional[included] || ck.isBuildOnly(mkline) {
+			continue
+		}
+		if optionConditionIn(condition, guards[included]) {
+			continue
+		}
+
+		mkline.Warnf("The buildlink3.mk file of this package should also include %q when the option %q is %s.",
+			mkline.IncludedFile().String(), condition.option, condStr(condition.enabled, "enabled", "disabled"))
+		mkline.Explain(
+			"When a library of this package depends on another library",
+			"only for some options, the packages that use this library",
+			"need the other library as well.",
+			"Otherwise they fail to link.",
+			"",
+			"To propagate the dependency,",
+			"add these lines to the buildlink3.mk file of this package:",
+			"",
+			sprintf("\tpkgbase := %s", pkgbase),
+			"\t.include \"../../mk/pkg-build-options.mk\"",
+			sprintf("\t.if %s${PKG_BUILD_OPTIONS.%s:M%s}",
+				condStr(condition.enabled, "", "!"), pkgbase, condition.option),
+			sprintf("\t.include %q", mkline.IncludedFile().String()),
+			"\t.endif",
+			"",
+			"If the dependency is only needed for building this package,",
+			"set its BUILDLINK_DEPMETHOD to \"build\".")
+	}
+}
+
+func optionConditionIn(condition optionCondition, conditions []optionCondition) bool {
+	for _, c := range conditions {
+		if c == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// isBuildOnly returns whether the included buildlink3.mk file is
+// only used for building the package, via BUILDLINK_DEPMETHOD.
+func (ck *OptionsLinesChecker) isBuildOnly(include *MkLine) bool {
+	pkg := ck.mklines.pkg
+	dir := NewPackagePath(include.IncludedFile().Dir())
+	for id, data := range pkg.bl3Data {
+		if data.pkgsrcdir == dir {
+			depmethod := pkg.vars.LastValue("BUILDLINK_DEPMETHOD." + string(id))
+			return strings.Contains(depmethod, "build")
+		}
+	}
+	return false
+}
+
 func (ck *OptionsLinesChecker) warnVarorder(mkline *MkLine) {
 	mkline.Warnf("Expected definition of PKG_OPTIONS_VAR.")
 	mkline.Explain(
@@ -1,6 +1,9 @@
 package pkglint
 
-import "gopkg.in/check.v1"
+import (
+	"gopkg.in/check.v1"
+	"strings"
+)
 
 func (s *Suite) Test_CheckLinesOptionsMk__autofix(c *check.C) {
 	t := s.Init(c)
@@ -755,6 +758,57 @@ func (s *Suite) Test_CheckLinesOptionsMk__supported_but_not_checked(c *check.C)
 // Up to April 2019, pkglint logged a wrong note saying that OTHER_VARIABLE
 // should have the positive branch first. That note was only ever intended
 // for PKG_OPTIONS.
+func (s *Suite) Test_OptionsLinesChecker_handleLowerLine(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpOption("x11", "")
+	t.SetUpOption("gtk", "")
+	t.SetUpOption("doc", "")
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("mk/bsd.options.mk")
+	t.FinishSetUp()
+	mklines := t.NewMkLines(t.File("category/package/options.mk"),
+		MkCvsID,
+		"",
+		"PKG_OPTIONS_VAR=\tPKG_OPTIONS.package",
+		"PKG_SUPPORTED_OPTIONS=\tx11 gtk doc",
+		"",
+		".include \"../../mk/bsd.options.mk\"",
+		"",
+		".include \"../../devel/always/buildlink3.mk\"",
+		".if ${PKG_OPTIONS:Mx11}",
+		".  include \"../../devel/x11/buildlink3.mk\"",
+		".  if ${OPSYS} == NetBSD",
+		".    include \"../../devel/x11-netbsd/buildlink3.mk\"",
+		".  endif",
+		".elif ${PKG_OPTIONS:Mgtk}",
+		".  include \"../../devel/gtk/buildlink3.mk\"",
+		".elif ${PKG_OPTIONS:Mdoc} && ${OPSYS} == NetBSD",
+		".  include \"../../devel/doc-netbsd/buildlink3.mk\"",
+		".else",
+		".  include \"../../devel/neither/buildlink3.mk\"",
+		".endif",
+		".include \"../../devel/after/buildlink3.mk\"")
+	ck := OptionsLinesChecker{
+		mklines, "", false, make(map[string]*MkLine),
+		false, make(map[string]*MkLine), nil, optionConditions{}, nil}
+
+	ck.collect()
+
+	var actual []string
+	for _, optional := range ck.optionalBuildlinks {
+		actual = append(actual, sprintf("%s %v",
+			optional.mkline.IncludedFile().Dir().Base(), optional.conditions))
+	}
+	t.CheckDeepEquals(actual, []string{
+		"x11 [x11]",
+		"x11-netbsd [x11]",
+		"gtk [!x11 gtk]",
+		"doc-netbsd [!x11 !gtk doc]",
+		"neither [!x11 !gtk]"})
+	t.CheckLen(ck.optionsStack.levels, 0)
+}
+
 func (s *Suite) Test_OptionsLinesChecker_handleLowerCondition__foreign_variable(c *check.C) {
 	t := s.Init(c)
 
@@ -781,3 +835,237 @@ func (s *Suite) Test_OptionsLinesChecker_handleLowerCondition__foreign_variable(
 		"WARN: ~/category/package/options.mk:8: Variable \"OTHER_VARIABLE\" is used but not defined.",
 		"WARN: ~/category/package/options.mk:4: Option \"opt\" should be handled below in an .if block.")
 }
+
+// createLibraryBuildlink3 creates the buildlink3.mk file for a library.
+// Unlike in Tester.CreateFileBuildlink3, the library is a full dependency.
+func createLibraryBuildlink3(t *Tester, pkgpath RelPath, id string) {
+	upperID := strings.ToUpper(id)
+	t.CreateFileLines(pkgpath.JoinNoClean("buildlink3.mk"),
+		MkCvsID,
+		"",
+		"BUILDLINK_TREE+=\t"+id,
+		"",
+		".if !defined("+upperID+"_BUILDLINK3_MK)",
+		upperID+"_BUILDLINK3_MK:=",
+		"",
+		"BUILDLINK_API_DEPENDS."+id+"+=\t"+id+">=0",
+		"BUILDLINK_PKGSRCDIR."+id+"?=\t../../"+pkgpath.String(),
+		".endif # "+upperID+"_BUILDLINK3_MK",
+		"",
+		"BUILDLINK_TREE+=\t-"+id)
+}
+
+func (s *Suite) Test_OptionsLinesChecker_checkBuildlink3Options(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpOption("x11", "")
+	t.SetUpOption("gtk", "")
+	t.SetUpOption("doc", "")
+	t.CreateFileLines("mk/bsd.options.mk")
+	t.CreateFileLines("mk/pkg-build-options.mk")
+	createLibraryBuildlink3(t, "x11/libX11", "libX11")
+	t.CreateFileBuildlink3("x11/gtk3/buildlink3.mk")
+	t.CreateFileBuildlink3("textproc/doctool/buildlink3.mk")
+	t.SetUpPackage("category/package",
+		".include \"options.mk\"")
+	t.CreateFileLines("category/package/options.mk",
+		MkCvsID,
+		"",
+		"PKG_OPTIONS_VAR=\tPKG_OPTIONS.package",
+		"PKG_SUPPORTED_OPTIONS=\tx11 gtk doc",
+		"",
+		".include \"../../mk/bsd.options.mk\"",
+		"",
+		".if ${PKG_OPTIONS:Mx11}",
+		".include \"../../x11/libX11/buildlink3.mk\"",
+		".endif",
+		"",
+		".if ${PKG_OPTIONS:Mgtk}",
+		".include \"../../x11/gtk3/buildlink3.mk\"",
+		".endif",
+		"",
+		".if ${PKG_OPTIONS:Mdoc}",
+		"BUILDLINK_DEPMETHOD.doctool=\tbuild",
+		".include \"../../textproc/doctool/buildlink3.mk\"",
+		".endif")
+	t.CreateFileBuildlink3("category/package/buildlink3.mk",
+		"pkgbase := package",
+		".include \"../../mk/pkg-build-options.mk\"",
+		".if ${PKG_BUILD_OPTIONS.package:Mgtk}",
+		".include \"../../x11/gtk3/buildlink3.mk\"",
+		".endif")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	G.Check(".")
+
+	// The gtk option is handled in the buildlink3.mk file,
+	// and the doc option only adds a build dependency.
+	t.CheckOutputLines(
+		"WARN: options.mk:9: The buildlink3.mk file of this package " +
+			"should also include \"../../x11/libX11/buildlink3.mk\" " +
+			"when the option \"x11\" is enabled.")
+}
+
+func (s *Suite) Test_OptionsLinesChecker_checkBuildlink3Options__negated(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("-Wall", "--explain")
+	t.SetUpOption("ssl", "")
+	t.SetUpOption("nls", "")
+	t.CreateFileLines("mk/bsd.options.mk")
+	createLibraryBuildlink3(t, "security/openssl", "openssl")
+	createLibraryBuildlink3(t, "devel/gettext-lib", "gettext")
+	t.SetUpPackage("category/package",
+		".include \"options.mk\"")
+	t.CreateFileLines("category/package/options.mk",
+		MkCvsID,
+		"",
+		"PKG_OPTIONS_VAR=\tPKG_OPTIONS.package",
+		"PKG_SUPPORTED_OPTIONS=\tssl nls",
+		"",
+		".include \"../../mk/bsd.options.mk\"",
+		"",
+		".if !empty(PKG_OPTIONS:Mssl)",
+		".include \"../../security/openssl/buildlink3.mk\"",
+		".endif",
+		"",
+		".if !${PKG_OPTIONS:Mnls}",
+		".include \"../../devel/gettext-lib/buildlink3.mk\"",
+		".endif")
+	t.CreateFileBuildlink3("category/package/buildlink3.mk")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	G.Check(".")
+
+	t.CheckOutputLines(
+		"WARN: options.mk:9: The buildlink3.mk file of this package "+
+			"should also include \"../../security/openssl/buildlink3.mk\" "+
+			"when the option \"ssl\" is enabled.",
+		"",
+		"\tWhen a library of this package depends on another library only for",
+		"\tsome options, the packages that use this library need the other",
+		"\tlibrary as well. Otherwise they fail to link.",
+		"",
+		"\tTo propagate the dependency, add these lines to the buildlink3.mk",
+		"\tfile of this package:",
+		"",
+		"\t\tpkgbase := package",
+		"\t\t.include \"../../mk/pkg-build-options.mk\"",
+		"\t\t.if ${PKG_BUILD_OPTIONS.package:Mssl}",
+		"\t\t.include \"../../security/openssl/buildlink3.mk\"",
+		"\t\t.endif",
+		"",
+		"\tIf the dependency is only needed for building this package, set its",
+		"\tBUILDLINK_DEPMETHOD to \"build\".",
+		"",
+		"WARN: options.mk:13: The buildlink3.mk file of this package "+
+			"should also include \"../../devel/gettext-lib/buildlink3.mk\" "+
+			"when the option \"nls\" is disabled.",
+		"",
+		"\tWhen a library of this package depends on another library only for",
+		"\tsome options, the packages that use this library need the other",
+		"\tlibrary as well. Otherwise they fail to link.",
+		"",
+		"\tTo propagate the dependency, add these lines to the buildlink3.mk",
+		"\tfile of this package:",
+		"",
+		"\t\tpkgbase := package",
+		"\t\t.include \"../../mk/pkg-build-options.mk\"",
+		"\t\t.if !${PKG_BUILD_OPTIONS.package:Mnls}",
+		"\t\t.include \"../../devel/gettext-lib/buildlink3.mk\"",
+		"\t\t.endif",
+		"",
+		"\tIf the dependency is only needed for building this package, set its",
+		"\tBUILDLINK_DEPMETHOD to \"build\".",
+		"")
+}
+
+func (s *Suite) Test_OptionsLinesChecker_checkBuildlink3Options__guard(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpOption("x11", "")
+	t.SetUpOption("gtk", "")
+	t.SetUpOption("doc", "")
+	t.CreateFileLines("mk/bsd.options.mk")
+	t.CreateFileLines("mk/pkg-build-options.mk")
+	createLibraryBuildlink3(t, "x11/libX11", "libX11")
+	createLibraryBuildlink3(t, "x11/gtk3", "gtk3")
+	createLibraryBuildlink3(t, "textproc/doctool", "doctool")
+	t.SetUpPackage("category/package",
+		".include \"options.mk\"")
+	t.CreateFileLines("category/package/options.mk",
+		MkCvsID,
+		"",
+		"PKG_OPTIONS_VAR=\tPKG_OPTIONS.package",
+		"PKG_SUPPORTED_OPTIONS=\tx11 gtk doc",
+		"",
+		".include \"../../mk/bsd.options.mk\"",
+		"",
+		".if ${PKG_OPTIONS:Mx11}",
+		".include \"../../x11/libX11/buildlink3.mk\"",
+		".endif",
+		"",
+		".if empty(PKG_OPTIONS:Mgtk)",
+		".include \"../../x11/gtk3/buildlink3.mk\"",
+		".endif",
+		"",
+		".if ${PKG_OPTIONS:Mdoc}",
+		".include \"../../textproc/doctool/buildlink3.mk\"",
+		".endif")
+	t.CreateFileBuildlink3("category/package/buildlink3.mk",
+		"pkgbase := package",
+		".include \"../../mk/pkg-build-options.mk\"",
+		".if ${PKG_BUILD_OPTIONS.package:Mgtk}",
+		".include \"../../x11/libX11/buildlink3.mk\"",
+		".include \"../../x11/gtk3/buildlink3.mk\"",
+		".endif",
+		".if ${OPSYS} == NetBSD && ${PKG_BUILD_OPTIONS.package:Mdoc}",
+		".include \"../../textproc/doctool/buildlink3.mk\"",
+		".endif")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	G.Check(".")
+
+	// The libX11 include is guarded by another option, and the gtk3
+	// include by the opposite condition. The doctool include has an
+	// additional condition, which is fine.
+	t.CheckOutputLines(
+		"WARN: options.mk:9: The buildlink3.mk file of this package "+
+			"should also include \"../../x11/libX11/buildlink3.mk\" "+
+			"when the option \"x11\" is enabled.",
+		"WARN: options.mk:13: The buildlink3.mk file of this package "+
+			"should also include \"../../x11/gtk3/buildlink3.mk\" "+
+			"when the option \"gtk\" is disabled.")
+}
+
+func (s *Suite) Test_OptionsLinesChecker_checkBuildlink3Options__no_buildlink3(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpOption("x11", "")
+	t.CreateFileLines("mk/bsd.options.mk")
+	t.CreateFileBuildlink3("x11/libX11/buildlink3.mk")
+	t.SetUpPackage("category/package",
+		".include \"options.mk\"")
+	t.CreateFileLines("category/package/options.mk",
+		MkCvsID,
+		"",
+		"PKG_OPTIONS_VAR=\tPKG_OPTIONS.package",
+		"PKG_SUPPORTED_OPTIONS=\tx11",
+		"",
+		".include \"../../mk/bsd.options.mk\"",
+		"",
+		".if ${PKG_OPTIONS:Mx11}",
+		".include \"../../x11/libX11/buildlink3.mk\"",
+		".endif")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	G.Check(".")
+
+	// Packages without a buildlink3.mk file are not used by other
+	// packages, therefore there's nothing to propagate.
+	t.CheckOutputEmpty()
+}
@@ -88,6 +88,17 @@ type Package struct {
 	// Contains the basenames of the distfiles that are mentioned in distinfo,
 	// for example "package-1.0.tar.gz", even if that file is in a DIST_SUBDIR.
 	distinfoDistfiles map[RelPath]bool
+
+	// The unpatched sources for --wrksrc or --distdir, against which
+	// the patches and the configure arguments are checked, or nil.
+	patchSource *PatchSource
+
+	// The staged installation for --destdir, with which the PLIST
+	// files are compared, or nil.
+	destdir *DestdirChecker
+
+	// The ELF files from the staged installation for --destdir, or nil.
+	elf *ElfChecker
 }
 
 func NewPackage(dir CurrPath) *Package {
@@ -139,9 +150,29 @@ func (pkg *Package) Check() {
 	if files == nil {
 		return
 	}
+	if G.Makesum {
+		files = pkg.makesum(files)
+	}
 	pkg.check(files, mklines, allLines)
 }
 
+// makesum regenerates the distinfo file before it is checked.
+// If the distinfo file has been created just now, it is added to the
+// files to be checked.
+func (pkg *Package) makesum(files []TypedFile) []TypedFile {
+	NewMakesum(pkg, G.distdir).Run()
+
+	for _, tf := range files {
+		if tf.kind == DistinfoFile {
+			return files
+		}
+	}
+	if distinfo := pkg.File(pkg.DistinfoFile); distinfo.IsFile() {
+		files = append(files, TypedFile{DistinfoFile, distinfo})
+	}
+	return files
+}
+
 func (pkg *Package) load() ([]TypedFile, *MkLines, *MkLines) {
 	// Load the package Makefile and all included files,
 	// to collect all used and defined variables and similar data.
@@ -586,7 +617,13 @@ func (pkg *Package) check(tfs []TypedFile, mklines, allLines *MkLines) {
 	haveDistinfo := false
 	havePatches := false
 
+	pkg.collectDependencies(allLines)
 	pkg.checkCvsExists()
+	pkg.patchSource = NewPatchSource(pkg)
+	if !G.destdir.IsEmpty() {
+		pkg.destdir = NewDestdirChecker(pkg, G.destdir)
+		pkg.elf = NewElfChecker(pkg.destdir)
+	}
 	for _, tf := range tfs {
 		filename := tf.path
 		if containsExpr(filename.String()) {
@@ -639,7 +676,223 @@ func (pkg *Package) check(tfs []TypedFile, mklines, allLines *MkLines) {
 	pkg.checkDistinfoFileAndPatchdir()
 	pkg.checkDistfilesInDistinfo(allLines)
 	pkg.checkPkgConfig(allLines)
+	pkg.checkFilesPortability()
+	pkg.checkFilesUnused(allLines)
+	if !G.wrksrc.IsEmpty() {
+		NewWrksrcChecker(pkg, G.wrksrc).Check(allLines)
+	}
+	if pkg.patchSource != nil {
+		NewConfigureArgsChecker(pkg, pkg.patchSource).Check(allLines)
+		NewLockfileChecker(pkg, pkg.patchSource).Check(allLines)
+	}
 	pkg.checkWipCommitMsg()
+	pkg.collectConflicts(allLines)
+	pkg.collectRedistribution(allLines)
+	pkg.collectPythonVersions(allLines)
+	G.InterPackage.AddIncludes(pkg.Pkgpath, allLines, pkg)
+}
+
+// collectDependencies adds the dependencies of the package and its
+// directly included buildlink3.mk files to the inter-package
+// dependency graph.
+func (pkg *Package) collectDependencies(allLines *MkLines) {
+	if G.InterPackage.depgraph == nil {
+		return
+	}
+	G.InterPackage.AddPackage(pkg.Pkgpath)
+
+	bl3Lines := make(map[*MkLine]PackagePath)
+	for bl3, mkline := range pkg.bl3 {
+		bl3Lines[mkline] = bl3
+	}
+
+	allLines.ForEach(func(mkline *MkLine) {
+		// The dependencies from other buildlink3.mk files belong to
+		// the other packages, and the infrastructure adds
+		// dependencies that are common to all packages.
+		if mkline.Basename == "buildlink3.mk" || mkline.Basename == "builtin.mk" ||
+			G.Pkgsrc.IsInfra(mkline.Filename()) {
+			return
+		}
+		conditional := allLines.indentation.IsConditional()
+
+		if bl3, found := bl3Lines[mkline]; found {
+			target := pkg.dependencyTarget(pkg.File(bl3).Dir())
+			if target != "" {
+				G.InterPackage.Depend(pkg.Pkgpath,
+					&Dependency{"buildlink3", pkg.bl3Pattern(target), target, mkline.Line, conditional, pkg.bl3BuildOnly(target)})
+			}
+			return
+		}
+
+		if !mkline.IsVarassign() {
+			return
+		}
+		switch mkline.Varname() {
+		case "DEPENDS", "BUILD_DEPENDS", "TOOL_DEPENDS", "TEST_DEPENDS":
+			break
+		default:
+			return
+		}
+
+		for _, value := range mkline.ValueFields(mkline.Value()) {
+			parts := mkline.ValueSplit(value, ":")
+			if len(parts) != 2 || NewPath(parts[1]).IsAbs() {
+				continue
+			}
+			dir := mkline.ResolveExprsInRelPath(NewRelPathString(parts[1]), pkg)
+			if containsExpr(dir.String()) {
+				continue
+			}
+			target := pkg.dependencyTarget(pkg.File(NewPackagePath(dir)))
+			if target != "" {
+				G.InterPackage.Depend(pkg.Pkgpath,
+					&Dependency{mkline.Varname(), parts[0], target, mkline.Line, conditional, false})
+			}
+		}
+	})
+}
+
+// collectConflicts remembers the CONFLICTS of the package and the files
+// from its PLIST, for checking them against the other packages.
+func (pkg *Package) collectConflicts(allLines *MkLines) {
+	if G.InterPackage.conflicts == nil {
+		return
+	}
+
+	var conflicts []*Conflict
+	for _, mkline := range allLines.mklines {
+		if !mkline.IsVarassign() || mkline.Varname() != "CONFLICTS" ||
+			mkline.Basename == "buildlink3.mk" || mkline.Basename == "builtin.mk" ||
+			G.Pkgsrc.IsInfra(mkline.Filename()) {
+			continue
+		}
+		for _, pattern := range mkline.ValueFields(mkline.Value()) {
+			if !containsExpr(pattern) {
+				conflicts = append(conflicts, &Conflict{pattern, mkline.Line})
+			}
+		}
+	}
+
+	G.InterPackage.AddConflicts(pkg.Pkgpath, pkg.EffectivePkgname, conflicts, pkg.Plist.Files)
+}
+
+// collectRedistribution remembers the license of the package and the
+// restrictions that apply in its default configuration.
+func (pkg *Package) collectRedistribution(allLines *MkLines) {
+	if G.InterPackage.redist == nil {
+		return
+	}
+
+	redist := Redistribution{}
+	allLines.ForEach(func(mkline *MkLine) {
+		if !mkline.IsVarassign() || allLines.indentation.IsConditional() ||
+			mkline.Basename == "buildlink3.mk" || mkline.Basename == "builtin.mk" ||
+			G.Pkgsrc.IsInfra(mkline.Filename()) {
+			return
+		}
+		switch mkline.Varname() {
+		case "NO_BIN_ON_FTP":
+			redist.NoBinOnFTP = true
+		case "RESTRICTED":
+			redist.Restricted = true
+		}
+	})
+	redist.License = resolveExprs(pkg.vars.LastValue("LICENSE"), allLines, nil)
+
+	G.InterPackage.AddRedistribution(pkg.Pkgpath, &redist)
+}
+
+// collectPythonVersions remembers the Python versions that the package
+// accepts, if it is a Python package.
+func (pkg *Package) collectPythonVersions(allLines *MkLines) {
+	if G.InterPackage.python == nil {
+		return
+	}
+
+	python := false
+	determined := true
+	var versions PythonVersions
+	allLines.ForEach(func(mkline *MkLine) {
+		if mkline.Basename == "buildlink3.mk" || mkline.Basename == "builtin.mk" ||
+			G.Pkgsrc.IsInfra(mkline.Filename()) || isPythonMk(G.Pkgsrc.Rel(mkline.Filename()).AsRelPath()) {
+			return
+		}
+
+		if mkline.IsInclude() && isPythonMk(mkline.IncludedFile()) {
+			python = true
+		}
+
+		if !mkline.IsVarassign() {
+			return
+		}
+		var field *[]string
+		switch mkline.Varname() {
+		case "PYTHON_VERSIONS_ACCEPTED":
+			field = &versions.Accepted
+		case "PYTHON_VERSIONS_INCOMPATIBLE":
+			field = &versions.Incompatible
+		default:
+			return
+		}
+		if allLines.indentation.IsConditional() || mkline.Op() == opAssignShell {
+			determined = false
+			return
+		}
+
+		value := mkline.ValueFields(resolveExprs(mkline.Value(), allLines, nil))
+		switch mkline.Op() {
+		case opAssignAppend:
+			*field = append(*field, value...)
+		case opAssignDefault:
+			if *field == nil {
+				*field = append([]string{}, value...)
+			}
+		default:
+			*field = append([]string{}, value...)
+		}
+	})
+
+	if python && determined {
+		G.InterPackage.AddPythonVersions(pkg.Pkgpath, &versions)
+	} else {
+		G.InterPackage.AddPythonVersions(pkg.Pkgpath, nil)
+	}
+}
+
+// dependencyTarget returns the package path of the given directory,
+// or an empty string if the directory is not a package directory.
+func (pkg *Package) dependencyTarget(dir CurrPath) PkgsrcPath {
+	target := G.Pkgsrc.Rel(dir.CleanPath())
+	if target.Count() != 2 || target.HasPrefixPath("..") {
+		return ""
+	}
+	return target
+}
+
+// bl3Pattern returns the BUILDLINK_API_DEPENDS pattern from the
+// buildlink3.mk file of the given package, if it has been loaded.
+func (pkg *Package) bl3Pattern(target PkgsrcPath) string {
+	for _, data := range pkg.bl3Data {
+		if data.apiDependsLine != nil &&
+			pkg.dependencyTarget(pkg.File(data.pkgsrcdir)) == target {
+			return data.apiDependsLine.Value()
+		}
+	}
+	return ""
+}
+
+// bl3BuildOnly returns whether the buildlink3.mk file of the target
+// package is only needed for building the package,
+// via its BUILDLINK_DEPMETHOD.
+func (pkg *Package) bl3BuildOnly(target PkgsrcPath) bool {
+	for id, data := range pkg.bl3Data {
+		if pkg.dependencyTarget(pkg.File(data.pkgsrcdir)) == target {
+			depmethod := pkg.vars.LastValue("BUILDLINK_DEPMETHOD." + string(id))
+			return strings.Contains(depmethod, "build")
+		}
+	}
+	return false
 }
 
 func (pkg *Package) checkCvsExists() {
@@ -787,6 +1040,85 @@ func (pkg *Package) checkPkgConfig(allLines *MkLines) {
 		"directory will be empty and pkg-config will not find anything.")
 }
 
+// checkFilesPortability checks the shell scripts from the FILESDIR,
+// since these are installed or run without going through the
+// check-portability target of the infrastructure.
+//
+// The rc.d scripts get the more thorough checks for shell programs.
+func (pkg *Package) checkFilesPortability() {
+	for _, filename := range pkg.File(pkg.Filesdir).ReadPaths() {
+		switch {
+		case !filename.IsFile():
+		case isRcdScript(filename, pkg):
+			CheckFileShellScript(filename, pkg)
+		default:
+			CheckFilePortability(filename)
+		}
+	}
+}
+
+// checkFilesUnused warns about the files directly in the FILESDIR that
+// are not mentioned in any of the package makefiles.
+//
+// Since the files are referenced in many ways, such as in SUBST blocks,
+// in INSTALL commands, in CONF_FILES or in MESSAGE_SRC, any mention of
+// the filename counts as a use. A reference to the FILESDIR that
+// cannot be resolved to a single file disables this check.
+//
+// Since other packages may use the files as well, by pointing their
+// FILESDIR to this package, this check is only enabled by -Cunused.
+func (pkg *Package) checkFilesUnused(allLines *MkLines) {
+	if !G.CheckUnused || pkg.Filesdir.HasPrefixPath("..") || containsExpr(pkg.Filesdir.String()) {
+		return
+	}
+
+	used := make(map[string]bool)
+	isNameChar := func(r rune) bool {
+		return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("+,-.@_", r)
+	}
+	for _, mkline := range allLines.mklines {
+		text := mkline.Text
+		for _, m := range regcomp(`\$\{FILESDIR\}`).FindAllStringIndex(text, -1) {
+			rest := resolveExprs(text[m[1]:], nil, pkg)
+			if !matches(rest, `^/[+,\-.@\w]+(?:$|[/\s"';&|)])`) {
+				if trace.Tracing {
+					trace.Stepf("Cannot determine the files from %q.", text)
+				}
+				return
+			}
+		}
+
+		resolved := resolveExprs(text, nil, pkg)
+		for _, word := range strings.FieldsFunc(resolved, func(r rune) bool { return !isNameChar(r) }) {
+			used[word] = true
+		}
+		if mkline.IsVarassign() && mkline.Varcanon() == "RCD_SCRIPTS" {
+			for _, name := range mkline.ValueFields(resolveExprs(mkline.Value(), nil, pkg)) {
+				used[name+".sh"] = true
+			}
+		}
+	}
+
+	for _, filename := range pkg.File(pkg.Filesdir).ReadPaths() {
+		basename := filename.Base()
+		if used[basename.String()] || !filename.IsFile() ||
+			basename.HasSuffixText(".orig") || basename.HasSuffixText(".rej") ||
+			basename.ContainsText("README") {
+			continue
+		}
+
+		line := NewLineWhole(filename)
+		line.Warnf("This file seems to be unused.")
+		line.Explain(
+			"None of the package makefiles refers to this file,",
+			"neither via ${FILESDIR} nor via RCD_SCRIPTS,",
+			"which uses ${FILESDIR}/${name}.sh by default.",
+			"",
+			"Files that are no longer needed should be removed.")
+	}
+}
+
 func (pkg *Package) checkWipCommitMsg() {
 	if !G.Wip {
 		return
@@ -1611,6 +1943,11 @@ func (pkg *Package) checkLinesBuildlink3Inclusion(mklines *MkLines) {
 			included := pkg.Rel(mkline.IncludedFileFull())
 			if included.AsPath().HasSuffixPath("buildlink3.mk") {
 				includedFiles[included] = mkline
+				target := pkg.dependencyTarget(pkg.File(included).Dir())
+				if target != "" {
+					G.InterPackage.Depend(pkg.Pkgpath,
+						&Dependency{"buildlink3", pkg.bl3Pattern(target), target, mkline.Line, false, pkg.bl3BuildOnly(target)})
+				}
 				if pkg.bl3[included] == nil {
 					mkline.Warnf("%s is included by this file but not by the package.",
 						mkline.IncludedFile())
@@ -1704,7 +2041,7 @@ func (pkg *Package) AutofixDistinfo(oldSha1, newSha1 string) {
 		for _, line := range lines.Lines {
 			fix := line.Autofix()
 			fix.Warnf(SilentAutofixFormat)
-			fix.Replace(oldSha1, newSha1)
+			fix.ReplaceInTree(treeLine, oldSha1, newSha1)
 			fix.Apply()
 		}
 		lines.SaveAutofixChanges()
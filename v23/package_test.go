@@ -1480,6 +1480,214 @@ func (s *Suite) Test_Package_check__nonexistent_PATCHDIR(c *check.C) {
 	t.CheckOutputEmpty()
 }
 
+func (s *Suite) Test_Package_collectDependencies(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/lib")
+	t.CreateFileBuildlink3("devel/lib/buildlink3.mk")
+	t.SetUpPackage("devel/tester")
+	t.SetUpPackage("devel/app",
+		"TEST_DEPENDS+=\ttester-[0-9]*:../../devel/tester",
+		"",
+		".include \"../../devel/lib/buildlink3.mk\"")
+	t.CreateFileBuildlink3("devel/app/buildlink3.mk",
+		".include \"../../devel/lib/buildlink3.mk\"")
+	t.Chdir(".")
+	t.FinishSetUp()
+	G.InterPackage.EnableDependencies()
+
+	G.Check("devel/app")
+
+	// The inclusion from devel/app/buildlink3.mk is the same as the
+	// one from the Makefile and is therefore not added again.
+	deps := G.InterPackage.depgraph.Dependencies("devel/app")
+	t.CheckLen(deps, 2)
+	t.CheckEquals(deps[0].Kind, "TEST_DEPENDS")
+	t.CheckEquals(deps[0].Pattern, "tester-[0-9]*")
+	t.CheckEquals(deps[0].Target, PkgsrcPath("devel/tester"))
+	t.CheckEquals(deps[1].Kind, "buildlink3")
+	t.CheckEquals(deps[1].Pattern, "lib>=0")
+	t.CheckEquals(deps[1].Target, PkgsrcPath("devel/lib"))
+	t.CheckEquals(deps[1].Line.Filename(), CurrPath("devel/app/Makefile"))
+	// The buildlink3.mk files from CreateFileBuildlink3 set their
+	// BUILDLINK_DEPMETHOD to "build".
+	t.CheckEquals(deps[1].BuildOnly, true)
+}
+
+func (s *Suite) Test_Package_collectDependencies__disabled(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/app",
+		"DEPENDS+=\tlib-[0-9]*:../../devel/lib")
+	t.SetUpPackage("devel/lib")
+	t.FinishSetUp()
+
+	G.Check(t.File("devel/app"))
+
+	t.CheckEquals(G.InterPackage.depgraph, (*DependencyGraph)(nil))
+}
+
+func (s *Suite) Test_Package_collectConflicts(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/app",
+		"CONFLICTS+=\told-app-[0-9]* ${OTHER}-[0-9]*",
+		"CONFLICTS+=\tapp-nox11-[0-9]*",
+		"OTHER=\t\tother")
+	t.Chdir(".")
+	t.FinishSetUp()
+	G.InterPackage.EnableConflicts()
+
+	G.Check("devel/app")
+
+	// Patterns containing expressions are skipped.
+	conflicts := G.InterPackage.conflicts
+	t.CheckDeepEquals(conflicts.packages, []PkgsrcPath{"devel/app"})
+	t.CheckEquals(conflicts.pkgnames["devel/app"], "app-1.0")
+	t.CheckDeepEquals(conflicts.conflictPatterns("devel/app"),
+		[]string{"old-app-[0-9]*", "app-nox11-[0-9]*"})
+	t.CheckEquals(conflicts.conflicts["devel/app"][1].Line.Linenos(), "21")
+	t.CheckEquals(conflicts.owners["bin/program"].pkgpath, PkgsrcPath("devel/app"))
+}
+
+func (s *Suite) Test_Package_collectConflicts__disabled(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/app",
+		"CONFLICTS+=\told-app-[0-9]*")
+	t.FinishSetUp()
+
+	G.Check(t.File("devel/app"))
+
+	t.CheckEquals(G.InterPackage.conflicts, (*ConflictChecker)(nil))
+}
+
+func (s *Suite) Test_Package_collectRedistribution(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/app",
+		"LICENSE=\t${APP_LICENSE}",
+		"APP_LICENSE=\tgnu-gpl-v2",
+		"RESTRICTED=\tNo redistribution",
+		"",
+		".include \"../../mk/bsd.prefs.mk\"",
+		"",
+		".if ${OPSYS} == NetBSD",
+		"NO_BIN_ON_FTP=\t${RESTRICTED}",
+		".endif")
+	t.Chdir(".")
+	t.FinishSetUp()
+	G.InterPackage.EnableRedistribution()
+
+	G.Check("devel/app")
+
+	// The conditional NO_BIN_ON_FTP doesn't apply to the default
+	// configuration of the package.
+	t.CheckDeepEquals(G.InterPackage.redist.packages["devel/app"],
+		&Redistribution{"gnu-gpl-v2", false, true})
+}
+
+func (s *Suite) Test_Package_collectPythonVersions(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/py-app",
+		"PYTHON_VERSIONS_ACCEPTED=\t312 311 # needs new syntax",
+		"PYTHON_VERSIONS_ACCEPTED+=\t310 # via backports",
+		"PYTHON_VERSIONS_INCOMPATIBLE=\t310 # fails tests",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackage("devel/app")
+	t.CreateFileLines("lang/python/wheel.mk",
+		MkCvsID,
+		"PYTHON_VERSIONS_ACCEPTED?=\t27")
+	t.Chdir(".")
+	t.FinishSetUp()
+	G.InterPackage.EnablePythonVersions()
+
+	G.Check("devel/py-app")
+	G.Check("devel/app")
+
+	// The assignments from lang/python/*.mk are not considered.
+	python := G.InterPackage.python
+	t.CheckDeepEquals(python.packages["devel/py-app"],
+		&PythonVersions{[]string{"312", "311", "310"}, []string{"310"}})
+	t.CheckEquals(python.packages["devel/app"], (*PythonVersions)(nil))
+	t.CheckLen(python.packages, 2)
+}
+
+func (s *Suite) Test_Package_collectPythonVersions__conditional(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/py-app",
+		".include \"../../mk/bsd.prefs.mk\"",
+		"",
+		".if ${OPSYS} == NetBSD",
+		"PYTHON_VERSIONS_INCOMPATIBLE=\t27",
+		".endif",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.CreateFileLines("lang/python/wheel.mk",
+		MkCvsID)
+	t.Chdir(".")
+	t.FinishSetUp()
+	G.InterPackage.EnablePythonVersions()
+
+	G.Check("devel/py-app")
+
+	t.CheckEquals(G.InterPackage.python.packages["devel/py-app"], (*PythonVersions)(nil))
+	t.CheckLen(G.InterPackage.python.packages, 1)
+}
+
+func (s *Suite) Test_Package_dependencyTarget(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/app")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("devel/app"))
+
+	test := func(dir PackagePath, expected PkgsrcPath) {
+		t.CheckEquals(pkg.dependencyTarget(pkg.File(dir)), expected)
+	}
+
+	test("../../devel/lib", "devel/lib")
+	test("../../devel/lib/", "devel/lib")
+	test("../../devel", "")
+	test("../../devel/lib/subdir", "")
+	test("../../../outside/pkgsrc", "")
+}
+
+func (s *Suite) Test_Package_bl3Pattern(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileBuildlink3("devel/lib/buildlink3.mk")
+	t.SetUpPackage("devel/app",
+		".include \"../../devel/lib/buildlink3.mk\"")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("devel/app"))
+	pkg.load()
+
+	t.CheckEquals(pkg.bl3Pattern("devel/lib"), "lib>=0")
+	t.CheckEquals(pkg.bl3Pattern("devel/other"), "")
+}
+
+func (s *Suite) Test_Package_bl3BuildOnly(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileBuildlink3("devel/lib/buildlink3.mk")
+	t.CreateFileBuildlink3("devel/headers/buildlink3.mk")
+	t.SetUpPackage("devel/app",
+		".include \"../../devel/headers/buildlink3.mk\"",
+		".include \"../../devel/lib/buildlink3.mk\"",
+		"BUILDLINK_DEPMETHOD.lib=\tfull")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("devel/app"))
+	pkg.load()
+
+	t.CheckEquals(pkg.bl3BuildOnly("devel/headers"), true)
+	t.CheckEquals(pkg.bl3BuildOnly("devel/lib"), false)
+	t.CheckEquals(pkg.bl3BuildOnly("devel/other"), false)
+}
+
 func (s *Suite) Test_Package_checkCvsExistsDir(c *check.C) {
 	t := s.Init(c)
 
@@ -1798,6 +2006,95 @@ func (s *Suite) Test_Package_checkPkgConfig__mk_buildlink3(c *check.C) {
 	t.CheckOutputEmpty()
 }
 
+func (s *Suite) Test_Package_checkFilesPortability(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"FILESDIR=\tfiles.custom")
+	t.CreateFileLines("category/package/files.custom/script.sh",
+		"#! /bin/sh",
+		"test \"$1\" == start")
+	t.CreateFileLines("category/package/files.custom/sub/script.sh",
+		"#! /bin/sh",
+		"test \"$1\" == start")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	pkg := NewPackage(".")
+	pkg.load()
+	pkg.checkFilesPortability()
+
+	t.CheckOutputLines(
+		"ERROR: files.custom/script.sh:2: " +
+			"The \"==\" operator in test expressions is not portable.")
+}
+
+func (s *Suite) Test_Package_checkFilesUnused(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("-Wall", "-Cunused")
+	t.SetUpPackage("category/package",
+		"RCD_SCRIPTS=\tdaemon",
+		"MESSAGE_SRC=\t${PKGDIR}/files/MESSAGE.common",
+		"",
+		"post-extract:",
+		"\t${CP} ${FILESDIR}/${DISTNAME}.conf ${WRKSRC}/")
+	for _, name := range []string{"daemon.sh", "MESSAGE.common", "package-1.0.conf",
+		"unused.sh", "README.pkgsrc", "patch-aa.orig", "sub/unused"} {
+		t.CreateFileLines(NewRelPathString("category/package/files/" + name))
+	}
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	pkg := NewPackage(".")
+	_, _, allLines := pkg.load()
+	pkg.checkFilesUnused(allLines)
+
+	t.CheckOutputLines(
+		"WARN: files/unused.sh: This file seems to be unused.")
+}
+
+func (s *Suite) Test_Package_checkFilesUnused__unresolved(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("-Wall", "-Cunused")
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/files/unused.sh")
+	t.FinishSetUp()
+
+	test := func(filesdir PackagePath, lines []string, diagnostics ...string) {
+		pkg := NewPackage(t.File("category/package"))
+		pkg.Filesdir = filesdir
+		mklines := t.NewMkLines("Makefile", append([]string{MkCvsID}, lines...)...)
+
+		pkg.checkFilesUnused(mklines)
+
+		t.CheckOutput(diagnostics)
+	}
+
+	test("files", nil,
+		"WARN: ~/category/package/files/unused.sh: This file seems to be unused.")
+
+	// The file cannot be determined, therefore any file may be used.
+	test("files", []string{
+		"post-extract:",
+		"\t${CP} ${FILESDIR}/${OPSYS}.sh ${WRKSRC}/"},
+		nil...)
+	test("files", []string{
+		"post-extract:",
+		"\t${CP} ${FILESDIR}/*.sh ${WRKSRC}/"},
+		nil...)
+	test("files", []string{
+		"post-extract:",
+		"\tcd ${FILESDIR} && ${PAX} -rw . ${WRKSRC}"},
+		nil...)
+
+	// The FILESDIR is shared with another package,
+	// which may use the file.
+	test("../../category/other/files", nil,
+		nil...)
+}
+
 func (s *Suite) Test_Package_checkfilePackageMakefile__GNU_CONFIGURE(c *check.C) {
 	t := s.Init(c)
 
@@ -3360,6 +3657,32 @@ func (s *Suite) Test_Package_checkLinesBuildlink3Inclusion__file_but_not_package
 			"but not by the package.")
 }
 
+func (s *Suite) Test_Package_checkLinesBuildlink3Inclusion__dependency_graph(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("category/dependency/buildlink3.mk")
+	t.Chdir(".")
+	t.FinishSetUp()
+	G.InterPackage.EnableDependencies()
+	pkg := NewPackage(t.File("category/package"))
+	mklines := t.NewMkLines("category/package/buildlink3.mk",
+		MkCvsID,
+		"",
+		".include \"../../category/dependency/buildlink3.mk\"")
+
+	pkg.checkLinesBuildlink3Inclusion(mklines)
+
+	t.CheckOutputLines(
+		"WARN: category/package/buildlink3.mk:3: " +
+			"../../category/dependency/buildlink3.mk is included by this file " +
+			"but not by the package.")
+	deps := G.InterPackage.depgraph.Dependencies("category/package")
+	t.CheckLen(deps, 1)
+	t.CheckEquals(deps[0].Kind, "buildlink3")
+	t.CheckEquals(deps[0].Target, PkgsrcPath("category/dependency"))
+}
+
 // Several files from the pkgsrc infrastructure are named *.buildlink3.mk,
 // even though they don't follow the typical file format for buildlink3.mk
 // files. Therefore, they are ignored by this check.
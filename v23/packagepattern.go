@@ -1,6 +1,9 @@
 package pkglint
 
-import "github.com/rillig/pkglint/v23/pkgver"
+import (
+	"github.com/rillig/pkglint/v23/pkgver"
+	"path"
+)
 
 // PackagePattern is a pattern that matches zero or more packages including
 // their versions.
@@ -278,3 +281,101 @@ func (ck *PackagePatternChecker) checkDepends(pp *PackagePattern, prefix string,
 			ck.MkLine.RelMkLine(dependsLine))
 	}
 }
+
+// CheckSatisfiable checks that the package from the pkgsrc tree
+// satisfies the dependency pattern. The directory of the package is
+// given relative to the directory of the current line.
+//
+// This check is only done in -Cglobal mode since it needs to load the
+// makefiles of the other packages.
+func (ck *PackagePatternChecker) CheckSatisfiable(pattern string, dependencyDir string) {
+	if containsExpr(pattern) || containsExpr(dependencyDir) || NewPath(dependencyDir).IsAbs() {
+		return
+	}
+	pkgpath := G.Pkgsrc.Rel(ck.MkLine.File(NewRelPathString(dependencyDir)).CleanPath())
+	if pkgpath.Count() != 2 || pkgpath.HasPrefixPath("..") {
+		return
+	}
+	pkgname := G.Pkgsrc.Pkgname(pkgpath)
+	if pkgname == "" {
+		return
+	}
+
+	baseMatches, matches := packagePatternMatches(pattern, pkgname)
+	if matches {
+		return
+	}
+
+	if !baseMatches {
+		_, pkgbase, _ := matchPkgname(pkgname)
+		ck.MkLine.Errorf("Package pattern %q does not match the package base %q from %s.",
+			pattern, pkgbase, dependencyDir)
+	} else {
+		ck.MkLine.Errorf("Package pattern %q is not satisfied by %q from %s.",
+			pattern, pkgname, dependencyDir)
+	}
+	ck.MkLine.Explain(
+		"The package that is currently in the pkgsrc tree must satisfy",
+		"the dependency pattern.",
+		"Otherwise, the dependency cannot be built during a bulk build,",
+		"and all packages that depend on this package fail as well.",
+		"",
+		"Either adjust the dependency pattern,",
+		"or update the other package first.")
+}
+
+// checkBuildlink3Satisfiable checks that the BUILDLINK_API_DEPENDS
+// and BUILDLINK_ABI_DEPENDS patterns are satisfied by the package from
+// BUILDLINK_PKGSRCDIR.
+func (ck *PackagePatternChecker) checkBuildlink3Satisfiable(pattern string) {
+	varbase := varnameBase(ck.Varname)
+	if varbase != "BUILDLINK_API_DEPENDS" && varbase != "BUILDLINK_ABI_DEPENDS" ||
+		!ck.MkLine.IsVarassign() || ck.MkLines == nil {
+		return
+	}
+
+	id := varnameParam(ck.Varname)
+	pkgsrcdir := ck.MkLines.allVars.LastValue("BUILDLINK_PKGSRCDIR." + id)
+	if pkgsrcdir == "" && ck.MkLines.pkg != nil {
+		if data := ck.MkLines.pkg.bl3Data[Buildlink3ID(id)]; data != nil {
+			pkgsrcdir = data.pkgsrcdir.String()
+		}
+	}
+	if pkgsrcdir != "" {
+		ck.CheckSatisfiable(pattern, pkgsrcdir)
+	}
+}
+
+// packagePatternMatches returns whether the package name matches the
+// pattern, and whether at least the package base matches.
+//
+// Patterns that cannot be evaluated, such as those containing
+// expressions, are assumed to match.
+func packagePatternMatches(pattern string, pkgname string) (baseMatches bool, matches bool) {
+	_, pkgbase, version := matchPkgname(pkgname)
+
+	for _, alternative := range expandCurlyBraces(pattern) {
+		parser := NewMkParser(nil, alternative)
+		pp := ParsePackagePattern(parser)
+		if pp == nil || parser.Rest() != "" || containsExpr(alternative) {
+			return true, true
+		}
+
+		if ok, err := path.Match(pp.Pkgbase, pkgbase); !ok || err != nil {
+			continue
+		}
+		baseMatches = true
+
+		cmpLower := pkgver.Compare(version, pp.Lower)
+		cmpUpper := pkgver.Compare(version, pp.Upper)
+		wildcardOK, err := path.Match(pp.Wildcard, version)
+		if (pp.LowerOp != ">=" || cmpLower >= 0) &&
+			(pp.LowerOp != ">" || cmpLower > 0) &&
+			(pp.UpperOp != "<=" || cmpUpper <= 0) &&
+			(pp.UpperOp != "<" || cmpUpper < 0) &&
+			(pp.Wildcard == "" || wildcardOK && err == nil) {
+			return true, true
+		}
+	}
+	return baseMatches, false
+}
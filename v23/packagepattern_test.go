@@ -472,3 +472,104 @@ func (s *Suite) Test_PackagePatternChecker_checkDepends__ABI_API(c *check.C) {
 		"ERROR: Makefile:21: Packages must only require API versions, " +
 			"not ABI versions of dependencies.")
 }
+
+func (s *Suite) Test_PackagePatternChecker_CheckSatisfiable(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"DEPENDS+=\tlib>=2.0:../../category/lib",
+		"DEPENDS+=\tlib>=1.0:../../category/lib",
+		"DEPENDS+=\tlibrary>=1.0:../../category/lib",
+		"BUILD_DEPENDS+=\tlib-1.5{,nb*}:../../category/lib",
+		"BUILD_DEPENDS+=\t{lib,library}-[0-9]*:../../category/lib",
+		"TOOL_DEPENDS+=\tlib<1.5:../../category/lib",
+		"TOOL_DEPENDS+=\tunknown>=1.0:../../category/unknown")
+	t.SetUpPackage("category/lib",
+		"DISTNAME=\tlib-1.5",
+		"PKGREVISION=\t3")
+	t.SetUpPackage("category/unknown",
+		"PKGNAME=\t${PYPKGPREFIX}-unknown-1.0")
+	t.Chdir("category/package")
+	t.SetUpCommandLine("-Cglobal")
+	t.FinishSetUp()
+
+	G.checkdirPackage(".")
+
+	// The PKGNAME of category/unknown cannot be determined
+	// since PYPKGPREFIX is defined by the infrastructure.
+	t.CheckOutputLines(
+		"ERROR: Makefile:20: Package pattern \"lib>=2.0\" is not satisfied "+
+			"by \"lib-1.5nb3\" from ../../category/lib.",
+		"ERROR: Makefile:22: Package pattern \"library>=1.0\" does not match "+
+			"the package base \"lib\" from ../../category/lib.",
+		"ERROR: Makefile:25: Package pattern \"lib<1.5\" is not satisfied "+
+			"by \"lib-1.5nb3\" from ../../category/lib.")
+}
+
+func (s *Suite) Test_PackagePatternChecker_CheckSatisfiable__without_Cglobal(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"DEPENDS+=\tlib>=2.0:../../category/lib")
+	t.SetUpPackage("category/lib")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	G.checkdirPackage(".")
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_PackagePatternChecker_checkBuildlink3Satisfiable(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		".include \"../../category/lib/buildlink3.mk\"",
+		"BUILDLINK_API_DEPENDS.lib+=\tlib>=3.0")
+	t.SetUpPackage("category/lib")
+	t.CreateFileBuildlink3("category/lib/buildlink3.mk",
+		"BUILDLINK_ABI_DEPENDS.lib+=\tlib>=1.1")
+	t.Chdir("category/package")
+	t.SetUpCommandLine("-Cglobal")
+	t.FinishSetUp()
+
+	G.Check(".")
+	G.Check("../../category/lib")
+
+	// The BUILDLINK_API_DEPENDS.lib in category/lib/buildlink3.mk
+	// is ">=0" and thus satisfied.
+	t.CheckOutputLines(
+		"ERROR: Makefile:21: Package pattern \"lib>=3.0\" is not satisfied "+
+			"by \"lib-1.0\" from ../../category/lib.",
+		"ERROR: ../../category/lib/buildlink3.mk:12: "+
+			"Package pattern \"lib>=1.1\" is not satisfied "+
+			"by \"lib-1.0\" from ../../category/lib.")
+}
+
+func (s *Suite) Test_packagePatternMatches(c *check.C) {
+	t := s.Init(c)
+
+	test := func(pattern, pkgname string, baseMatches, matches bool) {
+		actualBase, actual := packagePatternMatches(pattern, pkgname)
+		t.CheckDeepEquals(
+			[]bool{actualBase, actual},
+			[]bool{baseMatches, matches})
+	}
+
+	test("pkg>=1.0", "pkg-1.0", true, true)
+	test("pkg>1.0", "pkg-1.0", true, false)
+	test("pkg>1.0", "pkg-1.0nb1", true, true)
+	test("pkg>=1.0<2", "pkg-2.0", true, false)
+	test("pkg>=1.0<=2", "pkg-2.0", true, true)
+	test("pkg-[0-9]*", "pkg-0.1", true, true)
+	test("pkg-1.0", "pkg-1.0nb2", true, false)
+	test("pkg-1.0{,nb*}", "pkg-1.0nb2", true, true)
+	test("pkg-1.*", "pkg-2.0", true, false)
+	test("{pkg,other}>=2", "other-2.0", true, true)
+	test("py[0-9]*-pkg>=1", "py312-pkg-1.0", true, true)
+	test("other>=1", "pkg-1.0", false, false)
+
+	// Patterns that cannot be evaluated are assumed to match.
+	test("pkg>=${VERSION}", "pkg-1.0", true, true)
+	test("pkg", "pkg-1.0", true, true)
+}
@@ -1,6 +1,9 @@
 package pkglint
 
-import "strings"
+import (
+	"github.com/rillig/pkglint/v23/ast"
+	"strings"
+)
 
 // Paragraph is a slice of makefile lines that is surrounded by empty lines.
 //
@@ -59,12 +62,16 @@ func (p *Paragraph) AlignTo(column int) {
 			return
 		}
 
-		trimmed := rtrimHspace(align)
-		newSpace := alignmentAfter(trimmed, column)
+		newSpace := alignmentAfter(rtrimHspace(align), column)
 
 		fix := mkline.Autofix()
 		fix.Notef(SilentAutofixFormat)
-		fix.ReplaceAfter(trimmed, align[len(trimmed):], newSpace)
+		f, tree := mkline.Tree()
+		if assign, ok := tree.(*ast.MkAssignLine); ok {
+			ed := ast.NewFileEditor(f)
+			ed.Replace(&assign.S2, ast.NewLiteral(newSpace))
+			fix.Edit(ed)
+		}
 		fix.Apply()
 	})
 }
@@ -51,7 +51,7 @@ func (s *Suite) Test_Paragraph_AlignTo(c *check.C) {
 	mklines.SaveAutofixChanges()
 
 	t.CheckOutputLines(
-		"AUTOFIX: ~/filename.mk:2: Replacing \"\" with \"\\t\\t\".",
+		"AUTOFIX: ~/filename.mk:2: Inserting \"\\t\\t\".",
 		"AUTOFIX: ~/filename.mk:5: Replacing \"\\t \\t\" with \"\\t\\t\".",
 		"AUTOFIX: ~/filename.mk:6: Replacing \"\\t\\t\\t\" with \"\\t\\t\".")
 
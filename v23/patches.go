@@ -2,10 +2,13 @@ package pkglint
 
 // Checks for patch files.
 
-import "strings"
+import (
+	"github.com/rillig/pkglint/v23/ast"
+	"strings"
+)
 
 func CheckLinesPatch(lines *Lines, pkg *Package) {
-	(&PatchChecker{lines, NewLinesLexer(lines), false, false}).Check(pkg)
+	(&PatchChecker{lines, NewLinesLexer(lines), false, false, nil}).Check(pkg)
 }
 
 type PatchChecker struct {
@@ -13,6 +16,18 @@ type PatchChecker struct {
 	llex              *LinesLexer
 	seenDocumentation bool
 	previousLineEmpty bool
+
+	// The hunks of the patch, for checking them against the
+	// unpatched sources, see PatchSource.
+	diffs []*patchFileDiff
+}
+
+// patchFileDiff is the part of a patch file that applies to a single
+// file, together with the lines from the patch file, for the diagnostics.
+type patchFileDiff struct {
+	*fileDiff
+	line      *Line   // The second line of the file header.
+	hunkLines []*Line // The "@@" line of each hunk.
 }
 
 const rePatchUniFileDel = `^---[\t ]([^\t ]+)(?:[\t ]+(.*))?$`
@@ -87,6 +102,10 @@ func (ck *PatchChecker) Check(pkg *Package) {
 		ck.checkCanonicalPatchName(patchedFiles[0])
 	}
 
+	if pkg != nil && pkg.patchSource != nil {
+		pkg.patchSource.Check(ck.lines, ck.diffs)
+	}
+
 	CheckLinesTrailingEmptyLines(ck.lines)
 	sha1Before := computePatchSha1Hex(ck.lines)
 	if SaveAutofixChanges(ck.lines) && pkg != nil {
@@ -99,6 +118,8 @@ func (ck *PatchChecker) Check(pkg *Package) {
 // See https://www.gnu.org/software/diffutils/manual/html_node/Detailed-Unified.html
 func (ck *PatchChecker) checkUnifiedDiff(patchedFile Path) {
 	isConfigure := ck.isConfigure(patchedFile)
+	isShell := isPortableShellFile(patchedFile)
+	diff := patchFileDiff{&fileDiff{"", patchedFile.String(), nil}, ck.llex.PreviousLine(), nil}
 
 	linesDiff := 0
 	hasHunks := false
@@ -114,6 +135,13 @@ func (ck *PatchChecker) checkUnifiedDiff(patchedFile Path) {
 		linesToDel := toInt(m[2], 1)
 		linenoAdd := toInt(m[3], 0)
 		linesToAdd := toInt(m[4], 1)
+		hunkLine := ck.llex.PreviousLine()
+		hunk := diffHunk{oldStart: linenoDel}
+		if m[2] != "" && linesToDel == 0 {
+			// For pure insertions, the line number refers to the line
+			// after which the new lines are inserted.
+			hunk.oldStart++
+		}
 		if linenoDel > 0 && linenoAdd > 0 && linenoDel+linesDiff != linenoAdd {
 			line := ck.llex.PreviousLine()
 			line.Notef("The difference between the line numbers %d and %d should be %d, not %d.",
@@ -145,12 +173,17 @@ func (ck *PatchChecker) checkUnifiedDiff(patchedFile Path) {
 				// There should be a space here, but that was a trailing space and
 				// has been trimmed down somewhere on its way. Doesn't matter,
 				// all the patch programs can handle this situation.
+				hunk.oldLines = append(hunk.oldLines, "")
+				hunk.newLines = append(hunk.newLines, "")
 				linesToDel--
 				linesToAdd--
 				linenoDel++
 				linenoAdd++
 
 			case hasPrefix(text, " "), hasPrefix(text, "\t"):
+				context := strings.TrimPrefix(text, " ")
+				hunk.oldLines = append(hunk.oldLines, context)
+				hunk.newLines = append(hunk.newLines, context)
 				linesToDel--
 				linesToAdd--
 				linenoDel++
@@ -158,14 +191,19 @@ func (ck *PatchChecker) checkUnifiedDiff(patchedFile Path) {
 				ck.checktextCvsID(text)
 
 			case hasPrefix(text, "-"):
+				hunk.oldLines = append(hunk.oldLines, text[1:])
 				linesToDel--
 				linenoDel++
 
 			case hasPrefix(text, "+"):
+				hunk.newLines = append(hunk.newLines, text[1:])
 				linesToAdd--
 				ck.checktextCvsID(text)
 				ck.checkConfigure(text[1:], isConfigure)
 				ck.checkAddedLine(text[1:], linenoAdd)
+				if isShell {
+					NewPortabilityChecker(true).CheckLine(line, 1)
+				}
 				linenoAdd++
 
 			case hasPrefix(text, "\\"):
@@ -186,9 +224,16 @@ func (ck *PatchChecker) checkUnifiedDiff(patchedFile Path) {
 			line.Warnf("Premature end of patch hunk (expected %d %s to be deleted and %d %s to be added).",
 				linesToDel, condStr(linesToDel != 1, "lines", "line"),
 				linesToAdd, condStr(linesToAdd != 1, "lines", "line"))
+		} else if linesToDel == 0 {
+			diff.hunks = append(diff.hunks, &hunk)
+			diff.hunkLines = append(diff.hunkLines, hunkLine)
 		}
 	}
 
+	if len(diff.hunks) > 0 {
+		ck.diffs = append(ck.diffs, &diff)
+	}
+
 	if !hasHunks {
 		ck.llex.CurrentLine().Errorf("No patch hunks for %q.", patchedFile.String())
 	}
@@ -390,7 +435,12 @@ func (ck *PatchChecker) checktextUniHunkCr() {
 	fix.Errorf("The hunk header must not end with a CR character.")
 	fix.Explain(
 		"The MacOS X patch utility cannot handle these.")
-	fix.Replace("\r\n", "\n")
+	f, _ := line.Tree()
+	text := f.Lines()[0]
+	end := len(f.Text(text)) - len("\n")
+	ed := ast.NewFileEditor(f)
+	ed.Remove(f.Slice(text, end-len("\r"), end))
+	fix.Edit(ed)
 	fix.Apply()
 }
 
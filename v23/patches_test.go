@@ -452,7 +452,7 @@ func (s *Suite) Test_CheckLinesPatch__crlf_autofix(c *check.C) {
 	// the pkgsrc infrastructure could fix these issues before actually
 	// applying the patches.
 	t.CheckOutputLines(
-		"AUTOFIX: ~/patch-aa:7: Replacing \"\\r\\n\" with \"\\n\".")
+		"AUTOFIX: ~/patch-aa:7: Deleting \"\\r\".")
 }
 
 func (s *Suite) Test_CheckLinesPatch__autogenerated(c *check.C) {
@@ -662,6 +662,35 @@ func (s *Suite) Test_PatchChecker_checkUnifiedDiff__line_number_mismatch(c *chec
 		"NOTE: patch-aa:10: The difference between the line numbers 5 and 7 should be 0, not 2.")
 }
 
+func (s *Suite) Test_PatchChecker_checkUnifiedDiff__portability(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("-Wall", "--show-autofix")
+	lines := t.NewLines("patch-configure",
+		CvsID,
+		"",
+		"Documentation",
+		"",
+		"--- configure.orig",
+		"+++ configure",
+		"@@ -1,3 +1,3 @@",
+		" #! /bin/sh",
+		"-if test \"$enable_x\" == yes; then",
+		"+if test \"$enable_x\" == yes && test \"$$\" == 1; then",
+		" fi")
+
+	CheckLinesPatch(lines, nil)
+
+	// Only the added lines are checked, since the other lines are not
+	// under the control of the patch.
+	t.CheckOutputLines(
+		"ERROR: patch-configure:10: The \"==\" operator in test expressions is not portable.",
+		"AUTOFIX: patch-configure:10: Replacing \"==\" with \"=\".",
+		"ERROR: patch-configure:10: The \"==\" operator in test expressions is not portable.",
+		"AUTOFIX: patch-configure:10: Replacing \"==\" with \"=\".")
+	t.CheckEquals(lines.Lines[9].RawText(0), "+if test \"$enable_x\" = yes && test \"$$\" = 1; then")
+}
+
 func (s *Suite) Test_PatchChecker_checkBeginDiff__multiple_patches_without_documentation(c *check.C) {
 	t := s.Init(c)
 
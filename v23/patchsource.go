@@ -0,0 +1,292 @@
+package pkglint
+
+import (
+	"github.com/rillig/pkglint/v23/regex"
+	"sort"
+	"strings"
+)
+
+// PatchSource provides the unpatched upstream sources of a package,
+// for checking whether the patches still apply to them and whether the
+// upstream build files still know the configure arguments.
+//
+// The sources come either from the directory given by --wrksrc,
+// or from the distfile in the directory given by --distdir,
+// which is extracted in memory.
+//
+// After the package has been updated to a new upstream version, some
+// of the patches typically don't apply anymore, and others have been
+// included upstream and are thus obsolete.
+type PatchSource struct {
+	fs  FileSystem
+	dir CurrPath
+}
+
+// NewPatchSource returns the unpatched sources of the package,
+// or nil if they are not available.
+func NewPatchSource(pkg *Package) *PatchSource {
+	if !G.wrksrc.IsEmpty() {
+		return &PatchSource{G.FileSystem, G.wrksrc}
+	}
+	if !G.distdir.IsEmpty() {
+		return loadPatchSource(pkg)
+	}
+	return nil
+}
+
+// loadPatchSource extracts the distfile of the package from the
+// DISTDIR into memory.
+func loadPatchSource(pkg *Package) *PatchSource {
+	vars := pkg.vars
+	distfile := "${DISTNAME}${EXTRACT_SUFX}"
+	if fields := strings.Fields(vars.LastValue("DISTFILES")); len(fields) > 0 {
+		distfile = fields[0]
+	}
+	distfile = resolveExprs(distfile, nil, pkg)
+	if subdir := vars.LastValue("DIST_SUBDIR"); subdir != "" {
+		distfile = resolveExprs(subdir, nil, pkg) + "/" + distfile
+	}
+
+	wrksrc := "${WRKDIR}/${DISTNAME}"
+	if vars.IsDefined("WRKSRC") {
+		wrksrc = vars.LastValue("WRKSRC")
+	}
+	wrksrc = resolveExprs(wrksrc, nil, pkg)
+
+	if containsExpr(distfile) || !isArchiveArg(distfile) ||
+		!hasPrefix(wrksrc, "${WRKDIR}/") || containsExpr(wrksrc[len("${WRKDIR}/"):]) {
+		if trace.Tracing {
+			trace.Stepf("Cannot determine the distfile %q with WRKSRC %q.", distfile, wrksrc)
+		}
+		return nil
+	}
+
+	filename := G.distdir.JoinNoClean(NewRelPathString(distfile)).CleanPath()
+	if !filename.IsFile() {
+		line := NewLineWhole(pkg.File("Makefile"))
+		line.Notef("The distfile %q is not in %q, therefore the patches are not checked.",
+			distfile, G.distdir.String())
+		return nil
+	}
+
+	mem := NewMemFileSystem()
+	if _, err := loadArchive(filename.String(), "/wrkdir", mem); err != nil {
+		G.Logger.TechErrorf(filename, "Cannot extract the distfile: %s", err)
+		return nil
+	}
+	rel := NewRelPathString(wrksrc[len("${WRKDIR}/"):]).Clean()
+	return &PatchSource{mem, NewCurrPathString("/wrkdir").JoinNoClean(rel)}
+}
+
+// Check applies the hunks from the patch file in memory and reports
+// the hunks that don't apply cleanly, similar to the output of patch(1).
+func (src *PatchSource) Check(lines *Lines, diffs []*patchFileDiff) {
+	var results []*patchHunkResult
+	obsolete := len(diffs) > 0
+	for _, diff := range diffs {
+		fileResults, ok := src.apply(diff)
+		results = append(results, fileResults...)
+		obsolete = obsolete && ok
+	}
+	for _, result := range results {
+		obsolete = obsolete && result.reversed
+	}
+
+	if obsolete {
+		line := lines.Whole()
+		line.Warnf("The patch is already applied to the extracted sources.")
+		line.Explain(
+			"The upstream sources already contain the changes from this patch,",
+			"typically because the patch has been accepted upstream.",
+			"",
+			"The patch can be removed.",
+			sprintf("Afterwards, run %q to remove it from the distinfo file.",
+				bmake("makepatchsum")))
+		return
+	}
+
+	for _, result := range results {
+		result.report()
+	}
+}
+
+// apply applies the hunks of the file diff to the unpatched file.
+// It returns false if the file doesn't exist.
+func (src *PatchSource) apply(diff *patchFileDiff) ([]*patchHunkResult, bool) {
+	name := NewPath(diff.newName).Clean()
+	if name.IsAbs() || name.HasPrefixPath("..") {
+		return nil, false
+	}
+
+	data, err := src.ReadFile(NewRelPath(name))
+	if err != nil {
+		for _, hunk := range diff.hunks {
+			if len(hunk.oldLines) > 0 {
+				diff.line.Errorf("The file %q does not exist in the extracted sources.", name.String())
+				diff.line.Explain(
+					"The patched file has probably been renamed or removed",
+					"in the upstream sources, which makes the patch stale.",
+					"",
+					"If the changes from the patch are still needed,",
+					"the patch must be adjusted to the new file.",
+					"Otherwise, the patch can be removed.",
+					sprintf("Afterwards, run %q to update the distinfo file.",
+						bmake("makepatchsum")))
+				break
+			}
+		}
+		return nil, false
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+
+	var results []*patchHunkResult
+	pos := 0    // The first line from lines that is not affected by the previous hunks.
+	offset := 0 // How far the hunks have moved compared to their header.
+	for i, hunk := range diff.hunks {
+		result := patchHunkResult{line: diff.hunkLines[i], failed: true}
+		expected := hunk.oldStart - 1 + offset
+
+		// A hunk without old lines would apply anywhere,
+		// but to an existing file, it adds the lines a second time.
+		if len(hunk.oldLines) > 0 || len(lines) == 0 {
+			if start, end, fuzz := src.findHunk(lines, hunk, pos, expected); start >= 0 {
+				result = patchHunkResult{result.line, false, false, start - (hunk.oldStart - 1), fuzz}
+				offset, pos = result.offset, end
+			}
+		}
+
+		if result.failed && len(hunk.newLines) > 0 {
+			var fd fileDiff
+			reversed := diffHunk{oldStart: hunk.oldStart, oldLines: hunk.newLines}
+			if start := fd.findHunk(lines, &reversed, pos, expected); start >= 0 {
+				result.failed, result.reversed = false, true
+				pos = start + len(hunk.newLines)
+			}
+		}
+
+		results = append(results, &result)
+	}
+	return results, true
+}
+
+// ReadFile returns the content of the file from the unpatched sources.
+func (src *PatchSource) ReadFile(rel RelPath) ([]byte, error) {
+	return src.fs.ReadFile(src.dir.JoinNoClean(rel).String())
+}
+
+// Find returns the files from the unpatched sources whose basename
+// matches the pattern, sorted by path.
+func (src *PatchSource) Find(pattern regex.Pattern) []RelPath {
+	var found []RelPath
+	var walk func(rel RelPath)
+	walk = func(rel RelPath) {
+		entries, err := src.fs.ReadDir(src.dir.JoinNoClean(rel).String())
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			entryRel := rel.JoinNoClean(NewRelPathString(entry.Name())).CleanDot()
+			if entry.IsDir() {
+				walk(entryRel)
+			} else if matches(entry.Name(), pattern) {
+				found = append(found, entryRel)
+			}
+		}
+	}
+	walk(".")
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	return found
+}
+
+// findHunk returns the range of lines to which the hunk applies,
+// and the fuzz that is needed for that.
+//
+// Like patch(1), it ignores up to 2 lines of leading and trailing
+// context if the hunk doesn't apply otherwise.
+// If the hunk doesn't apply at all, start is -1.
+func (src *PatchSource) findHunk(lines []string, hunk *diffHunk, minStart, expected int) (start, end, fuzz int) {
+	lead, trail := diffContext(hunk)
+
+	var fd fileDiff
+	for fuzz = 0; fuzz <= 2; fuzz++ {
+		front, back := min(fuzz, lead), min(fuzz, trail)
+		if fuzz > 0 && front == min(fuzz-1, lead) && back == min(fuzz-1, trail) {
+			continue
+		}
+
+		trimmed := diffHunk{oldLines: hunk.oldLines[front : len(hunk.oldLines)-back]}
+		if start = fd.findHunk(lines, &trimmed, minStart+front, expected+front); start >= 0 {
+			return start - front, start + len(trimmed.oldLines), fuzz
+		}
+	}
+	return -1, -1, 0
+}
+
+// patchHunkResult describes how a single hunk of a patch applies to the
+// unpatched sources.
+type patchHunkResult struct {
+	line *Line
+
+	failed   bool
+	reversed bool // The sources already contain the new lines.
+	offset   int  // How far the hunk has moved compared to its header.
+	fuzz     int  // How many lines of context had to be ignored.
+}
+
+func (r *patchHunkResult) report() {
+	switch {
+	case r.failed:
+		r.line.Errorf("This hunk does not apply to the extracted sources.")
+		r.line.Explain(
+			"The upstream sources have changed in a way that conflicts",
+			"with this hunk.",
+			"",
+			"If the upstream sources already contain an equivalent change,",
+			"the hunk can be removed.",
+			"Otherwise, the patch needs to be adjusted to the new sources.")
+
+	case r.reversed:
+		r.line.Warnf("This hunk is already applied to the extracted sources.")
+		r.line.Explain(
+			"The upstream sources already contain the changes from this hunk.",
+			"Therefore, the hunk can be removed from the patch.")
+
+	case r.fuzz > 0:
+		r.line.Warnf("This hunk only applies with fuzz %d to the extracted sources.", r.fuzz)
+		r.explainRegenerate()
+
+	case r.offset != 0:
+		r.line.Notef("This hunk applies with an offset of %d %s to the extracted sources.",
+			r.offset, condStr(r.offset == 1 || r.offset == -1, "line", "lines"))
+		r.explainRegenerate()
+	}
+}
+
+func (r *patchHunkResult) explainRegenerate() {
+	r.line.Explain(
+		"To make the patch apply cleanly again, run",
+		bmake("patch"),
+		"and then \"mkpatches\" to regenerate the patches",
+		sprintf("and %q to update the distinfo file.", bmake("makepatchsum")))
+}
+
+// diffContext returns the number of context lines at the beginning and
+// at the end of the hunk.
+func diffContext(hunk *diffHunk) (lead, trail int) {
+	oldLines, newLines := hunk.oldLines, hunk.newLines
+	n := min(len(oldLines), len(newLines))
+	for lead < n && oldLines[lead] == newLines[lead] {
+		lead++
+	}
+	for lead+trail < n && oldLines[len(oldLines)-1-trail] == newLines[len(newLines)-1-trail] {
+		trail++
+	}
+	return lead, trail
+}
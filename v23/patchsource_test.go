@@ -0,0 +1,318 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+// checkPatchSource applies the patch to the file src/file.c from the
+// extracted sources in work/package-1.0. The file consists of the
+// lines "line 1" to "line 10".
+func checkPatchSource(t *Tester, patchLines ...string) {
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, sprintf("line %d", i))
+	}
+	t.CreateFileLines("work/package-1.0/src/file.c", lines...)
+
+	patch := t.SetUpFileLines("patches/patch-src_file.c",
+		append([]string{CvsID, "", "Documentation", ""}, patchLines...)...)
+	ck := PatchChecker{patch, NewLinesLexer(patch), false, false, nil}
+	ck.Check(nil)
+
+	src := PatchSource{G.FileSystem, t.File("work/package-1.0")}
+	src.Check(patch, ck.diffs)
+}
+
+func (s *Suite) Test_NewPatchSource(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+
+	t.CheckEquals(NewPatchSource(pkg), (*PatchSource)(nil))
+
+	G.wrksrc = t.File("work/package-1.0")
+
+	t.CheckDeepEquals(NewPatchSource(pkg), &PatchSource{G.FileSystem, t.File("work/package-1.0")})
+}
+
+func (s *Suite) Test_loadPatchSource(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"EXTRACT_SUFX=\t.tar")
+	t.CreateFileLines("category/package/patches/patch-src_file.c",
+		CvsID,
+		"",
+		"Documentation",
+		"",
+		"--- src/file.c.orig",
+		"+++ src/file.c",
+		"@@ -1,2 +1,2 @@",
+		"-old",
+		"+new",
+		" context")
+	t.CreateFileLines("distfiles/package-1.0.tar")
+	t.AssertNil(t.File("distfiles/package-1.0.tar").WriteString(string(tarArchive(t,
+		"package-1.0/src/file.c",
+		"new\ncontext\n"))))
+	t.Chdir(".")
+
+	t.Main("-q", "--only=extracted sources", "--distdir=distfiles", "category/package")
+
+	t.CheckOutputLines(
+		"WARN: category/package/patches/patch-src_file.c: " +
+			"The patch is already applied to the extracted sources.")
+}
+
+func (s *Suite) Test_loadPatchSource__missing_distfile(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileDummyPatch("category/package/patches/patch-aa")
+	t.CreateFileLines("distfiles/other-1.0.tar.gz")
+	t.Chdir(".")
+
+	t.Main("-q", "--only=distfile", "--distdir=distfiles", "category/package")
+
+	t.CheckOutputLines(
+		"NOTE: category/package/Makefile: The distfile \"package-1.0.tar.gz\" " +
+			"is not in \"distfiles\", therefore the patches are not checked.")
+}
+
+func (s *Suite) Test_loadPatchSource__broken_distfile(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("distfiles/package-1.0.tar.gz",
+		"plain text")
+	t.Chdir(".")
+
+	t.Main("-q", "--only=distfile", "--distdir=distfiles", "category/package")
+
+	t.CheckOutputLines(
+		"ERROR: distfiles/package-1.0.tar.gz: " +
+			"Cannot extract the distfile: gzip: invalid header")
+}
+
+func (s *Suite) Test_PatchSource_Check(c *check.C) {
+	t := s.Init(c)
+
+	checkPatchSource(t,
+		"--- src/file.c.orig",
+		"+++ src/file.c",
+		"@@ -1,3 +1,3 @@",
+		" line 1",
+		"-line 2",
+		"+line two",
+		" line 3",
+		"@@ -5,3 +5,3 @@",
+		" line 5",
+		"-line six",
+		"+line 6",
+		" line 7",
+		"@@ -8,3 +8,3 @@",
+		" line 8",
+		"-old line",
+		"+new line",
+		" line 10")
+
+	t.CheckOutputLines(
+		"WARN: ~/patches/patch-src_file.c:12: "+
+			"This hunk is already applied to the extracted sources.",
+		"ERROR: ~/patches/patch-src_file.c:17: "+
+			"This hunk does not apply to the extracted sources.")
+}
+
+func (s *Suite) Test_PatchSource_Check__obsolete(c *check.C) {
+	t := s.Init(c)
+
+	checkPatchSource(t,
+		"--- src/file.c.orig",
+		"+++ src/file.c",
+		"@@ -1,3 +1,3 @@",
+		" line 1",
+		"-line two",
+		"+line 2",
+		" line 3")
+
+	t.CheckOutputLines(
+		"WARN: ~/patches/patch-src_file.c: " +
+			"The patch is already applied to the extracted sources.")
+}
+
+func (s *Suite) Test_PatchSource_apply(c *check.C) {
+	t := s.Init(c)
+
+	checkPatchSource(t,
+		"--- src/file.c.orig",
+		"+++ src/file.c",
+		"@@ -1,3 +1,3 @@",
+		" line 1",
+		"-line 2",
+		"+line two",
+		" line 3",
+		"--- src/missing.c.orig",
+		"+++ src/missing.c",
+		"@@ -1 +1 @@",
+		"-old",
+		"+new",
+		"--- /dev/null",
+		"+++ src/added.c",
+		"@@ -0,0 +1 @@",
+		"+added")
+
+	t.CheckOutputLines(
+		"WARN: ~/patches/patch-src_file.c: Contains patches for 3 files, should be only one.",
+		"ERROR: ~/patches/patch-src_file.c:13: "+
+			"The file \"src/missing.c\" does not exist in the extracted sources.")
+}
+
+func (s *Suite) Test_PatchSource_apply__stale(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+
+	checkPatchSource(t,
+		"--- src/renamed.c.orig",
+		"+++ src/renamed.c",
+		"@@ -1 +1 @@",
+		"-old",
+		"+new")
+
+	t.CheckOutputLines(
+		"WARN: ~/patches/patch-src_file.c: The patch file should be named "+
+			"\"patch-src_renamed.c\" to match the patched file \"src/renamed.c\".",
+		"ERROR: ~/patches/patch-src_file.c:6: "+
+			"The file \"src/renamed.c\" does not exist in the extracted sources.",
+		"",
+		"\tThe patched file has probably been renamed or removed in the",
+		"\tupstream sources, which makes the patch stale.",
+		"",
+		"\tIf the changes from the patch are still needed, the patch must be",
+		"\tadjusted to the new file. Otherwise, the patch can be removed.",
+		"\tAfterwards, run \""+confMake+" makepatchsum\" to update the distinfo file.",
+		"")
+}
+
+func (s *Suite) Test_PatchSource_apply__add_to_existing_file(c *check.C) {
+	t := s.Init(c)
+
+	checkPatchSource(t,
+		"--- /dev/null",
+		"+++ src/file.c",
+		"@@ -0,0 +1 @@",
+		"+added")
+
+	t.CheckOutputLines(
+		"ERROR: ~/patches/patch-src_file.c:7: " +
+			"This hunk does not apply to the extracted sources.")
+}
+
+func (s *Suite) Test_PatchSource_ReadFile(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("work/package-1.0/README",
+		"readme")
+	src := PatchSource{G.FileSystem, t.File("work/package-1.0")}
+
+	data, err := src.ReadFile("README")
+	_, errMissing := src.ReadFile("missing")
+
+	t.CheckEquals(string(data), "readme\n")
+	t.CheckEquals(err, nil)
+	t.CheckEquals(errMissing != nil, true)
+}
+
+func (s *Suite) Test_PatchSource_Find(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("work/package-1.0/CMakeLists.txt")
+	t.CreateFileLines("work/package-1.0/src/CMakeLists.txt")
+	t.CreateFileLines("work/package-1.0/cmake/module.cmake")
+	t.CreateFileLines("work/package-1.0/README")
+	src := PatchSource{G.FileSystem, t.File("work/package-1.0")}
+
+	t.CheckDeepEquals(src.Find(`^CMakeLists\.txt$|\.cmake$`), []RelPath{
+		"CMakeLists.txt",
+		"cmake/module.cmake",
+		"src/CMakeLists.txt"})
+	t.CheckDeepEquals(src.Find(`^nonexistent$`), []RelPath(nil))
+}
+
+func (s *Suite) Test_PatchSource_findHunk(c *check.C) {
+	t := s.Init(c)
+
+	lines := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	var src PatchSource
+
+	test := func(oldStart int, oldLines, newLines []string, start, end, fuzz int) {
+		hunk := diffHunk{oldStart: oldStart, oldLines: oldLines, newLines: newLines}
+		actualStart, actualEnd, actualFuzz := src.findHunk(lines, &hunk, 0, oldStart-1)
+		t.CheckDeepEquals(
+			[]int{actualStart, actualEnd, actualFuzz},
+			[]int{start, end, fuzz})
+	}
+
+	test(2, []string{"2", "3", "4"}, []string{"2", "three", "4"}, 1, 4, 0)
+	test(1, []string{"2", "3", "4"}, []string{"2", "three", "4"}, 1, 4, 0)
+	test(2, []string{"x", "3", "4"}, []string{"x", "three", "4"}, 1, 3, 1)
+	test(2, []string{"x", "y", "4", "5"}, []string{"x", "y", "four", "5"}, 1, 4, 2)
+	test(2, []string{"x", "y", "z", "5"}, []string{"x", "y", "z", "five"}, -1, -1, 0)
+	test(2, []string{"x"}, []string{"y"}, -1, -1, 0)
+}
+
+func (s *Suite) Test_patchHunkResult_report(c *check.C) {
+	t := s.Init(c)
+
+	line := t.NewLine("patch-aa", 5, "@@ -1,3 +1,3 @@")
+	test := func(result patchHunkResult, diagnostics ...string) {
+		result.line = line
+		result.report()
+		t.CheckOutput(diagnostics)
+	}
+
+	test(patchHunkResult{},
+		nil...)
+	test(patchHunkResult{failed: true},
+		"ERROR: patch-aa:5: This hunk does not apply to the extracted sources.")
+	test(patchHunkResult{reversed: true},
+		"WARN: patch-aa:5: This hunk is already applied to the extracted sources.")
+	test(patchHunkResult{offset: 3, fuzz: 2},
+		"WARN: patch-aa:5: This hunk only applies with fuzz 2 to the extracted sources.")
+	test(patchHunkResult{offset: -1},
+		"NOTE: patch-aa:5: This hunk applies with an offset of -1 line to the extracted sources.")
+	test(patchHunkResult{offset: 12},
+		"NOTE: patch-aa:5: This hunk applies with an offset of 12 lines to the extracted sources.")
+}
+
+func (s *Suite) Test_patchHunkResult_explainRegenerate(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+	line := t.NewLine("patch-aa", 5, "@@ -1,3 +1,3 @@")
+	result := patchHunkResult{line, false, false, 2, 0}
+
+	result.report()
+
+	t.CheckOutputLines(
+		"NOTE: patch-aa:5: This hunk applies with an offset of 2 lines to the extracted sources.",
+		"",
+		"\tTo make the patch apply cleanly again, run @BMAKE@ patch and then",
+		"\t\"mkpatches\" to regenerate the patches and \"@BMAKE@ makepatchsum\" to",
+		"\tupdate the distinfo file.",
+		"")
+}
+
+func (s *Suite) Test_diffContext(c *check.C) {
+	t := s.Init(c)
+
+	test := func(oldLines, newLines []string, lead, trail int) {
+		actualLead, actualTrail := diffContext(&diffHunk{oldLines: oldLines, newLines: newLines})
+		t.CheckDeepEquals([]int{actualLead, actualTrail}, []int{lead, trail})
+	}
+
+	test(nil, []string{"added"}, 0, 0)
+	test([]string{"1", "2", "3"}, []string{"1", "two", "3"}, 1, 1)
+	test([]string{"1", "2", "3", "4"}, []string{"1", "2", "4"}, 2, 1)
+	test([]string{"1", "1"}, []string{"1", "1", "1"}, 2, 0)
+}
@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
@@ -321,12 +322,16 @@ func (p CurrPath) Rel(rel CurrPath) RelPath {
 }
 
 func (p CurrPath) Rename(newName CurrPath) error {
-	return os.Rename(string(p), string(newName))
+	wfs, err := writableFileSystem("rename", string(p))
+	if err != nil {
+		return err
+	}
+	return wfs.Rename(string(p), string(newName))
 }
 
-func (p CurrPath) Lstat() (os.FileInfo, error) { return os.Lstat(string(p)) }
+func (p CurrPath) Lstat() (os.FileInfo, error) { return G.FileSystem.Lstat(string(p)) }
 
-func (p CurrPath) Stat() (os.FileInfo, error) { return os.Stat(string(p)) }
+func (p CurrPath) Stat() (os.FileInfo, error) { return G.FileSystem.Stat(string(p)) }
 
 func (p CurrPath) Exists() bool {
 	_, err := p.Lstat()
@@ -344,11 +349,15 @@ func (p CurrPath) IsDir() bool {
 }
 
 func (p CurrPath) Chmod(mode os.FileMode) error {
-	return os.Chmod(string(p), mode)
+	wfs, err := writableFileSystem("chmod", string(p))
+	if err != nil {
+		return err
+	}
+	return wfs.Chmod(string(p), mode)
 }
 
 func (p CurrPath) ReadDir() ([]os.DirEntry, error) {
-	return os.ReadDir(string(p))
+	return G.FileSystem.ReadDir(string(p))
 }
 
 func (p CurrPath) ReadPaths() []CurrPath {
@@ -366,15 +375,19 @@ func (p CurrPath) ReadPaths() []CurrPath {
 	return filenames
 }
 
-func (p CurrPath) Open() (*os.File, error) { return os.Open(string(p)) }
+func (p CurrPath) Open() (fs.File, error) { return G.FileSystem.Open(string(p)) }
 
 func (p CurrPath) ReadString() (string, error) {
-	bytes, err := os.ReadFile(string(p))
+	bytes, err := G.FileSystem.ReadFile(string(p))
 	return string(bytes), err
 }
 
 func (p CurrPath) WriteString(s string) error {
-	return os.WriteFile(string(p), []byte(s), 0666)
+	wfs, err := writableFileSystem("write", string(p))
+	if err != nil {
+		return err
+	}
+	return wfs.WriteFile(string(p), []byte(s), 0666)
 }
 
 // PkgsrcPath is a path relative to the pkgsrc root.
@@ -0,0 +1,300 @@
+package pkglint
+
+import "strings"
+
+// PackageEvaluator determines the values of a few variables of a
+// package, such as PKGNAME or CONFLICTS, without loading and checking
+// the package completely. It is used for checking the dependency
+// patterns and conflicts from other packages.
+//
+// Only the files of the package and the makefile fragments that are
+// shared between packages are loaded, but not the infrastructure files
+// and not the buildlink3.mk files, and neither the lang/python/*.mk
+// files, whose inclusion is only recorded. Variables that are assigned
+// conditionally or via the != operator cannot be evaluated, and neither
+// can variables from the infrastructure, such as PYPKGPREFIX.
+type PackageEvaluator struct {
+	vars          map[string]string
+	indeterminate map[string]bool
+	visited       map[CurrPath]bool
+
+	// Whether the package includes one of the lang/python/*.mk files.
+	python bool
+
+	// The assignments to DEPENDS, including the conditional ones.
+	depends []*MkLine
+}
+
+// NewPackageEvaluator loads the package Makefile and the files it
+// includes from the given package directory.
+func NewPackageEvaluator(pkgdir CurrPath) *PackageEvaluator {
+	ev := PackageEvaluator{
+		make(map[string]string),
+		make(map[string]bool),
+		make(map[CurrPath]bool),
+		false,
+		nil}
+	ev.load(pkgdir.JoinNoClean("Makefile"))
+	return &ev
+}
+
+// Pkgname returns the effective PKGNAME of the package, including the
+// "nb" suffix from PKGREVISION, or an empty string if it cannot be
+// determined.
+func (ev *PackageEvaluator) Pkgname() string {
+	expr := condStr(ev.isDefined("PKGNAME"), "${PKGNAME}", "${DISTNAME}")
+	pkgname, ok := ev.eval(expr, 0)
+	if !ok || !matchesPkgname(pkgname) {
+		return ""
+	}
+
+	if ev.isDefined("PKGREVISION") {
+		revision, ok := ev.eval("${PKGREVISION}", 0)
+		if !ok {
+			return ""
+		}
+		if revision != "" && revision != "0" {
+			pkgname += "nb" + revision
+		}
+	}
+	return pkgname
+}
+
+// Conflicts returns the patterns from the CONFLICTS variable, or false
+// if they cannot be determined.
+func (ev *PackageEvaluator) Conflicts() ([]string, bool) {
+	if !ev.isDefined("CONFLICTS") {
+		return nil, true
+	}
+	value, ok := ev.eval("${CONFLICTS}", 0)
+	if !ok {
+		return nil, false
+	}
+	return strings.Fields(value), true
+}
+
+// Redistribution returns the license and the redistribution
+// restrictions of the package. Restrictions that only apply in some
+// cases, such as for a package option, are not included.
+func (ev *PackageEvaluator) Redistribution() *Redistribution {
+	_, noBinOnFTP := ev.vars["NO_BIN_ON_FTP"]
+	_, restricted := ev.vars["RESTRICTED"]
+	license, ok := ev.eval("${LICENSE}", 0)
+	if !ok {
+		license = ""
+	}
+	return &Redistribution{license, noBinOnFTP, restricted}
+}
+
+// PythonVersions returns the Python versions that the package accepts,
+// or nil if the package is not a Python package or if the versions
+// cannot be determined.
+func (ev *PackageEvaluator) PythonVersions() *PythonVersions {
+	if !ev.python {
+		return nil
+	}
+
+	var versions PythonVersions
+	if ev.isDefined("PYTHON_VERSIONS_ACCEPTED") {
+		accepted, ok := ev.eval("${PYTHON_VERSIONS_ACCEPTED}", 0)
+		if !ok {
+			return nil
+		}
+		versions.Accepted = strings.Fields(accepted)
+		if versions.Accepted == nil {
+			versions.Accepted = []string{}
+		}
+	}
+	if ev.isDefined("PYTHON_VERSIONS_INCOMPATIBLE") {
+		incompatible, ok := ev.eval("${PYTHON_VERSIONS_INCOMPATIBLE}", 0)
+		if !ok {
+			return nil
+		}
+		versions.Incompatible = strings.Fields(incompatible)
+	}
+	return &versions
+}
+
+// Depends returns the packages from the DEPENDS variable, including
+// the dependencies that are only added in some configurations.
+// Dependencies whose directory cannot be determined are skipped.
+func (ev *PackageEvaluator) Depends() []PkgsrcPath {
+	var pkgpaths []PkgsrcPath
+	for _, mkline := range ev.depends {
+		for _, value := range mkline.ValueFields(mkline.Value()) {
+			parts := mkline.ValueSplit(value, ":")
+			if len(parts) != 2 {
+				continue
+			}
+			dir, ok := ev.eval(parts[1], 0)
+			if !ok || !hasPrefix(dir, "../../") {
+				continue
+			}
+			pkgpath := NewPkgsrcPath(NewPath(dir[6:]).Clean())
+			if pkgpath.Count() == 2 && !pkgpath.HasPrefixPath("..") {
+				pkgpaths = append(pkgpaths, pkgpath)
+			}
+		}
+	}
+	return pkgpaths
+}
+
+// Distfiles returns the files that are downloaded for the package,
+// from DISTFILES and PATCHFILES, without the IGNORE_DISTFILES and
+// prefixed with the DIST_SUBDIR, as they appear in the distinfo file.
+// It returns false if the distfiles cannot be determined.
+func (ev *PackageEvaluator) Distfiles() ([]string, bool) {
+	value := func(varname, fallback string) (string, bool) {
+		if !ev.isDefined(varname) {
+			return fallback, true
+		}
+		return ev.eval("${"+varname+"}", 0)
+	}
+
+	distfiles, ok := value("DISTFILES", "")
+	if !ev.isDefined("DISTFILES") {
+		distname, okName := ev.eval("${DISTNAME}", 0)
+		extractSufx, okSufx := value("EXTRACT_SUFX", ".tar.gz")
+		distfiles, ok = distname+extractSufx, okName && okSufx
+	}
+	patchfiles, okPatch := value("PATCHFILES", "")
+	ignored, okIgnored := value("IGNORE_DISTFILES", "")
+	subdir, okSubdir := value("DIST_SUBDIR", "")
+	if !ok || !okPatch || !okIgnored || !okSubdir {
+		return nil, false
+	}
+
+	var files []string
+	for _, file := range strings.Fields(distfiles + " " + patchfiles) {
+		if containsStr(strings.Fields(ignored), file) {
+			continue
+		}
+		if subdir != "" {
+			file = subdir + "/" + file
+		}
+		if !containsStr(files, file) {
+			files = append(files, file)
+		}
+	}
+	return files, true
+}
+
+func (ev *PackageEvaluator) isDefined(varname string) bool {
+	_, found := ev.vars[varname]
+	return found || ev.indeterminate[varname]
+}
+
+func (ev *PackageEvaluator) load(filename CurrPath) {
+	if ev.visited[filename] {
+		return
+	}
+	ev.visited[filename] = true
+
+	mklines := LoadMk(filename, nil, 0)
+	if mklines == nil {
+		return
+	}
+
+	depth := 0
+	for _, mkline := range mklines.mklines {
+		switch {
+		case mkline.IsVarassign():
+			ev.assign(mkline, depth > 0)
+
+		case mkline.IsDirective():
+			switch mkline.Directive() {
+			case "if", "ifdef", "ifndef", "ifmake", "ifnmake", "for":
+				depth++
+			case "endif", "endfor":
+				depth--
+			}
+
+		case mkline.IsInclude():
+			included := mkline.IncludedFile()
+			if containsExpr(included.String()) || included.HasBase("buildlink3.mk") ||
+				included.HasBase("builtin.mk") {
+				break
+			}
+			if isPythonMk(included) {
+				ev.python = true
+				break
+			}
+			includedFile := mkline.File(included).CleanPath()
+			if !G.Pkgsrc.IsInfra(includedFile) {
+				ev.load(includedFile)
+			}
+		}
+	}
+}
+
+func (ev *PackageEvaluator) assign(mkline *MkLine, conditional bool) {
+	varname := mkline.Varname()
+	if varname == "DEPENDS" {
+		ev.depends = append(ev.depends, mkline)
+	}
+	if conditional || mkline.Op() == opAssignShell {
+		ev.indeterminate[varname] = true
+		return
+	}
+
+	value := mkline.Value()
+	prev, found := ev.vars[varname]
+	switch mkline.Op() {
+	case opAssignAppend:
+		if found && prev != "" {
+			value = prev + " " + value
+		}
+	case opAssignDefault:
+		if found {
+			return
+		}
+	case opAssignEval:
+		// The expressions are evaluated later, which is only wrong
+		// if the variables are modified after this line.
+	}
+	ev.vars[varname] = value
+}
+
+// eval resolves the expressions in the text. It returns false if
+// any of the expressions cannot be resolved.
+func (ev *PackageEvaluator) eval(text string, depth int) (string, bool) {
+	if depth > 10 {
+		return "", false
+	}
+
+	tokens, rest := NewMkLexer(text, nil).MkTokens()
+	if rest != "" {
+		return "", false
+	}
+
+	var sb strings.Builder
+	for _, token := range tokens {
+		if token.Expr == nil {
+			sb.WriteString(token.Text)
+			continue
+		}
+
+		raw, found := ev.vars[token.Expr.varname]
+		if !found || ev.indeterminate[token.Expr.varname] {
+			return "", false
+		}
+		value, ok := ev.eval(raw, depth+1)
+		if !ok {
+			return "", false
+		}
+
+		for _, mod := range token.Expr.modifiers {
+			if mod.IsToLower() {
+				value = strings.ToLower(value)
+			} else if mod == "tu" {
+				value = strings.ToUpper(value)
+			} else if ok, subst := mod.Subst(value); ok && !containsExpr(subst) {
+				value = subst
+			} else {
+				return "", false
+			}
+		}
+		sb.WriteString(value)
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,287 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_NewPackageEvaluator(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+
+	ev := NewPackageEvaluator(t.File("category/package"))
+	missing := NewPackageEvaluator(t.File("category/nonexistent"))
+
+	t.CheckEquals(ev.vars["DISTNAME"], "package-1.0")
+	t.CheckLen(missing.vars, 0)
+}
+
+func (s *Suite) Test_PackageEvaluator_Pkgname(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/distname")
+	t.SetUpPackage("category/pkgname",
+		"PKGNAME=\t${DISTNAME:S,^pkgname,pkg,}",
+		"PKGREVISION=\t5")
+	t.SetUpPackage("category/revision-zero",
+		"PKGREVISION=\t0")
+	t.SetUpPackage("category/indeterminate",
+		".if ${OPSYS} == NetBSD",
+		"PKGREVISION=\t1",
+		".endif")
+	t.SetUpPackage("category/invalid",
+		"DISTNAME=\tinvalid")
+	t.FinishSetUp()
+
+	test := func(pkgpath RelPath, expected string) {
+		t.CheckEquals(NewPackageEvaluator(t.File(pkgpath)).Pkgname(), expected)
+	}
+
+	test("category/distname", "distname-1.0")
+	test("category/pkgname", "pkg-1.0nb5")
+	test("category/revision-zero", "revision-zero-1.0")
+	test("category/indeterminate", "")
+	test("category/invalid", "")
+	test("category/nonexistent", "")
+}
+
+func (s *Suite) Test_PackageEvaluator_Conflicts(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/none")
+	t.SetUpPackage("category/some",
+		"CONFLICTS+=\tother-[0-9]*",
+		"CONFLICTS+=\t${PKGBASE_OLD}-[0-9]*",
+		"PKGBASE_OLD=\told")
+	t.SetUpPackage("category/indeterminate",
+		".if ${OPSYS} == NetBSD",
+		"CONFLICTS=\tother-[0-9]*",
+		".endif")
+	t.FinishSetUp()
+
+	test := func(pkgpath RelPath, expected []string, expectedOK bool) {
+		conflicts, ok := NewPackageEvaluator(t.File(pkgpath)).Conflicts()
+		t.CheckDeepEquals(conflicts, expected)
+		t.CheckEquals(ok, expectedOK)
+	}
+
+	test("category/none", []string(nil), true)
+	test("category/some", []string{"other-[0-9]*", "old-[0-9]*"}, true)
+	test("category/indeterminate", []string(nil), false)
+}
+
+func (s *Suite) Test_PackageEvaluator_Redistribution(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/free")
+	t.SetUpPackage("category/restricted",
+		"LICENSE=\t${UNFREE_LICENSE}",
+		"UNFREE_LICENSE=\tunfree",
+		"RESTRICTED=\tNo redistribution",
+		"NO_BIN_ON_FTP=\t${RESTRICTED}")
+	t.SetUpPackage("category/option",
+		".if ${PKG_OPTIONS:Munfree}",
+		"RESTRICTED=\tNo redistribution",
+		"NO_BIN_ON_FTP=\t${RESTRICTED}",
+		".endif")
+	t.FinishSetUp()
+
+	test := func(pkgpath RelPath, expected Redistribution) {
+		t.CheckDeepEquals(*NewPackageEvaluator(t.File(pkgpath)).Redistribution(), expected)
+	}
+
+	test("category/free", Redistribution{"2-clause-bsd", false, false})
+	test("category/restricted", Redistribution{"unfree", true, true})
+
+	// Restrictions that only apply to some options are not included.
+	test("category/option", Redistribution{"2-clause-bsd", false, false})
+	test("category/nonexistent", Redistribution{"", false, false})
+}
+
+func (s *Suite) Test_PackageEvaluator_PythonVersions(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.SetUpPackage("category/py-default",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackage("category/py-versions",
+		"PYTHON_VERSIONS_ACCEPTED=\t${PY3_VERSIONS}",
+		"PYTHON_VERSIONS_INCOMPATIBLE=\t310",
+		"PY3_VERSIONS=\t\t312 311 310",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackage("category/py-indeterminate",
+		".if ${OPSYS} == NetBSD",
+		"PYTHON_VERSIONS_INCOMPATIBLE=\t27",
+		".endif",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.CreateFileLines("lang/python/wheel.mk",
+		MkCvsID,
+		"PYTHON_VERSIONS_ACCEPTED?=\t27")
+	t.FinishSetUp()
+
+	test := func(pkgpath RelPath, expected *PythonVersions) {
+		t.CheckDeepEquals(NewPackageEvaluator(t.File(pkgpath)).PythonVersions(), expected)
+	}
+
+	test("category/package", nil)
+
+	// The lang/python/*.mk files are not loaded.
+	test("category/py-default", &PythonVersions{nil, nil})
+	test("category/py-versions",
+		&PythonVersions{[]string{"312", "311", "310"}, []string{"310"}})
+	test("category/py-indeterminate", nil)
+}
+
+func (s *Suite) Test_PackageEvaluator_Depends(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"DEPENDS+=\tdirect>=1.0:../../category/direct",
+		"DEPENDS+=\tvariable>=1.0:${LIB_DIR}",
+		"DEPENDS+=\tunresolved>=1.0:${UNRESOLVED}",
+		"DEPENDS+=\tlocal>=1.0:../local",
+		"DEPENDS+=\tnot-a-dependency",
+		"LIB_DIR=\t../../category/lib/../variable",
+		".if ${OPSYS} == NetBSD",
+		"DEPENDS+=\tconditional>=1.0:../../category/conditional",
+		".endif")
+	t.FinishSetUp()
+
+	ev := NewPackageEvaluator(t.File("category/package"))
+
+	t.CheckDeepEquals(ev.Depends(), []PkgsrcPath{
+		"category/direct",
+		"category/variable",
+		"category/conditional"})
+}
+
+func (s *Suite) Test_PackageEvaluator_Distfiles(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/default")
+	t.SetUpPackage("category/suffix",
+		"EXTRACT_SUFX=\t.tar.xz")
+	t.SetUpPackage("category/files",
+		"DISTFILES=\t${DISTNAME}.tar.gz data-1.0.zip",
+		"DISTFILES+=\t${DISTNAME}.tar.gz",
+		"PATCHFILES=\tfix.diff.gz data-1.0.zip",
+		"IGNORE_DISTFILES=\tfix.diff.gz",
+		"DIST_SUBDIR=\tfiles")
+	t.SetUpPackage("category/none",
+		"DISTFILES=\t# none")
+	t.SetUpPackage("category/indeterminate",
+		".if ${OPSYS} == NetBSD",
+		"PATCHFILES=\tnetbsd.diff",
+		".endif")
+	t.SetUpPackage("category/unresolved",
+		"EXTRACT_SUFX=\t${UNRESOLVED}")
+	t.FinishSetUp()
+
+	test := func(pkgpath RelPath, expected []string, expectedOk bool) {
+		distfiles, ok := NewPackageEvaluator(t.File(pkgpath)).Distfiles()
+		t.CheckDeepEquals(distfiles, expected)
+		t.CheckEquals(ok, expectedOk)
+	}
+
+	test("category/default", []string{"default-1.0.tar.gz"}, true)
+	test("category/suffix", []string{"suffix-1.0.tar.xz"}, true)
+	test("category/files", []string{"files/files-1.0.tar.gz", "files/data-1.0.zip"}, true)
+	test("category/none", nil, true)
+	test("category/indeterminate", nil, false)
+	test("category/unresolved", nil, false)
+}
+
+func (s *Suite) Test_PackageEvaluator_isDefined(c *check.C) {
+	t := s.Init(c)
+
+	ev := NewPackageEvaluator(t.File("nonexistent"))
+	ev.vars["DEFINED"] = ""
+	ev.indeterminate["INDETERMINATE"] = true
+
+	t.CheckEquals(ev.isDefined("DEFINED"), true)
+	t.CheckEquals(ev.isDefined("INDETERMINATE"), true)
+	t.CheckEquals(ev.isDefined("UNDEFINED"), false)
+}
+
+func (s *Suite) Test_PackageEvaluator_load(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("category/package/Makefile",
+		MkCvsID,
+		".include \"../../category/common/Makefile.common\"",
+		".include \"../../category/lib/buildlink3.mk\"",
+		".include \"../../mk/bsd.pkg.mk\"",
+		".include \"${UNKNOWN}/file.mk\"",
+		".include \"Makefile\"")
+	t.CreateFileLines("category/common/Makefile.common",
+		MkCvsID,
+		"DISTNAME=\tcommon-1.0")
+	t.CreateFileLines("category/lib/buildlink3.mk",
+		MkCvsID,
+		"LIB=\tyes")
+	t.FinishSetUp()
+	ev := NewPackageEvaluator(t.File("category/nonexistent"))
+
+	ev.load(t.File("category/package/Makefile"))
+
+	// Neither buildlink3.mk files nor infrastructure files are loaded.
+	t.CheckDeepEquals(ev.vars, map[string]string{"DISTNAME": "common-1.0"})
+}
+
+func (s *Suite) Test_PackageEvaluator_assign(c *check.C) {
+	t := s.Init(c)
+
+	mklines := t.NewMkLines("Makefile",
+		"ASSIGN=\tfirst",
+		"ASSIGN=\tsecond",
+		"APPEND+=\tfirst",
+		"APPEND+=\tsecond",
+		"DEFAULT?=\tfirst",
+		"DEFAULT?=\tsecond",
+		"EVAL:=\t${ASSIGN}",
+		"SHELL!=\techo shell",
+		"COND=\tconditional")
+	ev := NewPackageEvaluator(t.File("nonexistent"))
+
+	for i, mkline := range mklines.mklines {
+		ev.assign(mkline, i == len(mklines.mklines)-1)
+	}
+
+	t.CheckDeepEquals(ev.vars, map[string]string{
+		"ASSIGN":  "second",
+		"APPEND":  "first second",
+		"DEFAULT": "first",
+		"EVAL":    "${ASSIGN}"})
+	t.CheckDeepEquals(ev.indeterminate, map[string]bool{
+		"SHELL": true,
+		"COND":  true})
+}
+
+func (s *Suite) Test_PackageEvaluator_eval(c *check.C) {
+	t := s.Init(c)
+
+	ev := NewPackageEvaluator(t.File("nonexistent"))
+	ev.vars["DISTNAME"] = "Package-${VERSION}"
+	ev.vars["VERSION"] = "1.0"
+	ev.vars["LOOP"] = "${LOOP}"
+	ev.indeterminate["INDETERMINATE"] = true
+
+	test := func(text string, expected string, expectedOK bool) {
+		actual, ok := ev.eval(text, 0)
+		t.CheckEquals(actual, expected)
+		t.CheckEquals(ok, expectedOK)
+	}
+
+	test("${DISTNAME}", "Package-1.0", true)
+	test("${DISTNAME:tl}", "package-1.0", true)
+	test("${DISTNAME:tu}", "PACKAGE-1.0", true)
+	test("${DISTNAME:S,-,-lib-,}", "Package-lib-1.0", true)
+	test("${DISTNAME:C,^(.*)-,\\1,}", "", false)
+	test("${DISTNAME:Mpattern}", "", false)
+	test("${UNDEFINED}", "", false)
+	test("${INDETERMINATE}", "", false)
+	test("${LOOP}", "", false)
+	test("${UNFINISHED", "", false)
+}
@@ -18,7 +18,9 @@ const confVersion = "@VERSION@"
 
 // Pkglint is a container for all global variables of this Go package.
 type Pkglint struct {
-	CheckGlobal bool
+	CheckGlobal,
+	CheckIncluders,
+	CheckUnused bool
 
 	WarnError,
 	WarnExtra,
@@ -28,6 +30,7 @@ type Pkglint struct {
 	Profiling,
 	DumpMakefile,
 	Import,
+	Makesum,
 	Network,
 	Recursive bool
 
@@ -47,6 +50,10 @@ type Pkglint struct {
 
 	Logger Logger
 
+	// FileSystem is used for all file access.
+	// To save the changes in --autofix mode, it must be writable.
+	FileSystem FileSystem
+
 	loaded    *histogram.Histogram
 	res       regex.Registry
 	fileCache *FileCache
@@ -57,17 +64,55 @@ type Pkglint struct {
 	cwd CurrPath
 
 	InterPackage InterPackage
+
+	// depgraphFormat is the format for --dump-depgraph,
+	// or empty if the dependency graph is not dumped.
+	depgraphFormat string
+
+	// whoIncludes is the file for --who-includes,
+	// or empty if the including packages are not listed.
+	whoIncludes CurrPath
+
+	// fragments are the makefile fragments for -Cincluders,
+	// which are checked after all packages have been checked.
+	fragments []*FragmentChecker
+
+	// revbump is the package for --revbump, whose dependent packages
+	// get their PKGREVISION bumped, or empty in the normal mode.
+	revbump CurrPath
+
+	// revbumpAbi is whether --revbump also updates the
+	// BUILDLINK_ABI_DEPENDS of the package, for --revbump-abi.
+	revbumpAbi bool
+
+	// destdir is the staged installation directory for --destdir,
+	// with which the PLIST files are compared, or empty.
+	destdir CurrPath
+
+	// distdir is the directory with the distfiles for --distdir,
+	// against which the patches are applied, or empty.
+	distdir CurrPath
+
+	// wrksrc is the directory with the extracted sources for --wrksrc,
+	// with which the package settings are compared, or empty.
+	wrksrc CurrPath
+
+	// patched is the file system with the changes from --apply-diff.
+	// Before checking it, the unpatched packages are checked to
+	// get the baseline diagnostics.
+	patched FileSystem
 }
 
-func NewPkglint(stdout io.Writer, stderr io.Writer) Pkglint {
+func NewPkglint(stdout io.Writer, stderr io.Writer) *Pkglint {
 	cwd, err := os.Getwd()
 	assertNil(err, "os.Getwd")
 
-	p := Pkglint{
-		res:       regex.NewRegistry(),
-		fileCache: NewFileCache(200),
-		cwd:       NewCurrPathSlash(cwd),
-		interner:  NewStringInterner()}
+	p := &Pkglint{
+		FileSystem: OSFileSystem{},
+		res:        regex.NewRegistry(),
+		fileCache:  NewFileCache(200),
+		cwd:        NewCurrPathSlash(cwd),
+		interner:   NewStringInterner()}
 	p.Logger.out = NewSeparatorWriter(stdout)
 	p.Logger.err = NewSeparatorWriter(stderr)
 	return p
@@ -75,7 +120,7 @@ func NewPkglint(stdout io.Writer, stderr io.Writer) Pkglint {
 
 // unusablePkglint returns a pkglint object that crashes as early as possible.
 // This is to ensure that tests are properly initialized and shut down.
-func unusablePkglint() Pkglint { return Pkglint{} }
+func unusablePkglint() *Pkglint { return &Pkglint{} }
 
 type Hash struct {
 	hash     []byte
@@ -121,14 +166,41 @@ func (p *Pkglint) Main(stdout io.Writer, stderr io.Writer, args []string) (exitC
 		defer p.setUpProfiling()()
 	}
 
+	if p.patched != nil {
+		p.Logger.baseline = p.checkUnpatched(args)
+		p.FileSystem = p.patched
+	}
+
 	p.prepareMainLoop()
 
-	for !p.Todo.IsEmpty() {
-		p.Check(p.Todo.Pop())
+	if !p.revbump.IsEmpty() {
+		// In this mode, the packages from the command line are only
+		// checked to provide their dependencies. The other packages
+		// from the tree are evaluated by the Revbump.
+		checked := p.checkSeparately(args, NewDiagBaseline())
+		NewRevbump(p.Pkgsrc.Rel(p.revbump), p.revbumpAbi, &checked).Run()
+		p.Todo = CurrPathQueue{}
 	}
 
+	p.checkAll()
 	p.Pkgsrc.checkToplevelUnusedLicenses()
 
+	if p.depgraphFormat != "" {
+		var sb strings.Builder
+		err := p.InterPackage.depgraph.Dump(&sb, p.depgraphFormat)
+		assertNil(err, "Dump")
+		p.Logger.out.Separate()
+		p.Logger.out.Write(sb.String())
+	}
+
+	if !p.whoIncludes.IsEmpty() {
+		var sb strings.Builder
+		err := p.InterPackage.includes.Write(&sb, p.Pkgsrc.Rel(p.whoIncludes))
+		assertNil(err, "Write")
+		p.Logger.out.Separate()
+		p.Logger.out.Write(sb.String())
+	}
+
 	p.Logger.ShowSummary(args)
 	if p.WarnError && p.Logger.warnings != 0 {
 		return 1
@@ -184,15 +256,79 @@ func (p *Pkglint) prepareMainLoop() {
 	}
 }
 
+// checkAll checks the queued directories and files,
+// followed by the makefile fragments from -Cincluders
+// and the checks that span multiple packages.
+func (p *Pkglint) checkAll() {
+	for !p.Todo.IsEmpty() {
+		p.Check(p.Todo.Pop())
+	}
+	for _, fragment := range p.fragments {
+		fragment.Check()
+	}
+
+	p.InterPackage.CheckDependencyCycles()
+	p.InterPackage.CheckConflicts()
+	p.InterPackage.CheckRedistribution()
+	p.InterPackage.CheckPythonVersions()
+}
+
+// checkUnpatched checks the directories from the command line in the
+// unpatched tree and returns the recorded diagnostics, so that checking
+// the patched tree only reports the new diagnostics.
+func (p *Pkglint) checkUnpatched(args []string) *DiagBaseline {
+	baseline := NewDiagBaseline()
+	p.checkSeparately(args, baseline)
+	baseline.Finish()
+	return baseline
+}
+
+// checkSeparately checks the directories from the command line,
+// recording the diagnostics in the baseline instead of logging them,
+// and returns the data collected about the checked packages.
+//
+// To keep the passes independent, the directories are checked by
+// a fresh pkglint instance, which serves as G during the check.
+// The fresh instance doesn't fix anything.
+func (p *Pkglint) checkSeparately(args []string, baseline *DiagBaseline) InterPackage {
+	fresh := NewPkglint(p.Logger.out.out, p.Logger.err.out)
+	fresh.Testing = p.Testing
+
+	G = fresh
+	defer func() { G = p }()
+
+	exitcode := fresh.ParseCommandLine(args)
+	assert(exitcode == -1)
+	fresh.Logger.Opts.Autofix = false
+	fresh.Logger.Opts.ShowAutofix = false
+	fresh.Logger.baseline = baseline
+
+	fresh.prepareMainLoop()
+	fresh.checkAll()
+	return fresh.InterPackage
+}
+
 func (p *Pkglint) ParseCommandLine(args []string) int {
 	lopts := &p.Logger.Opts
 	opts := getopt.NewOptions()
 
 	var showHelp bool
 	var showVersion bool
+	var pkgsrcdir string
+	var applyDiff string
+	var dumpDepgraph string
+	var whoIncludes string
+	var revbump string
+	var destdir string
+	var distdir string
+	var wrksrc string
 
 	check := opts.AddFlagGroup('C', "check", "check,...", "enable or disable specific checks")
+	opts.AddStrVar(0, "apply-diff", &applyDiff, "", "check only the changes from the given unified diff")
 	opts.AddFlagVar('d', "debug", &trace.Tracing, false, "log verbose call traces for debugging")
+	opts.AddStrVar(0, "destdir", &destdir, "", "compare the PLIST files with the files installed in the given directory")
+	opts.AddStrVar(0, "distdir", &distdir, "", "check the patches against the distfiles in the given directory")
+	opts.AddStrVar(0, "dump-depgraph", &dumpDepgraph, "", "dump the dependency graph as dot or json")
 	opts.AddFlagVar('e', "explain", &lopts.Explain, false, "explain the diagnostics or give further help")
 	opts.AddFlagVar('f', "show-autofix", &lopts.ShowAutofix, false, "show what pkglint can fix automatically")
 	opts.AddFlagVar('F', "autofix", &lopts.Autofix, false, "try to automatically fix some errors")
@@ -200,16 +336,24 @@ func (p *Pkglint) ParseCommandLine(args []string) int {
 	opts.AddFlagVar('h', "help", &showHelp, false, "show a detailed usage message")
 	opts.AddFlagVar('I', "dumpmakefile", &p.DumpMakefile, false, "dump the Makefile after parsing")
 	opts.AddFlagVar('i', "import", &p.Import, false, "prepare the import of a wip package")
+	opts.AddFlagVar(0, "makesum", &p.Makesum, false, "regenerate the distinfo files from the distfiles in --distdir")
 	opts.AddFlagVar('n', "network", &p.Network, false, "enable checks that need network access")
 	opts.AddStrList('o', "only", &lopts.Only, "only log diagnostics containing the given text")
 	opts.AddFlagVar('p', "profiling", &p.Profiling, false, "profile the executing program")
+	opts.AddStrVar(0, "pkgsrcdir", &pkgsrcdir, ".", "pkgsrc root for archives, git trees and diffs")
 	opts.AddFlagVar('q', "quiet", &lopts.Quiet, false, "don't show a summary line when finishing")
 	opts.AddFlagVar('r', "recursive", &p.Recursive, false, "check subdirectories, too")
+	opts.AddStrVar(0, "revbump", &revbump, "", "bump PKGREVISION of the packages that depend on the given package")
+	opts.AddFlagVar(0, "revbump-abi", &p.revbumpAbi, false, "update the BUILDLINK_ABI_DEPENDS for --revbump")
 	opts.AddFlagVar('s', "source", &lopts.ShowSource, false, "show the source lines together with diagnostics")
 	opts.AddFlagVar('V', "version", &showVersion, false, "show the version number of pkglint")
+	opts.AddStrVar(0, "who-includes", &whoIncludes, "", "list the packages that include the given file")
+	opts.AddStrVar(0, "wrksrc", &wrksrc, "", "check the package settings against the extracted sources in the given directory")
 	warn := opts.AddFlagGroup('W', "warning", "warning,...", "enable or disable groups of warnings")
 
 	check.AddFlagVar("global", &p.CheckGlobal, false, "inter-package checks")
+	check.AddFlagVarNoAll("includers", &p.CheckIncluders, false, "check makefile fragments once per including package")
+	check.AddFlagVarNoAll("unused", &p.CheckUnused, false, "report unused files in the FILESDIR")
 
 	warn.AddFlagVarNoAll("error", &p.WarnError, false, "treat warnings as errors")
 	warn.AddFlagVar("extra", &p.WarnExtra, false, "enable some extra warnings")
@@ -235,12 +379,92 @@ func (p *Pkglint) ParseCommandLine(args []string) int {
 		return 0
 	}
 
+	var archives *MemFileSystem
 	for _, arg := range remainingArgs {
-		p.Todo.Push(NewCurrPathSlash(arg))
+		if !isArchiveArg(arg) {
+			p.Todo.Push(NewCurrPathSlash(arg))
+			continue
+		}
+
+		if archives == nil {
+			archives = NewMemFileSystem()
+		}
+		dir, err := loadArchive(arg, NewCurrPathSlash(pkgsrcdir), archives)
+		if err != nil {
+			p.Logger.TechFatalf(NewCurrPathSlash(arg), "Cannot load archive: %s", err)
+		}
+		p.Todo.Push(dir)
+	}
+	if archives != nil {
+		overlay := NewOverlayFileSystem(archives, p.FileSystem)
+		replaceArchivePackages(overlay, NewCurrPathSlash(pkgsrcdir))
+		p.FileSystem = overlay
+	}
+	if p.CheckIncluders && (lopts.Autofix || lopts.ShowAutofix) {
+		p.Logger.TechFatalf("", "The -Cincluders option cannot be combined with --autofix or --show-autofix.")
+	}
+	if applyDiff != "" {
+		if lopts.Autofix || lopts.ShowAutofix {
+			p.Logger.TechFatalf("", "The --apply-diff option cannot be combined with --autofix or --show-autofix.")
+		}
+		patched, pkgdirs, err := loadDiff(NewCurrPathSlash(applyDiff), NewCurrPathSlash(pkgsrcdir))
+		if err != nil {
+			p.Logger.TechFatalf(NewCurrPathSlash(applyDiff), "Cannot apply diff: %s", err)
+		}
+		if len(pkgdirs) == 0 {
+			p.Logger.TechFatalf(NewCurrPathSlash(applyDiff), "The diff doesn't affect any package.")
+		}
+		p.Todo.Push(pkgdirs...)
+		p.patched = patched
+	}
+	if dumpDepgraph != "" && dumpDepgraph != "dot" && dumpDepgraph != "json" {
+		p.Logger.TechFatalf("", "Invalid format %q for --dump-depgraph, valid formats are dot and json.", dumpDepgraph)
+	}
+	p.depgraphFormat = dumpDepgraph
+	if p.CheckGlobal || dumpDepgraph != "" || revbump != "" {
+		p.InterPackage.EnableDependencies()
+	}
+	p.whoIncludes = NewCurrPathSlash(whoIncludes)
+	if p.CheckGlobal || whoIncludes != "" || p.CheckIncluders || revbump != "" {
+		p.InterPackage.EnableIncludes()
+	}
+	if p.CheckGlobal {
+		p.InterPackage.EnableConflicts()
+		p.InterPackage.EnableRedistribution()
+		p.InterPackage.EnablePythonVersions()
+	}
+	p.revbump = NewCurrPathSlash(revbump)
+	p.destdir = NewCurrPathSlash(destdir)
+	if destdir != "" && !p.destdir.IsDir() {
+		p.Logger.TechFatalf(p.destdir, "Not a directory.")
+	}
+	p.distdir = NewCurrPathSlash(distdir)
+	if distdir != "" && !p.distdir.IsDir() {
+		p.Logger.TechFatalf(p.distdir, "Not a directory.")
+	}
+	if p.Makesum && distdir == "" {
+		p.Logger.TechFatalf("", "The --makesum option requires --distdir.")
+	}
+	p.wrksrc = NewCurrPathSlash(wrksrc)
+	if wrksrc != "" && !p.wrksrc.IsDir() {
+		p.Logger.TechFatalf(p.wrksrc, "Not a directory.")
 	}
 	if p.Todo.IsEmpty() {
 		p.Todo.Push(".")
 	}
+	if len(p.Todo.entries) > 1 || p.Recursive {
+		// These directories belong to a single package.
+		// For --makesum, the --distdir is the shared DISTDIR instead.
+		if destdir != "" {
+			p.Logger.TechFatalf("", "The --destdir option only works for a single package.")
+		}
+		if distdir != "" && !p.Makesum {
+			p.Logger.TechFatalf("", "The --distdir option only works for a single package.")
+		}
+		if wrksrc != "" {
+			p.Logger.TechFatalf("", "The --wrksrc option only works for a single package.")
+		}
+	}
 
 	return -1
 }
@@ -561,7 +785,7 @@ func (p *Pkglint) checkReg(tf TypedFile, basename RelPath, depth int, pkg *Packa
 		}
 
 	case basename == "DEINSTALL" || basename == "INSTALL":
-		CheckFileOther(filename)
+		CheckFileShellScript(filename, pkg)
 
 	case basename.HasPrefixText("MESSAGE"):
 		CheckFileMessage(filename)
@@ -590,7 +814,11 @@ func (p *Pkglint) checkReg(tf TypedFile, basename RelPath, depth int, pkg *Packa
 
 	case tf.kind == MkFile &&
 		!G.Pkgsrc.Rel(filename).AsPath().ContainsPath("files"):
-		CheckFileMk(filename, pkg)
+		if p.CheckIncluders {
+			p.fragments = append(p.fragments, NewFragmentChecker(filename, pkg))
+		} else {
+			CheckFileMk(filename, pkg)
+		}
 
 	case basename.HasPrefixText("PLIST"):
 		if lines := Load(filename, NotEmpty|LogErrors); lines != nil {
@@ -606,6 +834,13 @@ func (p *Pkglint) checkReg(tf TypedFile, basename RelPath, depth int, pkg *Packa
 
 	case filename.Dir().HasBase("files"):
 		// Skip files directly in the files/ directory, but not those further down.
+		// Only the shell scripts are checked.
+		// Whether the files are used is checked in Package.checkFilesUnused.
+		if isRcdScript(filename, pkg) {
+			CheckFileShellScript(filename, pkg)
+		} else {
+			CheckFilePortability(filename)
+		}
 
 	case basename == "spec":
 		if !p.Pkgsrc.Rel(filename).HasPrefixPath("regress") {
@@ -787,6 +1022,11 @@ type InterPackage struct {
 	usedLicenses map[string]struct{}
 	bl3Names     map[string]Location
 	descr        map[[sha1.Size]byte][]CurrPath
+	depgraph     *DependencyGraph
+	conflicts    *ConflictChecker
+	redist       *RedistributionChecker
+	python       *PythonVersionChecker
+	includes     *IncludeIndex
 }
 
 func (ip *InterPackage) Enable() {
@@ -794,7 +1034,12 @@ func (ip *InterPackage) Enable() {
 		make(map[string]*Hash),
 		make(map[string]struct{}),
 		make(map[string]Location),
-		make(map[[sha1.Size]byte][]CurrPath)}
+		make(map[[sha1.Size]byte][]CurrPath),
+		ip.depgraph,
+		ip.conflicts,
+		ip.redist,
+		ip.python,
+		ip.includes}
 
 	// This is the only license that is added by an infrastructure file,
 	// mk/djbware.mk. The correct way to handle this situation would be
@@ -805,6 +1050,47 @@ func (ip *InterPackage) Enable() {
 
 func (ip *InterPackage) Enabled() bool { return ip.hashes != nil }
 
+// EnableDependencies enables collecting the dependency graph,
+// independently of the other inter-package checks.
+func (ip *InterPackage) EnableDependencies() {
+	if ip.depgraph == nil {
+		ip.depgraph = NewDependencyGraph()
+	}
+}
+
+// EnableConflicts enables collecting the CONFLICTS and the PLIST files
+// of the checked packages, which is only done in -Cglobal mode.
+func (ip *InterPackage) EnableConflicts() {
+	if ip.conflicts == nil {
+		ip.conflicts = NewConflictChecker()
+	}
+}
+
+// EnableRedistribution enables collecting the licenses and the
+// redistribution restrictions of the checked packages,
+// which is only done in -Cglobal mode.
+func (ip *InterPackage) EnableRedistribution() {
+	if ip.redist == nil {
+		ip.redist = NewRedistributionChecker()
+	}
+}
+
+// EnablePythonVersions enables collecting the Python versions that the
+// checked packages accept, which is only done in -Cglobal mode.
+func (ip *InterPackage) EnablePythonVersions() {
+	if ip.python == nil {
+		ip.python = NewPythonVersionChecker()
+	}
+}
+
+// EnableIncludes enables collecting the includes of the checked
+// packages, for finding the packages that include a certain file.
+func (ip *InterPackage) EnableIncludes() {
+	if ip.includes == nil {
+		ip.includes = NewIncludeIndex()
+	}
+}
+
 func (ip *InterPackage) Hash(alg string, filename RelPath, hashBytes []byte, loc *Location) *Hash {
 	key := alg + ":" + filename.String()
 	if otherHash := ip.hashes[key]; otherHash != nil {
@@ -842,16 +1128,139 @@ func (ip *InterPackage) Bl3(name string, loc *Location) *Location {
 	return nil
 }
 
+// AddPackage adds the package to the dependency graph, even if the
+// package doesn't have any dependencies.
+func (ip *InterPackage) AddPackage(pkgpath PkgsrcPath) {
+	if ip.depgraph != nil {
+		ip.depgraph.AddPackage(pkgpath)
+	}
+}
+
+// Depend remembers that the package depends on another package.
+func (ip *InterPackage) Depend(pkgpath PkgsrcPath, dep *Dependency) {
+	if ip.depgraph != nil {
+		ip.depgraph.Add(pkgpath, dep)
+	}
+}
+
+// CheckDependencyCycles reports each group of packages that depend
+// on each other, at the dependency of the alphabetically first package
+// of the group.
+func (ip *InterPackage) CheckDependencyCycles() {
+	if ip.depgraph == nil {
+		return
+	}
+
+	for _, cycle := range ip.depgraph.Cycles() {
+		start := cycle[len(cycle)-1].Target
+		path := []string{start.String()}
+		for _, dep := range cycle {
+			path = append(path, dep.Target.String())
+		}
+		line := cycle[0].Line
+		line.Errorf("Dependency cycle: %s.", strings.Join(path, " -> "))
+		line.Explain(
+			"The packages in a dependency cycle cannot be built,",
+			"since each of them needs another of them to be installed first.",
+			"",
+			"Dependencies from TEST_DEPENDS are not considered here,",
+			"since they are only needed for running the tests.")
+	}
+}
+
+// AddConflicts remembers the CONFLICTS and the installed files of the
+// package, to check them against the other packages later.
+func (ip *InterPackage) AddConflicts(pkgpath PkgsrcPath, pkgname string,
+	conflicts []*Conflict, plist map[RelPath]*PlistLine) {
+
+	if ip.conflicts != nil {
+		ip.conflicts.AddPackage(pkgpath, pkgname, conflicts, plist)
+	}
+}
+
+// CheckConflicts checks the CONFLICTS and the installed files of all
+// checked packages against each other.
+func (ip *InterPackage) CheckConflicts() {
+	if ip.conflicts != nil {
+		ip.conflicts.Check()
+	}
+}
+
+// AddRedistribution remembers the license and the redistribution
+// restrictions of the package.
+func (ip *InterPackage) AddRedistribution(pkgpath PkgsrcPath, redist *Redistribution) {
+	if ip.redist != nil {
+		ip.redist.AddPackage(pkgpath, redist)
+	}
+}
+
+// CheckRedistribution propagates the licenses and the redistribution
+// restrictions along the dependencies of the checked packages.
+func (ip *InterPackage) CheckRedistribution() {
+	if ip.redist != nil && ip.depgraph != nil {
+		ip.redist.Check(ip.depgraph)
+	}
+}
+
+// AddPythonVersions remembers the Python versions that the package
+// accepts, or nil if it is not a Python package.
+func (ip *InterPackage) AddPythonVersions(pkgpath PkgsrcPath, versions *PythonVersions) {
+	if ip.python != nil {
+		ip.python.AddPackage(pkgpath, versions)
+	}
+}
+
+// CheckPythonVersions checks that the checked Python packages don't
+// accept more Python versions than their dependencies.
+func (ip *InterPackage) CheckPythonVersions() {
+	if ip.python != nil && ip.depgraph != nil {
+		ip.python.Check(ip.depgraph)
+	}
+}
+
+// AddIncludes remembers the includes from the package Makefile and
+// the files that have been loaded for it.
+func (ip *InterPackage) AddIncludes(pkgpath PkgsrcPath, allLines *MkLines, pkg *Package) {
+	if ip.includes != nil {
+		ip.includes.AddPackage(pkgpath, allLines, pkg)
+	}
+}
+
+// Includers returns the checked packages that include the given file,
+// directly or indirectly, sorted by package path.
+func (ip *InterPackage) Includers(file PkgsrcPath) []PkgsrcPath {
+	if ip.includes == nil {
+		return nil
+	}
+
+	var includers []PkgsrcPath
+	for _, chain := range ip.includes.Includers(file) {
+		includers = append(includers, chain[0].Dir())
+	}
+	return includers
+}
+
+// IncludersInTree returns the packages from the whole tree that include
+// the given file, directly or indirectly, sorted by package path.
+func (ip *InterPackage) IncludersInTree(file PkgsrcPath) []PkgsrcPath {
+	if ip.includes == nil {
+		return nil
+	}
+
+	ip.includes.AddTree()
+	return ip.Includers(file)
+}
+
 func (ip *InterPackage) CheckDuplicateDescr(filename CurrPath) {
 	descr := ip.descr
 	if descr == nil {
 		return
 	}
-	b, err := os.ReadFile(filename.String())
+	b, err := filename.ReadString()
 	if err != nil {
 		return
 	}
-	h := sha1.Sum(b)
+	h := sha1.Sum([]byte(b))
 	existing := descr[h]
 	descr[h] = append(existing, filename)
 	var duplicate CurrPath
@@ -49,7 +49,11 @@ func (s *Suite) Test_Pkglint_Main__help(c *check.C) {
 		"usage: pkglint [options] dir...",
 		"",
 		"  -C, --check=check,...       enable or disable specific checks",
+		"  --apply-diff                check only the changes from the given unified diff",
 		"  -d, --debug                 log verbose call traces for debugging",
+		"  --destdir                   compare the PLIST files with the files installed in the given directory",
+		"  --distdir                   check the patches against the distfiles in the given directory",
+		"  --dump-depgraph             dump the dependency graph as dot or json",
 		"  -e, --explain               explain the diagnostics or give further help",
 		"  -f, --show-autofix          show what pkglint can fix automatically",
 		"  -F, --autofix               try to automatically fix some errors",
@@ -57,19 +61,27 @@ func (s *Suite) Test_Pkglint_Main__help(c *check.C) {
 		"  -h, --help                  show a detailed usage message",
 		"  -I, --dumpmakefile          dump the Makefile after parsing",
 		"  -i, --import                prepare the import of a wip package",
+		"  --makesum                   regenerate the distinfo files from the distfiles in --distdir",
 		"  -n, --network               enable checks that need network access",
 		"  -o, --only                  only log diagnostics containing the given text",
 		"  -p, --profiling             profile the executing program",
+		"  --pkgsrcdir                 pkgsrc root for archives, git trees and diffs",
 		"  -q, --quiet                 don't show a summary line when finishing",
 		"  -r, --recursive             check subdirectories, too",
+		"  --revbump                   bump PKGREVISION of the packages that depend on the given package",
+		"  --revbump-abi               update the BUILDLINK_ABI_DEPENDS for --revbump",
 		"  -s, --source                show the source lines together with diagnostics",
 		"  -V, --version               show the version number of pkglint",
+		"  --who-includes              list the packages that include the given file",
+		"  --wrksrc                    check the package settings against the extracted sources in the given directory",
 		"  -W, --warning=warning,...   enable or disable groups of warnings",
 		"",
 		"  Flags for -C, --check:",
-		"    all      all of the following",
-		"    none     none of the following",
-		"    global   inter-package checks (disabled)",
+		"    all         all of the following",
+		"    none        none of the following",
+		"    global      inter-package checks (disabled)",
+		"    includers   check makefile fragments once per including package (disabled)",
+		"    unused      report unused files in the FILESDIR (disabled)",
 		"",
 		"  Flags for -W, --warning:",
 		"    all       all of the following",
@@ -372,6 +384,169 @@ func (s *Suite) Test_Pkglint_Main__Werror_error(c *check.C) {
 }
 
 // Branch coverage for Logger.Logf, the level != Fatal case.
+func (s *Suite) Test_Pkglint_Main__apply_diff(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"COMMENT= Comment")
+	t.CreateFileLines("proposed.diff",
+		"Please review the following update.",
+		"",
+		"Index: category/package/Makefile",
+		"--- category/package/Makefile.orig",
+		"+++ category/package/Makefile",
+		"@@ -8,3 +8,4 @@",
+		" MAINTAINER=\tpkgsrc-users@NetBSD.org",
+		" HOMEPAGE=\t# none",
+		" COMMENT= Comment",
+		"+LICENSE+=\tgnu-gpl-v2",
+		"")
+	t.Chdir(".")
+
+	t.Main("-Wall", "--apply-diff", "proposed.diff")
+
+	// The note about the misaligned COMMENT is not reported since
+	// it is already present in the unpatched package.
+	t.CheckOutputLines(
+		"WARN: category/package/Makefile:11: Variable LICENSE is overwritten in line 12.",
+		"ERROR: category/package/Makefile:11: Parse error for appended license condition \"gnu-gpl-v2\".",
+		"1 error and 1 warning found.",
+		"(Run \"pkglint -e -Wall --apply-diff proposed.diff\" to show explanations.)")
+
+	// The diff is only applied in memory.
+	data, err := OSFileSystem{}.ReadFile(t.File("category/package/Makefile").String())
+	t.CheckEquals(err, nil)
+	t.CheckEquals(contains(string(data), "LICENSE+="), false)
+}
+
+func (s *Suite) Test_Pkglint_Main__apply_diff_errors(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("infra.diff",
+		"--- mk/bsd.pkg.mk",
+		"+++ mk/bsd.pkg.mk",
+		"@@ -1 +1,2 @@",
+		" "+MkCvsID,
+		"+# comment")
+	t.Chdir(".")
+
+	t.Main("--apply-diff", "infra.diff", "--autofix")
+	t.Main("--apply-diff", "infra.diff")
+	t.Main("--apply-diff", "missing.diff")
+
+	t.CheckOutputLines(
+		"FATAL: The --apply-diff option cannot be combined with --autofix or --show-autofix.",
+		"FATAL: infra.diff: The diff doesn't affect any package.",
+		"FATAL: missing.diff: Cannot apply diff: open missing.diff: no such file or directory")
+}
+
+func (s *Suite) Test_Pkglint_Main__includers_autofix(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.Chdir(".")
+
+	t.Main("-Cincluders", "--show-autofix", "category/package/Makefile")
+
+	t.CheckOutputLines(
+		"FATAL: The -Cincluders option cannot be combined with --autofix or --show-autofix.")
+}
+
+func (s *Suite) Test_Pkglint_Main__dependency_cycle(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/a",
+		"DEPENDS+=\tb-[0-9]*:../../devel/b")
+	t.SetUpPackage("devel/b",
+		"BUILD_DEPENDS+=\tc-[0-9]*:../../devel/c")
+	t.SetUpPackage("devel/c",
+		"TOOL_DEPENDS+=\ta-[0-9]*:../../devel/a")
+	t.Chdir(".")
+
+	t.Main("-Cglobal", "-q", "devel/a", "devel/b", "devel/c")
+
+	t.CheckOutputLines(
+		"ERROR: devel/a/Makefile:20: Dependency cycle: "+
+			"devel/a -> devel/b -> devel/c -> devel/a.",
+		"WARN: devel/b/PLIST:2: The file \"bin/program\" is also installed "+
+			"by ../../devel/a, without a declared conflict.",
+		"WARN: devel/c/PLIST:2: The file \"bin/program\" is also installed "+
+			"by ../../devel/a, without a declared conflict.")
+}
+
+func (s *Suite) Test_Pkglint_Main__dump_depgraph(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/a",
+		"DEPENDS+=\tb-[0-9]*:../../devel/b")
+	t.SetUpPackage("devel/b")
+	t.Chdir(".")
+
+	t.Main("--dump-depgraph=dot", "-q", "devel/a", "devel/b")
+
+	t.CheckOutputLines(
+		"digraph dependencies {",
+		"\t\"devel/a\";",
+		"\t\"devel/a\" -> \"devel/b\" [label=\"DEPENDS\"];",
+		"\t\"devel/b\";",
+		"}")
+}
+
+func (s *Suite) Test_Pkglint_Main__dump_depgraph_json(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/a",
+		"DEPENDS+=\tb-[0-9]*:../../devel/b")
+	t.SetUpPackage("devel/b")
+	t.Chdir(".")
+
+	t.Main("--dump-depgraph=json", "-q", "devel/a")
+	t.Main("--dump-depgraph=svg", "devel/a")
+
+	t.CheckOutputLines(
+		"{",
+		"\t\"packages\": {",
+		"\t\t\"devel/a\": [",
+		"\t\t\t{",
+		"\t\t\t\t\"kind\": \"DEPENDS\",",
+		"\t\t\t\t\"pattern\": \"b-[0-9]*\",",
+		"\t\t\t\t\"target\": \"devel/b\",",
+		"\t\t\t\t\"location\": \"devel/a/Makefile:20\"",
+		"\t\t\t}",
+		"\t\t]",
+		"\t}",
+		"}",
+		"FATAL: Invalid format \"svg\" for --dump-depgraph, valid formats are dot and json.")
+}
+
+func (s *Suite) Test_Pkglint_Main__who_includes(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/lib")
+	t.CreateFileBuildlink3("devel/lib/buildlink3.mk")
+	t.SetUpPackage("devel/app",
+		".include \"../../devel/lib/buildlink3.mk\"")
+	t.SetUpPackage("devel/other")
+	t.CreateFileLines("mk/compiler.mk",
+		MkCvsID)
+	t.CreateFileLines("mk/bsd.pkg.mk",
+		MkCvsID,
+		".include \"../../mk/compiler.mk\"")
+	t.Chdir(".")
+
+	t.Main("--who-includes=devel/lib/buildlink3.mk", "-q", "devel/app", "devel/lib", "devel/other")
+	t.Main("--who-includes=mk/compiler.mk", "-q", "devel/app", "devel/lib", "devel/other")
+
+	// The infrastructure files are not loaded by the packages,
+	// they are scanned for the includes separately.
+	t.CheckOutputLines(
+		"devel/app: devel/app/Makefile -> devel/lib/buildlink3.mk",
+		"devel/app: devel/app/Makefile -> mk/bsd.pkg.mk -> mk/compiler.mk",
+		"devel/lib: devel/lib/Makefile -> mk/bsd.pkg.mk -> mk/compiler.mk",
+		"devel/other: devel/other/Makefile -> mk/bsd.pkg.mk -> mk/compiler.mk")
+}
+
 func (s *Suite) Test_Pkglint_prepareMainLoop__fatal(c *check.C) {
 	t := s.Init(c)
 
@@ -383,6 +558,88 @@ func (s *Suite) Test_Pkglint_prepareMainLoop__fatal(c *check.C) {
 		"FATAL: does-not-exist: Must be inside a pkgsrc tree.")
 }
 
+func (s *Suite) Test_Pkglint_checkAll(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/a",
+		"DEPENDS+=\tb-[0-9]*:../../devel/b")
+	t.SetUpPackage("devel/b",
+		"DEPENDS+=\ta-[0-9]*:../../devel/a")
+	t.FinishSetUp()
+	t.Chdir(".")
+	t.CheckEquals(G.ParseCommandLine([]string{"pkglint", "-Cglobal", "devel/a", "devel/b"}), -1)
+	G.prepareMainLoop()
+
+	G.checkAll()
+
+	// The inter-package checks run after all packages have been checked.
+	t.CheckOutputLines(
+		"ERROR: devel/a/Makefile:20: Dependency cycle: devel/a -> devel/b -> devel/a.",
+		"WARN: devel/b/PLIST:2: The file \"bin/program\" is also installed "+
+			"by ../../devel/a, without a declared conflict.")
+	t.CheckEquals(G.Todo.IsEmpty(), true)
+}
+
+func (s *Suite) Test_Pkglint_checkUnpatched(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"COMMENT= Comment")
+	t.CreateFileLines("proposed.diff",
+		"--- category/package/DESCR",
+		"+++ category/package/DESCR",
+		"@@ -1 +1 @@",
+		"-Package description",
+		"+Improved description")
+	t.FinishSetUp()
+	t.Chdir(".")
+	args := []string{"pkglint", "-Wall", "--apply-diff", "proposed.diff"}
+	t.CheckEquals(G.ParseCommandLine(args), -1)
+	fileCache := G.fileCache
+
+	baseline := G.checkUnpatched(args)
+
+	// Nothing is logged for the unpatched tree.
+	t.CheckOutputEmpty()
+	t.CheckEquals(baseline.recording, false)
+	t.CheckEquals(len(baseline.counts), 1)
+
+	// The state of the main pass is not affected by the baseline pass.
+	t.CheckEquals(G.fileCache, fileCache)
+	t.CheckEquals(G.Logger.baseline, (*DiagBaseline)(nil))
+	t.CheckDeepEquals(G.Todo.entries, []CurrPath{"./category/package"})
+}
+
+func (s *Suite) Test_Pkglint_checkSeparately(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/lib")
+	t.SetUpPackage("category/package",
+		"COMMENT= Comment",
+		"DEPENDS+=\tlib>=1.0:../../category/lib")
+	t.FinishSetUp()
+	t.Chdir(".")
+	args := []string{"pkglint", "-Wall", "--autofix", "--dump-depgraph=dot", "category/package"}
+	t.CheckEquals(G.ParseCommandLine(args), -1)
+	current := G
+
+	checked := G.checkSeparately(args, NewDiagBaseline())
+
+	// Nothing is logged, and nothing is fixed.
+	t.CheckOutputEmpty()
+	text, err := t.File("category/package/Makefile").ReadString()
+	assertNil(err, "ReadString")
+	t.CheckEquals(strings.Contains(text, "COMMENT= Comment\n"), true)
+
+	t.CheckDeepEquals(checked.depgraph.Dependents("category/lib", "DEPENDS"),
+		[]PkgsrcPath{"category/package"})
+
+	// The state of the current pass is not affected.
+	t.CheckEquals(G, current)
+	t.CheckEquals(G.Logger.Opts.Autofix, true)
+	t.CheckLen(G.InterPackage.depgraph.Packages(), 0)
+}
+
 func (s *Suite) Test_Pkglint_ParseCommandLine__only(c *check.C) {
 	t := s.Init(c)
 
@@ -396,6 +653,180 @@ func (s *Suite) Test_Pkglint_ParseCommandLine__only(c *check.C) {
 		confVersion)
 }
 
+func (s *Suite) Test_Pkglint_ParseCommandLine__archive(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"COMMENT= Comment")
+	archive := packPackage(t, "category/package")
+	t.CreateFileLines("upload/package.tar")
+	t.AssertNil(t.File("upload/package.tar").WriteString(string(archive)))
+
+	t.Main("-Wall", "--pkgsrcdir", t.File(".").String(),
+		t.File("upload/package.tar").String())
+
+	// The files from the archive are checked as if they were
+	// extracted into the pkgsrc root directory.
+	t.CheckOutputLines(
+		"NOTE: ~/category/package/Makefile:10: "+
+			"This variable value should be aligned with tabs, not spaces, to column 17 instead of 10.",
+		"Looks fine.",
+		"(Run \"pkglint -e -Wall --pkgsrcdir ~ ~/upload/package.tar\" to show explanations.)",
+		"(Run \"pkglint -fs -Wall --pkgsrcdir ~ ~/upload/package.tar\" to show what can be fixed automatically.)",
+		"(Run \"pkglint -F -Wall --pkgsrcdir ~ ~/upload/package.tar\" to automatically fix some issues.)")
+	// The package exists only in memory.
+	t.CheckEquals(t.File("category/package").Exists(), true)
+	_, err := OSFileSystem{}.Stat(t.File("category/package").String())
+	t.CheckEquals(os.IsNotExist(err), true)
+}
+
+// The package from the archive replaces the package from the tree,
+// therefore the patch that has been removed from the package is not
+// checked anymore.
+func (s *Suite) Test_Pkglint_ParseCommandLine__archive_replaces_package(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	archive := packPackage(t, "category/package")
+	t.CreateFileDummyPatch("category/package/patches/patch-aa")
+	t.CreateFileLines("upload/package.tar")
+	t.AssertNil(t.File("upload/package.tar").WriteString(string(archive)))
+
+	t.Main("-Wall", "-q", "--pkgsrcdir", t.File(".").String(),
+		t.File("upload/package.tar").String())
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__archive_error(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+
+	t.Main("--pkgsrcdir", t.File(".").String(), t.File("nonexistent.tar.gz").String())
+
+	t.CheckOutputLines(
+		"FATAL: ~/nonexistent.tar.gz: Cannot load archive: " +
+			"open ~/nonexistent.tar.gz: no such file or directory")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__destdir_nonexistent(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.Chdir(".")
+
+	t.Main("--destdir=nonexistent", "category/package")
+
+	t.CheckOutputLines(
+		"FATAL: nonexistent: Not a directory.")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__destdir_several_packages(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.SetUpPackage("category/other")
+	t.CreateFileTree("destdir")
+	t.Chdir(".")
+
+	t.Main("--destdir=destdir", "category/package", "category/other")
+	t.Main("--destdir=destdir", "-r", "category")
+
+	t.CheckOutputLines(
+		"FATAL: The --destdir option only works for a single package.",
+		"FATAL: The --destdir option only works for a single package.")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__distdir_nonexistent(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.Chdir(".")
+
+	t.Main("--distdir=nonexistent", "category/package")
+
+	t.CheckOutputLines(
+		"FATAL: nonexistent: Not a directory.")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__distdir_several_packages(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.SetUpPackage("category/other")
+	t.CreateFileTree("distfiles")
+	t.Chdir(".")
+
+	t.Main("--distdir=distfiles", "category/package", "category/other")
+
+	t.CheckOutputLines(
+		"FATAL: The --distdir option only works for a single package.")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__distdir_makesum_several_packages(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.SetUpPackage("category/other")
+	t.CreateFileTree("distfiles")
+	t.Chdir(".")
+
+	// For --makesum, the distfiles directory is the DISTDIR,
+	// which is shared by the packages.
+	t.Main("--distdir=distfiles", "--makesum", "-q", "category/package", "category/other")
+
+	t.CheckOutputLines(
+		"ERROR: category/package/distinfo: "+
+			"The distfile \"package-1.0.tar.gz\" is not in \"distfiles\".",
+		"NOTE: category/package/Makefile: "+
+			"The distfile \"package-1.0.tar.gz\" is not in \"distfiles\", "+
+			"therefore the patches are not checked.",
+		"ERROR: category/other/distinfo: "+
+			"The distfile \"other-1.0.tar.gz\" is not in \"distfiles\".",
+		"NOTE: category/other/Makefile: "+
+			"The distfile \"other-1.0.tar.gz\" is not in \"distfiles\", "+
+			"therefore the patches are not checked.")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__makesum_without_distdir(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.Chdir(".")
+
+	t.Main("--makesum", "category/package")
+
+	t.CheckOutputLines(
+		"FATAL: The --makesum option requires --distdir.")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__wrksrc_nonexistent(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.Chdir(".")
+
+	t.Main("--wrksrc=nonexistent", "category/package")
+
+	t.CheckOutputLines(
+		"FATAL: nonexistent: Not a directory.")
+}
+
+func (s *Suite) Test_Pkglint_ParseCommandLine__wrksrc_several_packages(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.SetUpPackage("category/other")
+	t.CreateFileTree("work/package-1.0")
+	t.Chdir(".")
+
+	t.Main("--wrksrc=work/package-1.0", "category/package", "category/other")
+
+	t.CheckOutputLines(
+		"FATAL: The --wrksrc option only works for a single package.")
+}
+
 func (s *Suite) Test_Pkglint_Check__outside(c *check.C) {
 	t := s.Init(c)
 
@@ -1446,6 +1877,24 @@ func (s *Suite) Test_Pkglint_loadCvsEntries__with_Entries_Log(c *check.C) {
 		"ERROR: ~/CVS/Entries.Log:4: Invalid line: R /invalid/")
 }
 
+func (s *Suite) Test_InterPackage_Enable(c *check.C) {
+	t := s.Init(c)
+
+	var ip InterPackage
+	ip.Enable()
+
+	// Without -Cglobal, --dump-depgraph or --revbump,
+	// the dependency graph is not collected.
+	t.CheckEquals(ip.depgraph, (*DependencyGraph)(nil))
+
+	ip.EnableDependencies()
+	ip.AddPackage("devel/a")
+	ip.Enable()
+
+	// The packages that have already been collected are kept.
+	t.CheckDeepEquals(ip.depgraph.Packages(), []PkgsrcPath{"devel/a"})
+}
+
 func (s *Suite) Test_InterPackage_Bl3__same_identifier(c *check.C) {
 	t := s.Init(c)
 
@@ -1479,3 +1928,233 @@ func (s *Suite) Test_InterPackage_Bl3__same_identifier(c *check.C) {
 			"BUILDLINK_PKGSRCDIR.package1 must be set to the package's own path "+
 			"(../../category/package2), not ../../category/package1.")
 }
+
+func (s *Suite) Test_InterPackage_Depend(c *check.C) {
+	t := s.Init(c)
+
+	var ip InterPackage
+	line := t.NewLine("Makefile", 20, "")
+
+	// Without EnableDependencies, nothing is collected.
+	ip.Depend("devel/a", &Dependency{"DEPENDS", "b-[0-9]*", "devel/b", line, false, false})
+	t.CheckEquals(ip.depgraph, (*DependencyGraph)(nil))
+
+	ip.EnableDependencies()
+	ip.AddPackage("devel/b")
+	ip.Depend("devel/a", &Dependency{"DEPENDS", "b-[0-9]*", "devel/b", line, false, false})
+
+	t.CheckDeepEquals(ip.depgraph.Packages(), []PkgsrcPath{"devel/a", "devel/b"})
+}
+
+func (s *Suite) Test_InterPackage_CheckDependencyCycles(c *check.C) {
+	t := s.Init(c)
+
+	var ip InterPackage
+	ip.EnableDependencies()
+	ip.Depend("devel/a", &Dependency{"DEPENDS", "b-[0-9]*", "devel/b",
+		t.NewLine("devel/a/Makefile", 20, ""), false, false})
+	ip.Depend("devel/b", &Dependency{"buildlink3", "a>=1", "devel/a",
+		t.NewLine("devel/b/buildlink3.mk", 12, ""), false, false})
+	ip.Depend("devel/b", &Dependency{"TEST_DEPENDS", "b-[0-9]*", "devel/b",
+		t.NewLine("devel/b/Makefile", 21, ""), false, false})
+
+	ip.CheckDependencyCycles()
+
+	t.CheckOutputLines(
+		"ERROR: devel/a/Makefile:20: Dependency cycle: devel/a -> devel/b -> devel/a.")
+}
+
+func (s *Suite) Test_InterPackage_AddConflicts(c *check.C) {
+	t := s.Init(c)
+
+	var ip InterPackage
+
+	// Without EnableConflicts, nothing is collected.
+	ip.AddConflicts("devel/a", "a-1.0", nil, nil)
+	t.CheckEquals(ip.conflicts, (*ConflictChecker)(nil))
+
+	ip.EnableConflicts()
+	ip.Enable()
+	ip.AddConflicts("devel/a", "a-1.0", nil, nil)
+
+	// The conflicts are kept when the other inter-package checks
+	// are enabled later.
+	t.CheckDeepEquals(ip.conflicts.packages, []PkgsrcPath{"devel/a"})
+}
+
+func (s *Suite) Test_InterPackage_CheckConflicts(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("devel/b")
+	t.FinishSetUp()
+	var ip InterPackage
+	ip.CheckConflicts()
+	ip.EnableConflicts()
+	line := t.NewLine(t.File("devel/a/Makefile"), 20, "CONFLICTS+=\tb-[0-9]*")
+	ip.AddConflicts("devel/a", "a-1.0", []*Conflict{{"b-[0-9]*", line}}, nil)
+
+	ip.CheckConflicts()
+
+	t.CheckOutputLines(
+		"WARN: ~/devel/a/Makefile:20: The package \"b-1.0\" " +
+			"from ../../devel/b should also conflict with \"a-[0-9]*\".")
+}
+
+func (s *Suite) Test_InterPackage_AddRedistribution(c *check.C) {
+	t := s.Init(c)
+
+	var ip InterPackage
+	redist := Redistribution{"unfree", true, true}
+
+	// Without EnableRedistribution, nothing is collected.
+	ip.AddRedistribution("devel/a", &redist)
+	t.CheckEquals(ip.redist, (*RedistributionChecker)(nil))
+
+	ip.EnableRedistribution()
+	ip.Enable()
+	ip.AddRedistribution("devel/a", &redist)
+
+	t.CheckEquals(ip.redist.packages["devel/a"], &redist)
+}
+
+func (s *Suite) Test_InterPackage_CheckRedistribution(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	var ip InterPackage
+	ip.EnableRedistribution()
+	ip.AddRedistribution("devel/a", &Redistribution{})
+	ip.AddRedistribution("devel/b", &Redistribution{"", true, true})
+
+	// Without the dependency graph, nothing is checked.
+	ip.CheckRedistribution()
+	t.CheckOutputEmpty()
+
+	ip.EnableDependencies()
+	ip.Depend("devel/a", &Dependency{"DEPENDS", "b-[0-9]*", "devel/b",
+		t.NewLine("devel/a/Makefile", 20, ""), false, false})
+
+	ip.CheckRedistribution()
+
+	t.CheckOutputLines(
+		"WARN: devel/a/Makefile:20: The RESTRICTED package devel/b "+
+			"should only be a dependency if a package option is enabled.",
+		"WARN: devel/a/Makefile:20: This package should also define NO_BIN_ON_FTP, "+
+			"since its dependency devel/b cannot be distributed in binary form.")
+}
+
+func (s *Suite) Test_InterPackage_AddPythonVersions(c *check.C) {
+	t := s.Init(c)
+
+	var ip InterPackage
+	versions := PythonVersions{nil, []string{"27"}}
+
+	// Without EnablePythonVersions, nothing is collected.
+	ip.AddPythonVersions("devel/py-a", &versions)
+	t.CheckEquals(ip.python, (*PythonVersionChecker)(nil))
+
+	ip.EnablePythonVersions()
+	ip.Enable()
+	ip.AddPythonVersions("devel/py-a", &versions)
+
+	t.CheckEquals(ip.python.packages["devel/py-a"], &versions)
+}
+
+func (s *Suite) Test_InterPackage_CheckPythonVersions(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	var ip InterPackage
+	ip.EnablePythonVersions()
+	ip.AddPythonVersions("devel/py-a", &PythonVersions{nil, nil})
+	ip.AddPythonVersions("devel/py-b", &PythonVersions{[]string{"312"}, nil})
+
+	// Without the dependency graph, nothing is checked.
+	ip.CheckPythonVersions()
+	t.CheckOutputEmpty()
+
+	ip.EnableDependencies()
+	ip.Depend("devel/py-a", &Dependency{"DEPENDS", "${PYPKGPREFIX}-b-[0-9]*", "devel/py-b",
+		t.NewLine("devel/py-a/Makefile", 20, ""), false, false})
+
+	ip.CheckPythonVersions()
+
+	t.CheckOutputLines(
+		"WARN: devel/py-a/Makefile:20: The dependency devel/py-b " +
+			"doesn't accept Python 27, 38, 39, 310, 311, although this package does.")
+}
+
+func (s *Suite) Test_InterPackage_AddIncludes(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	mklines := t.SetUpFileMkLines("devel/a/Makefile",
+		MkCvsID,
+		".include \"../../mk/bsd.pkg.mk\"")
+	t.FinishSetUp()
+	var ip InterPackage
+
+	// Without EnableIncludes, nothing is collected.
+	ip.AddIncludes("devel/a", mklines, nil)
+	t.CheckEquals(ip.includes, (*IncludeIndex)(nil))
+
+	ip.EnableIncludes()
+	ip.Enable()
+	ip.AddIncludes("devel/a", mklines, nil)
+
+	t.CheckDeepEquals(ip.includes.includers["mk/bsd.pkg.mk"],
+		[]PkgsrcPath{"devel/a/Makefile"})
+}
+
+func (s *Suite) Test_InterPackage_Includers(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	direct := t.SetUpFileMkLines("category/direct/Makefile",
+		MkCvsID,
+		".include \"../../category/common/Makefile.common\"")
+	indirect := t.SetUpFileMkLines("category/indirect/Makefile",
+		MkCvsID,
+		".include \"../../category/common/indirect.mk\"")
+	t.CreateFileLines("category/common/indirect.mk",
+		MkCvsID,
+		".include \"Makefile.common\"")
+	t.CreateFileLines("category/common/Makefile.common",
+		MkCvsID)
+	t.FinishSetUp()
+	var ip InterPackage
+
+	t.CheckDeepEquals(ip.Includers("category/common/Makefile.common"), []PkgsrcPath(nil))
+
+	ip.EnableIncludes()
+	ip.AddIncludes("category/indirect", indirect, nil)
+	ip.AddIncludes("category/direct", direct, nil)
+
+	t.CheckDeepEquals(ip.Includers("category/common/Makefile.common"), []PkgsrcPath{
+		"category/direct",
+		"category/indirect"})
+	t.CheckDeepEquals(ip.Includers("category/common/unknown.mk"), []PkgsrcPath(nil))
+}
+
+func (s *Suite) Test_InterPackage_IncludersInTree(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/checked",
+		".include \"../../category/common/Makefile.common\"")
+	t.SetUpPackage("category/unchecked",
+		".include \"../../category/common/Makefile.common\"")
+	t.CreateFileLines("category/common/Makefile.common",
+		MkCvsID)
+	t.FinishSetUp()
+	var ip InterPackage
+
+	t.CheckDeepEquals(ip.IncludersInTree("category/common/Makefile.common"), []PkgsrcPath(nil))
+
+	ip.EnableIncludes()
+
+	t.CheckDeepEquals(ip.IncludersInTree("category/common/Makefile.common"), []PkgsrcPath{
+		"category/checked",
+		"category/unchecked"})
+}
@@ -2,8 +2,8 @@ package pkglint
 
 import (
 	"github.com/rillig/pkglint/v23/regex"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 )
@@ -34,7 +34,9 @@ type Pkgsrc struct {
 	suggestedWipUpdates []SuggestedUpdate
 
 	changes      Changes
-	listVersions map[string][]string // See Pkgsrc.ListVersions
+	listVersions map[string][]string              // See Pkgsrc.ListVersions
+	packages     map[PkgsrcPath]*PackageEvaluator // See Pkgsrc.Evaluate
+	allPackages  []PkgsrcPath                     // See Pkgsrc.Packages
 
 	// Variables that may be overridden by the pkgsrc user.
 	// They are typically defined in mk/defaults/mk.conf.
@@ -59,6 +61,8 @@ func NewPkgsrc(dir CurrPath) *Pkgsrc {
 		nil,
 		Changes{},
 		make(map[string][]string),
+		make(map[PkgsrcPath]*PackageEvaluator),
+		nil,
 		NewScope(),
 		make(map[string]string),
 		NewVarTypeRegistry()}
@@ -559,16 +563,16 @@ func (src *Pkgsrc) loadUntypedVars() {
 		})
 	}
 
-	handleFile := func(pathName string, info os.FileInfo, err error) error {
+	handleFile := func(pathName string, entry fs.DirEntry, err error) error {
 		assertNil(err, "handleFile %q", pathName)
-		baseName := info.Name()
-		if info.Mode().IsRegular() && (hasSuffix(baseName, ".mk") || baseName == "mk.conf") {
+		baseName := entry.Name()
+		if entry.Type().IsRegular() && (hasSuffix(baseName, ".mk") || baseName == "mk.conf") {
 			handleMkFile(NewCurrPathSlash(pathName))
 		}
 		return nil
 	}
 
-	err := filepath.Walk(src.File("mk").String(), handleFile)
+	err := fs.WalkDir(G.FileSystem, src.File("mk").String(), handleFile)
 	assertNil(err, "Walk error in pkgsrc infrastructure")
 }
 
@@ -675,6 +679,48 @@ func (src *Pkgsrc) Latest(category PkgsrcPath, re regex.Pattern, repl string) st
 	return ""
 }
 
+// Evaluate returns the evaluated variables of the package from the tree.
+func (src *Pkgsrc) Evaluate(pkgpath PkgsrcPath) *PackageEvaluator {
+	if ev := src.packages[pkgpath]; ev != nil {
+		return ev
+	}
+	ev := NewPackageEvaluator(src.File(pkgpath))
+	src.packages[pkgpath] = ev
+	return ev
+}
+
+// Pkgname returns the effective PKGNAME of the package from the tree,
+// including the "nb" suffix, or an empty string if it cannot be
+// determined.
+func (src *Pkgsrc) Pkgname(pkgpath PkgsrcPath) string {
+	return src.Evaluate(pkgpath).Pkgname()
+}
+
+// Packages returns all package directories from the tree, sorted.
+// A package directory is a directory two levels below the top
+// directory that contains a Makefile.
+func (src *Pkgsrc) Packages() []PkgsrcPath {
+	if src.allPackages != nil {
+		return src.allPackages
+	}
+
+	packages := []PkgsrcPath{}
+	for _, category := range src.ReadDir(".") {
+		if !category.IsDir() || category.Name() == "mk" {
+			continue
+		}
+		categoryPath := NewPkgsrcPath(NewPath(category.Name()))
+		for _, pkg := range src.ReadDir(categoryPath) {
+			pkgpath := categoryPath.JoinNoClean(NewRelPathString(pkg.Name()))
+			if pkg.IsDir() && src.File(pkgpath.JoinNoClean("Makefile")).IsFile() {
+				packages = append(packages, pkgpath)
+			}
+		}
+	}
+	src.allPackages = packages
+	return packages
+}
+
 // ListVersions searches the category for subdirectories matching the given
 // regular expression, replaces their names with repl and returns a slice
 // of them, properly sorted from early to late.
@@ -557,6 +557,58 @@ func (s *Suite) Test_Pkgsrc_Latest__not_found(c *check.C) {
 		"ERROR: ~/lang: Cannot find package versions of \"^python[0-9]+$\".")
 }
 
+func (s *Suite) Test_Pkgsrc_Evaluate(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"CONFLICTS=\tother-[0-9]*")
+	t.FinishSetUp()
+
+	ev := G.Pkgsrc.Evaluate("category/package")
+
+	t.CheckEquals(ev.Pkgname(), "package-1.0")
+	t.CheckEquals(G.Pkgsrc.Evaluate("category/package"), ev)
+}
+
+func (s *Suite) Test_Pkgsrc_Pkgname(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"PKGREVISION=\t1")
+	t.FinishSetUp()
+
+	t.CheckEquals(G.Pkgsrc.Pkgname("category/package"), "package-1.0nb1")
+
+	// The result is cached.
+	t.Remove("category/package/Makefile")
+	t.CheckEquals(G.Pkgsrc.Pkgname("category/package"), "package-1.0nb1")
+
+	t.CheckEquals(G.Pkgsrc.Pkgname("category/nonexistent"), "")
+}
+
+func (s *Suite) Test_Pkgsrc_Packages(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.SetUpPackage("wip/package")
+	t.CreateFileLines("category/Makefile",
+		MkCvsID)
+	t.CreateFileLines("category/no-package/DESCR",
+		"Not a package.")
+	t.CreateFileLines("mk/subdir/Makefile",
+		MkCvsID)
+	t.FinishSetUp()
+
+	t.CheckDeepEquals(G.Pkgsrc.Packages(), []PkgsrcPath{
+		"category/package",
+		"wip/package"})
+
+	// The result is cached.
+	t.CreateFileLines("category/new/Makefile",
+		MkCvsID)
+	t.CheckLen(G.Pkgsrc.Packages(), 2)
+}
+
 func (s *Suite) Test_Pkgsrc_ListVersions__ensure_transitive(c *check.C) {
 	t := s.Init(c)
 
@@ -863,9 +915,11 @@ func (s *Suite) Test_Pkgsrc_checkToplevelUnusedLicenses(c *check.C) {
 		"WARN: ~/category/package2/DESCR: DESCR file is the same "+
 			"as \"../../category/package/DESCR\".",
 		"ERROR: ~/category/package2/Makefile:11: License file ../../licenses/missing does not exist.",
+		"WARN: ~/category/package2/PLIST:2: The file \"bin/program\" is also installed "+
+			"by ../../category/package, without a declared conflict.",
 		"WARN: ~/licenses/gnu-gpl-v2: This license seems to be unused.", // Added by Tester.SetUpPkgsrc
 		"WARN: ~/licenses/gnu-gpl-v3: This license seems to be unused.",
-		"1 error and 3 warnings found.",
+		"1 error and 4 warnings found.",
 		t.Shquote("(Run \"pkglint -e -r -Cglobal %s\" to show explanations.)", "."))
 }
 
@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/textproc"
 	"sort"
 	"strings"
@@ -68,6 +69,11 @@ func (ck *PlistChecker) Check(lines *Lines) {
 	ck.pathChecker.checkOmf(plines)
 	CheckLinesTrailingEmptyLines(lines)
 
+	if pkg := ck.pathChecker.pkg; pkg != nil && pkg.destdir != nil {
+		plines = pkg.destdir.Check(lines, plines)
+		pkg.elf.Check(lines, plines)
+	}
+
 	ck.sortChecker.Sort(lines.Lines, plines)
 	SaveAutofixChanges(lines)
 }
@@ -190,7 +196,7 @@ func (ck *PlistPathChecker) Check(pline *PlistLine, rel RelPath) {
 		fix.Explain(
 			"The pkgsrc infrastructure takes care of replacing the correct value",
 			"when generating the actual PLIST for the package.")
-		fix.Replace("${PKGMANDIR}/", "man/")
+		fix.ReplaceInTree(treeLine, "${PKGMANDIR}/", "man/")
 		fix.Apply()
 
 		// Since the autofix only applies to the Line, the PlistLine needs to be updated manually.
@@ -243,7 +249,7 @@ func (ck *PlistPathChecker) checkPathMisc(rel RelPath, pline *PlistLine) {
 		fix.Warnf("Include \"../../lang/python/egg.mk\" " +
 			"instead of listing .egg-info files directly.")
 		if canFix {
-			fix.Replace(pline.Path().Dir().String(), "${PYSITELIB}/${EGG_INFODIR}")
+			fix.ReplaceInTree(treeLine, pline.Path().Dir().String(), "${PYSITELIB}/${EGG_INFODIR}")
 		}
 		fix.Apply()
 		if canFix {
@@ -380,7 +386,12 @@ func (ck *PlistPathChecker) checkPathMan(pline *PlistLine) {
 			"configured by the pkgsrc user.",
 			"Compression and decompression takes place automatically,",
 			"no matter if the .gz extension is mentioned in the PLIST or not.")
-		fix.ReplaceAt(0, len(pline.Line.Text)-len(".gz"), ".gz", "")
+		f, _ := pline.Line.Tree()
+		line := f.Lines()[0]
+		end := len(f.Text(line)) - len("\n")
+		ed := ast.NewFileEditor(f)
+		ed.Remove(f.Slice(line, end-len(".gz"), end))
+		fix.Edit(ed)
 		fix.Apply()
 	}
 }
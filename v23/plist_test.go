@@ -968,7 +968,7 @@ func (s *Suite) Test_PlistPathChecker_checkPathMan__gz(c *check.C) {
 	t.ExpectDiagnosticsAutofix(
 		doTest,
 		"NOTE: PLIST:2: The .gz extension is unnecessary for manual pages.",
-		"AUTOFIX: PLIST:2: Replacing \".gz\" with \"\".")
+		"AUTOFIX: PLIST:2: Deleting \".gz\".")
 }
 
 func (s *Suite) Test_PlistPathChecker_checkPathShare(c *check.C) {
@@ -0,0 +1,140 @@
+package pkglint
+
+import (
+	"github.com/rillig/pkglint/v23/ast"
+	"path"
+	"strings"
+)
+
+// PortabilityChecker finds the constructs in shell programs that only
+// work in some shells, like the check-portability target of the pkgsrc
+// infrastructure does after extracting the sources.
+//
+// Running these checks in pkglint reports the problems already before
+// building the package, instead of after a failed build on a platform
+// whose /bin/sh is not bash.
+type PortabilityChecker struct {
+	// Whether the file belongs to pkgsrc, in which case the == operator
+	// is fixed automatically.
+	autofix bool
+}
+
+func NewPortabilityChecker(autofix bool) *PortabilityChecker {
+	return &PortabilityChecker{autofix}
+}
+
+// CheckFilePortability checks a shell script from the files/ directory
+// of a package.
+func CheckFilePortability(filename CurrPath) {
+	lines := Load(filename, 0)
+	if lines == nil || !isPortableShellScript(lines) {
+		return
+	}
+
+	ck := NewPortabilityChecker(true)
+	for _, line := range lines.Lines {
+		ck.CheckLine(line, 0)
+	}
+	SaveAutofixChanges(lines)
+}
+
+// CheckLine checks the shell code from the line, starting at the given
+// index, which is nonzero for the added lines in patches.
+func (ck *PortabilityChecker) CheckLine(line *Line, start int) {
+	// The shell tokenizer expects the text in makefile syntax,
+	// in which a single dollar sign starts a make expression.
+	escaped := strings.ReplaceAll(line.Text[start:], "$", "$$")
+	tokenizer := NewShTokenizer(nil, escaped)
+
+	// The number of bytes by which the autofixes have shortened the line.
+	shift := 0
+
+	var command []*ShToken
+	for {
+		token := tokenizer.ShToken()
+		if token == nil || token.Atoms[0].Type == shtComment {
+			break
+		}
+		if token.Atoms[0].Type == shtOperator {
+			command = nil
+			continue
+		}
+
+		for _, atom := range token.Atoms {
+			// Inside single quotes, the variable is not expanded.
+			if atom.Type == shtShExpr && atom.ShVarname() == "RANDOM" &&
+				!hasSuffix(atom.Quoting.String(), "s") {
+				ck.warnRandom(line)
+			}
+		}
+
+		switch {
+		case len(command) == 0 && matches(token.MkText, `^(!|if|elif|then|else|while|until|do)$`):
+			continue
+		case len(command) == 0 && token.MkText == "[[":
+			ck.warnDoubleBracket(line)
+		case token.MkText == "==" && len(command) > 0 &&
+			(command[0].MkText == "test" || command[0].MkText == "["):
+			offset := token.Offset - strings.Count(escaped[:token.Offset], "$")/2
+			before := len(line.RawText(0))
+			ck.errorEquals(line, start+offset-shift)
+			shift += before - len(line.RawText(0))
+		}
+		command = append(command, token)
+	}
+}
+
+func (ck *PortabilityChecker) errorEquals(line *Line, index int) {
+	fix := line.Autofix()
+	fix.Errorf("The \"==\" operator in test expressions is not portable.")
+	fix.Explain(
+		"The \"test\" command, as well as the \"[\" command,",
+		"are not required to know the \"==\" operator.",
+		"Only a few implementations like bash and some versions of ksh",
+		"support it.",
+		"",
+		"When \"test foo == foo\" runs on a platform that doesn't support",
+		"the \"==\" operator, the result is \"false\" instead of \"true\",",
+		"which leads to unexpected behavior.",
+		"",
+		"Use \"=\" instead.")
+	if ck.autofix {
+		f, _ := line.Tree()
+		ed := ast.NewFileEditor(f)
+		ed.Replace(f.Slice(f.Lines()[0], index, index+len("==")), ast.NewLiteral("="))
+		fix.Edit(ed)
+	}
+	fix.Apply()
+}
+
+func (ck *PortabilityChecker) warnRandom(line *Line) {
+	line.Warnf("The variable $RANDOM is not portable.")
+	line.Explain(
+		"The variable $RANDOM is only available in bash and some other",
+		"shells.",
+		"In the other shells, it is empty.",
+		"",
+		"To create temporary files or directories, use mktemp instead.")
+}
+
+func (ck *PortabilityChecker) warnDoubleBracket(line *Line) {
+	line.Warnf("The [[ command is not portable.")
+	line.Explain(
+		"The [[ command is only available in bash, ksh and zsh.",
+		"Use \"[\" or \"test\" instead,",
+		"or change the interpreter of the script to bash.")
+}
+
+// isPortableShellScript returns whether the lines form a script for
+// the POSIX shell, as opposed to scripts for bash or other interpreters.
+func isPortableShellScript(lines *Lines) bool {
+	return lines.Len() > 0 &&
+		matches(lines.Lines[0].Text, `^#!\s*(?:/usr/bin/env\s+)?(?:\S*/)?sh(?:\s|$)`)
+}
+
+// isPortableShellFile returns whether the file is a shell script,
+// judging only by its name.
+func isPortableShellFile(filename Path) bool {
+	base := path.Base(filename.String())
+	return base == "configure" || hasSuffix(base, ".sh")
+}
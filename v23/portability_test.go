@@ -0,0 +1,157 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_NewPortabilityChecker(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewPortabilityChecker(true)
+
+	t.CheckEquals(ck.autofix, true)
+}
+
+func (s *Suite) Test_CheckFilePortability(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileLines("category/package/files/script.sh",
+		"#! /bin/sh",
+		"if [ \"$1\" == \"--help\" ]; then",
+		"\techo \"usage: $0 [--help]\"",
+		"fi")
+	t.CreateFileLines("category/package/files/script.bash",
+		"#! /usr/pkg/bin/bash",
+		"[[ $1 == --help ]] && echo $RANDOM")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	G.Check(".")
+
+	t.CheckOutputLines(
+		"ERROR: files/script.sh:2: The \"==\" operator in test expressions is not portable.")
+
+	t.SetUpCommandLine("-Wall", "--autofix")
+
+	G.Check(".")
+
+	t.CheckOutputLines(
+		"AUTOFIX: files/script.sh:2: Replacing \"==\" with \"=\".")
+	t.CheckFileLines("files/script.sh",
+		"#! /bin/sh",
+		"if [ \"$1\" = \"--help\" ]; then",
+		"\techo \"usage: $0 [--help]\"",
+		"fi")
+}
+
+func (s *Suite) Test_PortabilityChecker_CheckLine(c *check.C) {
+	t := s.Init(c)
+
+	test := func(text string, diagnostics ...string) {
+		line := t.NewLine("script.sh", 3, text)
+		NewPortabilityChecker(false).CheckLine(line, 0)
+		t.CheckOutput(diagnostics)
+	}
+
+	test("test \"$a\" = \"$b\"",
+		nil...)
+	test("test \"$a\" == \"$b\"",
+		"ERROR: script.sh:3: The \"==\" operator in test expressions is not portable.")
+	test("if [ $$ == 1 ] && [ $a == 1 ]; then :; fi",
+		"ERROR: script.sh:3: The \"==\" operator in test expressions is not portable.",
+		"ERROR: script.sh:3: The \"==\" operator in test expressions is not portable.")
+
+	// Only the test command is checked, other commands may use "==".
+	test("echo == ; expr 1 == 1",
+		nil...)
+	test("echo '[ a == b ]'",
+		nil...)
+	test("# if [ a == b ]",
+		nil...)
+	test("if [[ -n $x ]]; then",
+		"WARN: script.sh:3: The [[ command is not portable.")
+	test("echo [[",
+		nil...)
+	test("tmp=/tmp/file.$RANDOM.${RANDOM}",
+		"WARN: script.sh:3: The variable $RANDOM is not portable.",
+		"WARN: script.sh:3: The variable $RANDOM is not portable.")
+	test("echo '$RANDOM'",
+		nil...)
+
+	// The rest of the line is not checked since the string continues
+	// in the next line.
+	test("echo \"unfinished; test a == b",
+		nil...)
+}
+
+func (s *Suite) Test_PortabilityChecker_errorEquals(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("-Wall", "--show-autofix")
+	line := t.NewLine("script.sh", 3, "test \"$$\" == \"$1\"")
+
+	NewPortabilityChecker(true).CheckLine(line, 0)
+
+	t.CheckOutputLines(
+		"ERROR: script.sh:3: The \"==\" operator in test expressions is not portable.",
+		"AUTOFIX: script.sh:3: Replacing \"==\" with \"=\".")
+	t.CheckEquals(line.Text, "test \"$$\" = \"$1\"")
+}
+
+func (s *Suite) Test_PortabilityChecker_warnRandom(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+	line := t.NewLine("script.sh", 3, "echo $RANDOM")
+
+	NewPortabilityChecker(false).warnRandom(line)
+
+	t.CheckOutputLines(
+		"WARN: script.sh:3: The variable $RANDOM is not portable.",
+		"",
+		"\tThe variable $RANDOM is only available in bash and some other",
+		"\tshells. In the other shells, it is empty.",
+		"",
+		"\tTo create temporary files or directories, use mktemp instead.",
+		"")
+}
+
+func (s *Suite) Test_PortabilityChecker_warnDoubleBracket(c *check.C) {
+	t := s.Init(c)
+
+	line := t.NewLine("script.sh", 3, "[[ -f file ]]")
+
+	NewPortabilityChecker(false).warnDoubleBracket(line)
+
+	t.CheckOutputLines(
+		"WARN: script.sh:3: The [[ command is not portable.")
+}
+
+func (s *Suite) Test_isPortableShellScript(c *check.C) {
+	t := s.Init(c)
+
+	test := func(firstLine string, expected bool) {
+		lines := t.NewLines("script", firstLine)
+		t.CheckEquals(isPortableShellScript(lines), expected)
+	}
+
+	test("#!/bin/sh", true)
+	test("#! /bin/sh -e", true)
+	test("#!/usr/bin/env sh", true)
+	test("#!@SH@", false)
+	test("#!/bin/bash", false)
+	test("#!/usr/bin/env bash", false)
+	test("#!/usr/bin/perl", false)
+	test("echo 'no interpreter'", false)
+
+	t.CheckEquals(isPortableShellScript(t.NewLines("empty")), false)
+}
+
+func (s *Suite) Test_isPortableShellFile(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(isPortableShellFile("configure"), true)
+	t.CheckEquals(isPortableShellFile("src/configure"), true)
+	t.CheckEquals(isPortableShellFile("scripts/install.sh"), true)
+	t.CheckEquals(isPortableShellFile("configure.ac"), false)
+	t.CheckEquals(isPortableShellFile("Makefile"), false)
+}
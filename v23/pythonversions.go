@@ -0,0 +1,124 @@
+package pkglint
+
+import "strings"
+
+// PythonVersions describes the Python versions that a package that
+// includes one of the lang/python/*.mk files can be built with.
+type PythonVersions struct {
+	// The versions from PYTHON_VERSIONS_ACCEPTED,
+	// or nil if the variable is not defined.
+	Accepted []string
+
+	// The versions from PYTHON_VERSIONS_INCOMPATIBLE.
+	Incompatible []string
+}
+
+// Supported returns the versions from all that the package can be
+// built with, keeping the order of all.
+func (pv *PythonVersions) Supported(all []string) []string {
+	var supported []string
+	for _, version := range all {
+		if (pv.Accepted == nil || containsStr(pv.Accepted, version)) &&
+			!containsStr(pv.Incompatible, version) {
+			supported = append(supported, version)
+		}
+	}
+	return supported
+}
+
+// PythonVersionChecker checks that the Python packages don't accept
+// Python versions that their Python dependencies don't accept.
+//
+// Such inconsistencies only show up when the packages are built with
+// a Python version other than the default, which is why they are easy
+// to miss in everyday development.
+type PythonVersionChecker struct {
+	packages map[PkgsrcPath]*PythonVersions
+}
+
+func NewPythonVersionChecker() *PythonVersionChecker {
+	return &PythonVersionChecker{make(map[PkgsrcPath]*PythonVersions)}
+}
+
+// AddPackage remembers the Python versions of the checked package.
+func (ck *PythonVersionChecker) AddPackage(pkgpath PkgsrcPath, versions *PythonVersions) {
+	ck.packages[pkgpath] = versions
+}
+
+// Check checks the dependencies of all checked Python packages from
+// the dependency graph.
+func (ck *PythonVersionChecker) Check(g *DependencyGraph) {
+	all := ck.allVersions()
+	if len(all) == 0 {
+		return
+	}
+
+	for _, pkgpath := range g.Packages() {
+		if versions := ck.packages[pkgpath]; versions != nil {
+			ck.checkPackage(g, pkgpath, versions.Supported(all), all)
+		}
+	}
+}
+
+func (ck *PythonVersionChecker) checkPackage(g *DependencyGraph, pkgpath PkgsrcPath, supported []string, all []string) {
+	for _, dep := range g.Dependencies(pkgpath) {
+		// Dependencies that are conditional often depend on the
+		// Python version, such as backports of newer modules.
+		if dep.Conditional || dep.Target == pkgpath {
+			continue
+		}
+		depVersions := ck.versions(dep.Target)
+		if depVersions == nil {
+			continue
+		}
+
+		depSupported := depVersions.Supported(all)
+		var missing []string
+		for _, version := range supported {
+			if !containsStr(depSupported, version) {
+				missing = append(missing, version)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		line := dep.Line
+		line.Warnf("The dependency %s doesn't accept Python %s, although this package does.",
+			dep.Target.String(), strings.Join(missing, ", "))
+		line.Explain(
+			"When this package is built with one of these Python versions,",
+			"the dependency cannot be built, and the build fails.",
+			"",
+			"To fix this, either add these versions to PYTHON_VERSIONS_INCOMPATIBLE",
+			"in this package, or make the dependency accept them as well.")
+	}
+}
+
+// versions returns the Python versions of the package, preferring
+// the data from the checked package over the evaluated one.
+// It returns nil if the package is not a Python package or if its
+// Python versions cannot be determined.
+func (ck *PythonVersionChecker) versions(pkgpath PkgsrcPath) *PythonVersions {
+	if versions, found := ck.packages[pkgpath]; found {
+		return versions
+	}
+	return G.Pkgsrc.Evaluate(pkgpath).PythonVersions()
+}
+
+// allVersions returns the Python versions that are available in pkgsrc.
+func (ck *PythonVersionChecker) allVersions() []string {
+	vartype := G.Pkgsrc.VariableType(nil, "PYTHON_VERSION_DEFAULT")
+	if vartype == nil || !vartype.basicType.IsEnum() {
+		return nil
+	}
+	return strings.Fields(vartype.basicType.AllowedEnums())
+}
+
+// isPythonMk returns whether the included file is one of the
+// lang/python/*.mk files that make a package a Python package.
+func isPythonMk(includedFile RelPath) bool {
+	return includedFile.Dir().Base() == "python" &&
+		includedFile.Dir().Dir().Base() == "lang" &&
+		includedFile.HasSuffixText(".mk")
+}
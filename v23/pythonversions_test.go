@@ -0,0 +1,172 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_PythonVersions_Supported(c *check.C) {
+	t := s.Init(c)
+
+	all := []string{"27", "39", "310", "311"}
+
+	test := func(versions PythonVersions, expected ...string) {
+		t.CheckDeepEquals(versions.Supported(all), expected)
+	}
+
+	test(PythonVersions{nil, nil},
+		"27", "39", "310", "311")
+	test(PythonVersions{nil, []string{"27", "39"}},
+		"310", "311")
+	test(PythonVersions{[]string{"311", "310", "38"}, nil},
+		"310", "311")
+	test(PythonVersions{[]string{"311", "310"}, []string{"310"}},
+		"311")
+
+	// An empty PYTHON_VERSIONS_ACCEPTED accepts nothing.
+	test(PythonVersions{[]string{}, nil},
+		nil...)
+}
+
+func (s *Suite) Test_NewPythonVersionChecker(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewPythonVersionChecker()
+
+	t.CheckLen(ck.packages, 0)
+}
+
+func (s *Suite) Test_PythonVersionChecker_AddPackage(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewPythonVersionChecker()
+	versions := PythonVersions{nil, []string{"27"}}
+
+	ck.AddPackage("category/py-package", &versions)
+	ck.AddPackage("category/package", nil)
+
+	t.CheckEquals(ck.packages["category/py-package"], &versions)
+	t.CheckEquals(ck.packages["category/package"], (*PythonVersions)(nil))
+	t.CheckLen(ck.packages, 2)
+}
+
+func (s *Suite) Test_PythonVersionChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("lang/python/wheel.mk",
+		MkCvsID)
+	t.SetUpPackageOwnPlist("category/py-app",
+		"PYTHON_VERSIONS_INCOMPATIBLE=\t27",
+		"DEPENDS+=\t${PYPKGPREFIX}-lib-[0-9]*:../../category/py-lib",
+		"DEPENDS+=\t${PYPKGPREFIX}-old-[0-9]*:../../category/py-old",
+		"DEPENDS+=\tlib-[0-9]*:../../category/lib",
+		"",
+		".include \"../../mk/bsd.prefs.mk\"",
+		"",
+		".if ${OPSYS} == NetBSD",
+		"DEPENDS+=\t${PYPKGPREFIX}-netbsd-[0-9]*:../../category/py-netbsd",
+		".endif",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackageOwnPlist("category/py-lib",
+		"PYTHON_VERSIONS_INCOMPATIBLE=\t27 38",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackageOwnPlist("category/py-old",
+		"PYTHON_VERSIONS_ACCEPTED=\t27",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackageOwnPlist("category/py-netbsd",
+		"PYTHON_VERSIONS_ACCEPTED=\t27",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackage("category/lib")
+	t.Chdir(".")
+
+	t.Main("-Cglobal", "-q", "category/py-app", "category/py-lib")
+
+	// The dependency on category/py-netbsd is conditional,
+	// therefore it is not checked.
+	t.CheckOutputLines(
+		"WARN: category/py-app/Makefile:21: The dependency category/py-lib "+
+			"doesn't accept Python 38, although this package does.",
+		"WARN: category/py-app/Makefile:22: The dependency category/py-old "+
+			"doesn't accept Python 38, 39, 310, 311, 312, although this package does.")
+}
+
+func (s *Suite) Test_PythonVersionChecker_checkPackage(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewPythonVersionChecker()
+	ck.AddPackage("category/py-all", &PythonVersions{nil, nil})
+	ck.AddPackage("category/py-new", &PythonVersions{nil, []string{"27", "39"}})
+	ck.AddPackage("category/package", nil)
+	g := newDependencyGraphForTest(t,
+		"category/py-app DEPENDS category/py-all",
+		"category/py-app TOOL_DEPENDS category/py-new",
+		"category/py-app buildlink3 category/package",
+		"category/py-app TEST_DEPENDS category/py-app")
+	all := []string{"27", "39", "310"}
+
+	test := func(supported []string, diagnostics ...string) {
+		ck.checkPackage(g, "category/py-app", supported, all)
+		t.CheckOutput(diagnostics)
+	}
+
+	test([]string{"310"},
+		nil...)
+
+	test([]string{"27", "39", "310"},
+		"WARN: Makefile:2: The dependency category/py-new "+
+			"doesn't accept Python 27, 39, although this package does.")
+}
+
+func (s *Suite) Test_PythonVersionChecker_versions(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("lang/python/wheel.mk",
+		MkCvsID)
+	t.SetUpPackageOwnPlist("category/py-package",
+		"PYTHON_VERSIONS_INCOMPATIBLE=\t27",
+		"",
+		".include \"../../lang/python/wheel.mk\"")
+	t.SetUpPackage("category/package")
+	t.FinishSetUp()
+	ck := NewPythonVersionChecker()
+	checked := PythonVersions{[]string{"312"}, nil}
+	ck.AddPackage("category/py-checked", &checked)
+	ck.AddPackage("category/checked", nil)
+
+	t.CheckEquals(ck.versions("category/py-checked"), &checked)
+	t.CheckEquals(ck.versions("category/checked"), (*PythonVersions)(nil))
+	t.CheckDeepEquals(ck.versions("category/py-package"),
+		&PythonVersions{nil, []string{"27"}})
+	t.CheckEquals(ck.versions("category/package"), (*PythonVersions)(nil))
+}
+
+func (s *Suite) Test_PythonVersionChecker_allVersions(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.CreateFileLines("lang/python27/Makefile")
+	t.CreateFileLines("lang/python313/Makefile")
+	t.FinishSetUp()
+	ck := NewPythonVersionChecker()
+
+	t.CheckDeepEquals(ck.allVersions(), []string{"27", "313"})
+}
+
+func (s *Suite) Test_isPythonMk(c *check.C) {
+	t := s.Init(c)
+
+	test := func(includedFile RelPath, expected bool) {
+		t.CheckEquals(isPythonMk(includedFile), expected)
+	}
+
+	test("../../lang/python/wheel.mk", true)
+	test("../../lang/python/pyversion.mk", true)
+	test("../../lang/python/versioned_dependencies.mk", true)
+	test("../../lang/python312/buildlink3.mk", false)
+	test("../../lang/python/README", false)
+	test("../../devel/python/module.mk", false)
+	test("python/module.mk", false)
+}
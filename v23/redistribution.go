@@ -0,0 +1,212 @@
+package pkglint
+
+import "github.com/rillig/pkglint/v23/licenses"
+
+// Redistribution describes the license of a package and the
+// restrictions on redistributing it.
+type Redistribution struct {
+	License    string // The LICENSE expression, or empty if unknown
+	NoBinOnFTP bool   // Whether NO_BIN_ON_FTP is defined unconditionally
+	Restricted bool   // Whether RESTRICTED is defined unconditionally
+}
+
+// RedistributionChecker propagates the licenses and the redistribution
+// restrictions of the packages along their run-time dependencies,
+// to find the binary packages that cannot be distributed as-is.
+//
+// Only the dependencies that are added in the default configuration
+// of the package are considered, not those that depend on package
+// options or on the platform.
+type RedistributionChecker struct {
+	packages map[PkgsrcPath]*Redistribution
+
+	// The licenses from DEFAULT_ACCEPTABLE_LICENSES,
+	// or nil if they are not known.
+	defaultLicenses map[string]bool
+}
+
+func NewRedistributionChecker() *RedistributionChecker {
+	return &RedistributionChecker{make(map[PkgsrcPath]*Redistribution), nil}
+}
+
+// AddPackage remembers the license and the restrictions of the
+// checked package.
+func (ck *RedistributionChecker) AddPackage(pkgpath PkgsrcPath, redist *Redistribution) {
+	ck.packages[pkgpath] = redist
+}
+
+// Check checks the run-time dependencies of all checked packages
+// from the dependency graph.
+func (ck *RedistributionChecker) Check(g *DependencyGraph) {
+	ck.defaultLicenses = ck.loadDefaultLicenses()
+
+	for _, pkgpath := range g.Packages() {
+		if redist := ck.packages[pkgpath]; redist != nil {
+			ck.checkPackage(g, pkgpath, redist)
+		}
+	}
+}
+
+func (ck *RedistributionChecker) checkPackage(g *DependencyGraph, pkgpath PkgsrcPath, redist *Redistribution) {
+	warnedNoBinOnFTP := false
+	notedLicense := false
+
+	for _, dep := range g.Dependencies(pkgpath) {
+		if !isRunTimeDependency(dep) || dep.Conditional {
+			continue
+		}
+		line := dep.Line
+
+		if ck.redistribution(dep.Target).Restricted && !redist.Restricted {
+			line.Warnf("The RESTRICTED package %s should only be a dependency if a package option is enabled.",
+				dep.Target.String())
+			line.Explain(
+				"Since the dependency cannot be redistributed,",
+				"the binary package of this package cannot be installed",
+				"from a binary package repository alone.",
+				"",
+				"To keep this package useful in its default configuration,",
+				"make the dependency optional via a package option",
+				"that is not enabled by default.")
+		}
+
+		for _, other := range ck.runTimeClosure(g, dep.Target) {
+			otherRedist := ck.redistribution(other)
+
+			if otherRedist.NoBinOnFTP && !redist.NoBinOnFTP && !warnedNoBinOnFTP {
+				warnedNoBinOnFTP = true
+				line.Warnf("This package should also define NO_BIN_ON_FTP, "+
+					"since its dependency %s cannot be distributed in binary form.",
+					other.String())
+				line.Explain(
+					"When a dependency of a package is not allowed on FTP servers,",
+					"the binary package of this package is useless on these servers",
+					"as well.",
+					"",
+					"To prevent the binary package from being uploaded,",
+					"restrict this package as well, for example:",
+					"",
+					sprintf("\tRESTRICTED=\tDepends on %s", other.String()),
+					"\tNO_BIN_ON_FTP=\t${RESTRICTED}")
+			}
+
+			license := ck.unacceptableLicense(otherRedist.License)
+			if license != "" && ck.unacceptableLicense(redist.License) == "" && !notedLicense {
+				notedLicense = true
+				line.Notef("The dependency %s has the license %q, which is not in DEFAULT_ACCEPTABLE_LICENSES.",
+					other.String(), license)
+				line.Explain(
+					"Although the license of this package is acceptable by default,",
+					"the package cannot be built without changing ACCEPTABLE_LICENSES,",
+					"since one of its dependencies has a license that is not.",
+					"",
+					"Consider making the dependency optional,",
+					"or document this in the package.")
+			}
+		}
+	}
+}
+
+// runTimeClosure returns the package and all packages that it needs at
+// run time, in breadth-first order.
+//
+// For packages that have not been checked, their dependencies are not
+// known, therefore only the package itself is returned.
+func (ck *RedistributionChecker) runTimeClosure(g *DependencyGraph, pkgpath PkgsrcPath) []PkgsrcPath {
+	closure := []PkgsrcPath{pkgpath}
+	seen := map[PkgsrcPath]bool{pkgpath: true}
+
+	for i := 0; i < len(closure); i++ {
+		for _, dep := range g.Dependencies(closure[i]) {
+			if isRunTimeDependency(dep) && !dep.Conditional && !seen[dep.Target] {
+				seen[dep.Target] = true
+				closure = append(closure, dep.Target)
+			}
+		}
+	}
+	return closure
+}
+
+// redistribution returns the license and the restrictions of the
+// package, preferring the data from the checked package over the
+// evaluated one.
+func (ck *RedistributionChecker) redistribution(pkgpath PkgsrcPath) *Redistribution {
+	if redist := ck.packages[pkgpath]; redist != nil {
+		return redist
+	}
+	return G.Pkgsrc.Evaluate(pkgpath).Redistribution()
+}
+
+// unacceptableLicense returns a license from the license condition
+// that prevents the condition from being acceptable by default.
+// It returns an empty string if the condition is acceptable by default,
+// or if this cannot be determined.
+func (ck *RedistributionChecker) unacceptableLicense(license string) string {
+	if ck.defaultLicenses == nil || license == "" || containsExpr(license) {
+		return ""
+	}
+	cond := licenses.Parse(license)
+	if cond == nil {
+		return ""
+	}
+
+	var unacceptable func(cond *licenses.Condition) string
+	unacceptable = func(cond *licenses.Condition) string {
+		switch {
+		case cond.Name != "":
+			if ck.defaultLicenses[cond.Name] {
+				return ""
+			}
+			return cond.Name
+		case cond.Paren != nil:
+			return unacceptable(cond.Paren)
+		case cond.Or:
+			first := ""
+			for _, child := range cond.Children {
+				name := unacceptable(child)
+				if name == "" {
+					return ""
+				}
+				if first == "" {
+					first = name
+				}
+			}
+			return first
+		}
+		for _, child := range cond.Children {
+			if name := unacceptable(child); name != "" {
+				return name
+			}
+		}
+		return ""
+	}
+	return unacceptable(cond)
+}
+
+// loadDefaultLicenses returns the licenses from DEFAULT_ACCEPTABLE_LICENSES
+// in mk/license.mk, or nil if that file doesn't define them.
+func (ck *RedistributionChecker) loadDefaultLicenses() map[string]bool {
+	mklines := G.Pkgsrc.LoadMk("mk/license.mk", 0)
+	if mklines == nil {
+		return nil
+	}
+
+	var defaults map[string]bool
+	mklines.ForEach(func(mkline *MkLine) {
+		if mkline.IsVarassign() && mkline.Varname() == "DEFAULT_ACCEPTABLE_LICENSES" {
+			if defaults == nil {
+				defaults = make(map[string]bool)
+			}
+			for _, license := range mkline.ValueFields(mkline.Value()) {
+				defaults[license] = true
+			}
+		}
+	})
+	return defaults
+}
+
+// isRunTimeDependency returns whether the dependency is needed when the
+// package is installed, as opposed to only when it is built or tested.
+func isRunTimeDependency(dep *Dependency) bool {
+	return dep.Kind == "DEPENDS" || dep.Kind == "buildlink3" && !dep.BuildOnly
+}
@@ -0,0 +1,225 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_NewRedistributionChecker(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewRedistributionChecker()
+
+	t.CheckLen(ck.packages, 0)
+	t.CheckDeepEquals(ck.defaultLicenses, map[string]bool(nil))
+}
+
+func (s *Suite) Test_RedistributionChecker_AddPackage(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewRedistributionChecker()
+	redist := Redistribution{"2-clause-bsd", false, false}
+
+	ck.AddPackage("category/package", &redist)
+
+	t.CheckEquals(ck.packages["category/package"], &redist)
+}
+
+func (s *Suite) Test_RedistributionChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	t.CreateFileLines("mk/license.mk",
+		MkCvsID,
+		"DEFAULT_ACCEPTABLE_LICENSES=\t2-clause-bsd",
+		"DEFAULT_ACCEPTABLE_LICENSES+=\tgnu-gpl-v2")
+	t.CreateFileLines("licenses/unfree",
+		"You must not do anything with this software.")
+	t.SetUpPackageOwnPlist("category/app",
+		"DEPENDS+=\tlib-[0-9]*:../../category/lib",
+		"DEPENDS+=\trestricted-[0-9]*:../../category/restricted",
+		".if ${OPSYS} == NetBSD",
+		"DEPENDS+=\toptional-[0-9]*:../../category/optional",
+		".endif")
+	t.SetUpPackageOwnPlist("category/lib",
+		"DEPENDS+=\tnobin-[0-9]*:../../category/nobin")
+	t.SetUpPackageOwnPlist("category/nobin",
+		"LICENSE=\tunfree",
+		"RESTRICTED=\tSee LICENSE",
+		"NO_BIN_ON_FTP=\t${RESTRICTED}")
+	t.SetUpPackageOwnPlist("category/restricted",
+		"RESTRICTED=\tNo redistribution",
+		"NO_BIN_ON_FTP=\t${RESTRICTED}")
+	t.SetUpPackageOwnPlist("category/optional",
+		"RESTRICTED=\tNo redistribution")
+	t.Chdir(".")
+
+	t.Main("-Cglobal", "-q", "category/app", "category/lib", "category/nobin")
+
+	// The dependency on category/optional is only added on NetBSD,
+	// therefore it is not considered.
+	t.CheckOutputLines(
+		"WARN: category/app/Makefile:20: This package should also define NO_BIN_ON_FTP, "+
+			"since its dependency category/nobin cannot be distributed in binary form.",
+		"NOTE: category/app/Makefile:20: The dependency category/nobin has the license \"unfree\", "+
+			"which is not in DEFAULT_ACCEPTABLE_LICENSES.",
+		"WARN: category/app/Makefile:21: The RESTRICTED package category/restricted "+
+			"should only be a dependency if a package option is enabled.",
+		"WARN: category/lib/Makefile:20: The RESTRICTED package category/nobin "+
+			"should only be a dependency if a package option is enabled.",
+		"WARN: category/lib/Makefile:20: This package should also define NO_BIN_ON_FTP, "+
+			"since its dependency category/nobin cannot be distributed in binary form.",
+		"NOTE: category/lib/Makefile:20: The dependency category/nobin has the license \"unfree\", "+
+			"which is not in DEFAULT_ACCEPTABLE_LICENSES.")
+}
+
+func (s *Suite) Test_RedistributionChecker_checkPackage(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewRedistributionChecker()
+	ck.defaultLicenses = map[string]bool{"free": true}
+	ck.AddPackage("category/restricted", &Redistribution{"free", true, true})
+	ck.AddPackage("category/unfree", &Redistribution{"unfree", false, false})
+	g := newDependencyGraphForTest(t,
+		"category/app TEST_DEPENDS category/restricted",
+		"category/app BUILD_DEPENDS category/unfree",
+		"category/app DEPENDS category/restricted",
+		"category/app DEPENDS category/unfree")
+
+	test := func(redist *Redistribution, diagnostics ...string) {
+		ck.checkPackage(g, "category/app", redist)
+		t.CheckOutput(diagnostics)
+	}
+
+	// Dependencies that are only needed for building or testing
+	// are not considered.
+	test(&Redistribution{"free", false, false},
+		"WARN: Makefile:3: The RESTRICTED package category/restricted "+
+			"should only be a dependency if a package option is enabled.",
+		"WARN: Makefile:3: This package should also define NO_BIN_ON_FTP, "+
+			"since its dependency category/restricted cannot be distributed in binary form.",
+		"NOTE: Makefile:4: The dependency category/unfree has the license \"unfree\", "+
+			"which is not in DEFAULT_ACCEPTABLE_LICENSES.")
+
+	// A package that is restricted itself doesn't need further warnings.
+	test(&Redistribution{"free", true, true},
+		"NOTE: Makefile:4: The dependency category/unfree has the license \"unfree\", "+
+			"which is not in DEFAULT_ACCEPTABLE_LICENSES.")
+
+	// If the license of the package itself is not acceptable by default,
+	// the license of the dependency doesn't make a difference.
+	test(&Redistribution{"unfree", true, true},
+		nil...)
+}
+
+func (s *Suite) Test_RedistributionChecker_checkPackage__conditional(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewRedistributionChecker()
+	ck.AddPackage("category/restricted", &Redistribution{"", true, true})
+	g := NewDependencyGraph()
+	line := t.NewLine("Makefile", 20, "")
+	g.Add("category/app", &Dependency{"DEPENDS", "", "category/restricted", line, true, false})
+
+	ck.checkPackage(g, "category/app", &Redistribution{})
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_RedistributionChecker_runTimeClosure(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewRedistributionChecker()
+	g := newDependencyGraphForTest(t,
+		"category/a DEPENDS category/b",
+		"category/a buildlink3 category/c",
+		"category/b DEPENDS category/d",
+		"category/b TOOL_DEPENDS category/e",
+		"category/c DEPENDS category/a",
+		"category/d DEPENDS category/b")
+	line := t.NewLine("Makefile", 20, "")
+	g.Add("category/a", &Dependency{"DEPENDS", "", "category/f", line, true, false})
+
+	t.CheckDeepEquals(ck.runTimeClosure(g, "category/a"), []PkgsrcPath{
+		"category/a", "category/b", "category/c", "category/d"})
+	t.CheckDeepEquals(ck.runTimeClosure(g, "category/unknown"), []PkgsrcPath{
+		"category/unknown"})
+}
+
+func (s *Suite) Test_RedistributionChecker_redistribution(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"RESTRICTED=\tNo redistribution",
+		"NO_BIN_ON_FTP=\t${RESTRICTED}")
+	t.FinishSetUp()
+	ck := NewRedistributionChecker()
+	checked := Redistribution{"gnu-gpl-v2", false, false}
+	ck.AddPackage("category/checked", &checked)
+
+	t.CheckEquals(ck.redistribution("category/checked"), &checked)
+	t.CheckDeepEquals(ck.redistribution("category/package"),
+		&Redistribution{"2-clause-bsd", true, true})
+}
+
+func (s *Suite) Test_RedistributionChecker_unacceptableLicense(c *check.C) {
+	t := s.Init(c)
+
+	ck := NewRedistributionChecker()
+
+	test := func(license string, expected string) {
+		t.CheckEquals(ck.unacceptableLicense(license), expected)
+	}
+
+	// Without DEFAULT_ACCEPTABLE_LICENSES, every license is acceptable.
+	test("unfree", "")
+
+	ck.defaultLicenses = map[string]bool{"free": true, "gratis": true}
+
+	test("free", "")
+	test("unfree", "unfree")
+	test("free OR unfree", "")
+	test("unfree OR other", "unfree")
+	test("free AND gratis", "")
+	test("free AND unfree", "unfree")
+	test("(free AND unfree) OR (gratis)", "")
+	test("(free AND unfree) OR (other)", "unfree")
+	test("${PERL5_LICENSE}", "")
+	test("free AND", "")
+	test("", "")
+}
+
+func (s *Suite) Test_RedistributionChecker_loadDefaultLicenses(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPkgsrc()
+	t.FinishSetUp()
+	ck := NewRedistributionChecker()
+
+	t.CheckDeepEquals(ck.loadDefaultLicenses(), map[string]bool(nil))
+
+	t.CreateFileLines("mk/license.mk",
+		MkCvsID,
+		"DEFAULT_ACCEPTABLE_LICENSES=\tfree",
+		"DEFAULT_ACCEPTABLE_LICENSES+=\tgratis libre")
+
+	t.CheckDeepEquals(ck.loadDefaultLicenses(), map[string]bool{
+		"free":   true,
+		"gratis": true,
+		"libre":  true})
+}
+
+func (s *Suite) Test_isRunTimeDependency(c *check.C) {
+	t := s.Init(c)
+
+	test := func(kind string, buildOnly bool, expected bool) {
+		t.CheckEquals(isRunTimeDependency(&Dependency{Kind: kind, BuildOnly: buildOnly}), expected)
+	}
+
+	test("DEPENDS", false, true)
+	test("buildlink3", false, true)
+	test("buildlink3", true, false)
+	test("BUILD_DEPENDS", false, false)
+	test("TOOL_DEPENDS", false, false)
+	test("TEST_DEPENDS", false, false)
+}
@@ -0,0 +1,272 @@
+package pkglint
+
+import (
+	"github.com/rillig/pkglint/v23/ast"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Revbump bumps the PKGREVISION of the packages that depend on a
+// package, for example after the shared libraries of that package have
+// changed incompatibly, so that the dependent packages are rebuilt.
+//
+// The dependent packages are those that have the package in their
+// DEPENDS, and those that include its buildlink3.mk file, directly or
+// via other buildlink3.mk files.
+//
+// The dependent packages are searched in the whole tree. For the
+// packages that have been checked from the command line, the
+// dependencies from the check are used, the other packages are only
+// evaluated.
+//
+// All changes are made as autofixes, to be reviewed with --show-autofix
+// and applied with --autofix.
+type Revbump struct {
+	pkgpath PkgsrcPath
+
+	// abi is whether the BUILDLINK_ABI_DEPENDS of the package is
+	// updated as well, for --revbump-abi.
+	abi bool
+
+	// checked contains the dependencies and includes of the checked
+	// packages.
+	checked *InterPackage
+}
+
+func NewRevbump(pkgpath PkgsrcPath, abi bool, checked *InterPackage) *Revbump {
+	return &Revbump{pkgpath, abi, checked}
+}
+
+// Run bumps the PKGREVISION of each dependent package and, if requested,
+// updates the BUILDLINK_ABI_DEPENDS of the package.
+func (rb *Revbump) Run() {
+	if !G.Pkgsrc.File(rb.pkgpath.JoinNoClean("Makefile")).IsFile() {
+		G.Logger.TechFatalf(G.Pkgsrc.File(rb.pkgpath), "Not a package directory.")
+	}
+
+	if rb.abi {
+		rb.updateAbiDepends()
+	}
+	for _, dependent := range rb.dependents() {
+		rb.bump(dependent)
+	}
+}
+
+// dependents returns the packages that depend on the package,
+// sorted by package path.
+func (rb *Revbump) dependents() []PkgsrcPath {
+	seen := map[PkgsrcPath]bool{rb.pkgpath: true}
+	var dependents []PkgsrcPath
+	add := func(pkgpath PkgsrcPath) {
+		if !seen[pkgpath] {
+			seen[pkgpath] = true
+			dependents = append(dependents, pkgpath)
+		}
+	}
+
+	for _, pkgpath := range rb.checked.IncludersInTree(rb.pkgpath.JoinNoClean("buildlink3.mk")) {
+		add(pkgpath)
+	}
+	graph := rb.checked.depgraph
+	for _, pkgpath := range graph.Dependents(rb.pkgpath, "DEPENDS") {
+		add(pkgpath)
+	}
+	for _, pkgpath := range G.Pkgsrc.Packages() {
+		if graph.Contains(pkgpath) {
+			continue
+		}
+		for _, target := range G.Pkgsrc.Evaluate(pkgpath).Depends() {
+			if target == rb.pkgpath {
+				add(pkgpath)
+			}
+		}
+	}
+
+	sort.Slice(dependents, func(i, j int) bool { return dependents[i] < dependents[j] })
+	return dependents
+}
+
+// bump increments the PKGREVISION in the Makefile of the dependent
+// package, or adds it if there is none yet.
+func (rb *Revbump) bump(dependent PkgsrcPath) {
+	mklines := LoadMk(G.Pkgsrc.File(dependent.JoinNoClean("Makefile")), nil, NotEmpty|LogErrors)
+	if mklines == nil {
+		return
+	}
+
+	var revisions []*MkLine
+	conditional := false
+	mklines.ForEach(func(mkline *MkLine) {
+		if mkline.IsVarassign() && mkline.Varname() == "PKGREVISION" {
+			revisions = append(revisions, mkline)
+			conditional = conditional || mklines.indentation.IsConditional()
+		}
+	})
+
+	switch {
+	case len(revisions) == 1 && !conditional:
+		rb.increment(revisions[0])
+	case len(revisions) > 0:
+		rb.warnManual(revisions[0].Line)
+	case G.Pkgsrc.Evaluate(dependent).isDefined("PKGREVISION"):
+		// The PKGREVISION comes from a file that is shared with other
+		// packages, such as a Makefile.common.
+		rb.warnManual(NewLineWhole(mklines.lines.Filename))
+	default:
+		rb.add(mklines)
+	}
+	mklines.SaveAutofixChanges()
+}
+
+func (rb *Revbump) increment(mkline *MkLine) {
+	value := mkline.Value()
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		rb.warnManual(mkline.Line)
+		return
+	}
+
+	fix := mkline.Autofix()
+	fix.Notef("Bump the PKGREVISION to %d for the change to %s.", n+1, rb.pkgpath.String())
+	rb.replaceValue(fix, mkline, strconv.Itoa(n+1))
+	fix.Apply()
+}
+
+// replaceValue replaces the value of the variable assignment,
+// keeping the alignment and the comment.
+func (rb *Revbump) replaceValue(fix *Autofix, mkline *MkLine, value string) {
+	f, tree := mkline.Tree()
+	if assign, ok := tree.(*ast.MkAssignLine); ok {
+		ed := ast.NewFileEditor(f)
+		ed.Replace(assign.Value, ast.NewLiteral(value))
+		fix.Edit(ed)
+	}
+}
+
+func (rb *Revbump) warnManual(line *Line) {
+	line.Warnf("The PKGREVISION cannot be bumped automatically for the change to %s.",
+		rb.pkgpath.String())
+	line.Explain(
+		"Since this package depends on the changed package,",
+		"it needs to be rebuilt, which is triggered by bumping its PKGREVISION.",
+		"",
+		"Pkglint only bumps the PKGREVISION automatically if it is a number",
+		"that is defined unconditionally in the package Makefile,",
+		"or if it is not defined at all.",
+		"In the other cases, bump the PKGREVISION manually.")
+}
+
+// add adds the PKGREVISION in the place where the VarorderChecker
+// expects it.
+func (rb *Revbump) add(mklines *MkLines) {
+	mkline, below := rb.position(mklines)
+	if mkline == nil {
+		rb.warnManual(NewLineWhole(mklines.lines.Filename))
+		return
+	}
+
+	text := alignWith("PKGREVISION=", mkline.ValueAlign())
+	if text == "PKGREVISION=" {
+		text += "\t"
+	}
+	text += "1"
+
+	fix := mkline.Autofix()
+	fix.Notef("Add PKGREVISION=1 for the change to %s.", rb.pkgpath.String())
+	if below {
+		fix.InsertBelow(text)
+	} else {
+		fix.InsertAbove(text)
+	}
+	fix.Apply()
+}
+
+// position returns the line below which the PKGREVISION is added,
+// or the line above which it is added if below is false.
+//
+// The PKGREVISION is placed after the variables that precede it in
+// varorderVariables. If there are none, it is placed before the
+// first of the variables that follow it.
+func (rb *Revbump) position(mklines *MkLines) (mkline *MkLine, below bool) {
+	index := varorderIndex("PKGREVISION")
+
+	var before, after *MkLine
+	mklines.ForEach(func(ml *MkLine) {
+		if !ml.IsVarassign() || mklines.indentation.IsConditional() {
+			return
+		}
+		switch i := varorderIndex(ml.Varcanon()); {
+		case i < 0:
+			break
+		case i < index:
+			before = ml
+		case i > index && after == nil:
+			after = ml
+		}
+	})
+
+	if before != nil {
+		return before, true
+	}
+	return after, false
+}
+
+// updateAbiDepends updates the BUILDLINK_ABI_DEPENDS in the
+// buildlink3.mk file of the package to the current version of the
+// package, or adds it below the BUILDLINK_API_DEPENDS.
+func (rb *Revbump) updateAbiDepends() {
+	filename := G.Pkgsrc.File(rb.pkgpath.JoinNoClean("buildlink3.mk"))
+	if !filename.IsFile() {
+		return
+	}
+	pkgname := G.Pkgsrc.Pkgname(rb.pkgpath)
+	if pkgname == "" {
+		return
+	}
+	version := pkgname[strings.LastIndexByte(pkgname, '-')+1:]
+
+	mklines := LoadMk(filename, nil, NotEmpty|LogErrors)
+	if mklines == nil {
+		return
+	}
+
+	var api, abi *MkLine
+	mklines.ForEach(func(mkline *MkLine) {
+		if mkline.IsVarassign() {
+			switch mkline.Varcanon() {
+			case "BUILDLINK_API_DEPENDS.*":
+				api = mkline
+			case "BUILDLINK_ABI_DEPENDS.*":
+				abi = mkline
+			}
+		}
+	})
+
+	switch {
+	case abi != nil:
+		value := abi.Value()
+		i := strings.Index(value, ">=")
+		if i < 0 || value[i+2:] == version {
+			break
+		}
+		fix := abi.Autofix()
+		fix.Notef("Update the BUILDLINK_ABI_DEPENDS to version %s.", version)
+		rb.replaceValue(fix, abi, value[:i+2]+version)
+		fix.Apply()
+
+	case api != nil:
+		value := api.Value()
+		i := strings.Index(value, ">=")
+		if i < 0 {
+			break
+		}
+		align := api.ValueAlign()
+		text := strings.Replace(align, "_API_", "_ABI_", 1) + value[:i+2] + version
+		fix := api.Autofix()
+		fix.Notef("Add the BUILDLINK_ABI_DEPENDS for version %s.", version)
+		fix.InsertBelow(text)
+		fix.Apply()
+	}
+	mklines.SaveAutofixChanges()
+}
@@ -0,0 +1,356 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+// setUpRevbumpPackages creates the library category/lib and the packages
+// category/app, category/tool and category/indirect that depend on it.
+func setUpRevbumpPackages(t *Tester) {
+	t.SetUpPackage("category/lib",
+		"PKGREVISION=\t2")
+	t.SetUpPackage("category/app",
+		".include \"../../category/lib/buildlink3.mk\"")
+	t.SetUpPackage("category/tool",
+		"PKGREVISION=\t5",
+		"DEPENDS+=\tlib>=1.0:../../category/lib")
+	t.SetUpPackage("category/indirect",
+		"PKGREVISION=\t${TOOL_REVISION}",
+		"TOOL_REVISION=\t3",
+		".include \"../../category/app/buildlink3.mk\"")
+	t.SetUpPackage("category/other")
+	t.CreateFileBuildlink3("category/lib/buildlink3.mk")
+	t.CreateFileBuildlink3("category/app/buildlink3.mk",
+		".include \"../../category/lib/buildlink3.mk\"")
+}
+
+func (s *Suite) Test_NewRevbump(c *check.C) {
+	t := s.Init(c)
+
+	var checked InterPackage
+
+	rb := NewRevbump("category/lib", true, &checked)
+
+	t.CheckEquals(rb.pkgpath, NewPkgsrcPath("category/lib"))
+	t.CheckEquals(rb.abi, true)
+	t.CheckEquals(rb.checked, &checked)
+}
+
+func (s *Suite) Test_Revbump_Run(c *check.C) {
+	t := s.Init(c)
+
+	setUpRevbumpPackages(t)
+	t.CreateFileLines("Makefile",
+		MkCvsID,
+		"SUBDIR+=\tcategory")
+	t.CreateFileLines("mk/misc/category.mk")
+	t.Chdir(".")
+
+	t.Main("--revbump=category/lib", "--revbump-abi", "-q", "-r", ".")
+	t.Main("--revbump=category/lib", "--revbump-abi", "-q", "--autofix", "-r", ".")
+
+	t.CheckOutputLines(
+		"NOTE: category/lib/buildlink3.mk:8: "+
+			"Add the BUILDLINK_ABI_DEPENDS for version 1.0nb2.",
+		"NOTE: category/app/Makefile:3: "+
+			"Add PKGREVISION=1 for the change to category/lib.",
+		"WARN: category/indirect/Makefile:20: "+
+			"The PKGREVISION cannot be bumped automatically for the change to category/lib.",
+		"NOTE: category/tool/Makefile:20: "+
+			"Bump the PKGREVISION to 6 for the change to category/lib.",
+		"AUTOFIX: category/lib/buildlink3.mk:8: "+
+			"Inserting a line \"BUILDLINK_ABI_DEPENDS.lib+=\\tlib>=1.0nb2\" below this line.",
+		"AUTOFIX: category/app/Makefile:3: "+
+			"Inserting a line \"PKGREVISION=\\t1\" below this line.",
+		"AUTOFIX: category/tool/Makefile:20: "+
+			"Replacing \"5\" with \"6\".")
+	t.CheckFileLines("category/app/Makefile",
+		MkCvsID,
+		"",
+		"DISTNAME=\tapp-1.0",
+		"PKGREVISION=\t1",
+		"#PKGNAME=\tpackage-1.0",
+		"CATEGORIES=\tcategory",
+		"MASTER_SITES=\t# none",
+		"",
+		"MAINTAINER=\tpkgsrc-users@NetBSD.org",
+		"HOMEPAGE=\t# none",
+		"COMMENT=\tDummy package",
+		"LICENSE=\t2-clause-bsd",
+		"",
+		".include \"suppress-varorder.mk\"",
+		"",
+		"# filler",
+		"# filler",
+		"# filler",
+		"# filler",
+		"",
+		".include \"../../category/lib/buildlink3.mk\"",
+		"",
+		".include \"../../mk/bsd.pkg.mk\"")
+}
+
+func (s *Suite) Test_Revbump_Run__unchecked_packages(c *check.C) {
+	t := s.Init(c)
+
+	setUpRevbumpPackages(t)
+	t.Chdir(".")
+
+	// The dependent packages are found in the whole tree,
+	// even if they are not checked.
+	// The BUILDLINK_ABI_DEPENDS is left as is.
+	t.Main("--revbump=category/lib", "-q", "category/tool")
+
+	t.CheckOutputLines(
+		"NOTE: category/tool/../../category/app/Makefile:3: "+
+			"Add PKGREVISION=1 for the change to category/lib.",
+		"WARN: category/tool/../../category/indirect/Makefile:20: "+
+			"The PKGREVISION cannot be bumped automatically for the change to category/lib.",
+		"NOTE: category/tool/../../category/tool/Makefile:20: "+
+			"Bump the PKGREVISION to 6 for the change to category/lib.")
+}
+
+func (s *Suite) Test_Revbump_Run__not_a_package(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.Chdir(".")
+
+	t.Main("--revbump=category/nonexistent")
+
+	t.CheckOutputLines(
+		"FATAL: category/nonexistent: Not a package directory.")
+}
+
+func (s *Suite) Test_Revbump_dependents(c *check.C) {
+	t := s.Init(c)
+
+	setUpRevbumpPackages(t)
+	t.FinishSetUp()
+	t.Chdir(".")
+	checked := G.checkSeparately([]string{"pkglint", "--revbump=category/lib",
+		"category/lib", "category/app"},
+		NewDiagBaseline())
+
+	// The package category/indirect includes the buildlink3.mk file
+	// of category/lib via the one from category/app.
+	// The packages category/indirect and category/tool have not been
+	// checked, they are found in the tree.
+	t.CheckDeepEquals(NewRevbump("category/lib", false, &checked).dependents(), []PkgsrcPath{
+		"category/app",
+		"category/indirect",
+		"category/tool"})
+	t.CheckDeepEquals(NewRevbump("category/tool", false, &checked).dependents(), []PkgsrcPath(nil))
+}
+
+func (s *Suite) Test_Revbump_bump(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/conditional",
+		".if ${OPSYS} == NetBSD",
+		"PKGREVISION=\t1",
+		".endif")
+	t.SetUpPackage("category/common",
+		".include \"../../category/common/Makefile.common\"")
+	t.CreateFileLines("category/common/Makefile.common",
+		MkCvsID,
+		"PKGREVISION=\t1")
+	t.SetUpPackage("category/twice",
+		"PKGREVISION=\t1",
+		"PKGREVISION=\t2")
+	t.FinishSetUp()
+	rb := NewRevbump("category/lib", false, nil)
+
+	rb.bump("category/conditional")
+	rb.bump("category/common")
+	rb.bump("category/twice")
+
+	t.CheckOutputLines(
+		"WARN: ~/category/conditional/Makefile:21: "+
+			"The PKGREVISION cannot be bumped automatically for the change to category/lib.",
+		"WARN: ~/category/common/Makefile: "+
+			"The PKGREVISION cannot be bumped automatically for the change to category/lib.",
+		"WARN: ~/category/twice/Makefile:20: "+
+			"The PKGREVISION cannot be bumped automatically for the change to category/lib.")
+}
+
+func (s *Suite) Test_Revbump_increment(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mklines := t.NewMkLines("Makefile",
+		"PKGREVISION=\t9 # comment",
+		"PKGREVISION=\t-1")
+	rb := NewRevbump("category/lib", false, nil)
+
+	rb.increment(mklines.mklines[0])
+	rb.increment(mklines.mklines[1])
+
+	t.CheckOutputLines(
+		"NOTE: Makefile:1: Bump the PKGREVISION to 10 for the change to category/lib.",
+		"AUTOFIX: Makefile:1: Replacing \"9\" with \"10\".")
+}
+
+func (s *Suite) Test_Revbump_replaceValue(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	mklines := t.NewMkLines("Makefile",
+		"PKGREVISION=\t1 # 1")
+	mkline := mklines.mklines[0]
+	rb := NewRevbump("category/lib", false, nil)
+
+	fix := mkline.Autofix()
+	fix.Notef("Replace.")
+	rb.replaceValue(fix, mkline, "2")
+	fix.Apply()
+
+	// Only the value is replaced, not the same text from the comment.
+	t.CheckEquals(mkline.RawText(0), "PKGREVISION=\t2 # 1")
+	t.CheckOutputLines(
+		"NOTE: Makefile:1: Replace.",
+		"AUTOFIX: Makefile:1: Replacing \"1\" with \"2\".")
+}
+
+func (s *Suite) Test_Revbump_warnManual(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+	line := t.NewLine("Makefile", 20, "PKGREVISION=\t${REVISION}")
+
+	NewRevbump("category/lib", false, nil).warnManual(line)
+
+	t.CheckOutputLines(
+		"WARN: Makefile:20: The PKGREVISION cannot be bumped automatically "+
+			"for the change to category/lib.",
+		"",
+		"\tSince this package depends on the changed package, it needs to be",
+		"\trebuilt, which is triggered by bumping its PKGREVISION.",
+		"",
+		"\tPkglint only bumps the PKGREVISION automatically if it is a number",
+		"\tthat is defined unconditionally in the package Makefile, or if it is",
+		"\tnot defined at all. In the other cases, bump the PKGREVISION",
+		"\tmanually.",
+		"")
+}
+
+func (s *Suite) Test_Revbump_add(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	rb := NewRevbump("category/lib", false, nil)
+
+	test := func(lines []string, diagnostics ...string) {
+		rb.add(t.NewMkLines("Makefile", lines...))
+		t.CheckOutput(diagnostics)
+	}
+
+	test(
+		[]string{
+			"DISTNAME=\tpackage-1.0",
+			"PKGNAME=\tpkg-1.0",
+			"CATEGORIES=\tcategory"},
+		"NOTE: Makefile:2: Add PKGREVISION=1 for the change to category/lib.",
+		"AUTOFIX: Makefile:2: Inserting a line \"PKGREVISION=\\t1\" below this line.")
+
+	// The alignment of the value is taken from the neighboring line.
+	test(
+		[]string{
+			"CATEGORIES=\t\tcategory"},
+		"NOTE: Makefile:1: Add PKGREVISION=1 for the change to category/lib.",
+		"AUTOFIX: Makefile:1: Inserting a line \"PKGREVISION=\\t\\t1\" above this line.")
+
+	test(
+		[]string{
+			"DISTNAME= package-1.0"},
+		"NOTE: Makefile:1: Add PKGREVISION=1 for the change to category/lib.",
+		"AUTOFIX: Makefile:1: Inserting a line \"PKGREVISION=\\t1\" below this line.")
+
+	// Since --show-autofix only shows the fixable diagnostics,
+	// the warning is not shown.
+	test(
+		[]string{
+			"UNRELATED=\tvalue"},
+		nil...)
+}
+
+func (s *Suite) Test_Revbump_position(c *check.C) {
+	t := s.Init(c)
+
+	rb := NewRevbump("category/lib", false, nil)
+
+	test := func(lines []string, expectedLineno int, expectedBelow bool) {
+		mklines := t.NewMkLines("Makefile", lines...)
+		mkline, below := rb.position(mklines)
+		if expectedLineno == 0 {
+			t.CheckEquals(mkline, (*MkLine)(nil))
+		} else {
+			t.CheckEquals(mkline.Location.lineno, expectedLineno)
+		}
+		t.CheckEquals(below, expectedBelow)
+	}
+
+	test([]string{
+		"DISTNAME=\tpackage-1.0",
+		"PKGNAME=\tpkg-1.0",
+		"CATEGORIES=\tcategory"},
+		2, true)
+
+	test([]string{
+		"#PKGNAME=\tpkg-1.0",
+		"CATEGORIES=\tcategory",
+		"MASTER_SITES=\t# none"},
+		2, false)
+
+	// Conditional assignments are not considered.
+	test([]string{
+		".if 1",
+		"DISTNAME=\tpackage-1.0",
+		".endif",
+		"CATEGORIES=\tcategory"},
+		4, false)
+
+	test([]string{
+		"UNRELATED=\tvalue"},
+		0, false)
+}
+
+func (s *Suite) Test_Revbump_updateAbiDepends(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--show-autofix")
+	t.SetUpPackage("category/current",
+		"PKGREVISION=\t3")
+	t.SetUpPackage("category/outdated",
+		"PKGNAME=\tpy-outdated-2.0")
+	t.SetUpPackage("category/unknown",
+		"PKGNAME=\t${PYPKGPREFIX}-unknown-1.0")
+	t.SetUpPackage("category/no-bl3")
+	t.CreateFileBuildlink3("category/current/buildlink3.mk",
+		"BUILDLINK_ABI_DEPENDS.current+=\tcurrent>=1.0nb3")
+	t.CreateFileBuildlink3("category/outdated/buildlink3.mk",
+		"BUILDLINK_ABI_DEPENDS.outdated+=\t${PYPKGPREFIX}-outdated>=1.0")
+	t.CreateFileBuildlink3("category/unknown/buildlink3.mk")
+	t.FinishSetUp()
+
+	test := func(pkgpath PkgsrcPath, diagnostics ...string) {
+		NewRevbump(pkgpath, true, nil).updateAbiDepends()
+		t.CheckOutput(diagnostics)
+	}
+
+	test("category/current",
+		nil...)
+
+	// The part before the version is kept.
+	test("category/outdated",
+		"NOTE: ~/category/outdated/buildlink3.mk:12: "+
+			"Update the BUILDLINK_ABI_DEPENDS to version 2.0.",
+		"AUTOFIX: ~/category/outdated/buildlink3.mk:12: "+
+			"Replacing \"${PYPKGPREFIX}-outdated>=1.0\" with \"${PYPKGPREFIX}-outdated>=2.0\".")
+
+	// Since the PKGNAME of the package cannot be determined,
+	// the BUILDLINK_ABI_DEPENDS is left as is.
+	test("category/unknown",
+		nil...)
+
+	test("category/no-bl3",
+		nil...)
+}
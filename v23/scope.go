@@ -308,6 +308,16 @@ func (s *Scope) LastValueFound(varname string) (value string, found bool, indete
 	return v.fallback, v.fallback != "", v.indeterminate
 }
 
+// LastValueFields returns the value of the variable, split into
+// shell words, or nil if the variable is not defined.
+func (s *Scope) LastValueFields(varname string) []string {
+	mkline := s.LastDefinition(varname)
+	if mkline == nil {
+		return nil
+	}
+	return mkline.ValueFields(s.LastValue(varname))
+}
+
 func (s *Scope) DefineAll(other *Scope) {
 	for _, varname := range other.varnames() {
 		v := other.vs[varname]
@@ -378,6 +378,26 @@ func (s *Suite) Test_Scope_LastValueFound(c *check.C) {
 		"WARN: file.mk:2: Variable \"VAR\" is defined but not used.")
 }
 
+func (s *Suite) Test_Scope_LastValueFields(c *check.C) {
+	t := s.Init(c)
+
+	mklines := t.NewMkLines("file.mk",
+		MkCvsID,
+		"FILES_SUBST+=\tSHELL=${SH:Q}",
+		"FILES_SUBST+=\tMESSAGE=\"hello, world\"")
+
+	scope := NewScope()
+	mklines.ForEach(func(mkline *MkLine) {
+		if mkline.IsVarassign() {
+			scope.Define(mkline.Varname(), mkline)
+		}
+	})
+
+	t.CheckDeepEquals(scope.LastValueFields("FILES_SUBST"),
+		[]string{"SHELL=${SH:Q}", "MESSAGE=\"hello, world\""})
+	t.CheckNil(scope.LastValueFields("UNDEFINED"))
+}
+
 // Scope.DefineAll copies only the variable definitions,
 // but not the uses of variables.
 func (s *Suite) Test_Scope_DefineAll(c *check.C) {
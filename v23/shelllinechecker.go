@@ -17,11 +17,14 @@ type ShellLineChecker struct {
 	// checkExpr is set to false when checking a single shell word
 	// in order to skip duplicate warnings in variable assignments.
 	checkExpr bool
+
+	// The location of the checked shell word in the line, if known.
+	span *Span
 }
 
 func NewShellLineChecker(mklines *MkLines, mkline *MkLine) *ShellLineChecker {
 	assertNotNil(mklines)
-	return &ShellLineChecker{mklines, mkline, true}
+	return &ShellLineChecker{mklines, mkline, true, nil}
 }
 
 // CheckShellCommands checks for a list of shell commands, of which each one
@@ -65,6 +68,14 @@ func (ck *ShellLineChecker) CheckShellCommandLine(shelltext string) {
 		}
 	}
 
+	// The shell text of a shell command line starts after the tab.
+	at := func(start, end int) Diagnoser {
+		if ck.mkline.IsShellCommand() && ck.mkline.ShellCommand() == shelltext {
+			return line.At(Span{1 + start, 1 + end})
+		}
+		return line
+	}
+
 	lexer := textproc.NewLexer(shelltext)
 	lexer.NextHspace()
 	hiddenAndSuppress := lexer.NextBytesFunc(func(b byte) bool { return b == '-' || b == '@' })
@@ -73,15 +84,17 @@ func (ck *ShellLineChecker) CheckShellCommandLine(shelltext string) {
 	}
 	setE := lexer.SkipString("${RUN}")
 	if !setE {
+		start := lexer.Offset()
 		if lexer.NextString("${_PKG_SILENT}${_PKG_DEBUG}") != "" {
-			line.Errorf("Use of _PKG_SILENT and _PKG_DEBUG is obsolete. Use ${RUN} instead.")
+			at(start, lexer.Offset()).Errorf("Use of _PKG_SILENT and _PKG_DEBUG is obsolete. Use ${RUN} instead.")
 		}
 	}
 	lexer.SkipHspace()
 	lexer.SkipString("${_ULIMIT_CMD}") // It brings its own semicolon, just like ${RUN}.
 
-	if contains(lexer.Rest(), "${RUN}") {
-		line.Errorf("The expression \"${RUN}\" must only occur at the beginning of a shell command line.")
+	if index := strings.Index(lexer.Rest(), "${RUN}"); index != -1 {
+		start := lexer.Offset() + index
+		at(start, start+len("${RUN}")).Errorf("The expression \"${RUN}\" must only occur at the beginning of a shell command line.")
 		line.Explain(
 			"The expression ${RUN} expands to special instructions for make",
 			"that are only valid at the beginning of a shell command line,",
@@ -94,7 +107,7 @@ func (ck *ShellLineChecker) CheckShellCommandLine(shelltext string) {
 		fix := line.Autofix()
 		fix.Notef("A trailing semicolon at the end of a shell command line is redundant.")
 		if strings.Count(shelltext, ";") == 1 {
-			fix.Replace(";", "")
+			fix.ReplaceInTree(treeCode, ";", "")
 		}
 		fix.Apply()
 	}
@@ -170,8 +183,6 @@ func (ck *ShellLineChecker) CheckWord(token string, checkQuoting bool, time Tool
 		return
 	}
 
-	var line = ck.mkline.Line
-
 	// Delegate check for shell words consisting of a single expression
 	// to the MkLineChecker. Examples for these are ${VAR:Mpattern} or $@.
 	if expr := ToExpr(token); expr != nil {
@@ -183,11 +194,11 @@ func (ck *ShellLineChecker) CheckWord(token string, checkQuoting bool, time Tool
 	}
 
 	if matches(token, `\$\{PREFIX\}/man(?:$|/)`) {
-		line.Warnf("Use ${PKGMANDIR} instead of \"man\".")
+		ck.Warnf("Use ${PKGMANDIR} instead of \"man\".")
 	}
 
 	if G.Pkgsrc != nil && contains(token, "etc/rc.d") {
-		line.Warnf("Use the RCD_SCRIPTS mechanism to install rc.d scripts automatically to ${RCD_SCRIPTS_EXAMPLEDIR}.")
+		ck.Warnf("Use the RCD_SCRIPTS mechanism to install rc.d scripts automatically to ${RCD_SCRIPTS_EXAMPLEDIR}.")
 	}
 
 	ck.checkWordQuoting(token, checkQuoting, time)
@@ -652,6 +663,10 @@ func (ck *ShellLineChecker) warnMultiLineComment(rawIndex int, raw *RawLine) {
 }
 
 func (ck *ShellLineChecker) Warnf(format string, args ...interface{}) {
+	if ck.span != nil {
+		ck.mkline.At(*ck.span).Warnf(format, args...)
+		return
+	}
 	ck.mkline.Warnf(format, args...)
 }
 
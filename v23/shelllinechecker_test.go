@@ -181,6 +181,26 @@ func (s *Suite) Test_ShellLineChecker_CheckShellCommandLine(c *check.C) {
 }
 
 // TODO: Document in detail that strip is not a regular tool.
+func (s *Suite) Test_ShellLineChecker_CheckShellCommandLine__location(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--gcc-output-format")
+	t.SetUpTool("echo", "", AtRunTime)
+	mklines := t.NewMkLines("Makefile",
+		"\t${_PKG_SILENT}${_PKG_DEBUG}echo hello",
+		"\techo && ${RUN} echo")
+
+	for _, mkline := range mklines.mklines {
+		ck := NewShellLineChecker(mklines, mkline)
+		ck.CheckShellCommandLine(mkline.ShellCommand())
+	}
+
+	t.CheckOutputLines(
+		"Makefile:1:2: error: Use of _PKG_SILENT and _PKG_DEBUG is obsolete. Use ${RUN} instead.",
+		"Makefile:2:10: error: The expression \"${RUN}\" must only occur "+
+			"at the beginning of a shell command line.")
+}
+
 func (s *Suite) Test_ShellLineChecker_CheckShellCommandLine__strip(c *check.C) {
 	t := s.Init(c)
 
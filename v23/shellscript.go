@@ -0,0 +1,364 @@
+package pkglint
+
+import (
+	"bufio"
+	"strings"
+)
+
+// ShellScriptChecker checks the shell programs of a package that are
+// not embedded in makefiles, which are the INSTALL and DEINSTALL scripts
+// and the rc.d scripts from the files/ directory.
+//
+// Since these are plain shell programs, a single dollar sign starts
+// a shell variable, and the make variables are only available via
+// the @VAR@ placeholders from FILES_SUBST.
+type ShellScriptChecker struct {
+	lines *Lines
+	pkg   *Package
+
+	// Either "INSTALL", "DEINSTALL" or "rc.d".
+	kind string
+}
+
+func NewShellScriptChecker(lines *Lines, pkg *Package, kind string) *ShellScriptChecker {
+	return &ShellScriptChecker{lines, pkg, kind}
+}
+
+// CheckFileShellScript checks an INSTALL or DEINSTALL script,
+// or an rc.d script from the files/ directory.
+func CheckFileShellScript(filename CurrPath, pkg *Package) {
+	if trace.Tracing {
+		defer trace.Call(filename)()
+	}
+
+	lines := Load(filename, NotEmpty|LogErrors)
+	if lines == nil {
+		return
+	}
+
+	kind := "rc.d"
+	if basename := filename.Base(); basename == "INSTALL" || basename == "DEINSTALL" {
+		kind = basename.String()
+		CheckLinesTrailingEmptyLines(lines)
+	}
+	NewShellScriptChecker(lines, pkg, kind).Check()
+	SaveAutofixChanges(lines)
+}
+
+// Check runs the checks for the whole shell program.
+func (ck *ShellScriptChecker) Check() {
+	portability := NewPortabilityChecker(true)
+	for _, line := range ck.lines.Lines {
+		portability.CheckLine(line, 0)
+	}
+
+	if program := ck.parse(); program != nil {
+		ck.checkCommands(program)
+		if ck.kind == "rc.d" {
+			ck.checkRcd(program)
+		} else {
+			ck.checkStages(program)
+		}
+	}
+
+	ck.checkPlaceholders()
+}
+
+// parse parses the whole file as a shell program.
+// It returns nil if the program is too complicated for the parser,
+// which doesn't know all shell constructs yet.
+func (ck *ShellScriptChecker) parse() *MkShList {
+	var tokens []string
+	heredoc := ""
+	text := ""
+	for _, line := range ck.lines.Lines {
+		if heredoc != "" {
+			if strings.TrimLeft(line.Text, "\t") == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+
+		text += line.Text
+		if hasSuffix(text, "\\") {
+			text = text[:len(text)-1]
+			continue
+		}
+
+		// The shell tokenizer expects the text in makefile syntax,
+		// in which a single dollar sign starts a make expression.
+		tokenizer := NewShTokenizer(nil, strings.ReplaceAll(text, "$", "$$"))
+		text = ""
+		for {
+			token := tokenizer.ShToken()
+			if token == nil || token.Atoms[0].Type == shtComment {
+				break
+			}
+			if len(tokens) > 0 && matches(tokens[len(tokens)-1], `^\d*<<-?$`) {
+				heredoc = strings.Trim(token.MkText, "\"'\\")
+			}
+			tokens = append(tokens, token.MkText)
+		}
+		if rest := tokenizer.parser.Rest(); rest != "" && !hasPrefix(rest, "#") {
+			if trace.Tracing {
+				trace.Stepf("Cannot tokenize %q.", rest)
+			}
+			return nil
+		}
+		tokens = append(tokens, "\n")
+	}
+
+	lexer := NewShellLexer(tokens, "")
+	parser := shyyParserImpl{}
+	if parser.Parse(lexer) != 0 || lexer.error != "" {
+		if trace.Tracing {
+			trace.Stepf("Cannot parse the shell program near %q.", lexer.current)
+		}
+		return nil
+	}
+	return lexer.result
+}
+
+// checkCommands runs those checks for the shell commands in makefiles
+// that also apply to plain shell programs.
+//
+// The words are checked for their quoting, and the simple commands
+// for the sed and pax arguments. The other checks from
+// SimpleCommandChecker are about the tools from USE_TOOLS, the ${TOOL}
+// form of the commands and the install phase, which are only
+// available in makefiles.
+func (ck *ShellScriptChecker) checkCommands(program *MkShList) {
+	mklines := NewMkLines(NewLines(ck.lines.Filename, nil), ck.pkg, nil)
+
+	// The words come in the same order as in the file,
+	// therefore the search for their lines continues
+	// where the previous word has been found.
+	index := 0
+	mkline := func(word *ShToken) *MkLine {
+		text := strings.ReplaceAll(word.MkText, "$$", "$")
+		for i := index; i < len(ck.lines.Lines); i++ {
+			if contains(ck.lines.Lines[i].Text, text) {
+				index = i
+				break
+			}
+		}
+		line := ck.lines.Lines[index]
+		return &MkLine{line, mkLineSplitResult{}, mkLineShell{line.Text}}
+	}
+
+	walker := NewMkShWalker()
+	walker.Callback.SimpleCommand = func(command *MkShSimpleCommand) {
+		if command.Name == nil {
+			return
+		}
+		scc := NewSimpleCommandChecker(command, RunTime, mkline(command.Name), mklines)
+		scc.checkRegexReplace()
+		scc.checkPaxPe()
+	}
+	walker.Callback.Word = func(word *ShToken) {
+		// The commands in backticks would be checked like the
+		// commands in makefiles, which doesn't fit these scripts.
+		if contains(word.MkText, "`") {
+			return
+		}
+		slc := NewShellLineChecker(mklines, mkline(word))
+		slc.checkWordQuoting(word.MkText, false, RunTime)
+	}
+	walker.Walk(program)
+}
+
+// checkRcd checks that the rc.d script defines the variables
+// that rc.subr needs and finally calls run_rc_command.
+func (ck *ShellScriptChecker) checkRcd(program *MkShList) {
+	assigned := make(map[string]bool)
+	called := false
+
+	walker := NewMkShWalker()
+	walker.Callback.SimpleCommand = func(command *MkShSimpleCommand) {
+		for _, assignment := range command.Assignments {
+			assigned[strings.SplitN(assignment.MkText, "=", 2)[0]] = true
+		}
+		if command.Name != nil && command.Name.MkText == "run_rc_command" {
+			called = true
+		}
+	}
+	walker.Walk(program)
+
+	explain := func(line *Line) {
+		line.Explain(
+			"An rc.d script defines the variables \"name\" and \"rcvar\",",
+			"as well as the \"command\" that starts the service,",
+			"or the \"start_cmd\" for services that are not a single program.",
+			"After that, it calls run_rc_command from rc.subr,",
+			"which does the actual work.",
+			"",
+			"See rc.subr(8) for the details.")
+	}
+
+	line := ck.lines.Whole()
+	for _, varname := range [...]string{"name", "rcvar", "command"} {
+		if !assigned[varname] && !(varname == "command" && assigned["start_cmd"]) {
+			line.Warnf("The rc.d script should define the variable %q.", varname)
+			explain(line)
+		}
+	}
+	if !called {
+		line.Warnf("The rc.d script should call run_rc_command.")
+		explain(line)
+	}
+}
+
+// checkStages checks that the INSTALL or DEINSTALL script distinguishes
+// the stages in which it is run.
+func (ck *ShellScriptChecker) checkStages(program *MkShList) {
+	stages := map[string][]string{
+		"INSTALL":   {"PRE-INSTALL", "POST-INSTALL"},
+		"DEINSTALL": {"DEINSTALL", "POST-DEINSTALL"},
+	}[ck.kind]
+
+	seen := false
+	walker := NewMkShWalker()
+	walker.Callback.Case = func(caseClause *MkShCase) {
+		word := strings.Trim(strings.ReplaceAll(caseClause.Word.MkText, "$$", "$"), "\"")
+		if word != "${STAGE}" && word != "$STAGE" {
+			return
+		}
+		seen = true
+
+		for _, item := range caseClause.Cases {
+			for _, pattern := range item.Patterns {
+				stage := strings.Trim(pattern.MkText, "\"'")
+				if stage != "*" && !containsStr(stages, stage) {
+					ck.warnUnknownStage(stage, stages)
+				}
+			}
+		}
+	}
+	walker.Callback.Word = func(word *ShToken) {
+		if contains(word.MkText, "STAGE") {
+			seen = true
+		}
+	}
+	walker.Walk(program)
+
+	if !seen {
+		line := ck.lines.Whole()
+		line.Warnf("The %s script should distinguish the %s and %s stages.",
+			ck.kind, stages[0], stages[1])
+		line.Explain(
+			sprintf("The %s script is run several times,", ck.kind),
+			"and the variable STAGE says which stage is currently run.",
+			"",
+			"A typical script looks like this:",
+			"",
+			"\tcase ${STAGE} in",
+			sprintf("\t%s)", stages[0]),
+			"\t\t...",
+			"\t\t;;",
+			sprintf("\t%s)", stages[1]),
+			"\t\t...",
+			"\t\t;;",
+			"\tesac")
+	}
+}
+
+func (ck *ShellScriptChecker) warnUnknownStage(stage string, stages []string) {
+	line := ck.lines.Whole()
+	for _, l := range ck.lines.Lines {
+		if contains(l.Text, stage) {
+			line = l
+			break
+		}
+	}
+	line.Warnf("The %s script is not run in the stage %q, only in %s and %s.",
+		ck.kind, stage, stages[0], stages[1])
+}
+
+// checkPlaceholders checks that the @VAR@ placeholders are
+// substituted via FILES_SUBST.
+func (ck *ShellScriptChecker) checkPlaceholders() {
+	if ck.pkg == nil {
+		return
+	}
+
+	substituted := make(map[string]bool)
+	for _, field := range ck.pkg.vars.LastValueFields("FILES_SUBST") {
+		varname := strings.SplitN(field, "=", 2)[0]
+		if containsExpr(varname) || !contains(field, "=") {
+			return
+		}
+		substituted[varname] = true
+	}
+
+	for _, line := range ck.lines.Lines {
+		for _, m := range regcomp(`@(\w+)@`).FindAllStringSubmatch(line.Text, -1) {
+			varname := m[1]
+			if substituted[varname] || shellScriptInfraSubst[varname] {
+				continue
+			}
+			line.Warnf("The placeholder @%s@ is not replaced since %s is not in FILES_SUBST.",
+				varname, varname)
+			line.Explain(
+				"The @VAR@ placeholders in the INSTALL, DEINSTALL and rc.d scripts",
+				"are only replaced for the variables from FILES_SUBST.",
+				"Some of them, such as PREFIX or ECHO, are added by the",
+				"pkgsrc infrastructure, the others must be added by the package:",
+				"",
+				sprintf("\tFILES_SUBST+=\t%s=${%s:Q}", varname, varname))
+			substituted[varname] = true
+		}
+	}
+}
+
+// shellScriptInfraSubst contains the variables that the pkgsrc
+// infrastructure adds to FILES_SUBST, in mk/pkginstall/bsd.pkginstall.mk
+// and mk/pkginstall/bsd.rcd.mk.
+var shellScriptInfraSubst = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, varname := range strings.Fields("" +
+		"PREFIX LOCALBASE X11BASE DEPOTBASE VARBASE " +
+		"PKGBASE PKGNAME PKGVERSION PKGMANDIR " +
+		"PKG_SYSCONFBASE PKG_SYSCONFBASEDIR PKG_SYSCONFDIR " +
+		"PKG_SYSCONFDEPOTDIR PKG_SYSCONFVIEWDIR " +
+		"PKG_INSTALLATION_TYPE CONF_DEPENDS " +
+		"PKG_REGISTER_SHELLS PKG_UPDATE_FONTS_DB " +
+		"RCD_SCRIPTS_SHELL ROOT_USER ROOT_GROUP " +
+		"AWK BASENAME CAT CHGRP CHMOD CHOWN CMP CP DIRNAME " +
+		"ECHO ECHO_N EGREP EXPR FALSE FIND GREP GROUPADD GTAR " +
+		"HEAD ID LINKFARM LN LS MKDIR MV PERL5 PKG_ADMIN PKG_INFO " +
+		"PWD_CMD RM RMDIR SED SETENV SH SORT SU TEST TOUCH TR TRUE " +
+		"USERADD XARGS") {
+		m[varname] = true
+	}
+	return m
+}()
+
+// isRcdScript returns whether the file from the files/ directory
+// is an rc.d script, either because it is listed in RCD_SCRIPTS
+// or because it has the PROVIDE keyword of rcorder(8).
+//
+// Like rcorder, only the comments at the top of the file are read.
+func isRcdScript(filename CurrPath, pkg *Package) bool {
+	name := strings.TrimSuffix(filename.Base().String(), ".sh")
+	if pkg != nil && containsStr(pkg.vars.LastValueFields("RCD_SCRIPTS"), name) {
+		return true
+	}
+
+	f, err := filename.Open()
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if hasPrefix(text, "# PROVIDE:") {
+			return true
+		}
+		if text != "" && !hasPrefix(text, "#") {
+			break
+		}
+	}
+	return false
+}
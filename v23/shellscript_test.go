@@ -0,0 +1,326 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_NewShellScriptChecker(c *check.C) {
+	t := s.Init(c)
+
+	lines := t.NewLines("INSTALL",
+		"# $"+"NetBSD$")
+
+	ck := NewShellScriptChecker(lines, nil, "INSTALL")
+
+	t.CheckEquals(ck.lines, lines)
+	t.CheckEquals(ck.kind, "INSTALL")
+}
+
+func (s *Suite) Test_CheckFileShellScript(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"RCD_SCRIPTS=\tdaemon",
+		"FILES_SUBST+=\tDAEMON_USER=daemon")
+	t.CreateFileLines("category/package/files/daemon.sh",
+		"#!@RCD_SCRIPTS_SHELL@",
+		"#",
+		"# PROVIDE: daemon",
+		"# REQUIRE: DAEMON",
+		"",
+		"$_rc_subr_loaded . /etc/rc.subr",
+		"",
+		"name=\"daemon\"",
+		"rcvar=$name",
+		"command=\"@PREFIX@/sbin/${name}\"",
+		"command_args=\"-u @DAEMON_USER@ -g @DAEMON_GROUP@\"",
+		"",
+		"load_rc_config $name",
+		"run_rc_command \"$1\"")
+	t.CreateFileLines("category/package/INSTALL",
+		"# $"+"NetBSD$",
+		"",
+		"case ${STAGE} in",
+		"POST-INSTALL)",
+		"\tif [ \"$1\" == \"\" ]; then",
+		"\t\t${CHMOD} 0700 @VARBASE@/db/daemon",
+		"\tfi",
+		"\t;;",
+		"esac",
+		"")
+	t.Chdir("category/package")
+	t.FinishSetUp()
+
+	G.Check(".")
+
+	t.CheckOutputLines(
+		"NOTE: INSTALL:10: Trailing empty lines.",
+		"ERROR: INSTALL:5: The \"==\" operator in test expressions is not portable.",
+		"WARN: files/daemon.sh:11: The placeholder @DAEMON_GROUP@ is not replaced "+
+			"since DAEMON_GROUP is not in FILES_SUBST.")
+}
+
+func (s *Suite) Test_ShellScriptChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	lines := t.NewLines("DEINSTALL",
+		"# $"+"NetBSD$",
+		"",
+		"${RM} -f $RANDOM")
+
+	NewShellScriptChecker(lines, nil, "DEINSTALL").Check()
+
+	t.CheckOutputLines(
+		"WARN: DEINSTALL:3: The variable $RANDOM is not portable.",
+		"WARN: DEINSTALL: The DEINSTALL script should distinguish "+
+			"the DEINSTALL and POST-DEINSTALL stages.")
+}
+
+func (s *Suite) Test_ShellScriptChecker_parse(c *check.C) {
+	t := s.Init(c)
+
+	test := func(ok bool, texts ...string) {
+		lines := t.NewLines("script.sh", texts...)
+		program := NewShellScriptChecker(lines, nil, "rc.d").parse()
+		t.CheckEquals(program != nil, ok)
+	}
+
+	test(true,
+		"#! /bin/sh",
+		"name=\"daemon\" # The name of the service.",
+		"daemon_prestart() {",
+		"\tmkdir -p /var/run/daemon \\",
+		"\t\t/var/log/daemon",
+		"}",
+		"cat <<EOF > file",
+		"case $x in (*) esac",
+		"EOF",
+		"cat <<-'EOF'",
+		"\t((((",
+		"\tEOF")
+
+	// The string is not finished in the same line.
+	test(false,
+		"echo 'multi",
+		"line'")
+
+	test(false,
+		"if true; then")
+}
+
+func (s *Suite) Test_ShellScriptChecker_checkCommands(c *check.C) {
+	t := s.Init(c)
+
+	lines := t.NewLines("DEINSTALL",
+		"# $"+"NetBSD$",
+		"",
+		"set -- $@",
+		"echo \"$@\" $?",
+		"pid=`cat $@`",
+		"sed -e s,.*,, \\",
+		"\t-e 's,from,to,' file",
+		"pax -rw -pe . /dst")
+	ck := NewShellScriptChecker(lines, nil, "DEINSTALL")
+
+	ck.checkCommands(ck.parse())
+
+	// The commands in backticks are not checked.
+	t.CheckOutputLines(
+		"WARN: DEINSTALL:3: The $@ shell variable should only be used in double quotes.",
+		"WARN: DEINSTALL:4: The $? shell variable is often not available in \"set -e\" mode.",
+		"WARN: DEINSTALL:6: Substitution commands like \"s,.*,,\" should always be quoted.",
+		"WARN: DEINSTALL:8: Use the -pp option to pax(1) instead of -pe.")
+}
+
+func (s *Suite) Test_ShellScriptChecker_checkRcd(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+
+	test := func(texts []string, diagnostics ...string) {
+		lines := t.NewLines("daemon.sh", texts...)
+		ck := NewShellScriptChecker(lines, nil, "rc.d")
+		ck.checkRcd(ck.parse())
+		t.CheckOutput(diagnostics)
+	}
+
+	test([]string{
+		"name=daemon",
+		"rcvar=$name",
+		"start_cmd=daemon_start",
+		"daemon_start() { :; }",
+		"run_rc_command \"$1\""},
+		nil...)
+
+	test([]string{
+		"name=daemon",
+		"command=@PREFIX@/sbin/daemon",
+		"if [ -f /etc/rc.subr ]; then",
+		"\tload_rc_config $name",
+		"\trun_rc_command \"$1\"",
+		"fi"},
+		"WARN: daemon.sh: The rc.d script should define the variable \"rcvar\".",
+		"",
+		"\tAn rc.d script defines the variables \"name\" and \"rcvar\", as well as",
+		"\tthe \"command\" that starts the service, or the \"start_cmd\" for",
+		"\tservices that are not a single program. After that, it calls",
+		"\trun_rc_command from rc.subr, which does the actual work.",
+		"",
+		"\tSee rc.subr(8) for the details.",
+		"")
+
+	test([]string{
+		"name=daemon",
+		"rcvar=$name"},
+		"WARN: daemon.sh: The rc.d script should define the variable \"command\".",
+		"WARN: daemon.sh: The rc.d script should call run_rc_command.")
+}
+
+func (s *Suite) Test_ShellScriptChecker_checkStages(c *check.C) {
+	t := s.Init(c)
+
+	test := func(kind string, texts []string, diagnostics ...string) {
+		lines := t.NewLines(NewCurrPathString(kind), texts...)
+		ck := NewShellScriptChecker(lines, nil, kind)
+		ck.checkStages(ck.parse())
+		t.CheckOutput(diagnostics)
+	}
+
+	test("INSTALL",
+		[]string{
+			"case \"${STAGE}\" in",
+			"PRE-INSTALL|POST-INSTALL)",
+			"\t;;",
+			"*)",
+			"\t;;",
+			"esac"},
+		nil...)
+
+	test("INSTALL",
+		[]string{
+			"if [ \"$STAGE\" = POST-INSTALL ]; then",
+			"\t:",
+			"fi"},
+		nil...)
+
+	test("DEINSTALL",
+		[]string{
+			"case $STAGE in",
+			"DEINSTALL)",
+			"\t;;",
+			"POST-INSTALL)",
+			"\t;;",
+			"esac"},
+		"WARN: DEINSTALL:4: The DEINSTALL script is not run in the stage \"POST-INSTALL\", "+
+			"only in DEINSTALL and POST-DEINSTALL.")
+
+	// A case statement on another variable does not distinguish the stages.
+	test("INSTALL",
+		[]string{
+			"case $1 in",
+			"PRE_INSTALL)",
+			"\t;;",
+			"esac"},
+		"WARN: INSTALL: The INSTALL script should distinguish "+
+			"the PRE-INSTALL and POST-INSTALL stages.")
+}
+
+func (s *Suite) Test_ShellScriptChecker_warnUnknownStage(c *check.C) {
+	t := s.Init(c)
+
+	lines := t.NewLines("INSTALL",
+		"case ${STAGE} in",
+		"${UNKNOWN}) ;;",
+		"esac")
+	ck := NewShellScriptChecker(lines, nil, "INSTALL")
+
+	ck.warnUnknownStage("PRE_INSTALL", []string{"PRE-INSTALL", "POST-INSTALL"})
+
+	// Since the stage doesn't appear literally in the file,
+	// the warning refers to the whole file.
+	t.CheckOutputLines(
+		"WARN: INSTALL: The INSTALL script is not run in the stage \"PRE_INSTALL\", " +
+			"only in PRE-INSTALL and POST-INSTALL.")
+}
+
+func (s *Suite) Test_ShellScriptChecker_checkPlaceholders(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("--explain")
+	t.SetUpPackage("category/package",
+		"FILES_SUBST+=\tDAEMON_USER=${DAEMON_USER:Q}")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+	pkg.load()
+	lines := t.NewLines("INSTALL",
+		"${MKDIR} @VARBASE@/db/@DAEMON_DIR@",
+		"${CHOWN} @DAEMON_USER@ @VARBASE@/db/@DAEMON_DIR@ user@example.org")
+
+	NewShellScriptChecker(lines, pkg, "INSTALL").checkPlaceholders()
+
+	t.CheckOutputLines(
+		"WARN: INSTALL:1: The placeholder @DAEMON_DIR@ is not replaced "+
+			"since DAEMON_DIR is not in FILES_SUBST.",
+		"",
+		"\tThe @VAR@ placeholders in the INSTALL, DEINSTALL and rc.d scripts",
+		"\tare only replaced for the variables from FILES_SUBST. Some of them,",
+		"\tsuch as PREFIX or ECHO, are added by the pkgsrc infrastructure, the",
+		"\tothers must be added by the package:",
+		"",
+		"\t\tFILES_SUBST+=\tDAEMON_DIR=${DAEMON_DIR:Q}",
+		"")
+}
+
+func (s *Suite) Test_ShellScriptChecker_checkPlaceholders__indeterminate(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"FILES_SUBST+=\t${MY_FILES_SUBST}")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+	pkg.load()
+	lines := t.NewLines("INSTALL",
+		"${MKDIR} @DAEMON_DIR@")
+
+	NewShellScriptChecker(lines, pkg, "INSTALL").checkPlaceholders()
+
+	// Since the package adds arbitrary variables to FILES_SUBST,
+	// any placeholder may be replaced.
+	t.CheckOutputEmpty()
+
+	NewShellScriptChecker(lines, nil, "INSTALL").checkPlaceholders()
+
+	t.CheckOutputEmpty()
+}
+
+func (s *Suite) Test_isRcdScript(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"RCD_SCRIPTS=\tdaemon")
+	t.CreateFileLines("category/package/files/daemon.sh",
+		"#! /bin/sh")
+	t.CreateFileLines("category/package/files/other.sh",
+		"#!@RCD_SCRIPTS_SHELL@",
+		"#",
+		"# PROVIDE: other")
+	t.CreateFileLines("category/package/files/script.sh",
+		"#! /bin/sh")
+	t.CreateFileLines("category/package/files/late.sh",
+		"#! /bin/sh",
+		"",
+		"echo '# PROVIDE: late'",
+		"# PROVIDE: late")
+	t.FinishSetUp()
+	pkg := NewPackage(t.File("category/package"))
+	pkg.load()
+
+	test := func(filename RelPath, pkg *Package, expected bool) {
+		t.CheckEquals(isRcdScript(t.File(filename), pkg), expected)
+	}
+
+	test("category/package/files/daemon.sh", pkg, true)
+	test("category/package/files/daemon.sh", nil, false)
+	test("category/package/files/other.sh", nil, true)
+	test("category/package/files/script.sh", pkg, false)
+	test("category/package/files/late.sh", nil, false)
+	test("category/package/files/missing.sh", nil, false)
+}
@@ -454,7 +454,9 @@ func (p *ShTokenizer) ShToken() *ShToken {
 	}
 
 	if !curr.Type.IsWord() && q != shqSubsh {
-		return NewShToken(curr.MkText, curr)
+		token := NewShToken(curr.MkText, curr)
+		token.Offset = lexer.Offset() - len(curr.MkText)
+		return token
 	}
 
 	var atoms []*ShAtom
@@ -474,7 +476,9 @@ func (p *ShTokenizer) ShToken() *ShToken {
 		return nil
 	}
 
-	return NewShToken(lexer.Since(initialMark), atoms...)
+	token := NewShToken(lexer.Since(initialMark), atoms...)
+	token.Offset = lexer.MarkOffset(initialMark)
+	return token
 }
 
 func (p *ShTokenizer) Rest() string {
@@ -730,3 +730,17 @@ func (s *Suite) Test_ShTokenizer_ShToken(c *check.C) {
 	test("id=`${AWK} '{print}' < ${WRKSRC}/idfile`",
 		"id=`${AWK} '{print}' < ${WRKSRC}/idfile`")
 }
+
+func (s *Suite) Test_ShTokenizer_ShToken__offset(c *check.C) {
+	t := s.Init(c)
+
+	line := t.NewLine("filename.mk", 1, "")
+	p := NewShTokenizer(line, "echo  'a b' ;${TRUE}")
+
+	var offsets []int
+	for token := p.ShToken(); token != nil; token = p.ShToken() {
+		offsets = append(offsets, token.Offset)
+	}
+
+	t.CheckDeepEquals(offsets, []int{0, 6, 12, 13})
+}
@@ -131,12 +131,13 @@ func (q ShQuoting) ToExprContext() EctxQuoting {
 type ShToken struct {
 	MkText string // The text as it appeared in the makefile, after replacing `\#` with `#`
 	Atoms  []*ShAtom
+	Offset int // The byte offset in the text that has been tokenized
 }
 
 func NewShToken(mkText string, atoms ...*ShAtom) *ShToken {
 	assert(mkText != "")
 	assert(len(atoms) > 0)
-	return &ShToken{mkText, atoms}
+	return &ShToken{mkText, atoms, 0}
 }
 
 func (token *ShToken) String() string {
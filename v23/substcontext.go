@@ -455,8 +455,8 @@ func (b *substBlock) varassignStage(mkline *MkLine, pkg *Package) {
 			"",
 			"Instead of pre-patch, use post-extract.",
 			"Instead of post-patch, use pre-configure.")
-		fix.Replace("pre-patch", "post-extract")
-		fix.Replace("post-patch", "pre-configure")
+		fix.ReplaceInTree(treeValue, value,
+			condStr(value == "pre-patch", "post-extract", "pre-configure"))
 		fix.Apply()
 	}
 
@@ -533,7 +533,7 @@ func (b *substBlock) suggestSubstVars(mkline *MkLine) {
 			"Replacing @VAR@ with ${VAR} is such a typical pattern that pkgsrc has built-in support for it,",
 			"requiring only the variable name instead of the full sed command.")
 		if !mkline.HasComment() && len(tokens) == 2 && tokens[0] == "-e" {
-			fix.Replace(mkline.Text, alignWith(varop, mkline.ValueAlign())+varname)
+			fix.ReplaceInTree(treeLine, mkline.Text, alignWith(varop, mkline.ValueAlign())+varname)
 		}
 		fix.Apply()
 
@@ -572,7 +572,7 @@ func (b *substBlock) fixOperatorAppend(mkline *MkLine, dueToAutofix bool) {
 		fix.Warnf("All but the first assignment to %q should use the \"+=\" operator.",
 			mkline.Varname())
 	}
-	fix.Replace(before, after)
+	fix.ReplaceInTree(treeLine, before, after)
 	fix.Apply()
 }
 
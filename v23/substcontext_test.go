@@ -1216,11 +1216,9 @@ func (s *Suite) Test_substBlock_varassignStage__empty_class(c *check.C) {
 		"ERROR: filename.mk:7: Invalid SUBST class \"\" in variable name.")
 }
 
-// As of December 2019, pkglint does not use token positions internally.
-// Instead it only does simple string replacement when autofixing things.
-// To avoid damaging anything, replacements are only done if they are
-// unambiguous. This is not the case here, since line 4 contains the
-// string "pre-patch" twice.
+// Line 2 contains the string "pre-patch" twice.
+// Since the autofix edits the syntax tree of the line,
+// only the value is replaced, not the SUBST class.
 func (s *Suite) Test_substBlock_varassignStage__ambiguous_replacement(c *check.C) {
 	t := s.Init(c)
 
@@ -1234,9 +1232,14 @@ func (s *Suite) Test_substBlock_varassignStage__ambiguous_replacement(c *check.C
 
 	t.ExpectDiagnosticsAutofix(
 		doTest,
-		"WARN: filename.mk:2: Substitutions should not happen in the patch phase.")
+		"WARN: filename.mk:2: Substitutions should not happen in the patch phase.",
+		"AUTOFIX: filename.mk:2: Replacing \"pre-patch\" with \"post-extract\".")
 
-	t.CheckEquals(t.File("filename.mk").IsFile(), false)
+	t.CheckFileLines("filename.mk",
+		"SUBST_CLASSES+=         pre-patch",
+		"SUBST_STAGE.pre-patch=  post-extract",
+		"SUBST_FILES.pre-patch=  files",
+		"SUBST_VARS.pre-patch=   VARNAME")
 }
 
 func (s *Suite) Test_substBlock_varassignStage__with_NO_CONFIGURE(c *check.C) {
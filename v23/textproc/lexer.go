@@ -18,6 +18,7 @@ import (
 // They are typically used in switch statements, which don't allow variable declarations.
 type Lexer struct {
 	rest string
+	size int // the length of the whole text, for computing offsets
 }
 
 // LexerMark remembers a position in the string being parsed, to be able
@@ -35,12 +36,15 @@ type ByteSet struct {
 }
 
 func NewLexer(text string) *Lexer {
-	return &Lexer{text}
+	return &Lexer{text, len(text)}
 }
 
 // Rest returns the part of the string that has not yet been chopped off.
 func (l *Lexer) Rest() string { return l.rest }
 
+// Offset returns the number of bytes that have already been chopped off.
+func (l *Lexer) Offset() int { return l.size - len(l.rest) }
+
 // EOF returns whether the whole input has been consumed.
 func (l *Lexer) EOF() bool { return l.rest == "" }
 
@@ -230,10 +234,14 @@ func (l *Lexer) Since(mark LexerMark) string {
 	return string(mark)[0 : len(mark)-len(l.rest)]
 }
 
+// MarkOffset returns the offset of the position where the given mark
+// was taken.
+func (l *Lexer) MarkOffset(mark LexerMark) int { return l.size - len(mark) }
+
 // Copy returns a copy of this lexer.
 // It can be used to try one path of parsing and then either discard the
 // result or commit it back by calling Commit.
-func (l *Lexer) Copy() *Lexer { return &Lexer{l.rest} }
+func (l *Lexer) Copy() *Lexer { return &Lexer{l.rest, l.size} }
 
 // Commit copies the state of the other lexer into this lexer.
 // It always returns true so that it can be used in conditions.
@@ -36,6 +36,17 @@ func (s *Suite) Test_Lexer_Rest__end(c *check.C) {
 	c.Check(lexer.Rest(), equals, "")
 }
 
+func (s *Suite) Test_Lexer_Offset(c *check.C) {
+	lexer := NewLexer("text")
+
+	c.Check(lexer.Offset(), equals, 0)
+
+	lexer.Skip(3)
+
+	c.Check(lexer.Offset(), equals, 3)
+	c.Check(lexer.Copy().Offset(), equals, 3)
+}
+
 func (s *Suite) Test_Lexer_EOF__initial(c *check.C) {
 	lexer := NewLexer("text")
 
@@ -318,6 +329,16 @@ func (s *Suite) Test_Lexer_Since(c *check.C) {
 	c.Check(lexer.Since(mark), equals, "text")
 }
 
+func (s *Suite) Test_Lexer_MarkOffset(c *check.C) {
+	lexer := NewLexer("text")
+	lexer.Skip(1)
+	mark := lexer.Mark()
+	lexer.Skip(2)
+
+	c.Check(lexer.MarkOffset(mark), equals, 1)
+	c.Check(lexer.Offset(), equals, 3)
+}
+
 func (s *Suite) Test_Lexer_Copy(c *check.C) {
 	lexer := NewLexer("text")
 	copied := lexer.Copy()
@@ -29,6 +29,9 @@ func CheckdirToplevel(dir CurrPath) {
 
 	if G.Recursive {
 		G.InterPackage.Enable()
+		if G.InterPackage.conflicts != nil {
+			G.InterPackage.conflicts.fullTree = true
+		}
 		G.Todo.PushFront(ctx.subdirs...)
 	}
 }
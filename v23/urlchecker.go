@@ -107,7 +107,7 @@ func (ck *UrlChecker) CheckURL(url string) {
 			prefix := host[:len(host)-len(".NetBSD.org")]
 			fix := ck.mkline.Autofix()
 			fix.Warnf("Write NetBSD.org instead of %s.", host)
-			fix.Replace(host, prefix+".NetBSD.org")
+			fix.ReplaceInTree(treeValue, host, prefix+".NetBSD.org")
 			fix.Apply()
 		}
 
@@ -20,6 +20,7 @@ func (s *Suite) Test_NewUrlChecker(c *check.C) {
 		"",
 		"",
 		false,
+		nil,
 	})
 
 	t.CheckEquals(ck.varname, "MASTER_SITES")
@@ -1095,6 +1095,8 @@ func (reg *VarTypeRegistry) Init(src *Pkgsrc) {
 		"builtin.mk: set")
 	reg.sys("BUILTIN_X11_TYPE", BtUnknown)
 	reg.sys("BUILTIN_X11_VERSION", BtUnknown)
+	reg.pkglist("CARGO_CRATE_DEPENDS", BtFilename)
+	reg.pkg("CARGO_WRKSRC", BtPathname)
 	reg.DefineName("CATEGORIES", BtCategory, List|PackageSettable|Unique, "pkglist")
 	reg.sysloadbl3("CC_VERSION", BtMessage, DefinedIfInScope|NonemptyIfDefined)
 	reg.sysloadbl3("CC", BtShellCommand)
@@ -1130,6 +1132,7 @@ func (reg *VarTypeRegistry) Init(src *Pkgsrc) {
 	reg.pkg("CMAKE_ARG_PATH", BtPathname)
 	reg.pkglist("CMAKE_ARGS", BtShellWord)
 	reg.pkglist("CMAKE_ARGS.*", BtShellWord)
+	reg.pkglist("CMAKE_CONFIGURE_ARGS", BtShellWord)
 	reg.pkglist("CMAKE_DEPENDENCIES_REWRITE", BtWrksrcPathPattern)
 	reg.pkglist("CMAKE_MODULE_PATH_OVERRIDE", BtWrksrcPathPattern)
 	reg.pkg("CMAKE_PKGSRC_BUILD_FLAGS", BtYesNo)
@@ -1432,6 +1435,7 @@ func (reg *VarTypeRegistry) Init(src *Pkgsrc) {
 
 	reg.pkglist("MESSAGE_SRC", BtPathname)
 	reg.pkglist("MESSAGE_SUBST", BtShellWord)
+	reg.pkglist("MESON_ARGS", BtShellWord)
 	reg.pkgloadlist("MESON_REQD", BtVersion)
 	reg.pkg("META_PACKAGE", BtYes)
 	reg.syslist("MISSING_FEATURES", BtIdentifierDirect)
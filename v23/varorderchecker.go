@@ -180,6 +180,17 @@ func (*VarorderChecker) explain(mkline *MkLine) {
 		seeGuide("Package components, Makefile", "components.Makefile"))
 }
 
+// varorderIndex returns the index of the variable in varorderVariables,
+// or -1 if the variable is not part of the canonical order.
+func varorderIndex(varcanon string) int {
+	for i, v := range varorderVariables {
+		if v.canon == varcanon && varcanon != "" {
+			return i
+		}
+	}
+	return -1
+}
+
 type varorderRepetition uint8
 
 const (
@@ -769,3 +769,13 @@ func (s *Suite) Test_VarorderChecker_explain(c *check.C) {
 		"\thttps://www.NetBSD.org/docs/pkgsrc/pkgsrc.html#components.Makefile",
 		"")
 }
+
+func (s *Suite) Test_varorderIndex(c *check.C) {
+	t := s.Init(c)
+
+	t.CheckEquals(varorderIndex("DISTNAME"), 0)
+	t.CheckEquals(varorderIndex("PKGREVISION"), 4)
+	t.CheckEquals(varorderIndex("SITES.*"), 13)
+	t.CheckEquals(varorderIndex("UNRELATED"), -1)
+	t.CheckEquals(varorderIndex(""), -1)
+}
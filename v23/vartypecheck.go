@@ -1,6 +1,7 @@
 package pkglint
 
 import (
+	"github.com/rillig/pkglint/v23/ast"
 	"github.com/rillig/pkglint/v23/regex"
 	"github.com/rillig/pkglint/v23/textproc"
 	"path"
@@ -24,13 +25,24 @@ type VartypeCheck struct {
 	ValueNoVar string // The Value with all expressions removed.
 	MkComment  string // The comment including the "#".
 	Guessed    bool   // Whether the type definition is guessed (based on the variable name) or explicitly defined (see vardefs.go).
+
+	// The location of the value in the text of the line,
+	// or nil if the value does not appear literally in the line.
+	Span *Span
 }
 
-func (cv *VartypeCheck) Errorf(format string, args ...interface{}) { cv.MkLine.Errorf(format, args...) }
-func (cv *VartypeCheck) Warnf(format string, args ...interface{})  { cv.MkLine.Warnf(format, args...) }
-func (cv *VartypeCheck) Notef(format string, args ...interface{})  { cv.MkLine.Notef(format, args...) }
+func (cv *VartypeCheck) Errorf(format string, args ...interface{}) { cv.diag().Errorf(format, args...) }
+func (cv *VartypeCheck) Warnf(format string, args ...interface{})  { cv.diag().Warnf(format, args...) }
+func (cv *VartypeCheck) Notef(format string, args ...interface{})  { cv.diag().Notef(format, args...) }
 func (cv *VartypeCheck) Explain(explanation ...string)             { cv.MkLine.Explain(explanation...) }
 
+func (cv *VartypeCheck) diag() Diagnoser {
+	if cv.Span != nil {
+		return cv.MkLine.At(*cv.Span)
+	}
+	return cv.MkLine
+}
+
 // Autofix returns the autofix instance belonging to the line.
 //
 // Usage:
@@ -45,8 +57,8 @@ func (cv *VartypeCheck) Explain(explanation ...string)             { cv.MkLine.E
 //	    "Explanation ...",
 //	    "... end of explanation.")
 //
-//	fix.Replace("from", "to")
-//	fix.ReplaceAfter("prefix", "from", "to")
+//	cv.replace(fix, "from", "to")
+//	fix.Edit(editor)
 //	fix.InsertAbove("new line")
 //	fix.InsertBelow("new line")
 //	fix.Delete()
@@ -55,6 +67,18 @@ func (cv *VartypeCheck) Explain(explanation ...string)             { cv.MkLine.E
 //	fix.Apply()
 func (cv *VartypeCheck) Autofix() *Autofix { return cv.MkLine.Autofix() }
 
+// replace replaces the first occurrence of the text in the part of the
+// line that contains the checked values, which is either the value of a
+// variable assignment or the condition of a directive.
+func (cv *VartypeCheck) replace(fix *Autofix, from, to string) {
+	fix.ReplaceInTree(func(tree ast.MkLine) ast.Node {
+		if cond := treeCond(tree); cond != nil {
+			return cond
+		}
+		return treeValue(tree)
+	}, from, to)
+}
+
 // WithValue returns a new VartypeCheck context by copying all
 // fields except the value.
 //
@@ -352,6 +376,11 @@ func (cv *VartypeCheck) DependencyWithPath() {
 	}
 
 	cv.WithValue(pattern).PackagePattern()
+
+	if G.CheckGlobal {
+		ck := PackagePatternChecker{cv.Varname, cv.MkLine, cv.MkLines}
+		ck.CheckSatisfiable(pattern, parts[1])
+	}
 }
 
 func (cv *VartypeCheck) DistSuffix() {
@@ -502,7 +531,7 @@ func (cv *VartypeCheck) GccReqd() {
 			"",
 			"Starting with GCC 5, the major version is only the first number",
 			"such as 5, 7 or 15.")
-		fix.Replace(cv.Value, mainVersion)
+		cv.replace(fix, cv.Value, mainVersion)
 		fix.Apply()
 	} else {
 		cv.Errorf("GCC version numbers must have the format major[.minor[.patch]].")
@@ -809,6 +838,9 @@ func (cv *VartypeCheck) Option() {
 func (cv *VartypeCheck) PackagePattern() {
 	ck := PackagePatternChecker{cv.Varname, cv.MkLine, cv.MkLines}
 	ck.Check(cv.Value, cv.ValueNoVar)
+	if G.CheckGlobal {
+		ck.checkBuildlink3Satisfiable(cv.Value)
+	}
 }
 
 // Pathlist checks variables like the PATH environment variable.
@@ -916,7 +948,7 @@ func (cv *VartypeCheck) Perms() {
 		valueName := cv.Value[2 : len(cv.Value)-1]
 		fix := cv.Autofix()
 		fix.Errorf("%s must not be used in permission definitions. Use REAL_%[1]s instead.", valueName)
-		fix.Replace(valueName, "REAL_"+valueName)
+		cv.replace(fix, valueName, "REAL_"+valueName)
 		fix.Apply()
 	}
 }
@@ -1062,7 +1094,7 @@ func (cv *VartypeCheck) PrefixPathname() {
 		from := "${PKGMANDIR}/" + cv.Value[4:]
 		fix := cv.Autofix()
 		fix.Warnf("Use %q instead of %q.", from, cv.Value)
-		fix.Replace(cv.Value, from)
+		cv.replace(fix, cv.Value, from)
 		fix.Apply()
 	}
 }
@@ -1256,6 +1288,7 @@ func (cv *VartypeCheck) ShellCommands() {
 func (cv *VartypeCheck) ShellWord() {
 	ck := NewShellLineChecker(cv.MkLines, cv.MkLine)
 	ck.checkExpr = false
+	ck.span = cv.Span
 	ck.CheckWord(cv.Value, true, RunTime)
 }
 
@@ -1461,7 +1494,7 @@ func (cv *VartypeCheck) WrksrcPathPattern() {
 		fix.Explain(
 			"These pathname patters are interpreted relative to ${WRKSRC} by definition.",
 			"Therefore, that part can be left out.")
-		fix.Replace("${WRKSRC}/", "")
+		cv.replace(fix, "${WRKSRC}/", "")
 		fix.Apply()
 	}
 }
@@ -1478,7 +1511,7 @@ func (cv *VartypeCheck) WrksrcSubdirectory() {
 		fix.Notef("You can use %q instead of %q.", rest, cv.Value)
 		fix.Explain(
 			"These directories are interpreted relative to ${WRKSRC}.")
-		fix.Replace(cv.Value, rest)
+		cv.replace(fix, cv.Value, rest)
 		fix.Apply()
 
 	} else if cv.ValueNoVar == "" {
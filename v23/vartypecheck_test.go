@@ -64,6 +64,46 @@ func (s *Suite) Test_VartypeCheck_Autofix(c *check.C) {
 	t.CheckEquals(cv.Autofix(), mkline.Autofix())
 }
 
+func (s *Suite) Test_VartypeCheck_replace(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpCommandLine("-Wall", "--show-autofix")
+	test := func(text, from, to, fixed string, diagnostics ...string) {
+		mkline := t.NewMkLine("filename.mk", 123, text)
+		cv := VartypeCheck{MkLine: mkline}
+
+		fix := cv.Autofix()
+		fix.Warnf("Warning.")
+		cv.replace(fix, from, to)
+		fix.Apply()
+
+		t.CheckOutput(diagnostics)
+		t.CheckEquals(mkline.Text, fixed)
+	}
+
+	// The variable name and the comment are not part of the value.
+	test("VALUE=\tVALUE # VALUE",
+		"VALUE", "replaced",
+		"VALUE=\treplaced # VALUE",
+
+		"WARN: filename.mk:123: Warning.",
+		"AUTOFIX: filename.mk:123: Replacing \"VALUE\" with \"replaced\".")
+
+	test(".if ${value} == value",
+		"value", "replaced",
+		".if ${replaced} == value",
+
+		"WARN: filename.mk:123: Warning.",
+		"AUTOFIX: filename.mk:123: Replacing \"value\" with \"replaced\".")
+
+	// Neither the value of an assignment nor a condition.
+	test(".include \"value\"",
+		"value", "replaced",
+		".include \"value\"",
+
+		nil...)
+}
+
 func (s *Suite) Test_VartypeCheck_WithValue(c *check.C) {
 	t := s.Init(c)
 
@@ -2304,7 +2344,7 @@ func (s *Suite) Test_VartypeCheck_WrksrcPathPattern(c *check.C) {
 		"${WRKSRC}/relative/*.sh")
 
 	vt.Output(
-		"AUTOFIX: filename.mk:12: Replacing \"${WRKSRC}/\" with \"\".")
+		"AUTOFIX: filename.mk:12: Deleting \"${WRKSRC}/\".")
 
 	t.SetUpCommandLine("-Wall")
 
@@ -2555,7 +2595,7 @@ func (vt *VartypeCheckTester) Values(values ...string) {
 
 		for _, lineValue := range lineValues {
 			valueNovar := mkline.WithoutMakeVariables(lineValue)
-			vc := VartypeCheck{mklines, mkline, varname, vt.op, lineValue, valueNovar, comment, false}
+			vc := VartypeCheck{mklines, mkline, varname, vt.op, lineValue, valueNovar, comment, false, nil}
 			vt.basicType.checker(&vc)
 		}
 	}
@@ -0,0 +1,343 @@
+package pkglint
+
+import (
+	"github.com/rillig/pkglint/v23/regex"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// WrksrcChecker checks the package settings that refer to the files
+// from the extracted upstream sources, for --wrksrc.
+//
+// These settings are easily overlooked when the package is updated,
+// since they don't produce an error when they refer to files that
+// don't exist anymore.
+type WrksrcChecker struct {
+	pkg *Package
+	dir CurrPath
+
+	// The files and directories of the extracted sources,
+	// relative to the directory.
+	files []RelPath
+	dirs  map[RelPath]bool
+}
+
+func NewWrksrcChecker(pkg *Package, dir CurrPath) *WrksrcChecker {
+	ck := WrksrcChecker{pkg, dir, nil, map[RelPath]bool{".": true}}
+	ck.scan(dir, ".")
+	sort.Slice(ck.files, func(i, j int) bool { return ck.files[i] < ck.files[j] })
+	return &ck
+}
+
+func (ck *WrksrcChecker) Check(allLines *MkLines) {
+	ck.checkWrksrc()
+
+	allLines.ForEach(func(mkline *MkLine) {
+		if !mkline.IsVarassign() || G.Pkgsrc.IsInfra(mkline.Filename()) ||
+			mkline.Basename == "buildlink3.mk" || mkline.Basename == "builtin.mk" {
+			return
+		}
+
+		switch mkline.Varcanon() {
+		case "SUBST_FILES.*":
+			if ck.isBeforeConfigure(mkline.Varparam()) {
+				ck.checkFiles(mkline)
+			}
+		case "REPLACE_AWK", "REPLACE_BASH", "REPLACE_CSH", "REPLACE_KSH",
+			"REPLACE_PERL", "REPLACE_PERL6", "REPLACE_PYTHON", "REPLACE_SH":
+			ck.checkFiles(mkline)
+		case "CONFIGURE_DIRS", "BUILD_DIRS":
+			ck.checkDirs(mkline)
+		}
+	})
+
+	ck.checkConfigureScript()
+	ck.checkLanguages()
+	ck.checkPortability()
+}
+
+// checkWrksrc checks that the WRKSRC of the package refers to the
+// directory with the extracted sources.
+func (ck *WrksrcChecker) checkWrksrc() {
+	vars := ck.pkg.vars
+	value := "${WRKDIR}/${DISTNAME}"
+	mkline := vars.LastDefinition("WRKSRC")
+	if mkline != nil {
+		value = mkline.Value()
+	} else if mkline = vars.LastDefinition("DISTNAME"); mkline == nil {
+		return
+	}
+
+	resolved := resolveExprs(value, nil, ck.pkg)
+	if !hasPrefix(resolved, "${WRKDIR}/") {
+		return
+	}
+	rel := NewPath(strings.TrimPrefix(resolved, "${WRKDIR}/")).Clean()
+	if containsExpr(rel.String()) || G.Abs(ck.dir).HasSuffixPath(rel) {
+		return
+	}
+
+	mkline.Warnf("The WRKSRC %q does not match the extracted sources in %q.",
+		"${WRKDIR}/"+rel.String(), ck.dir.String())
+	mkline.Explain(
+		"After updating a package, the name of the directory in which",
+		"the sources are extracted often changes.",
+		"In such a case, the WRKSRC needs to be adjusted.")
+}
+
+// checkFiles checks that each pattern from the variable matches at
+// least one file from the extracted sources.
+func (ck *WrksrcChecker) checkFiles(mkline *MkLine) {
+	for _, pattern := range ck.fields(mkline) {
+		if ck.exists(pattern) {
+			continue
+		}
+
+		mkline.Warnf("The pattern %q does not match any file in the extracted sources.", pattern)
+		mkline.Explain(
+			"The files from the upstream sources often change between releases.",
+			"Patterns that don't match any file anymore are either stale",
+			"and can be removed, or they need to be adjusted to the new names.",
+			"",
+			"The patterns are relative to WRKSRC.")
+	}
+}
+
+func (ck *WrksrcChecker) checkDirs(mkline *MkLine) {
+	for _, dir := range ck.fields(mkline) {
+		if !ck.dirs[NewRelPathString(dir).Clean()] {
+			mkline.Warnf("The directory %q does not exist in the extracted sources.", dir)
+		}
+	}
+}
+
+// checkConfigureScript checks that each of the CONFIGURE_DIRS
+// contains the CONFIGURE_SCRIPT, unless the configure script is
+// generated by the GNU autotools.
+func (ck *WrksrcChecker) checkConfigureScript() {
+	vars := ck.pkg.vars
+	mkline := vars.LastDefinition("CONFIGURE_SCRIPT")
+	if mkline == nil {
+		mkline = vars.LastDefinition("GNU_CONFIGURE")
+	}
+	if mkline == nil {
+		mkline = vars.LastDefinition("HAS_CONFIGURE")
+	}
+	if mkline == nil || vars.IsDefined("NO_CONFIGURE") {
+		return
+	}
+
+	for _, tool := range strings.Fields(vars.LastValue("USE_TOOLS")) {
+		switch strings.SplitN(tool, ":", 2)[0] {
+		case "autoconf", "autoconf213", "autoreconf", "automake":
+			return
+		}
+	}
+
+	script := "./configure"
+	if def := vars.LastDefinition("CONFIGURE_SCRIPT"); def != nil {
+		script = def.Value()
+	}
+	script = resolveExprs(script, nil, ck.pkg)
+	inWrksrc := hasPrefix(script, "${WRKSRC}/")
+	script = ck.relative(script)
+	if script == "" {
+		return
+	}
+
+	dirs := []string{"."}
+	if def := vars.LastDefinition("CONFIGURE_DIRS"); def != nil {
+		dirs = ck.fields(def)
+	}
+
+	for _, dir := range dirs {
+		if !ck.dirs[NewRelPathString(dir).Clean()] {
+			continue // Already reported by checkDirs.
+		}
+		file := NewRelPathString(script).Clean()
+		if !inWrksrc {
+			file = NewRelPathString(dir).JoinNoClean(file).Clean()
+		}
+		if !ck.exists(file.String()) {
+			mkline.Warnf("The configure script %q does not exist in the extracted sources.",
+				file.String())
+		}
+	}
+}
+
+// checkLanguages checks that USE_LANGUAGES includes the languages of
+// the source files.
+func (ck *WrksrcChecker) checkLanguages() {
+	vars := ck.pkg.vars
+	if vars.IsDefined("NO_BUILD") || vars.IsDefined("META_PACKAGE") {
+		return
+	}
+
+	values := []string{"c"}
+	var line *Line
+	if mkline := vars.LastDefinition("USE_LANGUAGES"); mkline != nil {
+		values = strings.Fields(resolveExprs(vars.LastValue("USE_LANGUAGES"), nil, ck.pkg))
+		line = mkline.Line
+	} else {
+		line = NewLineWhole(ck.pkg.File("Makefile"))
+	}
+
+	for _, language := range wrksrcLanguages {
+		example := ck.findLanguage(language.extensions)
+		if example == "" || wrksrcUsesLanguage(values, language.values) {
+			continue
+		}
+
+		line.Warnf("The sources contain %s files such as %q, but USE_LANGUAGES doesn't include %s.",
+			language.name, example.String(), language.value)
+		line.Explain(
+			"The compilers for the languages that are not listed in",
+			"USE_LANGUAGES are not available when building the package.")
+	}
+}
+
+// checkPortability checks the shell scripts from the extracted sources,
+// except for those that match CHECK_PORTABILITY_SKIP.
+func (ck *WrksrcChecker) checkPortability() {
+	var skip []string
+	if mkline := ck.pkg.vars.LastDefinition("CHECK_PORTABILITY_SKIP"); mkline != nil {
+		skip = ck.fields(mkline)
+	}
+
+	portability := NewPortabilityChecker(false)
+	for _, file := range ck.files {
+		if ck.matchesAny(skip, file) {
+			continue
+		}
+
+		filename := ck.dir.JoinNoClean(file)
+		if f, err := filename.Open(); err == nil {
+			magic := make([]byte, 2)
+			_, err = io.ReadFull(f, magic)
+			_ = f.Close()
+			if err != nil || string(magic) != "#!" {
+				continue
+			}
+		}
+
+		lines := Load(filename, 0)
+		if lines == nil || !isPortableShellScript(lines) {
+			continue
+		}
+		for _, line := range lines.Lines {
+			portability.CheckLine(line, 0)
+		}
+	}
+}
+
+// findLanguage returns the first source file that has one of the
+// extensions, or an empty path.
+func (ck *WrksrcChecker) findLanguage(extensions []string) RelPath {
+	for _, file := range ck.files {
+		if containsStr(extensions, path.Ext(file.String())) {
+			return file
+		}
+	}
+	return ""
+}
+
+// isBeforeConfigure returns whether the SUBST class is applied before
+// the configure stage, since only in that case its files come from
+// the extracted sources instead of being generated during the build.
+func (ck *WrksrcChecker) isBeforeConfigure(id string) bool {
+	switch ck.pkg.vars.LastValue("SUBST_STAGE." + id) {
+	case "post-extract", "pre-patch", "post-patch", "pre-configure":
+		return true
+	}
+	return false
+}
+
+// fields returns the fields from the variable value, relative to WRKSRC.
+// The fields that cannot be resolved are skipped.
+func (ck *WrksrcChecker) fields(mkline *MkLine) []string {
+	var fields []string
+	for _, field := range mkline.ValueFields(resolveExprs(mkline.Value(), nil, ck.pkg)) {
+		if rel := ck.relative(field); rel != "" {
+			fields = append(fields, rel)
+		}
+	}
+	return fields
+}
+
+// relative returns the path relative to WRKSRC, or an empty string if
+// the path cannot be determined or is outside WRKSRC.
+func (ck *WrksrcChecker) relative(field string) string {
+	rel := field
+	if rel == "${WRKSRC}" {
+		rel = "."
+	}
+	rel = strings.TrimPrefix(rel, "${WRKSRC}/")
+	if containsExpr(rel) || NewPath(rel).IsAbs() || NewPath(rel).HasPrefixPath("..") {
+		return ""
+	}
+	return rel
+}
+
+func (ck *WrksrcChecker) matchesAny(patterns []string, file RelPath) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(NewPath(pattern).Clean().String(), file.String()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// exists returns whether the shell pattern matches at least one file
+// from the extracted sources.
+func (ck *WrksrcChecker) exists(pattern string) bool {
+	clean := NewPath(pattern).Clean().String()
+	for _, file := range ck.files {
+		if ok, _ := path.Match(clean, file.String()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (ck *WrksrcChecker) scan(dir CurrPath, rel RelPath) {
+	entries, err := dir.ReadDir()
+	if err != nil {
+		G.Logger.TechFatalf(dir, "Cannot read directory: %s", err)
+	}
+
+	for _, entry := range entries {
+		name := NewRelPathString(entry.Name())
+		entryRel := rel.JoinNoClean(name).CleanDot()
+		if entry.IsDir() {
+			ck.dirs[entryRel] = true
+			ck.scan(dir.JoinNoClean(name), entryRel)
+		} else {
+			ck.files = append(ck.files, entryRel)
+		}
+	}
+}
+
+// wrksrcLanguages lists the compiled languages, the file extensions of
+// their source files and the USE_LANGUAGES values that provide the
+// compiler for them.
+var wrksrcLanguages = []struct {
+	name       string
+	extensions []string
+	value      string
+	values     regex.Pattern
+}{
+	{"C", []string{".c"}, "c", `^(c|c\d+|gnu\d+)$`},
+	{"C++", []string{".cc", ".cpp", ".cxx", ".c++", ".C"}, "c++", `^(c|gnu)\+\+(\d+)?$`},
+	{"Fortran", []string{".f", ".f77", ".f90", ".f95", ".F", ".F90"}, "fortran", `^fortran(77)?$`},
+}
+
+func wrksrcUsesLanguage(values []string, pattern regex.Pattern) bool {
+	for _, value := range values {
+		if matches(value, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,400 @@
+package pkglint
+
+import "gopkg.in/check.v1"
+
+func (s *Suite) Test_NewWrksrcChecker(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"configure",
+		"src/main.c",
+		"src/util.c",
+		"doc/")
+	t.Chdir(".")
+
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	t.CheckDeepEquals(ck.files, []RelPath{"configure", "src/main.c", "src/util.c"})
+	t.CheckDeepEquals(ck.dirs, map[RelPath]bool{".": true, "doc": true, "src": true})
+}
+
+func (s *Suite) Test_WrksrcChecker_Check(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"GNU_CONFIGURE=\tyes",
+		"USE_LANGUAGES=\tc c++",
+		"REPLACE_PERL=\tscripts/*.pl",
+		"REPLACE_PYTHON=\tbin/stale.py",
+		"BUILD_DIRS=\tsrc lib",
+		"",
+		"SUBST_CLASSES+=\t\tpaths",
+		"SUBST_STAGE.paths=\tpre-configure",
+		"SUBST_MESSAGE.paths=\tFixing paths.",
+		"SUBST_FILES.paths=\tsrc/*.c src/config.h",
+		"SUBST_SED.paths=\t-e 's,/usr/local,${PREFIX},'")
+	t.CreateFileTree("work/package-1.0",
+		"configure",
+		"scripts/tool.pl",
+		"src/main.c",
+		"src/util.cpp")
+	t.Chdir(".")
+
+	t.Main("--wrksrc=work/package-1.0", "-q", "-Wall", "category/package")
+
+	t.CheckOutputLines(
+		"WARN: category/package/Makefile:23: "+
+			"The pattern \"bin/stale.py\" does not match any file in the extracted sources.",
+		"WARN: category/package/Makefile:24: "+
+			"The directory \"lib\" does not exist in the extracted sources.",
+		"WARN: category/package/Makefile:29: "+
+			"The pattern \"src/config.h\" does not match any file in the extracted sources.")
+}
+
+func (s *Suite) Test_WrksrcChecker_checkWrksrc(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0")
+	t.Chdir(".")
+	t.CreateFileTree("work/sub/dir")
+	t.FinishSetUp()
+
+	test := func(wrksrc string, dir CurrPath, diagnostics ...string) {
+		pkg := NewPackage("category/package")
+		pkg.load()
+		if wrksrc != "" {
+			mklines := t.NewMkLines("Makefile", "WRKSRC=\t"+wrksrc)
+			pkg.vars.Define("WRKSRC", mklines.mklines[0])
+		}
+
+		NewWrksrcChecker(pkg, dir).checkWrksrc()
+
+		t.CheckOutput(diagnostics)
+	}
+
+	// The default WRKSRC is derived from DISTNAME.
+	test("", "work/package-1.0",
+		nil...)
+	test("", "work/sub/dir",
+		"WARN: category/package/Makefile:3: The WRKSRC \"${WRKDIR}/package-1.0\" "+
+			"does not match the extracted sources in \"work/sub/dir\".")
+	test("${WRKDIR}/sub/dir", "work/sub/dir",
+		nil...)
+	test("${WRKDIR}/${DISTNAME:S,-,/,}", "work/sub/dir",
+		nil...)
+	test("${WRKDIR}", "work/sub/dir",
+		nil...)
+	test("${WRKDIR}/other", "work/sub/dir",
+		"WARN: Makefile:1: The WRKSRC \"${WRKDIR}/other\" "+
+			"does not match the extracted sources in \"work/sub/dir\".")
+}
+
+func (s *Suite) Test_WrksrcChecker_checkFiles(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"src/main.c",
+		"scripts/tool.pl")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+	mklines := t.NewMkLines("filename.mk",
+		"REPLACE_PERL=\tscripts/*.pl ${WRKSRC}/scripts/tool.pl",
+		"REPLACE_PERL=\tscripts/*.py ${UNKNOWN}/*.pl ../other/*.pl",
+		"REPLACE_PERL=\tsrc")
+
+	mklines.ForEach(ck.checkFiles)
+
+	t.CheckOutputLines(
+		"WARN: filename.mk:2: The pattern \"scripts/*.py\" "+
+			"does not match any file in the extracted sources.",
+		"WARN: filename.mk:3: The pattern \"src\" "+
+			"does not match any file in the extracted sources.")
+}
+
+func (s *Suite) Test_WrksrcChecker_checkDirs(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"src/main.c",
+		"empty/")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+	mklines := t.NewMkLines("filename.mk",
+		"BUILD_DIRS=\t. src ${WRKSRC}/empty ${WRKSRC} src/",
+		"BUILD_DIRS=\tmissing src/main.c")
+
+	mklines.ForEach(ck.checkDirs)
+
+	t.CheckOutputLines(
+		"WARN: filename.mk:2: The directory \"missing\" does not exist in the extracted sources.",
+		"WARN: filename.mk:2: The directory \"src/main.c\" does not exist in the extracted sources.")
+}
+
+func (s *Suite) Test_WrksrcChecker_checkConfigureScript(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"configure",
+		"sub/configure.sh",
+		"other/")
+	t.Chdir(".")
+	t.FinishSetUp()
+
+	test := func(lines []string, diagnostics ...string) {
+		pkg := NewPackage("category/package")
+		pkg.load()
+		mklines := t.NewMkLines("Makefile", lines...)
+		mklines.ForEach(func(mkline *MkLine) { pkg.vars.Define(mkline.Varname(), mkline) })
+
+		NewWrksrcChecker(pkg, "work/package-1.0").checkConfigureScript()
+
+		t.CheckOutput(diagnostics)
+	}
+
+	test([]string{"GNU_CONFIGURE=\tyes"},
+		nil...)
+	test([]string{
+		"GNU_CONFIGURE=\tyes",
+		"CONFIGURE_DIRS=\t. other missing"},
+		"WARN: Makefile:1: The configure script \"other/configure\" "+
+			"does not exist in the extracted sources.")
+	test([]string{
+		"HAS_CONFIGURE=\tyes",
+		"CONFIGURE_SCRIPT=\tconfigure.sh",
+		"CONFIGURE_DIRS=\tsub"},
+		nil...)
+	test([]string{
+		"CONFIGURE_SCRIPT=\t${WRKSRC}/sub/configure.sh",
+		"CONFIGURE_DIRS=\t. other"},
+		nil...)
+	test([]string{
+		"CONFIGURE_SCRIPT=\t${WRKSRC}/configure.sh"},
+		"WARN: Makefile:1: The configure script \"configure.sh\" "+
+			"does not exist in the extracted sources.")
+
+	// The configure script is generated.
+	test([]string{
+		"GNU_CONFIGURE=\tyes",
+		"CONFIGURE_DIRS=\tother",
+		"USE_TOOLS+=\tautoreconf:pkgsrc"},
+		nil...)
+	test([]string{
+		"GNU_CONFIGURE=\tyes",
+		"CONFIGURE_SCRIPT=\t${UNKNOWN}/configure"},
+		nil...)
+	test([]string{
+		"GNU_CONFIGURE=\tyes",
+		"CONFIGURE_DIRS=\tother",
+		"NO_CONFIGURE=\tyes"},
+		nil...)
+	test(nil,
+		nil...)
+}
+
+func (s *Suite) Test_WrksrcChecker_checkLanguages(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"main.c",
+		"lib/util.cpp",
+		"lib/blas.f90")
+	t.Chdir(".")
+	t.FinishSetUp()
+
+	test := func(lines []string, diagnostics ...string) {
+		pkg := NewPackage("category/package")
+		pkg.load()
+		mklines := t.NewMkLines("Makefile", lines...)
+		mklines.ForEach(func(mkline *MkLine) { pkg.vars.Define(mkline.Varname(), mkline) })
+
+		NewWrksrcChecker(pkg, "work/package-1.0").checkLanguages()
+
+		t.CheckOutput(diagnostics)
+	}
+
+	test([]string{"USE_LANGUAGES=\tc99 gnu++14 fortran77"},
+		nil...)
+	test([]string{"USE_LANGUAGES=\tc++"},
+		"WARN: Makefile:1: The sources contain C files such as \"main.c\", "+
+			"but USE_LANGUAGES doesn't include c.",
+		"WARN: Makefile:1: The sources contain Fortran files such as \"lib/blas.f90\", "+
+			"but USE_LANGUAGES doesn't include fortran.")
+	test(nil,
+		"WARN: category/package/Makefile: The sources contain C++ files such as \"lib/util.cpp\", "+
+			"but USE_LANGUAGES doesn't include c++.",
+		"WARN: category/package/Makefile: The sources contain Fortran files such as \"lib/blas.f90\", "+
+			"but USE_LANGUAGES doesn't include fortran.")
+	test([]string{
+		"USE_LANGUAGES=\t# none",
+		"NO_BUILD=\tyes"},
+		nil...)
+}
+
+func (s *Suite) Test_WrksrcChecker_checkPortability(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"CHECK_PORTABILITY_SKIP=	debian/*")
+	t.CreateFileTree("work/package-1.0")
+	t.Chdir(".")
+	t.CreateFileLines("work/package-1.0/configure",
+		"#! /bin/sh",
+		"test \"$enable_x\" == yes && echo $RANDOM")
+	t.CreateFileLines("work/package-1.0/debian/rules.sh",
+		"#! /bin/sh",
+		"test \"$1\" == clean")
+	t.CreateFileLines("work/package-1.0/build.bash",
+		"#! /bin/bash",
+		"[[ $1 == clean ]]")
+	t.CreateFileLines("work/package-1.0/README",
+		"test a == b")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	ck.checkPortability()
+
+	// Since the extracted sources are not part of pkgsrc,
+	// the "==" operator is not fixed automatically.
+	t.CheckOutputLines(
+		"ERROR: work/package-1.0/configure:2: "+
+			"The \"==\" operator in test expressions is not portable.",
+		"WARN: work/package-1.0/configure:2: The variable $RANDOM is not portable.")
+}
+
+func (s *Suite) Test_WrksrcChecker_findLanguage(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"README.C",
+		"src/a.cc",
+		"src/b.cpp")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	t.CheckEquals(ck.findLanguage([]string{".cc", ".cpp"}), NewRelPathString("src/a.cc"))
+	t.CheckEquals(ck.findLanguage([]string{".C"}), NewRelPathString("README.C"))
+	t.CheckEquals(ck.findLanguage([]string{".f"}), NewRelPathString(""))
+}
+
+func (s *Suite) Test_WrksrcChecker_isBeforeConfigure(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"SUBST_STAGE.early=\tpost-patch",
+		"SUBST_STAGE.late=\tpre-build")
+	t.CreateFileTree("work/package-1.0")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	t.CheckEquals(ck.isBeforeConfigure("early"), true)
+	t.CheckEquals(ck.isBeforeConfigure("late"), false)
+	t.CheckEquals(ck.isBeforeConfigure("undefined"), false)
+}
+
+func (s *Suite) Test_WrksrcChecker_fields(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package",
+		"SRCDIR=\tsrc")
+	t.CreateFileTree("work/package-1.0")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+	mklines := t.NewMkLines("filename.mk",
+		"BUILD_DIRS=\t${SRCDIR} ${WRKSRC}/lib ${UNKNOWN} /abs ../outside \"quoted dir\"")
+
+	t.CheckDeepEquals(ck.fields(mklines.mklines[0]),
+		[]string{"src", "lib", "\"quoted dir\""})
+}
+
+func (s *Suite) Test_WrksrcChecker_relative(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	t.CheckEquals(ck.relative("src"), "src")
+	t.CheckEquals(ck.relative("${WRKSRC}"), ".")
+	t.CheckEquals(ck.relative("${WRKSRC}/src"), "src")
+	t.CheckEquals(ck.relative("${WRKDIR}/src"), "")
+	t.CheckEquals(ck.relative("/usr/src"), "")
+	t.CheckEquals(ck.relative("../src"), "")
+}
+
+func (s *Suite) Test_WrksrcChecker_matchesAny(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	t.CheckEquals(ck.matchesAny([]string{"doc/*", "./configure"}, "configure"), true)
+	t.CheckEquals(ck.matchesAny([]string{"doc/*"}, "doc/build.sh"), true)
+	t.CheckEquals(ck.matchesAny([]string{"doc/*"}, "doc/sub/build.sh"), false)
+	t.CheckEquals(ck.matchesAny(nil, "configure"), false)
+}
+
+func (s *Suite) Test_WrksrcChecker_exists(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"src/main.c",
+		"src/sub/util.c")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	t.CheckEquals(ck.exists("src/main.c"), true)
+	t.CheckEquals(ck.exists("./src/main.c"), true)
+	t.CheckEquals(ck.exists("src/*.c"), true)
+	t.CheckEquals(ck.exists("src/*/*.[ch]"), true)
+	t.CheckEquals(ck.exists("*.c"), false)
+	t.CheckEquals(ck.exists("src"), false)
+	t.CheckEquals(ck.exists("src/[unclosed"), false)
+}
+
+func (s *Suite) Test_WrksrcChecker_scan(c *check.C) {
+	t := s.Init(c)
+
+	t.SetUpPackage("category/package")
+	t.CreateFileTree("work/package-1.0",
+		"b/file",
+		"a/file",
+		"empty/")
+	t.Chdir(".")
+	pkg, _ := t.LoadPackage("category/package")
+	ck := NewWrksrcChecker(pkg, "work/package-1.0")
+
+	t.CheckDeepEquals(ck.files, []RelPath{"a/file", "b/file"})
+	t.CheckDeepEquals(ck.dirs, map[RelPath]bool{".": true, "a": true, "b": true, "empty": true})
+}
+
+func (s *Suite) Test_wrksrcUsesLanguage(c *check.C) {
+	t := s.Init(c)
+
+	cxx := wrksrcLanguages[1].values
+
+	t.CheckEquals(wrksrcUsesLanguage([]string{"c", "c++"}, cxx), true)
+	t.CheckEquals(wrksrcUsesLanguage([]string{"c++11"}, cxx), true)
+	t.CheckEquals(wrksrcUsesLanguage([]string{"gnu++17"}, cxx), true)
+	t.CheckEquals(wrksrcUsesLanguage([]string{"c", "c99"}, cxx), false)
+	t.CheckEquals(wrksrcUsesLanguage(nil, cxx), false)
+}